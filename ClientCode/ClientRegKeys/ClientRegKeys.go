@@ -21,10 +21,15 @@
 
 package ClientRegKeys
 
-import "Registry/Registry"
+import (
+	"Registry/Registry"
+	"ULComm/ULComm"
+)
 
-// Type: int64
+// Type: []string (the names of the modules, in the order of their numbers)
 const K_MODULES_ACTIVE string = "MODULES_ACTIVE"
+// Type: string (JSON of a map of the module numbers to their MOD_1.ModCrashHistory)
+const K_MODULES_CRASH_HISTORY string = "MODULES_CRASH_HISTORY"
 
 // Type: string
 const K_LAST_SPEECH string = "LAST_SPEECH"
@@ -32,9 +37,9 @@ const K_LAST_SPEECH string = "LAST_SPEECH"
 // Type: bool
 const K_SHOW_APP_SIG string = "SHOW_APP_SIG"
 
-// Type: int
+// Type: int (synchronized to the server)
 const K_BATTERY_LEVEL string = "BATTERY_LEVEL"
-// Type: bool
+// Type: bool (synchronized to the server)
 const K_POWER_CONNECTED string = "POWER_CONNECTED"
 // Type: int
 const K_SCREEN_BRIGHTNESS string = "SCREEN_BRIGHTNESS"
@@ -43,19 +48,39 @@ const K_SOUND_VOLUME string = "SOUND_VOLUME"
 // Type: bool
 const K_SOUND_MUTED string = "SOUND_MUTED"
 
+// Type: ULComm.UserLocation (synchronized from the server)
+const K_USER_LOCATION string = "USER_LOCATION"
+
 /*
 RegisterValues registers the client values in the registry.
  */
 func RegisterValues() {
-	Registry.RegisterValue(K_MODULES_ACTIVE, "Modules active", "The modules that are active (in binary)", Registry.TYPE_LONG)
+	Registry.RegisterValue(K_MODULES_ACTIVE, "Modules active", "The modules that are active",
+		Registry.TYPE_STRING_LIST, false)
+	Registry.RegisterValue(K_MODULES_CRASH_HISTORY, "Modules crash history", "The crash history of the modules",
+		Registry.TYPE_STRING, false)
+
+	Registry.RegisterValue(K_LAST_SPEECH, "Last speech", "The last speech that was spoken", Registry.TYPE_STRING, true)
+
+	// Not persistent, or the app would be shown again on each start
+	Registry.RegisterValue(K_SHOW_APP_SIG, "Show-app signal", "Signal to show the app", Registry.TYPE_BOOL, false)
+
+	Registry.RegisterValue(K_BATTERY_LEVEL, "Battery level", "The battery level", Registry.TYPE_INT, true)
+	Registry.RegisterValue(K_POWER_CONNECTED, "Power connected", "Whether the power is connected", Registry.TYPE_BOOL,
+		true)
+	Registry.RegisterValue(K_SCREEN_BRIGHTNESS, "Screen brightness", "The screen brightness", Registry.TYPE_INT, true)
+	Registry.RegisterValue(K_SOUND_VOLUME, "Sound volume", "The sound volume", Registry.TYPE_INT, true)
+	Registry.RegisterValue(K_SOUND_MUTED, "Sound muted", "Whether the sound is muted", Registry.TYPE_BOOL, true)
 
-	Registry.RegisterValue(K_LAST_SPEECH, "Last speech", "The last speech that was spoken", Registry.TYPE_STRING)
+	Registry.RegisterJsonValue[ULComm.UserLocation](K_USER_LOCATION, "User location",
+		"The location of the user, computed by the server's User Locator", false).EnableSync(Registry.SYNC_TO_CLIENTS)
 
-	Registry.RegisterValue(K_SHOW_APP_SIG, "Show-app signal", "Signal to show the app", Registry.TYPE_BOOL)
+	// The server keeps these for each device (the Modules Manager sends them with Registry.SyncWithServer())
+	Registry.GetValue(K_BATTERY_LEVEL).EnableSync(Registry.SYNC_TO_SERVER)
+	Registry.GetValue(K_POWER_CONNECTED).EnableSync(Registry.SYNC_TO_SERVER)
 
-	Registry.RegisterValue(K_BATTERY_LEVEL, "Battery level", "The battery level", Registry.TYPE_INT)
-	Registry.RegisterValue(K_POWER_CONNECTED, "Power connected", "Whether the power is connected", Registry.TYPE_BOOL)
-	Registry.RegisterValue(K_SCREEN_BRIGHTNESS, "Screen brightness", "The screen brightness", Registry.TYPE_INT)
-	Registry.RegisterValue(K_SOUND_VOLUME, "Sound volume", "The sound volume", Registry.TYPE_INT)
-	Registry.RegisterValue(K_SOUND_MUTED, "Sound muted", "Whether the sound is muted", Registry.TYPE_BOOL)
+	// Keep a day of the device state, to know how it changed (like for the Reminders Reminder's conditions)
+	for _, key := range []string{K_BATTERY_LEVEL, K_POWER_CONNECTED, K_SCREEN_BRIGHTNESS, K_SOUND_VOLUME, K_SOUND_MUTED} {
+		Registry.GetValue(key).EnableHistory(1000, 24*60*60, true)
+	}
 }
@@ -0,0 +1,135 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package main
+
+import (
+	MOD_1 "ModManager"
+	"Registry/Registry"
+	"Utils"
+	"VISOR_Client/ClientRegKeys"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// _NOTIF_APP_NAME is the name of the application on the desktop notifications.
+const _NOTIF_APP_NAME string = "V.I.S.O.R."
+
+/*
+runHeadless runs the client version without the window and the tray icon (for desktops and servers without OpenGL),
+until VISOR is stopped.
+
+The notifications go to the desktop notifications (through D-Bus) or to the standard output if there are none, and the
+show-app signal is only logged.
+
+-----------------------------------------------------------
+
+– Params:
+  - module_stop – the stop signal of VISOR
+*/
+func runHeadless(module_stop Utils.StopSignal) {
+	moduleInfo_GL.Log.Info("Running in headless mode")
+
+	ClientRegKeys.RegisterValues()
+
+	// The Manager needs to be started first. It'll handle the others.
+	MOD_1.Start(modules_GL)
+
+	processNotifications(sendNotificationHeadless)
+
+	// Stop all the modules and then VISOR on Ctrl+C or SIGTERM
+	Utils.HandleStopSignalsMODULES(func() {
+		// Stop the modules in order first, and only then VISOR itself
+		_ = Utils.ShutdownMODULES(modules_GL)
+		modules_GL[Utils.NUM_MOD_VISOR].TransitionState(Utils.MOD_STATE_STOPPING)
+	})
+
+	var show_app_sig *Registry.Value = Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG)
+	var show_app_ch chan Registry.ValueChange = show_app_sig.Watch()
+	go func() {
+		for change := range show_app_ch {
+			if change.New_data.(bool) {
+				moduleInfo_GL.Log.Info("Show-app signal received - there's no window to show in headless mode")
+				show_app_sig.SetData(false, false)
+			}
+		}
+	}()
+
+	for {
+		if Utils.WaitWithStopTIMEDATE(module_stop, 1) {
+			break
+		}
+	}
+	Registry.UnwatchValues(show_app_ch)
+
+	exit_code_GL = Utils.ShutdownMODULES(modules_GL)
+}
+
+/*
+sendNotificationHeadless shows a notification through the freedesktop notifications D-Bus API or, if it's not
+available, prints it to the standard output.
+
+-----------------------------------------------------------
+
+– Params:
+  - title – the title of the notification
+  - text – the text of the notification
+*/
+func sendNotificationHeadless(title string, text string) {
+	if err := sendNotificationDBUS(title, text); nil != err {
+		moduleInfo_GL.Log.Debug("Desktop notifications not available - printing the notification", "error", err)
+
+		fmt.Println("[Notification] " + title + ": " + text)
+	}
+}
+
+/*
+sendNotificationDBUS shows a notification through the freedesktop notifications D-Bus API
+(org.freedesktop.Notifications) of the user session.
+
+-----------------------------------------------------------
+
+– Params:
+  - title – the title of the notification
+  - text – the text of the notification
+
+– Returns:
+  - nil if the notification was shown, an error otherwise (like if there's no session bus or notifications server)
+*/
+func sendNotificationDBUS(title string, text string) error {
+	conn, err := dbus.SessionBus()
+	if nil != err {
+		return err
+	}
+
+	return conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications").Call(
+		"org.freedesktop.Notifications.Notify", 0,
+		_NOTIF_APP_NAME,           // app_name
+		uint32(0),                 // replaces_id
+		"",                        // app_icon
+		title,                     // summary
+		text,                      // body
+		[]string{},                // actions
+		map[string]dbus.Variant{}, // hints
+		int32(-1),                 // expire_timeout (the server's default)
+	).Err
+}
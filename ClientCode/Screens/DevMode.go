@@ -25,6 +25,7 @@ import (
 	MOD_3 "Speech"
 	"SpeechQueue/SpeechQueue"
 	"Utils"
+	"context"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
@@ -53,7 +54,7 @@ func DevMode() fyne.CanvasObject {
 			{Text: "Text3", Widget: form_text3},
 		},
 		OnSubmit: func() {
-			Utils.SubmitFormWEBSITE(Utils.WebsiteForm{
+			Utils.SubmitFormWEBSITE(context.Background(), Utils.WebsiteForm{
 				Type:  form_type_.Text,
 				Text1: form_text1.Text,
 				Text2: form_text2.Text,
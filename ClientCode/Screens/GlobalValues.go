@@ -30,10 +30,14 @@ import (
 )
 
 var global_values_canvas_object_GL fyne.CanvasObject = nil
+var registry_text_GL *widget.Label = nil
 
 func GlobalValues() fyne.CanvasObject {
 	Current_screen_GL = global_values_canvas_object_GL
 	if global_values_canvas_object_GL != nil {
+		// The values may have changed while the screen wasn't shown
+		registry_text_GL.SetText(Registry.GetRegistryText())
+
 		return global_values_canvas_object_GL
 	}
 
@@ -47,14 +51,21 @@ func GlobalValues() fyne.CanvasObject {
 	registry_text.Wrapping = fyne.TextWrapWord // Enable text wrapping
 	var scroll_text *container.Scroll = container.NewVScroll(registry_text)
 	scroll_text.SetMinSize(screens_size_GL) // Set the minimum size for the scroll container
+	registry_text_GL = registry_text
 
+	// Re-render only when values change, and at most once per second with all the changes in the meantime
+	registry_text.SetText(Registry.GetRegistryText())
+	var registry_ch chan Registry.ValueChange = Registry.WatchValues("*")
 	go func() {
-		for {
+		for range registry_ch {
+			time.Sleep(1 * time.Second)
+			for len(registry_ch) > 0 {
+				<-registry_ch
+			}
+
 			if Current_screen_GL == global_values_canvas_object_GL {
 				registry_text.SetText(Registry.GetRegistryText())
 			}
-
-			time.Sleep(1 * time.Second)
 		}
 	}()
 
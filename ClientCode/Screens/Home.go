@@ -34,6 +34,32 @@ var Current_screen_GL fyne.CanvasObject = nil
 
 var screens_size_GL fyne.Size = fyne.NewSize(550, 480)
 
+/*
+runOnUIThread runs a function on the thread where fyne handles the user input (the event queue of the window), so that
+changes made to the widgets from other goroutines don't race with the user's clicks.
+
+This version of fyne has no fyne.Do(), so the event queue of the window is used directly if the driver has one -
+otherwise the function is run right away.
+
+-----------------------------------------------------------
+
+– Params:
+  - f – the function to run
+ */
+func runOnUIThread(f func()) {
+	if app := fyne.CurrentApp(); nil != app {
+		for _, window := range app.Driver().AllWindows() {
+			if event_queue, ok := window.(interface{QueueEvent(func())}); ok {
+				event_queue.QueueEvent(f)
+
+				return
+			}
+		}
+	}
+
+	f()
+}
+
 var home_canvas_object_GL fyne.CanvasObject = nil
 
 func Home() fyne.CanvasObject {
@@ -22,16 +22,23 @@
 package Screens
 
 import (
+	MOD_1 "ModManager"
 	"Utils"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var module_status_canvas_object_GL fyne.CanvasObject = nil
 
+// _LOGS_SHOWN is the number of most recent log entries shown on the screen.
+const _LOGS_SHOWN int = 20
+// _LOGS_UPDATE_S is the time between updates of the log entries shown on the screen.
+const _LOGS_UPDATE_S int64 = 5
+
 func ModulesStatus(modules []Utils.Module) fyne.CanvasObject {
 	Current_screen_GL = module_status_canvas_object_GL
 	if module_status_canvas_object_GL != nil {
@@ -45,37 +52,66 @@ func ModulesStatus(modules []Utils.Module) fyne.CanvasObject {
 	var scroll_text *container.Scroll = container.NewVScroll(module_status)
 	scroll_text.SetMinSize(module_status.MinSize()) // Set the minimum size for the scroll container
 
+	var logs_text *widget.Label = widget.NewLabel("")
+	logs_text.Wrapping = fyne.TextWrapWord
+
+	var check_boxes map[int]*widget.Check = getCheckBoxes(modules)
+
 	go func() {
+		var mod_states_ch chan Utils.ModStateChange = Utils.SubscribeModStatesMODULES()
+		var was_current bool = false
+		var last_logs_update int64 = 0
 		for {
-			if Current_screen_GL == module_status_canvas_object_GL {
+			// Update only when any module changes state or when the screen is shown again
+			var changed bool = false
+			select {
+				case <-mod_states_ch:
+					changed = true
+				case <-time.After(1 * time.Second):
+			}
+
+			var is_current bool = Current_screen_GL == module_status_canvas_object_GL
+			if is_current && (changed || !was_current) {
 				var text string = ""
-				for i, module := range modules {
+				for i := range modules {
 					if Utils.MOD_NUMS_SUPPORT[i] & Utils.MOD_CLIENT != 0 {
-						text += "- " + Utils.GetModNameMODULES(i) + " running: " + strconv.FormatBool(!module.Stopped) +
-							"\n\n"
+						text += "- " + Utils.GetModNameMODULES(i) + ": " +
+							Utils.MOD_STATES_NAMES[modules[i].GetState()] + "\n" + getCrashHistoryText(i) + "\n"
 					}
 				}
 				text = text[:len(text)-2] // Remove the last 2 newlines
-				module_status.SetText(text)
-				scroll_text.SetMinSize(module_status.MinSize())
-			}
 
-			time.Sleep(1 * time.Second)
+				runOnUIThread(func() {
+					module_status.SetText(text)
+					scroll_text.SetMinSize(module_status.MinSize())
+
+					// Show the modules disabled by the Manager (crash-looping ones) as unchecked, so they can be
+					// re-enabled
+					for mod_num, checkbox := range check_boxes {
+						syncCheckBox(checkbox, modules[mod_num].IsEnabled())
+					}
+				})
+			}
+			if is_current && (!was_current || time.Now().Unix() - last_logs_update >= _LOGS_UPDATE_S) {
+				var logs string = getLogsText()
+				runOnUIThread(func() {
+					logs_text.SetText(logs)
+				})
+				last_logs_update = time.Now().Unix()
+			}
+			was_current = is_current
 		}
 	}()
 
 	var canvas_objs []fyne.CanvasObject = []fyne.CanvasObject{
 		scroll_text,
 	}
-	for _, obj := range getCheckBoxes(modules) {
-		canvas_objs = append(canvas_objs, obj)
-	}
-
-	for _, obj := range canvas_objs {
-		if checkbox, ok := obj.(*widget.Check); ok {
-			checkbox.SetChecked(true)
-		}
+	for _, mod_num := range []int{Utils.NUM_MOD_Speech, Utils.NUM_MOD_RemindersReminder, Utils.NUM_MOD_SystemChecker,
+			Utils.NUM_MOD_SpeechRecognition} {
+		syncCheckBox(check_boxes[mod_num], modules[mod_num].IsEnabled())
+		canvas_objs = append(canvas_objs, check_boxes[mod_num])
 	}
+	canvas_objs = append(canvas_objs, widget.NewLabel("Recent warnings and errors:"), logs_text)
 
 
 
@@ -96,23 +132,114 @@ func ModulesStatus(modules []Utils.Module) fyne.CanvasObject {
 	return module_status_canvas_object_GL
 }
 
-func getCheckBoxes(modules []Utils.Module) []fyne.CanvasObject {
-	var check_boxes []fyne.CanvasObject
+func getCheckBoxes(modules []Utils.Module) map[int]*widget.Check {
+	var check_boxes map[int]*widget.Check = make(map[int]*widget.Check)
 
 	// Couldn't do it automatically. So here they are manually...
 
-	check_boxes = append(check_boxes, widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_Speech), func(b bool) {
-		modules[Utils.NUM_MOD_Speech].Enabled = b
-	}))
-	check_boxes = append(check_boxes, widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_RemindersReminder), func(b bool) {
-		modules[Utils.NUM_MOD_RemindersReminder].Enabled = b
-	}))
-	check_boxes = append(check_boxes, widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_SystemChecker), func(b bool) {
-		modules[Utils.NUM_MOD_SystemChecker].Enabled = b
-	}))
-	check_boxes = append(check_boxes, widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_SpeechRecognition), func(b bool) {
-		modules[Utils.NUM_MOD_SpeechRecognition].Enabled = b
-	}))
+	check_boxes[Utils.NUM_MOD_Speech] = widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_Speech), func(b bool) {
+		setModEnabled(modules, Utils.NUM_MOD_Speech, b)
+	})
+	check_boxes[Utils.NUM_MOD_RemindersReminder] = widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_RemindersReminder), func(b bool) {
+		setModEnabled(modules, Utils.NUM_MOD_RemindersReminder, b)
+	})
+	check_boxes[Utils.NUM_MOD_SystemChecker] = widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_SystemChecker), func(b bool) {
+		setModEnabled(modules, Utils.NUM_MOD_SystemChecker, b)
+	})
+	check_boxes[Utils.NUM_MOD_SpeechRecognition] = widget.NewCheck(Utils.GetModNameMODULES(Utils.NUM_MOD_SpeechRecognition), func(b bool) {
+		setModEnabled(modules, Utils.NUM_MOD_SpeechRecognition, b)
+	})
 
 	return check_boxes
 }
+
+/*
+syncCheckBox shows a module's enabled state on its check box without calling its OnChanged function, which is only
+for the user's clicks (it saves the state persistently). Call it on the UI thread.
+
+-----------------------------------------------------------
+
+– Params:
+  - checkbox – the check box of the module
+  - checked – true if the module is enabled, false otherwise
+ */
+func syncCheckBox(checkbox *widget.Check, checked bool) {
+	if checkbox.Checked == checked {
+		return
+	}
+
+	var on_changed func(bool) = checkbox.OnChanged
+	checkbox.OnChanged = nil
+	checkbox.SetChecked(checked)
+	checkbox.OnChanged = on_changed
+}
+
+/*
+setModEnabled enables or disables a module persistently. Enabling it also resets its crash-looping state, if it had
+one.
+
+-----------------------------------------------------------
+
+– Params:
+  - modules – the list of modules
+  - mod_num – the number of the module
+  - enabled – true to enable the module, false to disable it
+ */
+func setModEnabled(modules []Utils.Module, mod_num int, enabled bool) {
+	if err := MOD_1.SetModEnabled(mod_num, enabled); nil != err {
+		Utils.GetModLoggerLOGS(Utils.NUM_MOD_VISOR).Warning("Couldn't save the module state", "module",
+			modules[mod_num].Name, "error", err)
+	}
+}
+
+/*
+getCrashHistoryText gets the text describing the crash history of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the text describing the crash history of the module or an empty string if it never crashed
+ */
+func getCrashHistoryText(mod_num int) string {
+	var crash_history MOD_1.ModCrashHistory = MOD_1.GetCrashHistory(mod_num)
+	if len(crash_history.Crashes) == 0 {
+		return ""
+	}
+
+	var last_crash MOD_1.CrashInfo = crash_history.Crashes[len(crash_history.Crashes) - 1]
+	var text string = "  Crashes: " + strconv.Itoa(len(crash_history.Crashes)) + " (last on " +
+		Utils.GetDateTimeStrTIMEDATE(last_crash.Time) + ")\n"
+	if crash_history.Crash_looping {
+		text += "  DISABLED - crash-looping. Check the module to re-enable it.\n"
+	}
+	text += "  Last error: " + strings.Split(last_crash.Error, "\n")[0] + "\n"
+
+	return text
+}
+
+/*
+getLogsText gets the text describing the most recent warnings and errors logged by the modules.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the text describing the entries, from the newest to the oldest
+ */
+func getLogsText() string {
+	var log_entries []Utils.LogEntry = Utils.QueryLogsLOGS(-1, Utils.LOG_LEVEL_WARNING, _LOGS_SHOWN)
+	if len(log_entries) == 0 {
+		return "None"
+	}
+
+	var text string = ""
+	for i := len(log_entries) - 1; i >= 0; i-- {
+		var log_entry Utils.LogEntry = log_entries[i]
+		text += Utils.GetDateTimeStrTIMEDATE(log_entry.Time) + " - " + Utils.GetModNameMODULES(log_entry.Mod_num) +
+			" [" + Utils.LOG_LEVELS_NAMES[log_entry.Level] + "] " + log_entry.Msg + "\n"
+	}
+
+	return text[:len(text)-1] // Remove the last newline
+}
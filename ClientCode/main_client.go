@@ -23,9 +23,11 @@ package main
 
 import "C"
 import (
+	"context"
 	MOD_1 "ModManager"
 	"Registry/Registry"
 	"Utils"
+	"Utils/UtilsSWA"
 	"VISOR_Client/ClientRegKeys"
 	"VISOR_Client/Logo"
 	"VISOR_Client/Screens"
@@ -50,20 +52,49 @@ var (
 	realMain        Utils.RealMain = nil
 	moduleInfo_GL   Utils.ModuleInfo[_MGI]
 )
+var modules_GL []Utils.Module = nil
+var exit_code_GL int = Utils.EXIT_CODE_OK
 func main() {
-	var module Utils.Module = Utils.Module{
-		Num:     Utils.NUM_MOD_VISOR,
-		Name:    Utils.GetModNameMODULES(Utils.NUM_MOD_VISOR),
-		Stop:    false,
-		Stopped: false,
-		Enabled: true,
+	if len(os.Args) > 1 && "secrets" == os.Args[1] {
+		os.Exit(UtilsSWA.RunSecretsCmdSECRETS(os.Args[2:]))
 	}
-	Utils.ModStartup2[_MGI](realMain, &module, false)
+
+	// Unlock the secrets before reading the settings, which may reference them
+	if err := UtilsSWA.UnlockSecretsSECRETS(); nil != err {
+		log.Println("Error unlocking the secrets store: " + err.Error())
+	}
+
+	if Utils.WasArgUsedGENERAL(os.Args, "--check-config") {
+		if !Utils.CheckUserSettingsSETTINGS(false) {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	modules_GL = make([]Utils.Module, Utils.MODS_ARRAY_SIZE)
+	for i := 0; i < Utils.MODS_ARRAY_SIZE; i++ {
+		modules_GL[i].Num = i
+		modules_GL[i].Name = Utils.GetModNameMODULES(i)
+		modules_GL[i].SetEnabled(true)
+	}
+	Utils.ModStartup2[_MGI](realMain, &modules_GL[Utils.NUM_MOD_VISOR], false)
+
+	os.Exit(exit_code_GL)
 }
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
+		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
+
+		if Utils.WasArgUsedGENERAL(os.Args, "--headless") {
+			runHeadless(module_stop)
+
+			return
+		}
+
 		if !isOpenGLSupport() {
-			log.Println("Required OpenGL version not supported. Exiting...")
+			log.Println("Required OpenGL version not supported (use --headless to run without the window). " +
+				"Exiting...")
 
 			return
 		}
@@ -72,7 +103,7 @@ func init() {realMain =
 		if runtime.GOOS == "windows" {
 			if !Utils.WasArgUsedGENERAL(os.Args, "--conhost") {
 				// Restart the process with conhost.exe on Windows to be able to actually hide the window
-				if Utils.StartConAppPROCESSES(Utils.GetBinDirFILESDIRS().Add2(true, "VISOR.exe"), "--conhost") {
+				if Utils.StartConAppPROCESSES(Utils.GetBinDirFILESDIRS().Add2(false, "VISOR.exe"), "--conhost") {
 					return
 				}
 			}
@@ -84,30 +115,20 @@ func init() {realMain =
 
 		ClientRegKeys.RegisterValues()
 
-		var modules []Utils.Module
-		for i := 0; i < Utils.MODS_ARRAY_SIZE; i++ {
-			modules = append(modules, Utils.Module{
-				Num:     i,
-				Name:    Utils.GetModNameMODULES(i),
-				Stop:    true,
-				Stopped: true,
-				Enabled: true,
-			})
-		}
-		// Just for it to print that VISOR is running
-		modules[Utils.NUM_MOD_VISOR].Stop = false
-		modules[Utils.NUM_MOD_VISOR].Stopped = false
 		// The Manager needs to be started first. It'll handle the others.
-		modules[Utils.NUM_MOD_ModManager].Stop = false
-
-		MOD_1.Start(modules)
+		MOD_1.Start(modules_GL)
 
 		// Create a new application
 		my_app_GL = app.NewWithID("com.edw590.visor_c")
 		my_app_GL.SetIcon(Logo.LogoBlackGmail)
 		my_window_GL = my_app_GL.NewWindow("V.I.S.O.R.")
 
-		processNotifications()
+		processNotifications(func(title string, text string) {
+			my_app_GL.SendNotification(fyne.NewNotification(title, text))
+		})
+
+		// Ctrl+C or SIGTERM quit the same way as the tray Quit option
+		Utils.HandleStopSignalsMODULES(quit)
 
 		// Create the content area with a label to display different screens
 		var content_label *widget.Label = widget.NewLabel("Welcome!")
@@ -128,7 +149,7 @@ func init() {realMain =
 				content_container.Refresh()
 			}),
 			widget.NewButton("Modules Status", func() {
-				content_container.Objects = []fyne.CanvasObject{Screens.ModulesStatus(modules)}
+				content_container.Objects = []fyne.CanvasObject{Screens.ModulesStatus(modules_GL)}
 				content_container.Refresh()
 			}),
 			widget.NewButton("Calendar", func() {
@@ -168,11 +189,7 @@ func init() {realMain =
 					// Restore the previous screen state
 					Screens.Current_screen_GL = prev_screen
 				}),
-				fyne.NewMenuItem("Quit", func() {
-					Utils.SignalModulesStopMODULES(modules)
-
-					my_app_GL.Quit()
-				}),
+				fyne.NewMenuItem("Quit", quit),
 			)
 			desk.SetSystemTrayMenu(menu)
 			desk.SetSystemTrayIcon(icon)
@@ -186,43 +203,74 @@ func init() {realMain =
 			my_window_GL.Hide()
 		})
 
+		var show_app_sig *Registry.Value = Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG)
+		var show_app_ch chan Registry.ValueChange = show_app_sig.Watch()
 		go func() {
-			for {
-				if Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG).GetData(true, nil).(bool) {
+			for change := range show_app_ch {
+				if change.New_data.(bool) {
 					showWindow()
-					Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG).SetData(false, false)
+					show_app_sig.SetData(false, false)
 				}
-
-				time.Sleep(1 * time.Second)
 			}
 		}()
 
 		// Show and run the application
 		my_window_GL.Resize(fyne.NewSize(640, 480))
 		my_window_GL.ShowAndRun()
+
+		// In case the application was quit some other way
+		exit_code_GL = Utils.ShutdownMODULES(modules_GL)
 	}
 }
 
 /*
-processNotifications processes in a different thread the notifications queued in the notifications folder.
+quit stops all the modules and quits the application. VISOR then exits with the exit code of the shutdown.
  */
-func processNotifications() {
+func quit() {
+	exit_code_GL = Utils.ShutdownMODULES(modules_GL)
+
+	my_app_GL.Quit()
+}
+
+/*
+processNotifications shows in a different thread the notifications queued on Utils.TOPIC_NOTIFICATIONS (and the ones
+dropped in the notifications folder).
+
+-----------------------------------------------------------
+
+– Params:
+  - sendNotification – the function that shows a notification
+ */
+func processNotifications(sendNotification func(title string, text string)) {
+	Utils.TOPIC_NOTIFICATIONS.StartFolderAdapter(context.Background(),
+		Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR).Add2(true, Utils.NOTIFS_REL_FOLDER),
+		func(file_info Utils.FileInfo) (Utils.Notification, bool) {
+			var text *string = file_info.GPath.ReadTextFile()
+			if nil == text {
+				return Utils.Notification{}, false
+			}
+
+			return Utils.Notification{
+				Title: strings.Split(file_info.Name, "-")[0],
+				Text:  *text,
+			}, true
+		})
+
 	go func() {
+		var subscription *Utils.Subscription[Utils.Notification] = Utils.TOPIC_NOTIFICATIONS.Subscribe(
+			Utils.GetModSubscriberEVENTS(Utils.NUM_MOD_VISOR))
 		for {
-			var file_list []Utils.FileInfo = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR).
-				Add2(true, Utils.NOTIFS_REL_FOLDER).GetFileList()
-			for _, file := range file_list {
-				// Display the notification
-				notification := fyne.NewNotification(strings.Split(file.Name, "-")[0], *file.GPath.ReadTextFile())
-				my_app_GL.SendNotification(notification)
+			event, _ := subscription.Next(context.Background(), 60)
+			if nil == event {
+				continue
+			}
 
-				// Remove the file
-				_ = file.GPath.Remove()
+			// Display the notification
+			sendNotification(event.Payload.Title, event.Payload.Text)
 
-				time.Sleep(5 * time.Second)
-			}
+			subscription.Ack(event)
 
-			time.Sleep(1 * time.Second)
+			time.Sleep(5 * time.Second)
 		}
 	}()
 }
@@ -23,6 +23,7 @@ package GPT
 
 import (
 	"Utils"
+	"context"
 	"strconv"
 	"strings"
 )
@@ -103,9 +104,9 @@ func GetEntry(time int64, num int) *Entry {
 }
 
 func SendText(text string) error {
-	_, err := Utils.SubmitFormWEBSITE(Utils.WebsiteForm{
+	_, err := Utils.SubmitFormWEBSITE(context.Background(), Utils.WebsiteForm{
 		Type:  "GPT",
-		Text1: "[" + Utils.User_settings_GL.PersonalConsts.Device_ID + "]" + text,
+		Text1: "[" + Utils.GetUserSettingsSETTINGS().PersonalConsts.Device_ID + "]" + text,
 	})
 
 	return err
@@ -26,32 +26,81 @@ import (
 )
 
 /*
-getInternal returns whether the data is internal and the data to return.
+getRawData gets the data of the value as it's stored.
 
 -----------------------------------------------------------
 
 – Params:
   - curr_data – true to get the current data, false to get the previous data
-  - no_data – the data to return if there's no data or nil to return the default values
 
 – Returns:
-  - whether the no_data parameter was used
-  - the data to return
+  - the stored data
+ */
+func (value *Value) getRawData(curr_data bool) string {
+	value.mutex.RLock()
+	defer value.mutex.RUnlock()
+
+	if curr_data {
+		return value.curr_data
+	} else {
+		return value.prev_data
+	}
+}
+
+/*
+convertData converts stored data to one of the basic types (TYPE_BOOL to TYPE_STRING).
+
+-----------------------------------------------------------
+
+– Params:
+  - value_type – the type to convert to
+  - data – the stored data
+
+– Returns:
+  - the converted data (false or -1 if it can't be converted) or nil if the type isn't a basic one
  */
-func (value *Value) getInternal(curr_data bool, no_data any) (bool, any) {
-	if no_data != nil {
-		if curr_data {
-			if value.time_updated_curr == 0 {
-				return true, no_data
+func convertData(value_type string, data string) any {
+	switch value_type {
+		case TYPE_BOOL:
+			b, err := strconv.ParseBool(data)
+			if err != nil {
+				return false
 			}
-		} else {
-			if value.time_updated_prev == 0 {
-				return true, no_data
+
+			return b
+		case TYPE_INT:
+			i, err := strconv.Atoi(data)
+			if err != nil {
+				return -1
+			}
+
+			return i
+		case TYPE_LONG:
+			i, err := strconv.ParseInt(data, 10, 64)
+			if err != nil {
+				return int64(-1)
 			}
-		}
+
+			return i
+		case TYPE_FLOAT:
+			f, err := strconv.ParseFloat(data, 32)
+			if err != nil {
+				return float32(-1)
+			}
+
+			return float32(f)
+		case TYPE_DOUBLE:
+			f, err := strconv.ParseFloat(data, 64)
+			if err != nil {
+				return float64(-1)
+			}
+
+			return f
+		case TYPE_STRING:
+			return data
 	}
 
-	return false, nil
+	return nil
 }
 
 /*
@@ -66,6 +115,9 @@ GetTimeUpdated returns the time the data was updated in milliseconds.
   - the time the data was updated in milliseconds
  */
 func (value *Value) GetTimeUpdated(curr_data bool) int64 {
+	value.mutex.RLock()
+	defer value.mutex.RUnlock()
+
 	if curr_data {
 		return value.time_updated_curr
 	} else {
@@ -97,19 +149,7 @@ GetBool returns the boolean value of the Value.
   - the boolean value of the Value
  */
 func (value *Value) GetBool(curr_data bool) bool {
-	var data string
-	if curr_data {
-		data = value.curr_data
-	} else {
-		data = value.prev_data
-	}
-
-	i, err := strconv.ParseBool(data)
-	if err != nil {
-		return false
-	}
-
-	return i
+	return convertData(TYPE_BOOL, value.getRawData(curr_data)).(bool)
 }
 
 /*
@@ -124,19 +164,7 @@ GetInt returns the integer value of the Value.
   - the integer value of the Value
  */
 func (value *Value) GetInt(curr_data bool) int {
-	var data string
-	if curr_data {
-		data = value.curr_data
-	} else {
-		data = value.prev_data
-	}
-
-	i, err := strconv.Atoi(data)
-	if err != nil {
-		return -1
-	}
-
-	return i
+	return convertData(TYPE_INT, value.getRawData(curr_data)).(int)
 }
 
 /*
@@ -151,19 +179,7 @@ GetLong returns the long value of the Value.
   - the long value of the Value
  */
 func (value *Value) GetLong(curr_data bool) int64 {
-	var data string
-	if curr_data {
-		data = value.curr_data
-	} else {
-		data = value.prev_data
-	}
-
-	i, err := strconv.ParseInt(data, 10, 64)
-	if err != nil {
-		return -1
-	}
-
-	return i
+	return convertData(TYPE_LONG, value.getRawData(curr_data)).(int64)
 }
 
 /*
@@ -178,19 +194,7 @@ GetFloat returns the float value of the Value.
   - the float value of the Value
  */
 func (value *Value) GetFloat(curr_data bool) float32 {
-	var data string
-	if curr_data {
-		data = value.curr_data
-	} else {
-		data = value.prev_data
-	}
-
-	i, err := strconv.ParseFloat(data, 32)
-	if err != nil {
-		return -1
-	}
-
-	return float32(i)
+	return convertData(TYPE_FLOAT, value.getRawData(curr_data)).(float32)
 }
 
 /*
@@ -205,19 +209,7 @@ GetDouble returns the double value of the Value.
   - the double value of the Value
  */
 func (value *Value) GetDouble(curr_data bool) float64 {
-	var data string
-	if curr_data {
-		data = value.curr_data
-	} else {
-		data = value.prev_data
-	}
-
-	i, err := strconv.ParseFloat(data, 64)
-	if err != nil {
-		return -1
-	}
-
-	return i
+	return convertData(TYPE_DOUBLE, value.getRawData(curr_data)).(float64)
 }
 
 /*
@@ -232,11 +224,7 @@ GetString returns the string value of the Value.
   - the string value of the Value
  */
 func (value *Value) GetString(curr_data bool) string {
-	if curr_data {
-		return value.curr_data
-	} else {
-		return value.prev_data
-	}
+	return value.getRawData(curr_data)
 }
 
 /*
@@ -249,26 +237,39 @@ GetData returns the data of the Value.
   - no_data – the data to return if there's no data or nil to return the default values
  */
 func (value *Value) GetData(curr_data bool, no_data any) any {
-	no_data_used, no_data_ret := value.getInternal(curr_data, no_data)
-	if no_data_used {
-		return no_data_ret
+	value.mutex.RLock()
+	var data string = value.prev_data
+	var time_updated int64 = value.time_updated_prev
+	if curr_data {
+		data = value.curr_data
+		time_updated = value.time_updated_curr
 	}
+	value.mutex.RUnlock()
 
-	switch value.type_ {
-		case TYPE_BOOL:
-			return value.GetBool(curr_data)
-		case TYPE_INT:
-			return value.GetInt(curr_data)
-		case TYPE_LONG:
-			return value.GetLong(curr_data)
-		case TYPE_FLOAT:
-			return value.GetFloat(curr_data)
-		case TYPE_DOUBLE:
-			return value.GetDouble(curr_data)
-		case TYPE_STRING:
-			return value.GetString(curr_data)
+	if no_data != nil && time_updated == 0 {
+		return no_data
 	}
 
-	// Won't happen
-	return nil
+	return value.typedData(data)
+}
+
+/*
+GetTyped returns the data of the Value as the type T, which must be the Go type of the value's data (bool for TYPE_BOOL,
+int for TYPE_INT, int64 for TYPE_LONG, float32 for TYPE_FLOAT, float64 for TYPE_DOUBLE, string for TYPE_STRING and
+TYPE_ENUM, []string for TYPE_STRING_LIST, time.Time for TYPE_TIME and the declared type for TYPE_JSON).
+
+-----------------------------------------------------------
+
+– Params:
+  - value – the value
+  - curr_data – true to get the current data, false to get the previous data
+
+– Returns:
+  - the data or the zero value of T if T isn't the type of the value's data
+  - true if T is the type of the value's data, false otherwise
+ */
+func GetTyped[T any](value *Value, curr_data bool) (T, bool) {
+	data, ok := value.GetData(curr_data, nil).(T)
+
+	return data, ok
 }
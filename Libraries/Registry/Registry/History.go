@@ -0,0 +1,577 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils"
+	"encoding/json"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _HISTORY_DIR is the name of the directory with the spilled history of the values, inside VISOR's user data directory.
+const _HISTORY_DIR string = "registry_history"
+// _HISTORY_MIN_CAP is the minimum capacity of the ring of a history without a limit of entries.
+const _HISTORY_MIN_CAP int = 16
+
+// _HISTORY_SPILL_DEBOUNCE is the time to wait after an entry is removed from memory before appending it to the spill
+// file, so that the file isn't written while setting the value and entries removed close together are written at once.
+const _HISTORY_SPILL_DEBOUNCE time.Duration = 5 * time.Second
+// _HISTORY_TRIM_INTERVAL_MS is the minimum time between removals of the entries too old from a spill file.
+const _HISTORY_TRIM_INTERVAL_MS int64 = 60*60*1000
+
+var (
+	history_mutex_GL sync.Mutex
+	// spilled_values_GL are the values whose history is spilled to a file
+	spilled_values_GL []*Value = nil
+	// spill_timer_GL is the timer of the next spill, or nil if none is scheduled
+	spill_timer_GL *time.Timer = nil
+
+	// spill_files_mutex_GL makes the spill files be written one at a time
+	spill_files_mutex_GL sync.Mutex
+)
+
+// HistoryEntry is a past data of a value.
+type HistoryEntry struct {
+	// Data is the data, of the type of the value (like with GetData())
+	Data any
+	// Time is the time the data was set in milliseconds
+	Time int64
+}
+
+// HistoryStats are the aggregates of the history of a value in a time range.
+type HistoryStats struct {
+	// Num_states is the number of data the value had in the range (including the one it had at the start)
+	Num_states int
+	// Min is the minimum data in the range, for the number types
+	Min float64
+	// Max is the maximum data in the range, for the number types
+	Max float64
+	// Average is the average of the data in the range weighted by the time each one lasted, for the number types
+	Average float64
+	// Time_in_state is the time in milliseconds the value had each data in the range, by the data as text (like on
+	// GetRegistryText())
+	Time_in_state map[string]int64
+}
+
+// _HistoryEntry is a past data of a value as it's stored.
+type _HistoryEntry struct {
+	Data string
+	Time int64
+}
+
+// _History is the history of a value: a ring of the last entries, bounded by number and/or by age.
+type _History struct {
+	// max_entries is the maximum number of entries kept in memory, or 0 for no limit
+	max_entries int
+	// max_age_ms is the maximum age of the entries in milliseconds, or 0 for no limit
+	max_age_ms int64
+	// spill is whether the entries removed from memory because of max_entries are appended to a file
+	spill bool
+
+	// entries is the ring of entries
+	entries []_HistoryEntry
+	// first is the index of the oldest entry on the ring
+	first int
+	// count is the number of entries on the ring
+	count int
+
+	// pending are the entries removed from the ring waiting to be appended to the spill file, oldest first
+	pending []_HistoryEntry
+	// time_spilled is the time of the newest entry appended to the spill file (entries still on the ring may be there
+	// already after SaveHistory())
+	time_spilled int64
+	// time_trimmed is the last time the entries too old were removed from the spill file in milliseconds
+	time_trimmed int64
+}
+
+/*
+EnableHistory starts keeping the history of the data of the value (with the current data as the first entry). Does
+nothing if it's already enabled.
+
+Without spill, the history is only in memory and is lost when VISOR stops. With it, the entries that don't fit in memory
+are appended to a file in VISOR's user data directory (not while setting the value, but a bit later), where the queries
+also look for them. The entries still in memory are appended too when VISOR shuts down, so that the history continues
+after it starts again.
+
+-----------------------------------------------------------
+
+– Params:
+  - max_entries – the maximum number of entries kept in memory, or 0 for no limit
+  - max_age_s – the maximum age of the entries in seconds, or 0 for no limit
+  - spill – true to append the entries that don't fit in memory to a file, false to discard them
+*/
+func (value *Value) EnableHistory(max_entries int, max_age_s int, spill bool) {
+	value.mutex.Lock()
+	defer value.mutex.Unlock()
+
+	if nil != value.history {
+		return
+	}
+
+	value.history = &_History{
+		max_entries: max_entries,
+		max_age_ms:  int64(max_age_s) * 1000,
+		spill:       spill && max_entries > 0,
+	}
+	if value.history.spill {
+		// Continue from where the file was left, so that the entries already there (like the current data of a
+		// persistent value) aren't appended again
+		value.history.time_spilled = value.readLastSpilledTime()
+
+		history_mutex_GL.Lock()
+		if 0 == len(spilled_values_GL) {
+			Utils.AddShutdownHookMODULES(SaveHistory)
+		}
+		spilled_values_GL = append(spilled_values_GL, value)
+		history_mutex_GL.Unlock()
+	}
+	if 0 != value.time_updated_curr {
+		value.addHistoryEntry(_HistoryEntry{
+			Data: value.curr_data,
+			Time: value.time_updated_curr,
+		})
+	}
+}
+
+/*
+SaveHistory appends right away to the spill files the entries of the histories waiting for it, together with the ones
+still in memory, so that the histories are complete after VISOR starts again. It's called automatically when VISOR shuts
+down.
+*/
+func SaveHistory() {
+	spillHistories(true)
+}
+
+/*
+GetHistory gets the entries of the history of the value in a time range.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_start – the start of the range in milliseconds
+  - time_end – the end of the range in milliseconds
+
+– Returns:
+  - the entries set in the range, oldest first, or nil if the history isn't enabled
+*/
+func (value *Value) GetHistory(time_start int64, time_end int64) []HistoryEntry {
+	var history []HistoryEntry = nil
+	entries, time_start := value.getHistoryEntries(time_start, time_end)
+	for _, entry := range entries {
+		if entry.Time < time_start {
+			continue
+		}
+
+		history = append(history, HistoryEntry{
+			Data: value.typedData(entry.Data),
+			Time: entry.Time,
+		})
+	}
+
+	return history
+}
+
+/*
+GetHistoryStats gets the aggregates of the history of the value in a time range. The data the value had at the start of
+the range counts from the start.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_start – the start of the range in milliseconds
+  - time_end – the end of the range in milliseconds
+
+– Returns:
+  - the aggregates (all zero if the history isn't enabled or has nothing in the range)
+*/
+func (value *Value) GetHistoryStats(time_start int64, time_end int64) HistoryStats {
+	var stats HistoryStats = HistoryStats{
+		Time_in_state: make(map[string]int64),
+	}
+	var numeric bool = false
+	switch value.type_ {
+		case TYPE_INT, TYPE_LONG, TYPE_FLOAT, TYPE_DOUBLE:
+			numeric = true
+	}
+
+	entries, time_start := value.getHistoryEntries(time_start, time_end)
+	var weighted_sum float64 = 0
+	var total_time int64 = 0
+	for i, entry := range entries {
+		var state_start int64 = entry.Time
+		if state_start < time_start {
+			state_start = time_start
+		}
+		var state_end int64 = time_end
+		if i + 1 < len(entries) {
+			state_end = entries[i + 1].Time
+		}
+		var typed_data any = value.typedData(entry.Data)
+
+		stats.Num_states++
+		stats.Time_in_state[formatData(typed_data)] += state_end - state_start
+		if numeric {
+			var number float64 = 0
+			switch typed_data := typed_data.(type) {
+				case int:
+					number = float64(typed_data)
+				case int64:
+					number = float64(typed_data)
+				case float32:
+					number = float64(typed_data)
+				case float64:
+					number = typed_data
+			}
+			if 1 == stats.Num_states || number < stats.Min {
+				stats.Min = number
+			}
+			if 1 == stats.Num_states || number > stats.Max {
+				stats.Max = number
+			}
+			weighted_sum += number * float64(state_end - state_start)
+			total_time += state_end - state_start
+		}
+	}
+	if total_time > 0 {
+		stats.Average = weighted_sum / float64(total_time)
+	} else if stats.Num_states > 0 {
+		stats.Average = stats.Min
+	}
+
+	return stats
+}
+
+/*
+getHistoryEntries gets the stored entries of the history in a time range (from memory and, if needed, from the spill
+file), plus the last one before the range, which is the data the value had at its start. Entries older than the
+maximum age don't count. The spill file is only read after the value's mutex is unlocked.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_start – the start of the range in milliseconds
+  - time_end – the end of the range in milliseconds
+
+– Returns:
+  - the entries, oldest first
+  - the start of the range, later than the given one if it's before the maximum age
+*/
+func (value *Value) getHistoryEntries(time_start int64, time_end int64) ([]_HistoryEntry, int64) {
+	value.mutex.RLock()
+	var history *_History = value.history
+	if nil == history {
+		value.mutex.RUnlock()
+
+		return nil, time_start
+	}
+
+	if history.max_age_ms > 0 {
+		var time_oldest int64 = Utils.GetClockTIMEDATE().Now().UnixMilli() - history.max_age_ms
+		if time_start < time_oldest {
+			time_start = time_oldest
+		}
+	}
+
+	var entries []_HistoryEntry = append([]_HistoryEntry(nil), history.pending...)
+	for i := 0; i < history.count; i++ {
+		entries = append(entries, history.entries[(history.first + i) % len(history.entries)])
+	}
+	var spill bool = history.spill
+	value.mutex.RUnlock()
+
+	if spill && (0 == len(entries) || entries[0].Time > time_start) {
+		// Only the ones older than the ones in memory, in case they were spilled in the meantime
+		var time_before int64 = math.MaxInt64
+		if len(entries) > 0 {
+			time_before = entries[0].Time
+		}
+		entries = append(value.readSpilledHistory(time_before), entries...)
+	}
+
+	var entries_range []_HistoryEntry = nil
+	for _, entry := range entries {
+		if entry.Time > time_end {
+			break
+		}
+		if entry.Time <= time_start {
+			// Only the last one until the start is needed
+			entries_range = []_HistoryEntry{entry}
+		} else {
+			entries_range = append(entries_range, entry)
+		}
+	}
+
+	return entries_range, time_start
+}
+
+/*
+addHistoryEntry adds an entry to the history, removing the ones too old and the oldest if it's full. Call with the
+value's mutex locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - entry – the entry
+*/
+func (value *Value) addHistoryEntry(entry _HistoryEntry) {
+	var history *_History = value.history
+
+	// Keep the newest of the entries too old, which is the data the value had when the oldest allowed time started
+	if history.max_age_ms > 0 {
+		var time_oldest int64 = entry.Time - history.max_age_ms
+		for history.count > 1 && history.entries[(history.first + 1) % len(history.entries)].Time <= time_oldest {
+			history.first = (history.first + 1) % len(history.entries)
+			history.count--
+		}
+	}
+
+	if history.max_entries > 0 && history.count == history.max_entries {
+		var oldest_entry _HistoryEntry = history.entries[history.first]
+		if history.spill && oldest_entry.Time > history.time_spilled {
+			history.pending = append(history.pending, oldest_entry)
+			scheduleHistorySpill()
+		}
+		history.first = (history.first + 1) % len(history.entries)
+		history.count--
+	}
+
+	if history.count == len(history.entries) {
+		var new_cap int = 2 * len(history.entries)
+		if new_cap < _HISTORY_MIN_CAP {
+			new_cap = _HISTORY_MIN_CAP
+		}
+		if history.max_entries > 0 && new_cap > history.max_entries {
+			new_cap = history.max_entries
+		}
+
+		var entries []_HistoryEntry = make([]_HistoryEntry, new_cap)
+		for i := 0; i < history.count; i++ {
+			entries[i] = history.entries[(history.first + i) % len(history.entries)]
+		}
+		history.entries = entries
+		history.first = 0
+	}
+
+	history.entries[(history.first + history.count) % len(history.entries)] = entry
+	history.count++
+}
+
+/*
+getSpillPath gets the path of the file with the spilled history of the value.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the path of the file
+*/
+func (value *Value) getSpillPath() Utils.GPath {
+	return Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR).Add2(false, _HISTORY_DIR, value.key + ".jsonl")
+}
+
+/*
+scheduleHistorySpill schedules the appending of the entries removed from memory to the spill files, if it's not
+scheduled yet.
+*/
+func scheduleHistorySpill() {
+	history_mutex_GL.Lock()
+	defer history_mutex_GL.Unlock()
+
+	if nil == spill_timer_GL {
+		spill_timer_GL = time.AfterFunc(_HISTORY_SPILL_DEBOUNCE, func() {
+			spillHistories(false)
+		})
+	}
+}
+
+/*
+spillHistories appends to the spill files the entries of the histories waiting for it.
+
+-----------------------------------------------------------
+
+– Params:
+  - with_memory – true to also append the entries still in memory
+*/
+func spillHistories(with_memory bool) {
+	history_mutex_GL.Lock()
+	if nil != spill_timer_GL {
+		spill_timer_GL.Stop()
+		spill_timer_GL = nil
+	}
+	var values []*Value = append([]*Value(nil), spilled_values_GL...)
+	history_mutex_GL.Unlock()
+
+	spill_files_mutex_GL.Lock()
+	defer spill_files_mutex_GL.Unlock()
+
+	for _, value := range values {
+		value.spillHistory(with_memory)
+	}
+}
+
+/*
+spillHistory appends to the spill file the entries of the history waiting for it, removing the entries too old from the
+file if it's been a while since the last time. Call with spill_files_mutex_GL locked (the value's mutex is only locked to
+get the entries).
+
+-----------------------------------------------------------
+
+– Params:
+  - with_memory – true to also append the entries still in memory
+*/
+func (value *Value) spillHistory(with_memory bool) {
+	var now int64 = Utils.GetClockTIMEDATE().Now().UnixMilli()
+
+	value.mutex.Lock()
+	var history *_History = value.history
+	var entries []_HistoryEntry = history.pending
+	history.pending = nil
+	if with_memory {
+		for i := 0; i < history.count; i++ {
+			var entry _HistoryEntry = history.entries[(history.first + i) % len(history.entries)]
+			if entry.Time > history.time_spilled {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) > 0 {
+		history.time_spilled = entries[len(entries) - 1].Time
+	}
+	var trim bool = history.max_age_ms > 0 && now - history.time_trimmed >= _HISTORY_TRIM_INTERVAL_MS
+	if trim {
+		history.time_trimmed = now
+	}
+	var time_oldest int64 = now - history.max_age_ms
+	value.mutex.Unlock()
+
+	if len(entries) > 0 {
+		var lines []byte = nil
+		for _, entry := range entries {
+			line, _ := json.Marshal(entry)
+			lines = append(append(lines, line...), '\n')
+		}
+		if err := value.getSpillPath().WriteFile(lines, true); nil != err {
+			Utils.GetModLoggerLOGS(Utils.NUM_MOD_VISOR).Warning("Error spilling the history of a registry value",
+				"key", value.key, "error", err)
+		}
+	}
+	if trim {
+		value.trimSpilledHistory(time_oldest)
+	}
+}
+
+/*
+readSpilledHistory reads the entries of the history in the spill file.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_before – only the entries older than this time in milliseconds are returned
+
+– Returns:
+  - the entries, oldest first (an incomplete last line is ignored)
+*/
+func (value *Value) readSpilledHistory(time_before int64) []_HistoryEntry {
+	var p_spill *string = value.getSpillPath().ReadTextFile()
+	if nil == p_spill {
+		return nil
+	}
+
+	var entries []_HistoryEntry = nil
+	for _, line := range strings.Split(*p_spill, "\n") {
+		var entry _HistoryEntry
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &entry); nil != err {
+			continue
+		}
+		if entry.Time >= time_before {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+/*
+readLastSpilledTime reads the time of the newest entry in the spill file.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the time in milliseconds, or 0 if there are no entries (an incomplete last line is ignored)
+*/
+func (value *Value) readLastSpilledTime() int64 {
+	var p_spill *string = value.getSpillPath().ReadTextFile()
+	if nil == p_spill {
+		return 0
+	}
+
+	var lines []string = strings.Split(*p_spill, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		var entry _HistoryEntry
+		if err := json.Unmarshal([]byte(strings.TrimSpace(lines[i])), &entry); nil == err {
+			return entry.Time
+		}
+	}
+
+	return 0
+}
+
+/*
+trimSpilledHistory removes from the spill file the entries older than a time, except the newest of those, which is the
+data the value had at that time. Call with spill_files_mutex_GL locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_oldest – the time of the oldest entry to keep in milliseconds
+*/
+func (value *Value) trimSpilledHistory(time_oldest int64) {
+	var entries []_HistoryEntry = value.readSpilledHistory(math.MaxInt64)
+	var first int = 0
+	for first + 1 < len(entries) && entries[first + 1].Time <= time_oldest {
+		first++
+	}
+	if 0 == first {
+		return
+	}
+
+	var lines []byte = nil
+	for _, entry := range entries[first:] {
+		line, _ := json.Marshal(entry)
+		lines = append(append(lines, line...), '\n')
+	}
+
+	// Replace the file at once, so that queries reading it meanwhile don't see it incomplete
+	var file_system Utils.FileSystem = Utils.GetFileSystemFILESDIRS()
+	var spill_path string = value.getSpillPath().GPathToStringConversion()
+	var err error = file_system.WriteFile(spill_path + "_tmp", lines, false, false)
+	if nil == err {
+		err = file_system.Rename(spill_path + "_tmp", spill_path)
+	}
+	if nil != err {
+		Utils.GetModLoggerLOGS(Utils.NUM_MOD_VISOR).Warning("Error trimming the spilled history of a registry value",
+			"key", value.key, "error", err)
+	}
+}
@@ -0,0 +1,218 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils/UtilsTest"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryBoundedByCount(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.EnableHistory(3, 0, false)
+	var time_start int64 = harness.Clock.Now().UnixMilli()
+	for i := 1; i <= 5; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+
+	var history []HistoryEntry = value.GetHistory(time_start, harness.Clock.Now().UnixMilli())
+	if 3 != len(history) || 3 != history[0].Data || 5 != history[2].Data {
+		t.Fatalf("expected the last 3 entries, got %+v", history)
+	}
+	if time_start + 2 * 60 * 1000 != history[0].Time {
+		t.Fatalf("wrong time of the oldest entry %d", history[0].Time)
+	}
+}
+
+func TestHistoryBoundedByAge(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.EnableHistory(0, 60 * 60, false)
+	for i := 1; i <= 100; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+
+	// The last hour has the last 60 entries, the first one set right at its start
+	var now int64 = harness.Clock.Now().UnixMilli()
+	var history []HistoryEntry = value.GetHistory(0, now)
+	if 60 != len(history) || 41 != history[0].Data {
+		t.Fatalf("expected the entries of the last hour, got %d starting on %v", len(history), history[0].Data)
+	}
+	if stats := value.GetHistoryStats(0, now); 60 != stats.Num_states || 41 != stats.Min {
+		t.Fatalf("expected the stats of the last hour, got %+v", stats)
+	}
+}
+
+func TestHistoryStats(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("VOLUME", "Volume", "The sound volume", TYPE_INT, false)
+	value.SetInt(10, false)
+	value.EnableHistory(100, 0, false)
+	var time_start int64 = harness.Clock.Now().UnixMilli()
+	harness.Clock.Advance(30 * time.Minute)
+	value.SetInt(40, false)
+	harness.Clock.Advance(10 * time.Minute)
+	value.SetInt(70, false)
+	harness.Clock.Advance(20 * time.Minute)
+
+	var stats HistoryStats = value.GetHistoryStats(time_start, harness.Clock.Now().UnixMilli())
+	if 3 != stats.Num_states || 10 != stats.Min || 70 != stats.Max {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	// (10*30 + 40*10 + 70*20) / 60
+	if 35 != stats.Average {
+		t.Fatalf("expected an average of 35, got %f", stats.Average)
+	}
+	if 10 * 60 * 1000 != stats.Time_in_state["40"] {
+		t.Fatalf("expected 10 minutes on 40, got %d ms", stats.Time_in_state["40"])
+	}
+
+	// The data at the start of a range counts from its start
+	stats = value.GetHistoryStats(time_start + 35 * 60 * 1000, harness.Clock.Now().UnixMilli())
+	if 2 != stats.Num_states || 5 * 60 * 1000 != stats.Time_in_state["40"] {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	var power_value *Value = RegisterValue("POWER", "Power", "Whether the power is connected", TYPE_BOOL, false)
+	power_value.EnableHistory(100, 0, false)
+	time_start = harness.Clock.Now().UnixMilli()
+	power_value.SetBool(true, false)
+	harness.Clock.Advance(time.Minute)
+	power_value.SetBool(false, false)
+	harness.Clock.Advance(3 * time.Minute)
+	stats = power_value.GetHistoryStats(time_start, harness.Clock.Now().UnixMilli())
+	if 60 * 1000 != stats.Time_in_state["true"] || 3 * 60 * 1000 != stats.Time_in_state["false"] || 0 != stats.Max {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHistorySpilled(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.EnableHistory(2, 0, true)
+	var time_start int64 = harness.Clock.Now().UnixMilli()
+	for i := 1; i <= 5; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+
+	var history []HistoryEntry = value.GetHistory(time_start, harness.Clock.Now().UnixMilli())
+	if 5 != len(history) || 1 != history[0].Data || 5 != history[4].Data {
+		t.Fatalf("expected all the entries, got %+v", history)
+	}
+
+	// Only the ones in memory are needed here
+	if history = value.GetHistory(time_start + 4 * 60 * 1000, harness.Clock.Now().UnixMilli()); 1 != len(history) {
+		t.Fatalf("expected the last entry, got %+v", history)
+	}
+}
+
+func TestHistorySavedOnShutdown(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.EnableHistory(3, 0, true)
+	var time_start int64 = harness.Clock.Now().UnixMilli()
+	for i := 1; i <= 5; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+	SaveHistory()
+
+	restartRegistry()
+	value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.SetInt(6, false)
+	value.EnableHistory(3, 0, true)
+	harness.Clock.Advance(time.Minute)
+
+	var history []HistoryEntry = value.GetHistory(time_start, harness.Clock.Now().UnixMilli())
+	if 6 != len(history) {
+		t.Fatalf("expected the entries from before and after the restart, got %+v", history)
+	}
+	for i, entry := range history {
+		if i + 1 != entry.Data {
+			t.Fatalf("expected the entries in order, got %+v", history)
+		}
+	}
+}
+
+func TestHistoryNotSpilledAgainAfterRestart(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, true)
+	value.EnableHistory(3, 0, true)
+	var time_start int64 = harness.Clock.Now().UnixMilli()
+	for i := 1; i <= 5; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+	SavePersistentValues()
+	SaveHistory()
+
+	// The current data is loaded back with its time, which is already in the spill file
+	restartRegistry()
+	value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, true)
+	value.EnableHistory(3, 0, true)
+	SaveHistory()
+
+	var p_spill *string = value.getSpillPath().ReadTextFile()
+	if nil == p_spill {
+		t.Fatal("expected the spill file to exist")
+	}
+	if 5 != strings.Count(*p_spill, "\n") {
+		t.Fatalf("expected each entry spilled once, got:\n%s", *p_spill)
+	}
+	if stats := value.GetHistoryStats(time_start, harness.Clock.Now().UnixMilli()); 5 != stats.Num_states {
+		t.Fatalf("expected 5 states, got %+v", stats)
+	}
+}
+
+func TestHistorySpillTrimmed(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	value.EnableHistory(2, 60 * 60, true)
+	for i := 1; i <= 100; i++ {
+		value.SetInt(i, false)
+		harness.Clock.Advance(time.Minute)
+	}
+	SaveHistory()
+
+	// The file only keeps the last hour, starting with the data the value had at its start
+	var spilled []_HistoryEntry = value.readSpilledHistory(math.MaxInt64)
+	if 60 != len(spilled) || "41" != spilled[0].Data {
+		t.Fatalf("expected the entries of the last hour, got %d starting on %v", len(spilled), spilled[0].Data)
+	}
+	if history := value.GetHistory(0, harness.Clock.Now().UnixMilli()); 60 != len(history) || 41 != history[0].Data {
+		t.Fatalf("expected the entries of the last hour, got %+v", history)
+	}
+}
@@ -23,6 +23,9 @@ package Registry
 
 import (
 	"Utils"
+	"errors"
+	"reflect"
+	"sync"
 )
 
 const TYPE_BOOL string = "TYPE_BOOL"
@@ -31,6 +34,15 @@ const TYPE_LONG string = "TYPE_LONG"
 const TYPE_FLOAT string = "TYPE_FLOAT"
 const TYPE_DOUBLE string = "TYPE_DOUBLE"
 const TYPE_STRING string = "TYPE_STRING"
+// TYPE_STRING_LIST is a list of strings ([]string)
+const TYPE_STRING_LIST string = "TYPE_STRING_LIST"
+// TYPE_TIME is a point in time (time.Time)
+const TYPE_TIME string = "TYPE_TIME"
+// TYPE_JSON is an object of a Go type declared on the registration, stored in JSON. Register with RegisterJsonValue().
+const TYPE_JSON string = "TYPE_JSON"
+// TYPE_ENUM is a string that must be one of the members declared on the registration. Register with
+// RegisterEnumValue().
+const TYPE_ENUM string = "TYPE_ENUM"
 
 // Value represents a value in the registry
 type Value struct {
@@ -42,6 +54,10 @@ type Value struct {
 	description string
 	// type_ is the type of the value
 	type_ string
+	// json_type is the Go type of the data, for TYPE_JSON
+	json_type reflect.Type
+	// enum_members are the allowed data, for TYPE_ENUM
+	enum_members []string
 
 	// prev_data is the previous data of the value
 	prev_data string
@@ -51,9 +67,52 @@ type Value struct {
 	curr_data string
 	// time_updated_curr is the time the data was updated in milliseconds
 	time_updated_curr int64
+
+	// persistent is whether the value is kept across restarts
+	persistent bool
+	// history is the history of the data, or nil if it's not kept
+	history *_History
+	// sync is the direction the value is synchronized between the server and the clients (one of the SYNC_ constants)
+	sync string
+	// version is the version of the data: at least the time it was set in milliseconds and always bigger than the
+	// previous ones and the ones received from other devices, so that the last change wins on the synchronization
+	version int64
+	// version_synced is the version last sent to or received from the other side of the synchronization
+	version_synced int64
+
+	// mutex protects the data and the update times, so that the previous and current ones change together
+	mutex sync.RWMutex
 }
 
-var registry_GL []*Value = nil
+// ValueSnapshot is a copy of a value at a point in time.
+type ValueSnapshot struct {
+	// Key is the key of the value
+	Key string
+	// Pretty_name is the pretty name of the value
+	Pretty_name string
+	// Description is the description of the value
+	Description string
+	// Type is the type of the value
+	Type string
+	// Persistent is whether the value is kept across restarts
+	Persistent bool
+	// Prev_data is the previous data of the value, of the type of the value (like with GetData())
+	Prev_data any
+	// Time_updated_prev is the time the previous data was updated in milliseconds
+	Time_updated_prev int64
+	// Curr_data is the current data of the value, of the type of the value (like with GetData())
+	Curr_data any
+	// Time_updated_curr is the time the data was updated in milliseconds
+	Time_updated_curr int64
+}
+
+var (
+	registry_mutex_GL sync.RWMutex
+	// registry_GL has the values by key
+	registry_GL map[string]*Value = make(map[string]*Value)
+	// registry_keys_GL has the keys of the values in the order they were registered
+	registry_keys_GL []string = nil
+)
 
 // There's an init() function on Keys.go
 
@@ -66,22 +125,103 @@ RegisterValue registers a value in the registry.
   - key – the key of the value
   - pretty_name – the pretty name of the value
   - description – the description of the value
-  - value_type – the type of the value
+  - value_type – the type of the value (TYPE_JSON and TYPE_ENUM values must be registered with RegisterJsonValue()
+    and RegisterEnumValue() - it panics with them here)
+  - persistent – true to keep the value across restarts (its data is saved on change and restored here from the last
+    time it was saved), false to keep it only in memory
 
 – Returns:
-  - the created value or nil if the value already exists
+  - the created value or the existing one if a value with the key already exists
 */
-func RegisterValue(key string, pretty_name string, description string, value_type string) *Value {
-	if value := GetValue(key); value != nil {
-		return value
+func RegisterValue(key string, pretty_name string, description string, value_type string, persistent bool) *Value {
+	if TYPE_JSON == value_type || TYPE_ENUM == value_type {
+		panic(errors.New("Registry: the value \"" + key + "\" is of type " + value_type +
+			" - register it with RegisterJsonValue() or RegisterEnumValue()"))
 	}
 
-	var value *Value = &Value{
+	return registerValue(&Value{
 		key:          key,
 		pretty_name:  pretty_name,
 		description:  description,
 		type_:        value_type,
+		persistent:   persistent,
+	})
+}
+
+/*
+RegisterJsonValue registers a TYPE_JSON value in the registry, whose data is of the type T.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the value
+  - pretty_name – the pretty name of the value
+  - description – the description of the value
+  - persistent – same as in RegisterValue()
+
+– Returns:
+  - the created value or the existing one if a value with the key already exists
+*/
+func RegisterJsonValue[T any](key string, pretty_name string, description string, persistent bool) *Value {
+	return registerValue(&Value{
+		key:          key,
+		pretty_name:  pretty_name,
+		description:  description,
+		type_:        TYPE_JSON,
+		json_type:    reflect.TypeOf((*T)(nil)).Elem(),
+		persistent:   persistent,
+	})
+}
+
+/*
+RegisterEnumValue registers a TYPE_ENUM value in the registry. Its data starts as the first member.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the value
+  - pretty_name – the pretty name of the value
+  - description – the description of the value
+  - members – the allowed data (at least one - it panics without any)
+  - persistent – same as in RegisterValue()
+
+– Returns:
+  - the created value or the existing one if a value with the key already exists
+*/
+func RegisterEnumValue(key string, pretty_name string, description string, members []string, persistent bool) *Value {
+	if 0 == len(members) {
+		panic(errors.New("Registry: the TYPE_ENUM value \"" + key + "\" has no members"))
+	}
+
+	return registerValue(&Value{
+		key:          key,
+		pretty_name:  pretty_name,
+		description:  description,
+		type_:        TYPE_ENUM,
+		enum_members: append([]string(nil), members...),
+		persistent:   persistent,
+	})
+}
+
+/*
+registerValue sets the default data of a new value and adds it to the registry.
+
+-----------------------------------------------------------
+
+– Params:
+  - value – the new value
+
+– Returns:
+  - the value or the existing one if a value with the key already exists
+*/
+func registerValue(value *Value) *Value {
+	registry_mutex_GL.Lock()
+	defer registry_mutex_GL.Unlock()
+
+	if existing_value, ok := registry_GL[value.key]; ok {
+		return existing_value
 	}
+	value.sync = SYNC_NONE
 
 	switch value.type_ {
 		case TYPE_BOOL:
@@ -95,22 +235,42 @@ func RegisterValue(key string, pretty_name string, description string, value_typ
 			value.curr_data = "-1"
 		case TYPE_STRING:
 			value.prev_data = ""
+		case TYPE_STRING_LIST: fallthrough
+		case TYPE_TIME: fallthrough
+		case TYPE_JSON:
+			value.prev_data = value.defaultData()
+			value.curr_data = value.prev_data
+		case TYPE_ENUM:
+			value.prev_data = value.enum_members[0]
+			value.curr_data = value.enum_members[0]
 	}
 
+	if value.persistent {
+		restorePersistentValue(value)
+	}
 
-	registry_GL = append(registry_GL, value)
+	registry_GL[value.key] = value
+	registry_keys_GL = append(registry_keys_GL, value.key)
 
 	return value
 }
 
+/*
+GetValue gets a value from the registry based on its key.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the value
+
+– Returns:
+  - the value or nil if there's no value with the key
+*/
 func GetValue(key string) *Value {
-	for _, value := range registry_GL {
-		if value.key == key {
-			return value
-		}
-	}
+	registry_mutex_GL.RLock()
+	defer registry_mutex_GL.RUnlock()
 
-	return nil
+	return registry_GL[key]
 }
 
 /*
@@ -122,26 +282,73 @@ RemoveValue removes a value from the registry based on its key.
   - key – the key of the value
  */
 func RemoveValue(key string) {
-	for i, value := range registry_GL {
-		if value.key == key {
-			registry_GL = append(registry_GL[:i], registry_GL[i+1:]...)
+	registry_mutex_GL.Lock()
+	defer registry_mutex_GL.Unlock()
+
+	if _, ok := registry_GL[key]; !ok {
+		return
+	}
 
-			return
+	delete(registry_GL, key)
+	for i, registry_key := range registry_keys_GL {
+		if registry_key == key {
+			registry_keys_GL = append(registry_keys_GL[:i], registry_keys_GL[i+1:]...)
+
+			break
 		}
 	}
 }
 
+/*
+GetRegistrySnapshot gets a consistent copy of all the values: no value changes while the copy is made, so it's what
+the registry had at one point in time.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the copies of the values in the order they were registered
+*/
+func GetRegistrySnapshot() []ValueSnapshot {
+	registry_mutex_GL.RLock()
+	defer registry_mutex_GL.RUnlock()
+
+	var values []*Value = make([]*Value, 0, len(registry_keys_GL))
+	for _, key := range registry_keys_GL {
+		var value *Value = registry_GL[key]
+		value.mutex.RLock()
+		values = append(values, value)
+	}
+
+	var snapshot []ValueSnapshot = make([]ValueSnapshot, 0, len(values))
+	for _, value := range values {
+		snapshot = append(snapshot, ValueSnapshot{
+			Key:               value.key,
+			Pretty_name:       value.pretty_name,
+			Description:       value.description,
+			Type:              value.type_,
+			Persistent:        value.persistent,
+			Prev_data:         value.typedData(value.prev_data),
+			Time_updated_prev: value.time_updated_prev,
+			Curr_data:         value.typedData(value.curr_data),
+			Time_updated_curr: value.time_updated_curr,
+		})
+		value.mutex.RUnlock()
+	}
+
+	return snapshot
+}
+
 func GetRegistryText() string {
 	var text string = ""
 
-	for _, value := range registry_GL {
-		text += "Name: " + value.pretty_name + "\n" +
-				"Type: " + value.type_ + "\n" +
-				"Prev time: " + Utils.GetDateTimeStrTIMEDATE(value.time_updated_prev) + "\n" +
-				"Prev data: " + value.prev_data + "\n" +
-				"Curr time: " + Utils.GetDateTimeStrTIMEDATE(value.time_updated_curr) + "\n" +
-				"Curr data: " + value.curr_data + "\n" +
-				"Description: " + value.description + "\n\n"
+	for _, value := range GetRegistrySnapshot() {
+		text += "Name: " + value.Pretty_name + "\n" +
+				"Type: " + value.Type + "\n" +
+				"Prev time: " + Utils.GetDateTimeStrTIMEDATE(value.Time_updated_prev) + "\n" +
+				"Prev data: " + formatData(value.Prev_data) + "\n" +
+				"Curr time: " + Utils.GetDateTimeStrTIMEDATE(value.Time_updated_curr) + "\n" +
+				"Curr data: " + formatData(value.Curr_data) + "\n" +
+				"Description: " + value.Description + "\n\n"
 	}
 
 	return text
@@ -0,0 +1,111 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRegisterGetRemoveValue(t *testing.T) {
+	newHarness(t)
+
+	for i := 0; i < 3; i++ {
+		RegisterValue("KEY_" + strconv.Itoa(i), "Key " + strconv.Itoa(i), "A value", TYPE_INT, false)
+	}
+	if value := RegisterValue("KEY_1", "Other", "Another value", TYPE_BOOL, false); TYPE_INT != value.GetType() {
+		t.Fatal("registering an existing key didn't return the existing value")
+	}
+
+	RemoveValue("KEY_1")
+	if nil != GetValue("KEY_1") || nil == GetValue("KEY_2") {
+		t.Fatal("wrong value removed")
+	}
+
+	var snapshot []ValueSnapshot = GetRegistrySnapshot()
+	if 2 != len(snapshot) || "KEY_0" != snapshot[0].Key || "KEY_2" != snapshot[1].Key {
+		t.Fatalf("expected KEY_0 and KEY_2 in order, got %+v", snapshot)
+	}
+}
+
+func TestRegisterWrongFunctionPanics(t *testing.T) {
+	newHarness(t)
+
+	for _, register := range []func(){
+		func() {RegisterValue("OBJECT", "Object", "An object", TYPE_JSON, false)},
+		func() {RegisterValue("MODE", "Mode", "A mode", TYPE_ENUM, false)},
+		func() {RegisterEnumValue("MODE", "Mode", "A mode", nil, false)},
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal("an invalid registration didn't panic")
+				}
+			}()
+
+			register()
+		}()
+	}
+	if nil != GetValue("OBJECT") || nil != GetValue("MODE") {
+		t.Fatal("an invalid value was registered")
+	}
+}
+
+func TestConcurrentAccessConsistent(t *testing.T) {
+	newHarness(t)
+
+	var value *Value = RegisterValue("COUNTER", "Counter", "A counter", TYPE_INT, false)
+	value.SetInt(0, false)
+
+	var wait_group sync.WaitGroup
+	wait_group.Add(2)
+	go func() {
+		defer wait_group.Done()
+
+		for i := 1; i <= 1000; i++ {
+			value.SetInt(i, false)
+		}
+	}()
+	go func() {
+		defer wait_group.Done()
+
+		for i := 0; i < 1000; i++ {
+			RegisterValue("OTHER_" + strconv.Itoa(i % 10), "Other", "Another value", TYPE_BOOL, false).SetBool(0 == i % 2,
+				false)
+		}
+	}()
+
+	// The previous data must always be the one right before the current one
+	for i := 0; i < 1000; i++ {
+		for _, value_snapshot := range GetRegistrySnapshot() {
+			if "COUNTER" == value_snapshot.Key && value_snapshot.Curr_data.(int) != value_snapshot.Prev_data.(int) + 1 {
+				t.Fatalf("inconsistent snapshot %+v", value_snapshot)
+			}
+		}
+	}
+	wait_group.Wait()
+
+	if 1000 != value.GetInt(true) || 999 != value.GetInt(false) {
+		t.Fatalf("expected 1000 after 999, got %d after %d", value.GetInt(true), value.GetInt(false))
+	}
+}
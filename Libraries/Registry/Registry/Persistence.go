@@ -0,0 +1,275 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _PERSIST_FILE is the name of the file with the persistent values, inside VISOR's user data directory.
+const _PERSIST_FILE string = "registry.json"
+// _PERSIST_FILE_TMP is the name of the temporary file used to replace _PERSIST_FILE.
+const _PERSIST_FILE_TMP string = "registry.json_tmp"
+// _PERSIST_JOURNAL is the name of the journal with the changes after _PERSIST_FILE was written, one line per save.
+const _PERSIST_JOURNAL string = "registry_journal.jsonl"
+
+// _PERSIST_DEBOUNCE is the time to wait after a change before saving, so that values changing often are saved once.
+const _PERSIST_DEBOUNCE time.Duration = 2 * time.Second
+// _PERSIST_MAX_JOURNAL is the number of lines of the journal after which it's compacted into _PERSIST_FILE.
+const _PERSIST_MAX_JOURNAL int = 100
+
+// _PersistedValue is the saved state of a persistent value.
+type _PersistedValue struct {
+	Type              string
+	Prev_data         string
+	Time_updated_prev int64
+	Curr_data         string
+	Time_updated_curr int64
+}
+
+var (
+	persist_mutex_GL sync.Mutex
+	// persist_loaded_GL is whether persisted_GL was loaded already
+	persist_loaded_GL bool = false
+	// persisted_GL are the values as saved on the disk
+	persisted_GL map[string]_PersistedValue = nil
+	// pending_GL are the changed values waiting to be saved
+	pending_GL map[string]_PersistedValue = nil
+	// journal_lines_GL is the number of lines of the journal
+	journal_lines_GL int = 0
+	// save_timer_GL is the timer of the next save, or nil if none is scheduled
+	save_timer_GL *time.Timer = nil
+)
+
+/*
+SavePersistentValues saves right away the changes to the persistent values still waiting to be saved. It's called
+automatically when VISOR shuts down.
+*/
+func SavePersistentValues() {
+	persist_mutex_GL.Lock()
+	defer persist_mutex_GL.Unlock()
+
+	if nil != save_timer_GL {
+		save_timer_GL.Stop()
+		save_timer_GL = nil
+	}
+	if 0 == len(pending_GL) {
+		return
+	}
+
+	var err error
+	if journal_lines_GL >= _PERSIST_MAX_JOURNAL {
+		err = compactPersistedValues()
+	} else {
+		err = journalPersistedValues()
+	}
+	if nil != err {
+		// The changes stay pending, to be saved on the next change or on the shutdown
+		Utils.GetModLoggerLOGS(Utils.NUM_MOD_VISOR).Warning("Error saving the persistent registry values", "error",
+			err)
+	}
+}
+
+/*
+restorePersistentValue restores the data of a persistent value from the last time it was saved, if it was saved with the
+same type and the data is still valid for it.
+
+-----------------------------------------------------------
+
+– Params:
+  - value – the value
+*/
+func restorePersistentValue(value *Value) {
+	persist_mutex_GL.Lock()
+	defer persist_mutex_GL.Unlock()
+
+	loadPersistedValues()
+
+	persisted_value, ok := pending_GL[value.key]
+	if !ok {
+		persisted_value, ok = persisted_GL[value.key]
+	}
+	if !ok || persisted_value.Type != value.type_ {
+		return
+	}
+	// Like for a TYPE_JSON value whose Go type changed or a TYPE_ENUM one whose members changed
+	if _, err := value.decodeData(persisted_value.Curr_data); nil != err {
+		return
+	}
+	if _, err := value.decodeData(persisted_value.Prev_data); nil != err {
+		return
+	}
+
+	value.prev_data = persisted_value.Prev_data
+	value.time_updated_prev = persisted_value.Time_updated_prev
+	value.curr_data = persisted_value.Curr_data
+	value.time_updated_curr = persisted_value.Time_updated_curr
+}
+
+/*
+savePersistentValue schedules the saving of a persistent value that changed. Call with the value's mutex locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - value – the value
+*/
+func savePersistentValue(value *Value) {
+	persist_mutex_GL.Lock()
+	defer persist_mutex_GL.Unlock()
+
+	loadPersistedValues()
+
+	pending_GL[value.key] = _PersistedValue{
+		Type:              value.type_,
+		Prev_data:         value.prev_data,
+		Time_updated_prev: value.time_updated_prev,
+		Curr_data:         value.curr_data,
+		Time_updated_curr: value.time_updated_curr,
+	}
+	if nil == save_timer_GL {
+		save_timer_GL = time.AfterFunc(_PERSIST_DEBOUNCE, SavePersistentValues)
+	}
+}
+
+/*
+loadPersistedValues loads the saved values from the file and its journal, if they weren't loaded yet, and makes sure
+they're saved on the shutdown. Call with persist_mutex_GL locked.
+*/
+func loadPersistedValues() {
+	if persist_loaded_GL {
+		return
+	}
+	persist_loaded_GL = true
+	persisted_GL = make(map[string]_PersistedValue)
+	pending_GL = make(map[string]_PersistedValue)
+	journal_lines_GL = 0
+
+	var user_data_dir Utils.GPath = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR)
+	if p_file := user_data_dir.Add2(false, _PERSIST_FILE).ReadFile(); nil != p_file {
+		if err := Utils.FromJsonGENERAL(p_file, &persisted_GL); nil != err {
+			Utils.GetModLoggerLOGS(Utils.NUM_MOD_VISOR).Warning("Persistent registry values file unusable - " +
+				"ignoring it", "error", err)
+			persisted_GL = make(map[string]_PersistedValue)
+		}
+	}
+
+	// Each line of the journal has the values changed on one save. An incomplete last line (from a crash while writing
+	// it) is ignored.
+	if p_journal := user_data_dir.Add2(false, _PERSIST_JOURNAL).ReadTextFile(); nil != p_journal {
+		for _, line := range strings.Split(*p_journal, "\n") {
+			if "" == line {
+				continue
+			}
+			journal_lines_GL++
+
+			var changed_values map[string]_PersistedValue
+			if err := json.Unmarshal([]byte(line), &changed_values); nil != err {
+				continue
+			}
+			for key, persisted_value := range changed_values {
+				persisted_GL[key] = persisted_value
+			}
+		}
+	}
+
+	Utils.AddShutdownHookMODULES(SavePersistentValues)
+}
+
+/*
+journalPersistedValues appends the pending values to the journal. Call with persist_mutex_GL locked.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the values were saved, an error otherwise
+*/
+func journalPersistedValues() error {
+	line, err := json.Marshal(pending_GL)
+	if nil != err {
+		return err
+	}
+
+	var journal_path Utils.GPath = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR).Add2(false, _PERSIST_JOURNAL)
+	if err = journal_path.Create(true); nil != err {
+		return err
+	}
+	if err = Utils.GetFileSystemFILESDIRS().WriteFile(journal_path.GPathToStringConversion(), append(line, '\n'), true,
+			true); nil != err {
+		return err
+	}
+	journal_lines_GL++
+
+	for key, persisted_value := range pending_GL {
+		persisted_GL[key] = persisted_value
+	}
+	pending_GL = make(map[string]_PersistedValue)
+
+	return nil
+}
+
+/*
+compactPersistedValues writes all the values (saved and pending) to the file, replacing it, and empties the journal.
+Call with persist_mutex_GL locked.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the values were saved, an error otherwise
+*/
+func compactPersistedValues() error {
+	var all_values map[string]_PersistedValue = make(map[string]_PersistedValue, len(persisted_GL) + len(pending_GL))
+	for key, persisted_value := range persisted_GL {
+		all_values[key] = persisted_value
+	}
+	for key, persisted_value := range pending_GL {
+		all_values[key] = persisted_value
+	}
+
+	var user_data_dir Utils.GPath = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR)
+	if err := user_data_dir.Create(false); nil != err {
+		return err
+	}
+
+	var file_system Utils.FileSystem = Utils.GetFileSystemFILESDIRS()
+	var file_path string = user_data_dir.Add2(false, _PERSIST_FILE).GPathToStringConversion()
+	var file_path_tmp string = user_data_dir.Add2(false, _PERSIST_FILE_TMP).GPathToStringConversion()
+	if err := file_system.WriteFile(file_path_tmp, []byte(*Utils.ToJsonGENERAL(all_values)), false, true); nil != err {
+		return err
+	}
+	if err := file_system.Rename(file_path_tmp, file_path); nil != err {
+		return err
+	}
+
+	// Only now that the file has everything
+	_ = file_system.Remove(user_data_dir.Add2(false, _PERSIST_JOURNAL).GPathToStringConversion())
+	journal_lines_GL = 0
+
+	persisted_GL = all_values
+	pending_GL = make(map[string]_PersistedValue)
+
+	return nil
+}
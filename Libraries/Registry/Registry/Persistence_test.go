@@ -0,0 +1,147 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils"
+	"Utils/UtilsTest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newHarness(t *testing.T) *UtilsTest.TestHarness[any] {
+	var harness *UtilsTest.TestHarness[any] = UtilsTest.NewTestHarnessTESTING[any](Utils.NUM_MOD_VISOR,
+		Utils.UserSettings{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	t.Cleanup(func() {
+		restartRegistry()
+		harness.Close()
+	})
+	restartRegistry()
+
+	return harness
+}
+
+// restartRegistry empties the registry as if VISOR restarted (without saving the pending values and history).
+func restartRegistry() {
+	persist_mutex_GL.Lock()
+	defer persist_mutex_GL.Unlock()
+
+	if nil != save_timer_GL {
+		save_timer_GL.Stop()
+		save_timer_GL = nil
+	}
+	persist_loaded_GL = false
+
+	history_mutex_GL.Lock()
+	defer history_mutex_GL.Unlock()
+
+	if nil != spill_timer_GL {
+		spill_timer_GL.Stop()
+		spill_timer_GL = nil
+	}
+	spilled_values_GL = nil
+
+	registry_mutex_GL.Lock()
+	defer registry_mutex_GL.Unlock()
+
+	registry_GL = make(map[string]*Value)
+	registry_keys_GL = nil
+}
+
+func TestPersistentValueRestored(t *testing.T) {
+	newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, true)
+	RegisterValue("SIGNAL", "Signal", "A signal", TYPE_BOOL, false).SetBool(true, false)
+	value.SetInt(50, false)
+	value.SetInt(49, false)
+	var time_updated_prev int64 = value.GetTimeUpdated(false)
+	var time_updated_curr int64 = value.GetTimeUpdated(true)
+	SavePersistentValues()
+
+	restartRegistry()
+	value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, true)
+	if 49 != value.GetInt(true) || 50 != value.GetInt(false) {
+		t.Fatalf("expected 49 after 50, got %d after %d", value.GetInt(true), value.GetInt(false))
+	}
+	if time_updated_curr != value.GetTimeUpdated(true) || time_updated_prev != value.GetTimeUpdated(false) {
+		t.Fatal("the update times weren't restored")
+	}
+	if RegisterValue("SIGNAL", "Signal", "A signal", TYPE_BOOL, false).GetBool(true) {
+		t.Fatal("a value not persistent was restored")
+	}
+}
+
+func TestPersistentValuesJournalCompacted(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+
+	var value *Value = RegisterValue("SPEECH", "Speech", "The last speech", TYPE_STRING, true)
+	for i := 0; i <= _PERSIST_MAX_JOURNAL + 1; i++ {
+		value.SetString("Speech " + strconv.Itoa(i), false)
+		SavePersistentValues()
+	}
+	var user_data_dir Utils.GPath = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_VISOR)
+	if _, err := harness.File_system.Stat(user_data_dir.Add2(false, _PERSIST_FILE).GPathToStringConversion());
+			nil != err {
+		t.Fatal("the journal wasn't compacted into the file: " + err.Error())
+	}
+
+	restartRegistry()
+	value = RegisterValue("SPEECH", "Speech", "The last speech", TYPE_STRING, true)
+	var expected string = "Speech " + strconv.Itoa(_PERSIST_MAX_JOURNAL + 1)
+	if expected != value.GetString(true) {
+		t.Fatalf("expected \"%s\", got \"%s\"", expected, value.GetString(true))
+	}
+}
+
+func TestPersistentValueOtherTypeIgnored(t *testing.T) {
+	newHarness(t)
+
+	RegisterValue("LEVEL", "Level", "A level", TYPE_STRING, true).SetString("high", false)
+	SavePersistentValues()
+
+	restartRegistry()
+	if level := RegisterValue("LEVEL", "Level", "A level", TYPE_INT, true).GetInt(true); -1 != level {
+		t.Fatalf("expected the default value for a value registered with another type, got %d", level)
+	}
+}
+
+func TestPersistentValueInvalidDataIgnored(t *testing.T) {
+	newHarness(t)
+
+	RegisterValue("LEVEL", "Level", "A level", TYPE_INT, true).SetInt(5, false)
+	SavePersistentValues()
+
+	restartRegistry()
+	persist_mutex_GL.Lock()
+	loadPersistedValues()
+	var persisted_value _PersistedValue = persisted_GL["LEVEL"]
+	persisted_value.Curr_data = "five"
+	persisted_GL["LEVEL"] = persisted_value
+	persist_mutex_GL.Unlock()
+
+	// Reading it would give -1 anyway, so check what was restored
+	if value := RegisterValue("LEVEL", "Level", "A level", TYPE_INT, true); "-1" != value.curr_data {
+		t.Fatalf("expected the default value for invalid saved data, got \"%s\"", value.curr_data)
+	}
+}
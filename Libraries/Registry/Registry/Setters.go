@@ -22,20 +22,76 @@
 package Registry
 
 import (
+	"Utils"
 	"strconv"
-	"time"
 )
 
 /*
-setInternal sets the internal variables for the value.
+setInternal sets the new data and the internal variables for the value atomically.
+
+-----------------------------------------------------------
+
+- Params:
+  - new_data – the data to set
+  - update_if_same – whether to still update if the data is the same
+
+- Returns:
+  - whether the data was set
  */
-func (value *Value) setInternal(new_data string) {
-	if value.curr_data != new_data {
+func (value *Value) setInternal(new_data string, update_if_same bool) bool {
+	value.mutex.Lock()
+	defer value.mutex.Unlock()
+
+	if !update_if_same && value.curr_data == new_data {
+		return false
+	}
+
+	var time_updated int64 = Utils.GetClockTIMEDATE().Now().UnixMilli()
+	var version int64 = value.version + 1
+	if time_updated > version {
+		version = time_updated
+	}
+	value.storeData(new_data, time_updated, version)
+
+	return true
+}
+
+/*
+storeData stores new data of the value, saving it if it's persistent and adding it to the history and notifying its
+watches if the data changed. Call with the value's mutex locked.
+
+-----------------------------------------------------------
+
+- Params:
+  - new_data – the data to store
+  - time_updated – the time the data was set in milliseconds
+  - version – the version of the data
+ */
+func (value *Value) storeData(new_data string, time_updated int64, version int64) {
+	var changed bool = value.curr_data != new_data
+	if changed {
 		value.prev_data = value.curr_data
 		value.time_updated_prev = value.time_updated_curr
 	}
 
-	value.time_updated_curr = time.Now().UnixMilli()
+	value.time_updated_curr = time_updated
+	value.curr_data = new_data
+	value.version = version
+
+	if changed && nil != value.history {
+		value.addHistoryEntry(_HistoryEntry{
+			Data: new_data,
+			Time: value.time_updated_curr,
+		})
+	}
+
+	if value.persistent {
+		savePersistentValue(value)
+	}
+
+	if changed {
+		value.notifyChange()
+	}
 }
 
 /*
@@ -55,22 +111,7 @@ func (value *Value) SetBool(data bool, update_if_same bool) bool {
 		return false
 	}
 
-	var data_str string = strconv.FormatBool(data)
-	if !update_if_same && value.curr_data == data_str {
-		return false
-	}
-
-	var new_data string
-	if data {
-		new_data = "true"
-	} else {
-		new_data = "false"
-	}
-
-	value.setInternal(new_data)
-	value.curr_data = new_data
-
-	return true
+	return value.setInternal(strconv.FormatBool(data), update_if_same)
 }
 
 /*
@@ -90,17 +131,7 @@ func (value *Value) SetInt(data int, update_if_same bool) bool {
 		return false
 	}
 
-	var data_str string = strconv.Itoa(data)
-	if !update_if_same && value.curr_data == data_str {
-		return false
-	}
-
-	var new_data string = strconv.Itoa(data)
-
-	value.setInternal(new_data)
-	value.curr_data = new_data
-
-	return true
+	return value.setInternal(strconv.Itoa(data), update_if_same)
 }
 
 /*
@@ -120,17 +151,7 @@ func (value *Value) SetLong(data int64, update_if_same bool) bool {
 		return false
 	}
 
-	var data_str string = strconv.FormatInt(data, 10)
-	if !update_if_same && value.curr_data == data_str {
-		return false
-	}
-
-	var new_data string = strconv.FormatInt(data, 10)
-
-	value.setInternal(new_data)
-	value.curr_data = new_data
-
-	return true
+	return value.setInternal(strconv.FormatInt(data, 10), update_if_same)
 }
 
 /*
@@ -150,17 +171,7 @@ func (value *Value) SetFloat(data float32, update_if_same bool) bool {
 		return false
 	}
 
-	var data_str string = strconv.FormatFloat(float64(data), 'f', -1, 32)
-	if !update_if_same && value.curr_data == data_str {
-		return false
-	}
-
-	var new_data string = strconv.FormatFloat(float64(data), 'f', -1, 32)
-
-	value.setInternal(new_data)
-	value.curr_data = new_data
-
-	return true
+	return value.setInternal(strconv.FormatFloat(float64(data), 'f', -1, 32), update_if_same)
 }
 
 /*
@@ -180,17 +191,7 @@ func (value *Value) SetDouble(data float64, update_if_same bool) bool {
 		return false
 	}
 
-	var data_str string = strconv.FormatFloat(data, 'f', -1, 64)
-	if !update_if_same && value.curr_data == data_str {
-		return false
-	}
-
-	var new_data string = strconv.FormatFloat(data, 'f', -1, 64)
-
-	value.setInternal(new_data)
-	value.curr_data = new_data
-
-	return true
+	return value.setInternal(strconv.FormatFloat(data, 'f', -1, 64), update_if_same)
 }
 
 /*
@@ -210,18 +211,11 @@ func (value *Value) SetString(data string, update_if_same bool) bool {
 		return false
 	}
 
-	if !update_if_same && value.curr_data == data {
-		return false
-	}
-
-	value.setInternal(data)
-	value.curr_data = data
-
-	return true
+	return value.setInternal(data, update_if_same)
 }
 
 /*
-SetData sets the value and converts it to the right type automatically.
+SetData sets the value to data of the Go type of the value's data (see GetTyped()).
 
 -----------------------------------------------------------
 
@@ -230,24 +224,30 @@ SetData sets the value and converts it to the right type automatically.
   - update_if_same – whether to still update if the data is the same
 
 - Returns:
-  - whether the data was set
+  - whether the data was set (false if it isn't of the value's type or, for TYPE_ENUM, isn't a member)
  */
 func (value *Value) SetData(data any, update_if_same bool) bool {
-	switch value.type_ {
-		case TYPE_BOOL:
-			return value.SetBool(data.(bool), update_if_same)
-		case TYPE_INT:
-			return value.SetInt(data.(int), update_if_same)
-		case TYPE_LONG:
-			return value.SetLong(data.(int64), update_if_same)
-		case TYPE_FLOAT:
-			return value.SetFloat(data.(float32), update_if_same)
-		case TYPE_DOUBLE:
-			return value.SetDouble(data.(float64), update_if_same)
-		case TYPE_STRING:
-			return value.SetString(data.(string), update_if_same)
+	new_data, err := value.encodeData(data)
+	if nil != err {
+		return false
 	}
 
-	// Won't happen
-	return false
+	return value.setInternal(new_data, update_if_same)
+}
+
+/*
+SetTyped sets the value to data of the type T, which must be the Go type of the value's data (see GetTyped()).
+
+-----------------------------------------------------------
+
+- Params:
+  - value – the value
+  - data – the data to set
+  - update_if_same – whether to still update if the data is the same
+
+- Returns:
+  - whether the data was set (false if T isn't the type of the value's data or, for TYPE_ENUM, the data isn't a member)
+ */
+func SetTyped[T any](value *Value, data T, update_if_same bool) bool {
+	return value.SetData(data, update_if_same)
 }
@@ -0,0 +1,374 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// SYNC_NONE is for values that aren't synchronized (the default).
+const SYNC_NONE string = "SYNC_NONE"
+// SYNC_TO_SERVER is for client values whose changes are sent to the server. The server only accepts the keys it has
+// registered with this too, and keeps the data of each device in another value of the same type, with the key from
+// GetDeviceValueKey().
+const SYNC_TO_SERVER string = "SYNC_TO_SERVER"
+// SYNC_TO_CLIENTS is for server values whose changes are sent to the clients that have them registered with this too.
+const SYNC_TO_CLIENTS string = "SYNC_TO_CLIENTS"
+// SYNC_BOTH is for values shared by the server and the clients that have them registered with this too, whose changes
+// are sent both ways. The last change wins.
+const SYNC_BOTH string = "SYNC_BOTH"
+
+// _SYNC_MAX_DEVICES is the maximum number of devices the server keeps a SYNC_TO_SERVER value of.
+const _SYNC_MAX_DEVICES int = 32
+
+// device_values_mutex_GL makes the registrations of the devices' values one at a time, so that they're not more than
+// _SYNC_MAX_DEVICES.
+var device_values_mutex_GL sync.Mutex
+
+// ValueUpdate is the data of a value sent to the other side of the synchronization.
+type ValueUpdate struct {
+	// Key is the key of the value
+	Key string
+	// Pretty_name is the pretty name of the value
+	Pretty_name string
+	// Type is the type of the value
+	Type string
+	// Sync is the direction the value is synchronized (one of the SYNC_ constants)
+	Sync string
+	// Data is the data as it's stored
+	Data string
+	// Time_updated is the time the data was set in milliseconds
+	Time_updated int64
+	// Version is the version of the data (the update with the biggest one wins)
+	Version int64
+}
+
+// SyncRequest is what a client sends to the server to synchronize.
+type SyncRequest struct {
+	// Updates are the changes of the client's SYNC_TO_SERVER and SYNC_BOTH values since the last synchronization
+	Updates []ValueUpdate
+	// Versions are the versions the client has of its SYNC_TO_CLIENTS and SYNC_BOTH values, by key
+	Versions map[string]int64
+}
+
+/*
+EnableSync sets the direction the value is synchronized between the server and the clients. The clients synchronize with
+SyncWithServer() and the server answers with HandleSyncRequest().
+
+-----------------------------------------------------------
+
+– Params:
+  - direction – one of the SYNC_ constants
+*/
+func (value *Value) EnableSync(direction string) {
+	value.mutex.Lock()
+	defer value.mutex.Unlock()
+
+	value.sync = direction
+}
+
+/*
+GetDeviceValueKey gets the key the server keeps a SYNC_TO_SERVER value of a client with.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the value on the client
+  - device_id – the ID of the client device
+
+– Returns:
+  - the key on the server ("KEY@device_id", so WatchValues("KEY@*") watches the value of all the devices)
+*/
+func GetDeviceValueKey(key string, device_id string) string {
+	return key + "@" + device_id
+}
+
+/*
+SyncWithServer sends the changes of the SYNC_TO_SERVER and SYNC_BOTH values to the server and gets the changes of the
+SYNC_TO_CLIENTS and SYNC_BOTH ones, through the Website Backend.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to cancel the request with
+
+– Returns:
+  - nil if the synchronization was done, an error otherwise (the changes not sent are sent on the next one)
+*/
+func SyncWithServer(ctx context.Context) error {
+	var request SyncRequest = SyncRequest{
+		Versions: make(map[string]int64),
+	}
+	for _, value := range getSyncedValues() {
+		value.mutex.RLock()
+		if (SYNC_TO_SERVER == value.sync || SYNC_BOTH == value.sync) && value.version > value.version_synced {
+			request.Updates = append(request.Updates, value.getUpdate())
+		}
+		if SYNC_TO_CLIENTS == value.sync || SYNC_BOTH == value.sync {
+			request.Versions[value.key] = value.version
+		}
+		value.mutex.RUnlock()
+	}
+
+	response, err := Utils.SubmitFormWEBSITE(ctx, Utils.WebsiteForm{
+		Type:  "Registry",
+		Text1: Utils.GetUserSettingsSETTINGS().PersonalConsts.Device_ID,
+		Text2: *Utils.ToJsonGENERAL(request),
+	})
+	if nil != err {
+		return err
+	}
+	var updates []ValueUpdate
+	if err = json.Unmarshal(response, &updates); nil != err {
+		return errors.New("invalid synchronization response: " + err.Error())
+	}
+
+	for _, update := range request.Updates {
+		var value *Value = GetValue(update.Key)
+		if nil == value {
+			continue
+		}
+
+		value.mutex.Lock()
+		if value.version_synced < update.Version {
+			value.version_synced = update.Version
+		}
+		value.mutex.Unlock()
+	}
+	for _, update := range updates {
+		var value *Value = GetValue(update.Key)
+		if nil == value || (SYNC_TO_CLIENTS != value.getSync() && SYNC_BOTH != value.getSync()) {
+			continue
+		}
+
+		value.applyUpdate(update)
+	}
+
+	return nil
+}
+
+/*
+HandleSyncRequest handles on the server the synchronization request of a client: stores its changes (a SYNC_TO_SERVER
+value the server accepts is registered the first time it's received) and gets the ones of the server it doesn't have.
+
+-----------------------------------------------------------
+
+– Params:
+  - device_id – the ID of the client device
+  - request – the request of the client
+
+– Returns:
+  - the changes for the client
+*/
+func HandleSyncRequest(device_id string, request SyncRequest) []ValueUpdate {
+	for _, update := range request.Updates {
+		var value *Value = nil
+		switch update.Sync {
+			case SYNC_TO_SERVER:
+				value = registerDeviceValue(device_id, update)
+			case SYNC_BOTH:
+				value = GetValue(update.Key)
+				if nil != value && SYNC_BOTH != value.getSync() {
+					value = nil
+				}
+		}
+		if nil != value {
+			value.applyUpdate(update)
+		}
+	}
+
+	var updates []ValueUpdate = []ValueUpdate{}
+	for key, version := range request.Versions {
+		var value *Value = GetValue(key)
+		if nil == value {
+			continue
+		}
+
+		value.mutex.RLock()
+		if (SYNC_TO_CLIENTS == value.sync || SYNC_BOTH == value.sync) && value.version > version {
+			updates = append(updates, value.getUpdate())
+		}
+		value.mutex.RUnlock()
+	}
+
+	return updates
+}
+
+/*
+registerDeviceValue registers on the server the SYNC_TO_SERVER value of a client, if it's not registered yet, with the
+type of the value the server registered with the same key.
+
+-----------------------------------------------------------
+
+– Params:
+  - device_id – the ID of the client device
+  - update – an update of the value
+
+– Returns:
+  - the value or nil if the server doesn't accept the key or already keeps it for _SYNC_MAX_DEVICES devices
+*/
+func registerDeviceValue(device_id string, update ValueUpdate) *Value {
+	var declared_value *Value = GetValue(update.Key)
+	if nil == declared_value || SYNC_TO_SERVER != declared_value.getSync() {
+		return nil
+	}
+
+	device_values_mutex_GL.Lock()
+	defer device_values_mutex_GL.Unlock()
+
+	var key string = GetDeviceValueKey(update.Key, device_id)
+	if value := GetValue(key); nil != value {
+		return value
+	}
+	if countDeviceValues(update.Key) >= _SYNC_MAX_DEVICES {
+		return nil
+	}
+
+	return registerValue(&Value{
+		key:          key,
+		pretty_name:  declared_value.pretty_name + " (" + device_id + ")",
+		description:  "Synchronized from the device \"" + device_id + "\"",
+		type_:        declared_value.type_,
+		json_type:    declared_value.json_type,
+		enum_members: declared_value.enum_members,
+	})
+}
+
+/*
+countDeviceValues counts the devices the server keeps a SYNC_TO_SERVER value of.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the value on the clients
+
+– Returns:
+  - the number of devices
+*/
+func countDeviceValues(key string) int {
+	registry_mutex_GL.RLock()
+	defer registry_mutex_GL.RUnlock()
+
+	var count int = 0
+	for _, registered_key := range registry_keys_GL {
+		if strings.HasPrefix(registered_key, GetDeviceValueKey(key, "")) {
+			count++
+		}
+	}
+
+	return count
+}
+
+/*
+getSyncedValues gets the values that are synchronized.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the values
+*/
+func getSyncedValues() []*Value {
+	registry_mutex_GL.RLock()
+	defer registry_mutex_GL.RUnlock()
+
+	var values []*Value = nil
+	for _, key := range registry_keys_GL {
+		var value *Value = registry_GL[key]
+		if SYNC_NONE != value.getSync() {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+/*
+getSync gets the direction the value is synchronized.
+
+-----------------------------------------------------------
+
+– Returns:
+  - one of the SYNC_ constants
+*/
+func (value *Value) getSync() string {
+	value.mutex.RLock()
+	defer value.mutex.RUnlock()
+
+	return value.sync
+}
+
+/*
+getUpdate gets the update to send with the current data of the value. Call with the value's mutex locked.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the update
+*/
+func (value *Value) getUpdate() ValueUpdate {
+	return ValueUpdate{
+		Key:          value.key,
+		Pretty_name:  value.pretty_name,
+		Type:         value.type_,
+		Sync:         value.sync,
+		Data:         value.curr_data,
+		Time_updated: value.time_updated_curr,
+		Version:      value.version,
+	}
+}
+
+/*
+applyUpdate stores the data of an update received from the other side of the synchronization, if it's valid for the
+value and newer than the value's data (with the same version, the biggest data wins, so that both sides keep the same).
+
+-----------------------------------------------------------
+
+– Params:
+  - update – the update
+
+– Returns:
+  - true if the data was stored, false otherwise
+*/
+func (value *Value) applyUpdate(update ValueUpdate) bool {
+	value.mutex.Lock()
+	defer value.mutex.Unlock()
+
+	if update.Type != value.type_ && !(TYPE_ENUM == update.Type && TYPE_STRING == value.type_) {
+		return false
+	}
+	if _, err := value.decodeData(update.Data); nil != err {
+		return false
+	}
+	if update.Version < value.version || (update.Version == value.version && update.Data <= value.curr_data) {
+		return false
+	}
+
+	value.version_synced = update.Version
+	value.storeData(update.Data, update.Time_updated, update.Version)
+
+	return true
+}
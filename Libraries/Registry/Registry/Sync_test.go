@@ -0,0 +1,165 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"Utils"
+	"Utils/UtilsTest"
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestHandleSyncRequest(t *testing.T) {
+	newHarness(t)
+
+	RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false).EnableSync(SYNC_TO_SERVER)
+	var location *Value = RegisterValue("LOCATION", "Location", "The user location", TYPE_STRING, false)
+	location.EnableSync(SYNC_TO_CLIENTS)
+	location.SetString("home", false)
+	var mode *Value = RegisterEnumValue("MODE", "Mode", "A shared mode", []string{"normal", "quiet"}, false)
+	mode.EnableSync(SYNC_BOTH)
+	mode.SetData("quiet", false)
+
+	var updates []ValueUpdate = HandleSyncRequest("phone", SyncRequest{
+		Updates: []ValueUpdate{
+			{Key: "BATTERY", Pretty_name: "Battery", Type: TYPE_INT, Sync: SYNC_TO_SERVER, Data: "50", Version: 10},
+			// Older than the server's, so it loses
+			{Key: "MODE", Type: TYPE_ENUM, Sync: SYNC_BOTH, Data: "normal", Version: 1},
+		},
+		Versions: map[string]int64{
+			"LOCATION": 0,
+			"MODE":     1,
+		},
+	})
+
+	var battery *Value = GetValue(GetDeviceValueKey("BATTERY", "phone"))
+	if nil == battery || 50 != battery.GetInt(true) {
+		t.Fatal("the value of the device wasn't stored")
+	}
+	if "quiet" != mode.GetString(true) {
+		t.Fatal("an older change won")
+	}
+	if 2 != len(updates) {
+		t.Fatalf("expected the location and the mode, got %+v", updates)
+	}
+	for _, update := range updates {
+		if ("LOCATION" == update.Key && "home" != update.Data) || ("MODE" == update.Key && "quiet" != update.Data) {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	}
+
+	// Up to date already
+	updates = HandleSyncRequest("phone", SyncRequest{
+		Versions: map[string]int64{
+			"LOCATION": location.version,
+			"MODE":     mode.version,
+		},
+	})
+	if 0 != len(updates) {
+		t.Fatalf("expected no updates, got %+v", updates)
+	}
+
+	// A newer change of the shared value wins
+	HandleSyncRequest("phone", SyncRequest{
+		Updates: []ValueUpdate{{Key: "MODE", Type: TYPE_ENUM, Sync: SYNC_BOTH, Data: "normal", Version: mode.version + 1}},
+	})
+	if "normal" != mode.GetString(true) {
+		t.Fatal("a newer change lost")
+	}
+}
+
+func TestHandleSyncRequestRejected(t *testing.T) {
+	newHarness(t)
+
+	RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false).EnableSync(SYNC_TO_SERVER)
+	RegisterValue("VOLUME", "Volume", "The sound volume", TYPE_INT, false)
+
+	HandleSyncRequest("phone", SyncRequest{
+		Updates: []ValueUpdate{
+			// Not declared by the server
+			{Key: "SECRET", Type: TYPE_STRING, Sync: SYNC_TO_SERVER, Data: "x", Version: 1},
+			// Declared, but not as SYNC_TO_SERVER
+			{Key: "VOLUME", Type: TYPE_INT, Sync: SYNC_TO_SERVER, Data: "30", Version: 1},
+			// Declared with another type
+			{Key: "BATTERY", Type: TYPE_STRING, Sync: SYNC_TO_SERVER, Data: "full", Version: 1},
+		},
+	})
+	if nil != GetValue(GetDeviceValueKey("SECRET", "phone")) || nil != GetValue(GetDeviceValueKey("VOLUME", "phone")) {
+		t.Fatal("a value not accepted by the server was registered")
+	}
+	if -1 != GetValue(GetDeviceValueKey("BATTERY", "phone")).GetInt(true) {
+		t.Fatal("data of another type was stored")
+	}
+
+	for i := 0; i < _SYNC_MAX_DEVICES; i++ {
+		HandleSyncRequest("device" + strconv.Itoa(i), SyncRequest{
+			Updates: []ValueUpdate{{Key: "BATTERY", Type: TYPE_INT, Sync: SYNC_TO_SERVER, Data: "50", Version: 1}},
+		})
+	}
+	if _SYNC_MAX_DEVICES != countDeviceValues("BATTERY") {
+		t.Fatal("more devices than the maximum were kept")
+	}
+}
+
+func TestSyncWithServer(t *testing.T) {
+	var harness *UtilsTest.TestHarness[any] = newHarness(t)
+	harness.Website_client.SetFormResponse("Registry", []byte(*Utils.ToJsonGENERAL([]ValueUpdate{
+		{Key: "LOCATION", Type: TYPE_STRING, Sync: SYNC_TO_CLIENTS, Data: "home", Version: 5},
+	})))
+
+	var battery *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	battery.EnableSync(SYNC_TO_SERVER)
+	battery.SetInt(50, false)
+	var location *Value = RegisterValue("LOCATION", "Location", "The user location", TYPE_STRING, false)
+	location.EnableSync(SYNC_TO_CLIENTS)
+	RegisterValue("VOLUME", "Volume", "The sound volume", TYPE_INT, false).SetInt(30, false)
+
+	if err := SyncWithServer(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	var request SyncRequest
+	if err := json.Unmarshal([]byte(harness.Website_client.GetForms("Registry")[0].Text2), &request); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(request.Updates) || "BATTERY" != request.Updates[0].Key || "50" != request.Updates[0].Data {
+		t.Fatalf("expected only the battery to be sent, got %+v", request.Updates)
+	}
+	if version, ok := request.Versions["LOCATION"]; !ok || 0 != version || 1 != len(request.Versions) {
+		t.Fatalf("expected only the location to be asked for, got %+v", request.Versions)
+	}
+	if "home" != location.GetString(true) {
+		t.Fatal("the location wasn't received")
+	}
+
+	// Nothing changed since
+	if err := SyncWithServer(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(harness.Website_client.GetForms("Registry")[1].Text2), &request); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(request.Updates) || 5 != request.Versions["LOCATION"] {
+		t.Fatalf("unexpected request %+v", request)
+	}
+}
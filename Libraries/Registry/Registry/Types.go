@@ -0,0 +1,293 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*
+GetEnumMembers gets the allowed data of a TYPE_ENUM value.
+
+-----------------------------------------------------------
+
+– Returns:
+  - a copy of the members or nil if the value isn't TYPE_ENUM
+*/
+func (value *Value) GetEnumMembers() []string {
+	if TYPE_ENUM != value.type_ {
+		return nil
+	}
+
+	return append([]string(nil), value.enum_members...)
+}
+
+/*
+UnmarshalData decodes data in JSON into the Go type of the value's data (a time in RFC 3339 for TYPE_TIME), ready to
+be given to SetData().
+
+-----------------------------------------------------------
+
+– Params:
+  - data_json – the data in JSON
+
+– Returns:
+  - the data
+  - an error if the data isn't of the value's type, nil otherwise
+*/
+func (value *Value) UnmarshalData(data_json []byte) (any, error) {
+	var data_type reflect.Type = value.dataType()
+	if nil == data_type {
+		return nil, errors.New("unknown type " + value.type_)
+	}
+
+	var p_data reflect.Value = reflect.New(data_type)
+	if err := json.Unmarshal(data_json, p_data.Interface()); nil != err {
+		return nil, err
+	}
+	var data any = p_data.Elem().Interface()
+	if _, err := value.encodeData(data); nil != err {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+/*
+dataType gets the Go type of the value's data.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the type or nil if the value's type is unknown
+*/
+func (value *Value) dataType() reflect.Type {
+	switch value.type_ {
+		case TYPE_BOOL:
+			return reflect.TypeOf(false)
+		case TYPE_INT:
+			return reflect.TypeOf(0)
+		case TYPE_LONG:
+			return reflect.TypeOf(int64(0))
+		case TYPE_FLOAT:
+			return reflect.TypeOf(float32(0))
+		case TYPE_DOUBLE:
+			return reflect.TypeOf(float64(0))
+		case TYPE_STRING, TYPE_ENUM:
+			return reflect.TypeOf("")
+		case TYPE_STRING_LIST:
+			return reflect.TypeOf([]string(nil))
+		case TYPE_TIME:
+			return reflect.TypeOf(time.Time{})
+		case TYPE_JSON:
+			return value.json_type
+	}
+
+	return nil
+}
+
+/*
+defaultData gets the stored default data of a TYPE_STRING_LIST, TYPE_TIME or TYPE_JSON value: the Go zero value of its
+type (an empty list for TYPE_STRING_LIST).
+
+-----------------------------------------------------------
+
+– Returns:
+  - the stored data
+*/
+func (value *Value) defaultData() string {
+	if TYPE_STRING_LIST == value.type_ {
+		return "[]"
+	}
+
+	data, _ := value.encodeData(reflect.Zero(value.dataType()).Interface())
+
+	return data
+}
+
+/*
+encodeData checks if the data is of the value's type and converts it to be stored.
+
+-----------------------------------------------------------
+
+– Params:
+  - data – the data
+
+– Returns:
+  - the data to store
+  - an error if the data isn't of the value's type (or isn't a member, for TYPE_ENUM), nil otherwise
+*/
+func (value *Value) encodeData(data any) (string, error) {
+	var data_type reflect.Type = value.dataType()
+	if nil == data_type {
+		return "", errors.New("unknown type " + value.type_)
+	}
+	if nil == data || !reflect.TypeOf(data).AssignableTo(data_type) {
+		return "", fmt.Errorf("data of type %T is not of the value's type %s (%v)", data, value.type_, data_type)
+	}
+
+	switch value.type_ {
+		case TYPE_BOOL:
+			return strconv.FormatBool(data.(bool)), nil
+		case TYPE_INT:
+			return strconv.Itoa(data.(int)), nil
+		case TYPE_LONG:
+			return strconv.FormatInt(data.(int64), 10), nil
+		case TYPE_FLOAT:
+			return strconv.FormatFloat(float64(data.(float32)), 'f', -1, 32), nil
+		case TYPE_DOUBLE:
+			return strconv.FormatFloat(data.(float64), 'f', -1, 64), nil
+		case TYPE_STRING:
+			return data.(string), nil
+		case TYPE_ENUM:
+			for _, member := range value.enum_members {
+				if member == data.(string) {
+					return member, nil
+				}
+			}
+
+			return "", errors.New("\"" + data.(string) + "\" is not a member of the enum")
+		case TYPE_STRING_LIST:
+			if nil == data.([]string) {
+				return "[]", nil
+			}
+		case TYPE_TIME:
+			return data.(time.Time).Format(time.RFC3339Nano), nil
+	}
+
+	// TYPE_STRING_LIST and TYPE_JSON
+	data_json, err := json.Marshal(data)
+	if nil != err {
+		return "", err
+	}
+
+	return string(data_json), nil
+}
+
+/*
+decodeData converts stored data to the value's type.
+
+-----------------------------------------------------------
+
+– Params:
+  - data – the stored data
+
+– Returns:
+  - the converted data
+  - an error if the data isn't valid for the value's type, nil otherwise
+*/
+func (value *Value) decodeData(data string) (any, error) {
+	switch value.type_ {
+		case TYPE_STRING_LIST, TYPE_JSON:
+			return value.UnmarshalData([]byte(data))
+		case TYPE_TIME:
+			return time.Parse(time.RFC3339Nano, data)
+		case TYPE_ENUM:
+			if _, err := value.encodeData(data); nil != err {
+				return nil, err
+			}
+
+			return data, nil
+	}
+
+	var err error = nil
+	switch value.type_ {
+		case TYPE_BOOL:
+			_, err = strconv.ParseBool(data)
+		case TYPE_INT:
+			_, err = strconv.Atoi(data)
+		case TYPE_LONG:
+			_, err = strconv.ParseInt(data, 10, 64)
+		case TYPE_FLOAT:
+			_, err = strconv.ParseFloat(data, 32)
+		case TYPE_DOUBLE:
+			_, err = strconv.ParseFloat(data, 64)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	var typed_data any = convertData(value.type_, data)
+	if nil == typed_data {
+		return nil, errors.New("unknown type " + value.type_)
+	}
+
+	return typed_data, nil
+}
+
+/*
+typedData converts stored data to the value's type, like GetData() does.
+
+-----------------------------------------------------------
+
+– Params:
+  - data – the stored data
+
+– Returns:
+  - the converted data or, if it's not valid, the default of the type (false or -1 for the basic types and the Go zero
+    value for the others)
+*/
+func (value *Value) typedData(data string) any {
+	switch value.type_ {
+		case TYPE_STRING_LIST, TYPE_TIME, TYPE_JSON, TYPE_ENUM:
+			typed_data, err := value.decodeData(data)
+			if nil != err {
+				return reflect.Zero(value.dataType()).Interface()
+			}
+
+			return typed_data
+	}
+
+	return convertData(value.type_, data)
+}
+
+/*
+formatData formats the data of a value to be shown.
+
+-----------------------------------------------------------
+
+– Params:
+  - data – the data, of the value's type
+
+– Returns:
+  - the text, in JSON for the lists and the objects
+*/
+func formatData(data any) string {
+	switch data := data.(type) {
+		case bool, int, int64, float32, float64, string:
+			return fmt.Sprint(data)
+		case time.Time:
+			return data.Format(time.RFC3339Nano)
+	}
+
+	data_json, err := json.Marshal(data)
+	if nil != err {
+		return fmt.Sprint(data)
+	}
+
+	return string(data_json)
+}
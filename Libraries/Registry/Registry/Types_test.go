@@ -0,0 +1,121 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"testing"
+	"time"
+)
+
+type _TestDevice struct {
+	Name  string
+	Ports []int
+}
+
+func TestSetDataRejectsMismatchedTypes(t *testing.T) {
+	newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	if value.SetData("50", false) || value.SetData(int64(50), false) || value.SetData(nil, false) {
+		t.Fatal("data of another type was set")
+	}
+	if !SetTyped(value, 50, false) {
+		t.Fatal("data of the right type wasn't set")
+	}
+	if data, ok := GetTyped[int](value, true); !ok || 50 != data {
+		t.Fatalf("expected 50, got %v", data)
+	}
+	if _, ok := GetTyped[string](value, true); ok {
+		t.Fatal("got the data as another type")
+	}
+}
+
+func TestStructuredTypes(t *testing.T) {
+	newHarness(t)
+
+	var list_value *Value = RegisterValue("MODULES", "Modules", "Some modules", TYPE_STRING_LIST, false)
+	if data, _ := GetTyped[[]string](list_value, true); nil == data || 0 != len(data) {
+		t.Fatalf("expected an empty list by default, got %#v", data)
+	}
+	SetTyped(list_value, []string{"Speech", "Reminders"}, false)
+	if data, _ := GetTyped[[]string](list_value, true); 2 != len(data) || "Reminders" != data[1] {
+		t.Fatalf("unexpected list %v", data)
+	}
+
+	var time_value *Value = RegisterValue("LAST_BOOT", "Last boot", "The time of the last boot", TYPE_TIME, false)
+	var boot_time time.Time = time.Date(2024, 1, 1, 12, 0, 0, 5, time.UTC)
+	SetTyped(time_value, boot_time, false)
+	if data, _ := GetTyped[time.Time](time_value, true); !data.Equal(boot_time) {
+		t.Fatalf("expected %v, got %v", boot_time, data)
+	}
+
+	var json_value *Value = RegisterJsonValue[_TestDevice]("DEVICE", "Device", "A device", false)
+	if SetTyped(json_value, map[string]any{"Name": "Phone"}, false) {
+		t.Fatal("data of another Go type was set")
+	}
+	SetTyped(json_value, _TestDevice{Name: "Phone", Ports: []int{1, 2}}, false)
+	if data, _ := GetTyped[_TestDevice](json_value, true); "Phone" != data.Name || 2 != len(data.Ports) {
+		t.Fatalf("unexpected object %+v", data)
+	}
+
+	var enum_value *Value = RegisterEnumValue("POWER_MODE", "Power mode", "The power mode",
+		[]string{"normal", "saver"}, false)
+	if "normal" != enum_value.GetString(true) {
+		t.Fatalf("expected the first member by default, got %s", enum_value.GetString(true))
+	}
+	if SetTyped(enum_value, "turbo", false) || !SetTyped(enum_value, "saver", false) {
+		t.Fatal("the members weren't enforced")
+	}
+}
+
+func TestUnmarshalData(t *testing.T) {
+	newHarness(t)
+
+	var enum_value *Value = RegisterEnumValue("POWER_MODE", "Power mode", "The power mode",
+		[]string{"normal", "saver"}, false)
+	if _, err := enum_value.UnmarshalData([]byte(`"turbo"`)); nil == err {
+		t.Fatal("a non-member was accepted")
+	}
+
+	var json_value *Value = RegisterJsonValue[_TestDevice]("DEVICE", "Device", "A device", false)
+	data, err := json_value.UnmarshalData([]byte(`{"Name": "Phone", "Ports": [1]}`))
+	if nil != err || !json_value.SetData(data, false) {
+		t.Fatalf("the object wasn't set: %v", err)
+	}
+	if _, err = json_value.UnmarshalData([]byte(`{"Name": 5}`)); nil == err {
+		t.Fatal("an object of another type was accepted")
+	}
+}
+
+func TestPersistentEnumRestoredOnlyIfMember(t *testing.T) {
+	newHarness(t)
+
+	RegisterEnumValue("POWER_MODE", "Power mode", "The power mode", []string{"normal", "saver"}, true).
+		SetData("saver", false)
+	SavePersistentValues()
+
+	restartRegistry()
+	if value := RegisterEnumValue("POWER_MODE", "Power mode", "The power mode", []string{"normal", "performance"},
+			true); "normal" != value.GetString(true) {
+		t.Fatalf("restored data that isn't a member anymore: %s", value.GetString(true))
+	}
+}
@@ -0,0 +1,170 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"strings"
+	"sync"
+)
+
+// _WATCH_CHAN_SIZE is the size of the buffer of the channels of the watches.
+const _WATCH_CHAN_SIZE int = 100
+
+// ValueChange is a change of the data of a value, sent to the watches of the value.
+type ValueChange struct {
+	// Key is the key of the value
+	Key string
+	// Old_data is the data before the change, of the type of the value (like with GetData())
+	Old_data any
+	// Time_updated_old is the time the old data was set in milliseconds
+	Time_updated_old int64
+	// New_data is the data after the change, of the type of the value (like with GetData())
+	New_data any
+	// Time_updated_new is the time the new data was set in milliseconds
+	Time_updated_new int64
+}
+
+// _Watch is a watch on the values whose key matches a pattern.
+type _Watch struct {
+	// key_pattern is the key of the value or a key prefix ending in "*"
+	key_pattern string
+	// channel is where the changes are sent
+	channel chan ValueChange
+}
+
+var watches_GL []_Watch = nil
+var watches_mutex_GL sync.Mutex
+
+/*
+WatchValues watches the values whose key matches a pattern, getting each real change of their data from now on (setting
+the same data again isn't a change).
+
+The channel is buffered and a change is dropped for a watch whose buffer is full, so don't take too long to receive
+them.
+
+-----------------------------------------------------------
+
+– Params:
+  - key_pattern – the key of a value or, ending in "*", a key prefix to watch all the values whose key starts with it
+    ("*" alone watches all of them). The values don't need to be registered yet.
+
+– Returns:
+  - the channel on which the changes will be sent
+*/
+func WatchValues(key_pattern string) chan ValueChange {
+	watches_mutex_GL.Lock()
+	defer watches_mutex_GL.Unlock()
+
+	var channel chan ValueChange = make(chan ValueChange, _WATCH_CHAN_SIZE)
+	watches_GL = append(watches_GL, _Watch{
+		key_pattern: key_pattern,
+		channel:     channel,
+	})
+
+	return channel
+}
+
+/*
+UnwatchValues cancels a watch made with WatchValues() or Value.Watch() and closes its channel.
+
+-----------------------------------------------------------
+
+– Params:
+  - channel – the channel returned by WatchValues() or Value.Watch()
+*/
+func UnwatchValues(channel chan ValueChange) {
+	watches_mutex_GL.Lock()
+	defer watches_mutex_GL.Unlock()
+
+	for i, watch := range watches_GL {
+		if watch.channel == channel {
+			watches_GL = append(watches_GL[:i], watches_GL[i+1:]...)
+			close(channel)
+
+			break
+		}
+	}
+}
+
+/*
+Watch watches the value, getting each real change of its data from now on. Same as WatchValues() with the value's key.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the channel on which the changes will be sent
+*/
+func (value *Value) Watch() chan ValueChange {
+	return WatchValues(value.key)
+}
+
+/*
+notifyChange sends the last change of the value's data to the watches of the value. Call with the value's mutex
+locked, so that the changes are sent in the order they happened.
+*/
+func (value *Value) notifyChange() {
+	watches_mutex_GL.Lock()
+	defer watches_mutex_GL.Unlock()
+
+	if 0 == len(watches_GL) {
+		return
+	}
+
+	var change ValueChange = ValueChange{
+		Key:              value.key,
+		Old_data:         value.typedData(value.prev_data),
+		Time_updated_old: value.time_updated_prev,
+		New_data:         value.typedData(value.curr_data),
+		Time_updated_new: value.time_updated_curr,
+	}
+	for _, watch := range watches_GL {
+		if !keyMatches(watch.key_pattern, value.key) {
+			continue
+		}
+
+		select {
+			case watch.channel <- change:
+			default:
+				// Buffer full - drop it instead of blocking the setter
+		}
+	}
+}
+
+/*
+keyMatches checks if a key matches a key pattern of a watch.
+
+-----------------------------------------------------------
+
+– Params:
+  - key_pattern – the key or a key prefix ending in "*"
+  - key – the key to check
+
+– Returns:
+  - true if the key matches the pattern, false otherwise
+*/
+func keyMatches(key_pattern string, key string) bool {
+	if strings.HasSuffix(key_pattern, "*") {
+		return strings.HasPrefix(key, key_pattern[:len(key_pattern) - 1])
+	}
+
+	return key_pattern == key
+}
@@ -0,0 +1,84 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Registry
+
+import (
+	"testing"
+)
+
+// receiveChanges gets the changes already sent to a watch.
+func receiveChanges(channel chan ValueChange) []ValueChange {
+	var changes []ValueChange = nil
+	for {
+		select {
+			case change := <-channel:
+				changes = append(changes, change)
+			default:
+				return changes
+		}
+	}
+}
+
+func TestWatchOnlyRealChanges(t *testing.T) {
+	newHarness(t)
+
+	var value *Value = RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false)
+	var channel chan ValueChange = value.Watch()
+	defer UnwatchValues(channel)
+
+	value.SetInt(50, false)
+	var time_updated int64 = value.GetTimeUpdated(true)
+	value.SetInt(50, true)
+	value.SetInt(49, false)
+
+	var changes []ValueChange = receiveChanges(channel)
+	if 2 != len(changes) {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	if "BATTERY" != changes[0].Key || -1 != changes[0].Old_data || 50 != changes[0].New_data {
+		t.Fatalf("unexpected first change %+v", changes[0])
+	}
+	if 50 != changes[1].Old_data || 49 != changes[1].New_data || time_updated != changes[1].Time_updated_old ||
+			value.GetTimeUpdated(true) != changes[1].Time_updated_new {
+		t.Fatalf("unexpected second change %+v", changes[1])
+	}
+}
+
+func TestWatchKeyPrefix(t *testing.T) {
+	newHarness(t)
+
+	var channel chan ValueChange = WatchValues("SOUND_*")
+	RegisterValue("SOUND_MUTED", "Sound muted", "Whether the sound is muted", TYPE_BOOL, false).SetBool(true, false)
+	RegisterValue("SOUND_VOLUME", "Sound volume", "The sound volume", TYPE_INT, false).SetInt(30, false)
+	RegisterValue("BATTERY", "Battery", "The battery level", TYPE_INT, false).SetInt(50, false)
+
+	var changes []ValueChange = receiveChanges(channel)
+	if 2 != len(changes) || "SOUND_MUTED" != changes[0].Key || "SOUND_VOLUME" != changes[1].Key {
+		t.Fatalf("expected the changes of the 2 sound values, got %+v", changes)
+	}
+
+	UnwatchValues(channel)
+	if _, ok := <-channel; ok {
+		t.Fatal("the channel wasn't closed")
+	}
+	GetValue("SOUND_VOLUME").SetInt(40, false)
+}
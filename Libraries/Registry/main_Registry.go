@@ -27,7 +27,8 @@ import (
 )
 
 func main() {
-	var value *Registry.Value = Registry.RegisterValue("key1", "Pretty Name 1", "Description 1", Registry.TYPE_BOOL)
+	var value *Registry.Value = Registry.RegisterValue("key1", "Pretty Name 1", "Description 1", Registry.TYPE_BOOL,
+		false)
 
 	log.Println(value.GetBool(true))
 }
@@ -21,7 +21,10 @@
 
 package ULComm
 
-import "Utils"
+import (
+	"Utils"
+	"context"
+)
 
 type DeviceInfo struct {
 	// Device_id is the unique identifier of the device
@@ -35,9 +38,9 @@ type DeviceInfo struct {
 }
 
 func (device_info *DeviceInfo) SendInfo() error {
-	_, err := Utils.SubmitFormWEBSITE(Utils.WebsiteForm{
+	_, err := Utils.SubmitFormWEBSITE(context.Background(), Utils.WebsiteForm{
 		Type:  "UserLocator",
-		Text1: Utils.User_settings_GL.PersonalConsts.Device_ID,
+		Text1: Utils.GetUserSettingsSETTINGS().PersonalConsts.Device_ID,
 		Text2: *Utils.ToJsonGENERAL(device_info),
 	})
 
@@ -0,0 +1,308 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_1
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"Registry/Registry"
+	"Utils"
+)
+
+func init() {
+	Utils.RegisterControlCmdCONTROL("list", controlList)
+	Utils.RegisterControlCmdCONTROL("start", controlStart)
+	Utils.RegisterControlCmdCONTROL("stop", controlStop)
+	Utils.RegisterControlCmdCONTROL("restart", controlRestart)
+	Utils.RegisterControlCmdCONTROL("enable", controlEnable)
+	Utils.RegisterControlCmdCONTROL("disable", controlDisable)
+	Utils.RegisterControlCmdCONTROL("registry", controlRegistry)
+}
+
+/*
+SetModEnabled enables or disables a module persistently (it stays like that after VISOR is restarted). Enabling it also
+resets its crash-looping state, if it had one.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - enabled – true to enable the module, false to disable it
+
+– Returns:
+  - nil if the state was saved, an error otherwise (it's still applied until VISOR is restarted)
+ */
+func SetModEnabled(mod_num int, enabled bool) error {
+	if enabled {
+		ReenableModule(mod_num)
+	} else {
+		modules_GL[mod_num].SetEnabled(false)
+	}
+
+	return Utils.SaveModEnabledMODULES(Utils.GetModEnabledKeyMODULES(mod_num), enabled)
+}
+
+/*
+SetPluginEnabled is the same as SetModEnabled() but for a plugin.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the plugin
+  - enabled – true to enable the plugin, false to disable it
+
+– Returns:
+  - nil if the state was saved, an error otherwise
+ */
+func SetPluginEnabled(name string, enabled bool) error {
+	if enabled {
+		if !ReenablePlugin(name) {
+			return errors.New("no plugin named \"" + name + "\"")
+		}
+	} else {
+		var plugin *Utils.Plugin = Utils.GetPluginPLUGINS(name)
+		if nil == plugin {
+			return errors.New("no plugin named \"" + name + "\"")
+		}
+		plugin.Module.SetEnabled(false)
+	}
+
+	return Utils.SaveModEnabledMODULES(Utils.PLUGIN_ENABLED_KEY_PREFFIX + name, enabled)
+}
+
+/*
+applySavedModsEnabled applies the enabled states saved with SetModEnabled() to the modules.
+ */
+func applySavedModsEnabled() {
+	var mods_enabled map[string]bool = Utils.GetSavedModsEnabledMODULES()
+	for mod_num := range _MAP_MOD_NUM_START {
+		if enabled, ok := mods_enabled[Utils.GetModEnabledKeyMODULES(mod_num)]; ok {
+			modules_GL[mod_num].SetEnabled(enabled)
+		}
+	}
+}
+
+/*
+findControlTarget finds the module or plugin a control command is for.
+
+-----------------------------------------------------------
+
+– Params:
+  - args – the arguments of the command, which must be only the module or plugin
+
+– Returns:
+  - the number of the module, or -1 if it's a plugin
+  - the plugin, or nil if it's a module
+  - an error if there's no such module or plugin handled by the Manager, nil otherwise
+ */
+func findControlTarget(args []string) (int, *Utils.Plugin, error) {
+	if len(args) != 1 {
+		return -1, nil, errors.New("expected one module (number, MOD_n or name) or plugin name")
+	}
+
+	var mod_num int = Utils.FindModNumCONTROL(args[0])
+	if _, ok := _MAP_MOD_NUM_START[mod_num]; ok {
+		return mod_num, nil, nil
+	}
+	if plugin := Utils.GetPluginPLUGINS(args[0]); nil != plugin {
+		return -1, plugin, nil
+	}
+	if -1 != mod_num {
+		return -1, nil, errors.New("the module \"" + Utils.GetModNameMODULES(mod_num) + "\" isn't handled by the " +
+			"Manager on this version of VISOR")
+	}
+
+	return -1, nil, errors.New("no module or plugin \"" + args[0] + "\"")
+}
+
+/*
+getControlModuleText gets the line describing a module for the "list" command.
+
+-----------------------------------------------------------
+
+– Params:
+  - id – the identification of the module
+  - module – the module
+
+– Returns:
+  - the line describing the module
+ */
+func getControlModuleText(id string, module *Utils.Module) string {
+	state, time_state := module.GetStateAndTime()
+	var text string = id + " - " + module.Name + ": " + Utils.MOD_STATES_NAMES[state]
+	if time_state != 0 {
+		text += " (since " + Utils.GetDateTimeStrTIMEDATE(time_state) + ")"
+	}
+	if !module.IsEnabled() {
+		text += " [disabled]"
+	}
+
+	return text
+}
+
+/*
+controlList handles the "list" command: lists the modules and plugins with their states.
+ */
+func controlList(args []string, output func(line string) bool) error {
+	var mods_nums []int = nil
+	for mod_num := range _MAP_MOD_NUM_START {
+		mods_nums = append(mods_nums, mod_num)
+	}
+	sort.Ints(mods_nums)
+
+	for _, mod_num := range mods_nums {
+		output(getControlModuleText(Utils.GetModEnabledKeyMODULES(mod_num), &modules_GL[mod_num]))
+	}
+	for _, plugin := range Utils.GetPluginsPLUGINS() {
+		var text string = getControlModuleText("plugin", &plugin.Module)
+		if !plugin.IsSupported() {
+			text += " [not supported]"
+		}
+		output(text)
+	}
+
+	return nil
+}
+
+/*
+controlStart handles the "start" command: enables a module until VISOR is restarted, so the Manager starts it (even if
+it was crash-looping).
+ */
+func controlStart(args []string, output func(line string) bool) error {
+	mod_num, plugin, err := findControlTarget(args)
+	if nil != err {
+		return err
+	}
+
+	if nil != plugin {
+		ReenablePlugin(plugin.Manifest.Name)
+	} else {
+		ReenableModule(mod_num)
+	}
+	output("Enabled until VISOR restarts - the Manager will start it")
+
+	return nil
+}
+
+/*
+controlStop handles the "stop" command: disables a module until VISOR is restarted, so the Manager stops it.
+ */
+func controlStop(args []string, output func(line string) bool) error {
+	mod_num, plugin, err := findControlTarget(args)
+	if nil != err {
+		return err
+	}
+
+	if nil != plugin {
+		plugin.Module.SetEnabled(false)
+	} else {
+		modules_GL[mod_num].SetEnabled(false)
+	}
+	output("Disabled until VISOR restarts - the Manager will stop it")
+
+	return nil
+}
+
+/*
+controlRestart handles the "restart" command: stops a module so that the Manager starts it again.
+ */
+func controlRestart(args []string, output func(line string) bool) error {
+	mod_num, plugin, err := findControlTarget(args)
+	if nil != err {
+		return err
+	}
+
+	var module *Utils.Module = nil
+	if nil != plugin {
+		module = &plugin.Module
+	} else {
+		module = &modules_GL[mod_num]
+	}
+	if !module.IsEnabled() {
+		return errors.New("the module is disabled - use start or enable")
+	}
+	if module.GetState() != Utils.MOD_STATE_RUNNING {
+		return errors.New("the module isn't running (" + Utils.MOD_STATES_NAMES[module.GetState()] + ")")
+	}
+
+	if nil != plugin {
+		plugin.Stop()
+	} else {
+		module.TransitionState(Utils.MOD_STATE_STOPPING)
+	}
+	output("Stopping - the Manager will start it again")
+
+	return nil
+}
+
+/*
+controlEnable handles the "enable" command: enables a module persistently.
+ */
+func controlEnable(args []string, output func(line string) bool) error {
+	return controlSetEnabled(args, true, output)
+}
+
+/*
+controlDisable handles the "disable" command: disables a module persistently.
+ */
+func controlDisable(args []string, output func(line string) bool) error {
+	return controlSetEnabled(args, false, output)
+}
+
+/*
+controlSetEnabled enables or disables a module persistently, for the "enable" and "disable" commands.
+ */
+func controlSetEnabled(args []string, enabled bool, output func(line string) bool) error {
+	mod_num, plugin, err := findControlTarget(args)
+	if nil != err {
+		return err
+	}
+
+	if nil != plugin {
+		err = SetPluginEnabled(plugin.Manifest.Name, enabled)
+	} else {
+		err = SetModEnabled(mod_num, enabled)
+	}
+	if nil != err {
+		return errors.New("applied, but couldn't be saved for the next starts: " + err.Error())
+	}
+	if enabled {
+		output("Enabled - the Manager will start it")
+	} else {
+		output("Disabled - the Manager will stop it")
+	}
+
+	return nil
+}
+
+/*
+controlRegistry handles the "registry" command: dumps the Registry.
+ */
+func controlRegistry(args []string, output func(line string) bool) error {
+	for _, line := range strings.Split(strings.TrimSuffix(Registry.GetRegistryText(), "\n"), "\n") {
+		output(line)
+	}
+
+	return nil
+}
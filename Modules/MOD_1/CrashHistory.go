@@ -0,0 +1,263 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_1
+
+import (
+	"Registry/Registry"
+	"Utils"
+	"VISOR_Client/ClientRegKeys"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// _MAX_CRASHES_STORED is the maximum number of crashes kept in the crash history of each module.
+const _MAX_CRASHES_STORED int = 10
+
+// _RESTART_BACKOFF_BASE_S is the time to wait before restarting a module after its first crash. Each consecutive crash
+// doubles the time, up to _RESTART_BACKOFF_MAX_S.
+const _RESTART_BACKOFF_BASE_S int64 = 5
+// _RESTART_BACKOFF_MAX_S is the maximum time to wait before restarting a crashed module.
+const _RESTART_BACKOFF_MAX_S int64 = 30*60
+// _STABLE_RUN_S is the time a module must run without crashing for its consecutive crashes count to be reset.
+const _STABLE_RUN_S int64 = 5*60
+
+// _CRASH_LOOP_NUM is the number of crashes inside _CRASH_LOOP_WINDOW_S after which the module is considered to be
+// crash-looping and is disabled.
+const _CRASH_LOOP_NUM int = 5
+// _CRASH_LOOP_WINDOW_S is the time window in which _CRASH_LOOP_NUM crashes make a module be considered crash-looping.
+const _CRASH_LOOP_WINDOW_S int64 = 15*60
+
+// CrashInfo is the information about a crash of a module.
+type CrashInfo struct {
+	// Time is the time of the crash in milliseconds
+	Time int64
+	// Error is the error message of the crash
+	Error string
+}
+
+// ModCrashHistory is the crash history of a module.
+type ModCrashHistory struct {
+	// Crashes is the ring of the last crashes of the module, from the oldest to the newest
+	Crashes []CrashInfo
+	// Consec_crashes is the number of consecutive crashes of the module (without it running stable in between)
+	Consec_crashes int
+	// Next_start is the time in milliseconds before which the module won't be restarted
+	Next_start int64
+	// Crash_looping is true if the module crashed too many times in a short time and was disabled because of it
+	Crash_looping bool
+}
+
+var crash_histories_GL [Utils.MODS_ARRAY_SIZE]ModCrashHistory
+// time_started_GL is the time each module was last started in milliseconds
+var time_started_GL [Utils.MODS_ARRAY_SIZE]int64
+var crash_histories_mutex_GL sync.Mutex
+
+/*
+GetCrashHistory gets a copy of the crash history of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the crash history of the module
+ */
+func GetCrashHistory(mod_num int) ModCrashHistory {
+	crash_histories_mutex_GL.Lock()
+	defer crash_histories_mutex_GL.Unlock()
+
+	var crash_history ModCrashHistory = crash_histories_GL[mod_num]
+	crash_history.Crashes = append([]CrashInfo(nil), crash_history.Crashes...)
+
+	return crash_history
+}
+
+/*
+ReenableModule re-enables a module, resetting its crash-looping state in case it was disabled because of it.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+ */
+func ReenableModule(mod_num int) {
+	crash_histories_mutex_GL.Lock()
+	crash_histories_GL[mod_num].Crash_looping = false
+	crash_histories_GL[mod_num].Consec_crashes = 0
+	crash_histories_GL[mod_num].Next_start = 0
+	crash_histories_mutex_GL.Unlock()
+
+	modules_GL[mod_num].SetEnabled(true)
+
+	updateCrashHistoryReg()
+}
+
+/*
+registerModStart registers the time a module was started at.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+ */
+func registerModStart(mod_num int) {
+	crash_histories_mutex_GL.Lock()
+	defer crash_histories_mutex_GL.Unlock()
+
+	time_started_GL[mod_num] = time.Now().UnixMilli()
+}
+
+/*
+registerModCrash registers a crash of a module in its crash history, computes the time to wait before restarting it and
+disables it in case it's crash-looping.
+
+A crash already registered (same crash time) is ignored.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - crash_time – the time of the crash in milliseconds
+  - error_msg – the error message of the crash
+ */
+func registerModCrash(mod_num int, crash_time int64, error_msg string) {
+	crash_histories_mutex_GL.Lock()
+	added, crash_looping, crashes_in_window := crash_histories_GL[mod_num].addCrash(time_started_GL[mod_num],
+		crash_time, error_msg)
+	crash_histories_mutex_GL.Unlock()
+	if !added {
+		return
+	}
+
+	if crash_looping {
+		modules_GL[mod_num].SetEnabled(false)
+
+		_ = Utils.SendModErrorEmailMODULES(mod_num, "The module crashed " + strconv.Itoa(crashes_in_window) +
+			" times in the last " + strconv.FormatInt(_CRASH_LOOP_WINDOW_S/60, 10) + " minutes and was disabled " +
+			"until it's re-enabled. Last error:\n\n" + error_msg)
+	}
+
+	updateCrashHistoryReg()
+}
+
+/*
+addCrash adds a crash to the crash history, computes the time to wait before restarting the module and checks if it's
+crash-looping.
+
+Call it with crash_histories_mutex_GL locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - time_started – the time the module was last started in milliseconds
+  - crash_time – the time of the crash in milliseconds
+  - error_msg – the error message of the crash
+
+– Returns:
+  - false if the crash was already registered (same crash time), true otherwise
+  - true if the module just started crash-looping and must be disabled, false otherwise
+  - the number of crashes inside _CRASH_LOOP_WINDOW_S
+ */
+func (crash_history *ModCrashHistory) addCrash(time_started int64, crash_time int64, error_msg string) (bool, bool, int) {
+	var num_crashes int = len(crash_history.Crashes)
+	if num_crashes > 0 && crash_history.Crashes[num_crashes - 1].Time == crash_time {
+		return false, false, 0
+	}
+
+	crash_history.Crashes = append(crash_history.Crashes, CrashInfo{
+		Time:  crash_time,
+		Error: error_msg,
+	})
+	if len(crash_history.Crashes) > _MAX_CRASHES_STORED {
+		crash_history.Crashes = crash_history.Crashes[1:]
+	}
+
+	if crash_time - time_started >= _STABLE_RUN_S*1000 {
+		// The module was running fine for a while, so this is not a consecutive crash.
+		crash_history.Consec_crashes = 0
+	}
+	crash_history.Consec_crashes++
+
+	var backoff_s int64 = _RESTART_BACKOFF_BASE_S
+	for i := 1; i < crash_history.Consec_crashes && backoff_s < _RESTART_BACKOFF_MAX_S; i++ {
+		backoff_s *= 2
+	}
+	if backoff_s > _RESTART_BACKOFF_MAX_S {
+		backoff_s = _RESTART_BACKOFF_MAX_S
+	}
+	crash_history.Next_start = crash_time + backoff_s*1000
+
+	var crashes_in_window int = 0
+	for _, crash := range crash_history.Crashes {
+		if crash.Time >= crash_time - _CRASH_LOOP_WINDOW_S*1000 {
+			crashes_in_window++
+		}
+	}
+	var crash_looping bool = crashes_in_window >= _CRASH_LOOP_NUM && !crash_history.Crash_looping
+	if crash_looping {
+		crash_history.Crash_looping = true
+	}
+
+	return true, crash_looping, crashes_in_window
+}
+
+/*
+canRestartMod checks if a module can be (re)started, according to its crash history.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - true if the module is not crash-looping and its restart backoff time has passed, false otherwise
+ */
+func canRestartMod(mod_num int) bool {
+	crash_histories_mutex_GL.Lock()
+	defer crash_histories_mutex_GL.Unlock()
+
+	var crash_history ModCrashHistory = crash_histories_GL[mod_num]
+
+	return !crash_history.Crash_looping && time.Now().UnixMilli() >= crash_history.Next_start
+}
+
+/*
+updateCrashHistoryReg updates the crash history of all modules in the Registry (the plugins' ones by their names).
+ */
+func updateCrashHistoryReg() {
+	var crash_histories map[string]ModCrashHistory = make(map[string]ModCrashHistory)
+	for mod_num := 0; mod_num < Utils.MODS_ARRAY_SIZE; mod_num++ {
+		var crash_history ModCrashHistory = GetCrashHistory(mod_num)
+		if len(crash_history.Crashes) > 0 {
+			crash_histories[strconv.Itoa(mod_num)] = crash_history
+		}
+	}
+	for name, crash_history := range getPluginsCrashHistories() {
+		if len(crash_history.Crashes) > 0 {
+			crash_histories[name] = crash_history
+		}
+	}
+
+	Registry.GetValue(ClientRegKeys.K_MODULES_CRASH_HISTORY).SetData(*Utils.ToJsonGENERAL(crash_histories), false)
+}
@@ -0,0 +1,262 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package MOD_1
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"Utils"
+)
+
+// _ModDescriptor is the information the Manager needs about each module it handles.
+type _ModDescriptor struct {
+	// start is the Start() function of the module
+	start func(module *Utils.Module)
+	// deps is the list of the numbers of the modules this one depends on (they must be running for it to be started)
+	deps []int
+	// optional_deps is the list of the numbers of the modules this one uses if they're running. They're only started
+	// before this one, but this one is started without them too.
+	optional_deps []int
+}
+
+// mods_held_back_GL has the reason why each module is not being started, to only log it when it changes.
+var mods_held_back_GL [Utils.MODS_ARRAY_SIZE]string
+
+/*
+getModsStartOrder gets the modules of _MAP_MOD_NUM_START sorted in topological order - each module comes after all its
+dependencies (optional ones included). Modules with no dependencies between them are kept in numeric order.
+
+It panics if there's a dependency cycle or a dependency on a module the Manager doesn't handle.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the numbers of the modules in the order they must be started
+*/
+func getModsStartOrder() []int {
+	var mods_order []int = nil
+	var added [Utils.MODS_ARRAY_SIZE]bool
+	for len(mods_order) < len(_MAP_MOD_NUM_START) {
+		var added_any bool = false
+		for mod_num := 0; mod_num < Utils.MODS_ARRAY_SIZE; mod_num++ {
+			mod_descriptor, ok := _MAP_MOD_NUM_START[mod_num]
+			if !ok || added[mod_num] {
+				continue
+			}
+
+			var deps_added bool = true
+			for _, dep_num := range append(mod_descriptor.deps, mod_descriptor.optional_deps...) {
+				if _, ok := _MAP_MOD_NUM_START[dep_num]; !ok {
+					panic(errors.New("module " + strconv.Itoa(mod_num) + " depends on module " + strconv.Itoa(dep_num) +
+						", which is not handled by the Manager"))
+				}
+				if !added[dep_num] {
+					deps_added = false
+
+					break
+				}
+			}
+			if deps_added {
+				mods_order = append(mods_order, mod_num)
+				added[mod_num] = true
+				added_any = true
+
+				// Start over to keep the numeric order whenever possible
+				break
+			}
+		}
+
+		if !added_any {
+			panic(errors.New("dependency cycle between the modules " + getModsNotAdded(added)))
+		}
+	}
+
+	return mods_order
+}
+
+/*
+getModsNotAdded gets a string with the numbers of the modules of _MAP_MOD_NUM_START not yet added to the start order.
+
+-----------------------------------------------------------
+
+– Params:
+  - added – the modules already added
+
+– Returns:
+  - the numbers of the modules separated by commas
+*/
+func getModsNotAdded(added [Utils.MODS_ARRAY_SIZE]bool) string {
+	var mods_str string = ""
+	for mod_num := 0; mod_num < Utils.MODS_ARRAY_SIZE; mod_num++ {
+		if _, ok := _MAP_MOD_NUM_START[mod_num]; ok && !added[mod_num] {
+			if mods_str != "" {
+				mods_str += ", "
+			}
+			mods_str += strconv.Itoa(mod_num)
+		}
+	}
+
+	return mods_str
+}
+
+/*
+getModDepDisabled gets a dependency of a module that is disabled (optional dependencies are not checked).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the number of the disabled dependency or -1 if all the dependencies are enabled (or there are none)
+*/
+func getModDepDisabled(mod_num int) int {
+	for _, dep_num := range _MAP_MOD_NUM_START[mod_num].deps {
+		if !modules_GL[dep_num].IsEnabled() {
+			return dep_num
+		}
+	}
+
+	return -1
+}
+
+/*
+getModDepNotRunning gets a dependency of a module that is not running (or starting) (optional dependencies are not
+checked).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the number of the dependency not running or -1 if all the dependencies are running (or there are none)
+*/
+func getModDepNotRunning(mod_num int) int {
+	for _, dep_num := range _MAP_MOD_NUM_START[mod_num].deps {
+		if !isModRunning(dep_num) {
+			return dep_num
+		}
+	}
+
+	return -1
+}
+
+/*
+setModHeldBack logs why a module is not being started because of its dependencies, if the reason is different from the
+last one.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - dep_num – the number of the dependency holding it back, or -1 if the module is no longer held back
+*/
+func setModHeldBack(mod_num int, dep_num int) {
+	var reason string = ""
+	if -1 != dep_num {
+		reason = "it depends on the module \"" + Utils.GetModNameMODULES(dep_num) + "\", which is "
+		if !Utils.IsModSupportedMODULES(dep_num) {
+			reason += "not supported on this machine"
+		} else if !modules_GL[dep_num].IsEnabled() {
+			reason += "disabled"
+		} else {
+			reason += strings.ToLower(Utils.MOD_STATES_NAMES[modules_GL[dep_num].GetState()])
+		}
+	}
+	if reason == mods_held_back_GL[mod_num] {
+		return
+	}
+
+	mods_held_back_GL[mod_num] = reason
+	if "" != reason {
+		moduleInfo_GL.Log.Warning("Not starting the module: " + reason, "module", Utils.GetModNameMODULES(mod_num))
+	}
+}
+
+/*
+hasRunningDependents checks if any module depending on the given one is still running. The modules that only use it
+optionally count too if they're also being stopped, so that they stop before it.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - mods_stopping – the modules being stopped
+
+– Returns:
+  - true if any dependent is running, false otherwise
+*/
+func hasRunningDependents(mod_num int, mods_stopping [Utils.MODS_ARRAY_SIZE]bool) bool {
+	for dependent_num, mod_descriptor := range _MAP_MOD_NUM_START {
+		if !isModRunning(dependent_num) {
+			continue
+		}
+		if Utils.ContainsSLICES(mod_descriptor.deps, mod_num) ||
+				(mods_stopping[dependent_num] && Utils.ContainsSLICES(mod_descriptor.optional_deps, mod_num)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+stopAllModules stops all the modules handled by the Manager and waits for them to stop. The dependents are stopped
+before the modules they depend on (optionally too).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_states_ch – the channel returned by Utils.SubscribeModStatesMODULES()
+*/
+func stopAllModules(mod_states_ch chan Utils.ModStateChange) {
+	var mods_stopping [Utils.MODS_ARRAY_SIZE]bool
+	for mod_num := range _MAP_MOD_NUM_START {
+		mods_stopping[mod_num] = true
+	}
+
+	for {
+		var any_running bool = false
+		for mod_num := range _MAP_MOD_NUM_START {
+			if !isModRunning(mod_num) {
+				continue
+			}
+			any_running = true
+
+			if !hasRunningDependents(mod_num, mods_stopping) {
+				setModActive(mod_num, false)
+				modules_GL[mod_num].TransitionState(Utils.MOD_STATE_STOPPING)
+			}
+		}
+
+		if !any_running {
+			return
+		}
+
+		// Check again as soon as any module changes state
+		_, _ = Utils.WaitModStateChangeMODULES(mod_states_ch, func() bool {return false}, 1)
+	}
+}
@@ -28,13 +28,35 @@ import (
 	MOD_3 "Speech"
 	MOD_11 "SpeechRecognition"
 	MOD_10 "SystemState"
+	"SpeechQueue/SpeechQueue"
 	"Utils"
 )
 
 // Make sure to add the modules support check for each new module too...
-var _MAP_MOD_NUM_START = map[int]func(modules *Utils.Module){
-	Utils.NUM_MOD_Speech:            MOD_3.Start,
-	Utils.NUM_MOD_RemindersReminder: MOD_9.Start,
-	Utils.NUM_MOD_SystemChecker:     MOD_10.Start,
-	Utils.NUM_MOD_SpeechRecognition: MOD_11.Start,
+// MOD_9 also needs the User Locator output, but that one runs on the server, so it can't be declared here.
+var _MAP_MOD_NUM_START = map[int]_ModDescriptor{
+	Utils.NUM_MOD_Speech:            {start: MOD_3.Start},
+	// The reminders' speeches are only queued, so they're spoken whenever Speech runs
+	Utils.NUM_MOD_RemindersReminder: {start: MOD_9.Start, optional_deps: []int{Utils.NUM_MOD_Speech}},
+	Utils.NUM_MOD_SystemChecker:     {start: MOD_10.Start},
+	Utils.NUM_MOD_SpeechRecognition: {start: MOD_11.Start},
+}
+
+/*
+queueSpeech queues a speech, for the plugins.
+
+-----------------------------------------------------------
+
+– Params:
+  - text – the text to speak
+  - priority – one of the SpeechQueue.PRIORITY_ constants
+  - device_id – ignored on the client (the speech is always on this device)
+
+– Returns:
+  - nil (always queued)
+ */
+func queueSpeech(text string, priority int, device_id string) error {
+	MOD_3.QueueSpeech(text, priority, SpeechQueue.MODE_DEFAULT)
+
+	return nil
 }
@@ -24,6 +24,8 @@
 package MOD_1
 
 import (
+	"errors"
+
 	MOD_5 "EmailSender"
 	MOD_7 "GPTCommunicator"
 	MOD_6 "OnlineInfoChk"
@@ -35,12 +37,43 @@ import (
 )
 
 // Make sure to add the modules support check for each new module too...
-var _MAP_MOD_NUM_START = map[int]func(modules *Utils.Module){
-	Utils.NUM_MOD_SMARTChecker:      MOD_2.Start,
-	Utils.NUM_MOD_RssFeedNotifier:   MOD_4.Start,
-	Utils.NUM_MOD_EmailSender:       MOD_5.Start,
-	Utils.NUM_MOD_OnlineInfoChk:     MOD_6.Start,
-	Utils.NUM_MOD_GPTCommunicator:   MOD_7.Start,
-	Utils.NUM_MOD_WebsiteBackend:    MOD_8.Start,
-	Utils.NUM_MOD_UserLocator:       MOD_12.Start,
+var _MAP_MOD_NUM_START = map[int]_ModDescriptor{
+	Utils.NUM_MOD_SMARTChecker:      {start: MOD_2.Start},
+	Utils.NUM_MOD_RssFeedNotifier:   {start: MOD_4.Start},
+	Utils.NUM_MOD_EmailSender:       {start: MOD_5.Start},
+	Utils.NUM_MOD_OnlineInfoChk:     {start: MOD_6.Start},
+	// The devices' state for SpeakOnDevice() comes from the User Locator, and the Online Info Checker is only used
+	// for some commands
+	Utils.NUM_MOD_GPTCommunicator:   {start: MOD_7.Start, deps: []int{Utils.NUM_MOD_UserLocator},
+		optional_deps: []int{Utils.NUM_MOD_OnlineInfoChk}},
+	Utils.NUM_MOD_WebsiteBackend:    {start: MOD_8.Start},
+	Utils.NUM_MOD_UserLocator:       {start: MOD_12.Start},
+}
+
+/*
+queueSpeech speaks a text on a device, for the plugins.
+
+-----------------------------------------------------------
+
+– Params:
+  - text – the text to speak
+  - priority – ignored on the server
+  - device_id – the ID of the device to speak on, or empty for the device always with the user
+
+– Returns:
+  - nil if the text was sent to the device, an error otherwise
+ */
+func queueSpeech(text string, priority int, device_id string) error {
+	if "" == device_id {
+		device_id = Utils.GetUserSettingsSETTINGS().MOD_12.Devices_info.AlwaysWith_device_id
+	}
+
+	switch MOD_7.SpeakOnDevice(device_id, text) {
+		case MOD_7.ALREADY_WRITING:
+			return errors.New("already speaking on a device")
+		case MOD_7.DEVICE_NOT_ACTIVE:
+			return errors.New("the device \"" + device_id + "\" is not active")
+	}
+
+	return nil
 }
@@ -0,0 +1,330 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_1
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"Registry/Registry"
+	"SpeechQueue/SpeechQueue"
+	"Utils"
+)
+
+// plugins_crash_histories_GL is the crash history of each plugin by name. Protected by crash_histories_mutex_GL.
+var plugins_crash_histories_GL map[string]*ModCrashHistory = make(map[string]*ModCrashHistory)
+// plugins_time_started_GL is the time each plugin was last started in milliseconds. Protected by
+// crash_histories_mutex_GL.
+var plugins_time_started_GL map[string]int64 = make(map[string]int64)
+// plugins_problems_GL are the last problems found with the plugins' manifests, to log them only when they change.
+var plugins_problems_GL string = ""
+
+func init() {
+	Utils.RegisterPluginMethodPLUGINS("RegistryGet", pluginRegistryGet)
+	Utils.RegisterPluginMethodPLUGINS("RegistrySet", pluginRegistrySet)
+	Utils.RegisterPluginMethodPLUGINS("QueueSpeech", pluginQueueSpeech)
+}
+
+/*
+checkPlugins reloads the plugins' manifests and starts and stops the plugins like the built-in modules: the supported
+and enabled ones are kept running, with the same restart backoff and crash-loop and heartbeat checks.
+*/
+func checkPlugins() {
+	var problems []string = nil
+	for _, err := range Utils.LoadPluginsPLUGINS() {
+		problems = append(problems, err.Error())
+	}
+	if strings.Join(problems, "\n") != plugins_problems_GL {
+		plugins_problems_GL = strings.Join(problems, "\n")
+		for _, problem := range problems {
+			moduleInfo_GL.Log.Error("Invalid plugin manifest", "error", problem)
+		}
+	}
+
+	for _, plugin := range Utils.GetPluginsPLUGINS() {
+		var module *Utils.Module = &plugin.Module
+
+		checkPluginHeartbeat(plugin)
+
+		if state, time_state := module.GetStateAndTime(); state == Utils.MOD_STATE_CRASHED {
+			registerPluginCrash(plugin, time_state)
+		}
+
+		var must_run bool = module.IsEnabled() && !plugin.IsRemoved() && plugin.IsSupported()
+		if !module.IsRunning() && !module.IsEnabled() {
+			module.TransitionState(Utils.MOD_STATE_DISABLED)
+		}
+		if must_run && !module.IsRunning() && canRestartPlugin(plugin.Manifest.Name) {
+			crash_histories_mutex_GL.Lock()
+			plugins_time_started_GL[plugin.Manifest.Name] = time.Now().UnixMilli()
+			crash_histories_mutex_GL.Unlock()
+
+			if err := plugin.Start(); nil != err {
+				moduleInfo_GL.Log.Error("Error starting plugin", "plugin", plugin.Manifest.Name, "error", err)
+			}
+		} else if !must_run && module.GetState() == Utils.MOD_STATE_RUNNING {
+			plugin.Stop()
+		}
+	}
+}
+
+/*
+checkPluginHeartbeat checks if a plugin missed its heartbeats and, if it did, marks it as hung and stops it (which
+kills it if it doesn't exit in time).
+
+-----------------------------------------------------------
+
+– Params:
+  - plugin – the plugin
+ */
+func checkPluginHeartbeat(plugin *Utils.Plugin) {
+	var module *Utils.Module = &plugin.Module
+	if module.GetState() != Utils.MOD_STATE_RUNNING {
+		return
+	}
+
+	last_heartbeat, max_period_s := module.GetHeartbeat()
+	if max_period_s <= 0 || time.Now().UnixMilli() - last_heartbeat <= int64(max_period_s)*1000 {
+		return
+	}
+
+	if module.TransitionState(Utils.MOD_STATE_HUNG) {
+		_ = Utils.SendModErrorEmailMODULES(Utils.NUM_MOD_ModManager, "The plugin " + plugin.Manifest.Name +
+			" stopped sending heartbeats (last one on " + Utils.GetDateTimeStrTIMEDATE(last_heartbeat) +
+			", maximum period of " + strconv.Itoa(max_period_s) + " seconds) and is considered hung. It was " +
+			"signalled to stop and will be killed and restarted if it doesn't stop in time.")
+		plugin.Stop()
+	}
+}
+
+/*
+registerPluginCrash registers the last crash of a plugin in its crash history and disables it in case it's
+crash-looping.
+
+-----------------------------------------------------------
+
+– Params:
+  - plugin – the plugin
+  - time_crash – the time of the crash in milliseconds (the time of the transition to the crashed state)
+ */
+func registerPluginCrash(plugin *Utils.Plugin, time_crash int64) {
+	var name string = plugin.Manifest.Name
+
+	crash_histories_mutex_GL.Lock()
+	if nil == plugins_crash_histories_GL[name] {
+		plugins_crash_histories_GL[name] = &ModCrashHistory{}
+	}
+	added, crash_looping, crashes_in_window := plugins_crash_histories_GL[name].addCrash(plugins_time_started_GL[name],
+		time_crash, plugin.Module.GetCrashError())
+	crash_histories_mutex_GL.Unlock()
+	if !added {
+		return
+	}
+
+	if crash_looping {
+		plugin.Module.SetEnabled(false)
+
+		_ = Utils.SendModErrorEmailMODULES(Utils.NUM_MOD_ModManager, "The plugin " + name + " crashed " +
+			strconv.Itoa(crashes_in_window) + " times in the last " + strconv.FormatInt(_CRASH_LOOP_WINDOW_S/60, 10) +
+			" minutes and was disabled until it's re-enabled. Last error:\n\n" + plugin.Module.GetCrashError())
+	}
+
+	updateCrashHistoryReg()
+}
+
+/*
+canRestartPlugin checks if a plugin can be (re)started, according to its crash history.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the plugin
+
+– Returns:
+  - true if the plugin is not crash-looping and its restart backoff time has passed, false otherwise
+ */
+func canRestartPlugin(name string) bool {
+	crash_histories_mutex_GL.Lock()
+	defer crash_histories_mutex_GL.Unlock()
+
+	var crash_history *ModCrashHistory = plugins_crash_histories_GL[name]
+
+	return nil == crash_history ||
+		(!crash_history.Crash_looping && time.Now().UnixMilli() >= crash_history.Next_start)
+}
+
+/*
+ReenablePlugin re-enables a plugin, resetting its crash-looping state in case it was disabled because of it.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the plugin
+
+– Returns:
+  - false if there's no plugin with that name, true otherwise
+ */
+func ReenablePlugin(name string) bool {
+	var plugin *Utils.Plugin = Utils.GetPluginPLUGINS(name)
+	if nil == plugin {
+		return false
+	}
+
+	crash_histories_mutex_GL.Lock()
+	if crash_history := plugins_crash_histories_GL[name]; nil != crash_history {
+		crash_history.Crash_looping = false
+		crash_history.Consec_crashes = 0
+		crash_history.Next_start = 0
+	}
+	crash_histories_mutex_GL.Unlock()
+
+	plugin.Module.SetEnabled(true)
+
+	updateCrashHistoryReg()
+
+	return true
+}
+
+/*
+getPluginsCrashHistories gets a copy of the crash histories of the plugins.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the crash histories by plugin name
+ */
+func getPluginsCrashHistories() map[string]ModCrashHistory {
+	crash_histories_mutex_GL.Lock()
+	defer crash_histories_mutex_GL.Unlock()
+
+	var crash_histories map[string]ModCrashHistory = make(map[string]ModCrashHistory)
+	for name, crash_history := range plugins_crash_histories_GL {
+		var crash_history_copy ModCrashHistory = *crash_history
+		crash_history_copy.Crashes = append([]CrashInfo(nil), crash_history.Crashes...)
+		crash_histories[name] = crash_history_copy
+	}
+
+	return crash_histories
+}
+
+/*
+stopAllPlugins stops all the plugins and waits for them to stop (they're killed if they take too long).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_states_ch – the channel returned by Utils.SubscribeModStatesMODULES()
+*/
+func stopAllPlugins(mod_states_ch chan Utils.ModStateChange) {
+	for {
+		var any_running bool = false
+		for _, plugin := range Utils.GetPluginsPLUGINS() {
+			if !plugin.Module.IsRunning() {
+				continue
+			}
+			any_running = true
+
+			if plugin.Module.GetState() == Utils.MOD_STATE_RUNNING {
+				plugin.Stop()
+			}
+		}
+
+		if !any_running {
+			return
+		}
+
+		// Check again as soon as any plugin changes state
+		_, _ = Utils.WaitModStateChangeMODULES(mod_states_ch, func() bool {return false}, 1)
+	}
+}
+
+// pluginRegistryGet handles the "RegistryGet" method: {Key, Prev} -> {Type, Data, Time_updated}, with Prev true to
+// get the previous data instead of the current one.
+func pluginRegistryGet(plugin *Utils.Plugin, params json.RawMessage) (any, error) {
+	var get_params struct {
+		Key  string
+		Prev bool
+	}
+	if err := Utils.DecodePluginParamsPLUGINS(params, &get_params); nil != err {
+		return nil, err
+	}
+
+	var value *Registry.Value = Registry.GetValue(get_params.Key)
+	if nil == value {
+		return nil, errors.New("no Registry value with the key \"" + get_params.Key + "\"")
+	}
+
+	return map[string]any{
+		"Type":         value.GetType(),
+		"Data":         value.GetData(!get_params.Prev, nil),
+		"Time_updated": value.GetTimeUpdated(!get_params.Prev),
+	}, nil
+}
+
+// pluginRegistrySet handles the "RegistrySet" method: {Key, Data} -> true if the data was set (false if it was the
+// same). The data must be of the value's type.
+func pluginRegistrySet(plugin *Utils.Plugin, params json.RawMessage) (any, error) {
+	var set_params struct {
+		Key  string
+		Data json.RawMessage
+	}
+	if err := Utils.DecodePluginParamsPLUGINS(params, &set_params); nil != err {
+		return nil, err
+	}
+
+	var value *Registry.Value = Registry.GetValue(set_params.Key)
+	if nil == value {
+		return nil, errors.New("no Registry value with the key \"" + set_params.Key + "\"")
+	}
+
+	data, err := value.UnmarshalData(set_params.Data)
+	if nil != err {
+		return nil, errors.New("the data is not of the value's type " + value.GetType() + ": " + err.Error())
+	}
+
+	return value.SetData(data, false), nil
+}
+
+// pluginQueueSpeech handles the "QueueSpeech" method: {Text, Priority, Device_id}, with Priority one of the
+// SpeechQueue.PRIORITY_ constants. On the server, the text is spoken on the given device (or the one always with the
+// user).
+func pluginQueueSpeech(plugin *Utils.Plugin, params json.RawMessage) (any, error) {
+	var speech_params struct {
+		Text      string
+		Priority  int
+		Device_id string
+	}
+	if err := Utils.DecodePluginParamsPLUGINS(params, &speech_params); nil != err {
+		return nil, err
+	}
+	if "" == speech_params.Text {
+		return nil, errors.New("Text is required")
+	}
+	if speech_params.Priority < SpeechQueue.PRIORITY_LOW || speech_params.Priority >= SpeechQueue.NUM_PRIORITIES {
+		return nil, errors.New("invalid priority " + strconv.Itoa(speech_params.Priority))
+	}
+
+	return nil, queueSpeech(speech_params.Text, speech_params.Priority, speech_params.Device_id)
+}
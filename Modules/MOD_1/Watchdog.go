@@ -0,0 +1,75 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package MOD_1
+
+import (
+	"strconv"
+	"time"
+
+	"Utils"
+)
+
+// _HUNG_STOP_TIMEOUT_S is the time a hung module has to stop by itself before being forcibly restarted.
+const _HUNG_STOP_TIMEOUT_S int64 = 60
+
+/*
+checkModHeartbeat checks if a module missed its heartbeats and handles it in case it did.
+
+A module that missed its heartbeats is marked as hung (which signals it to stop) and the developer is warned by email.
+If it doesn't stop in _HUNG_STOP_TIMEOUT_S, it's forcibly stopped and registered as crashed, so that the Manager starts
+it again (after the restart backoff time).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+ */
+func checkModHeartbeat(mod_num int) {
+	var module *Utils.Module = &modules_GL[mod_num]
+	var now int64 = time.Now().UnixMilli()
+
+	switch module.GetState() {
+		case Utils.MOD_STATE_RUNNING, Utils.MOD_STATE_STOPPING:
+			last_heartbeat, max_period_s := module.GetHeartbeat()
+			if max_period_s <= 0 || now - last_heartbeat <= int64(max_period_s)*1000 {
+				return
+			}
+
+			if module.TransitionState(Utils.MOD_STATE_HUNG) {
+				// Only one email per incident - the module can only go to the hung state again after being restarted.
+				_ = Utils.SendModErrorEmailMODULES(mod_num, "The module stopped sending heartbeats (last one on " +
+					Utils.GetDateTimeStrTIMEDATE(last_heartbeat) + ", maximum period of " + strconv.Itoa(max_period_s) +
+					" seconds) and is considered hung. It was signalled to stop and will be forcibly restarted if it " +
+					"doesn't stop in " + strconv.FormatInt(_HUNG_STOP_TIMEOUT_S, 10) + " seconds.")
+			}
+		case Utils.MOD_STATE_HUNG:
+			if now - module.GetStateTime() <= _HUNG_STOP_TIMEOUT_S*1000 {
+				return
+			}
+
+			if module.ForceStop() {
+				setModActive(mod_num, false)
+				registerModCrash(mod_num, module.GetStateTime(), "The module hung and was forcibly stopped")
+			}
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"Registry/Registry"
 	"Utils"
 	"VISOR_Client/ClientRegKeys"
+	"context"
+	"sync"
 )
 
 // Modules Manager //
@@ -33,20 +35,49 @@ const _TIME_SLEEP_S int = 5
 
 var modules_GL []Utils.Module
 
+// mods_active_mutex_GL makes the changes to the active modules Registry value one at a time.
+var mods_active_mutex_GL sync.Mutex
+
 type _MGI any
 var (
-	realMain Utils.RealMain = nil
+	realMain Utils.RealMainCtx = nil
 	moduleInfo_GL Utils.ModuleInfo[_MGI]
 )
 func Start(modules []Utils.Module) {
 	modules_GL = modules
-	Utils.ModStartup[_MGI](realMain, &modules_GL[Utils.NUM_MOD_ModManager])
+	Utils.ModStartupCtx[_MGI](realMain, &modules_GL[Utils.NUM_MOD_ModManager])
 }
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
-		Registry.GetValue(ClientRegKeys.K_MODULES_ACTIVE).SetData(int64(0), false)
+		var module_stop Utils.StopSignal = func() bool {
+			return nil != ctx.Err()
+		}
+
+		Registry.GetValue(ClientRegKeys.K_MODULES_ACTIVE).SetData([]string{}, false)
+		updateCrashHistoryReg()
+		applySavedModsEnabled()
+
+		// For visorctl
+		stop_control, err := Utils.StartControlServerCONTROL(Utils.GetUserSettingsSETTINGS().PersonalConsts.VISOR_server)
+		if nil != err {
+			moduleInfo_GL.Log.Warning("Couldn't start the control endpoint", "error", err)
+		} else {
+			defer stop_control()
+		}
+
+		if !Utils.GetUserSettingsSETTINGS().PersonalConsts.VISOR_server {
+			// Apart from the modules' checks, so that a slow server doesn't delay them
+			var sync_wg sync.WaitGroup
+			sync_wg.Add(1)
+			go func() {
+				defer sync_wg.Done()
+
+				syncRegistry(ctx)
+			}()
+			defer sync_wg.Wait()
+		}
 
 		// Check all modules' support and put on a list to later warn if there were changes of support or not.
 		var mod_support_list [Utils.MODS_ARRAY_SIZE]bool
@@ -54,7 +85,19 @@ func init() {realMain =
 			mod_support_list[mod_num] = Utils.IsModSupportedMODULES(mod_num)
 		}
 
+		// Modules are started in this order and stopped in the reverse one
+		var mods_order []int = getModsStartOrder()
+
+		var mod_states_ch chan Utils.ModStateChange = Utils.SubscribeModStatesMODULES()
+		defer Utils.UnsubscribeModStatesMODULES(mod_states_ch)
+
+		// Not for the modules - for the systemd watchdog, which only gets pings while the Manager keeps checking them
+		moduleInfo_GL.SetHeartbeatPeriod(_TIME_SLEEP_S * 6)
+
+		var ready_notified bool = false
 		for {
+			moduleInfo_GL.Heartbeat()
+
 			var modules_to_start [Utils.MODS_ARRAY_SIZE]bool
 			var modules_to_stop [Utils.MODS_ARRAY_SIZE]bool
 
@@ -64,9 +107,9 @@ func init() {realMain =
 				}
 
 				// Only start the modules supported by the server or client depending on the VISOR_SERVER constant.
-				if Utils.User_settings_GL.PersonalConsts.VISOR_server && (Utils.MOD_NUMS_SUPPORT[mod_num] & Utils.MOD_SERVER == 0) {
+				if Utils.GetUserSettingsSETTINGS().PersonalConsts.VISOR_server && (Utils.MOD_NUMS_SUPPORT[mod_num] & Utils.MOD_SERVER == 0) {
 					continue
-				} else if !Utils.User_settings_GL.PersonalConsts.VISOR_server && (Utils.MOD_NUMS_SUPPORT[mod_num] & Utils.MOD_CLIENT == 0) {
+				} else if !Utils.GetUserSettingsSETTINGS().PersonalConsts.VISOR_server && (Utils.MOD_NUMS_SUPPORT[mod_num] & Utils.MOD_CLIENT == 0) {
 					continue
 				}
 
@@ -91,14 +134,36 @@ func init() {realMain =
 				//log.Println("-----------------------")
 				//log.Println("Module " + mod_name + " is supported: " + strconv.FormatBool(module_supported))
 				//log.Println("Module " + mod_name + " is running: " + strconv.FormatBool(isModRunning(mod_num)))
-				//log.Println("Module " + mod_name + " is enabled: " + strconv.FormatBool(modules_GL[mod_num].Enabled))
+				//log.Println("Module " + mod_name + " is enabled: " + strconv.FormatBool(modules_GL[mod_num].IsEnabled()))
+
+				checkModHeartbeat(mod_num)
+
+				if state, time_state := modules_GL[mod_num].GetStateAndTime(); state == Utils.MOD_STATE_CRASHED {
+					// Register the crash only once and decide when the module can be restarted.
+					registerModCrash(mod_num, time_state, modules_GL[mod_num].GetCrashError())
+				}
+
+				if !isModRunning(mod_num) && !modules_GL[mod_num].IsEnabled() {
+					modules_GL[mod_num].TransitionState(Utils.MOD_STATE_DISABLED)
+				}
 
 				if module_supported {
-					if !isModRunning(mod_num) && modules_GL[mod_num].Enabled {
+					if !isModRunning(mod_num) && modules_GL[mod_num].IsEnabled() {
+						if !canRestartMod(mod_num) {
+							// Crash-looping or still waiting for the restart backoff time to pass.
+							continue
+						}
+						if dep_num := getModDepDisabled(mod_num); -1 != dep_num {
+							// Refuse to start a module without the modules it needs.
+							setModHeldBack(mod_num, dep_num)
+
+							continue
+						}
+
 						//log.Println("Starting module: " + mod_name)
 
 						modules_to_start[mod_num] = true
-					} else if isModRunning(mod_num) && !modules_GL[mod_num].Enabled {
+					} else if isModRunning(mod_num) && (!modules_GL[mod_num].IsEnabled() || -1 != getModDepDisabled(mod_num)) {
 						//log.Println("Stopping module: " + mod_name)
 
 						modules_to_stop[mod_num] = true
@@ -112,37 +177,118 @@ func init() {realMain =
 				}
 			}
 
-			// Start the modules
-			for mod_num := 0; mod_num < Utils.MODS_ARRAY_SIZE; mod_num++ {
-				if modules_to_start[mod_num] && modules_GL[mod_num].Enabled {
-					var value *Registry.Value = Registry.GetValue(ClientRegKeys.K_MODULES_ACTIVE)
-					value.SetLong(value.GetLong(true) | (1 << mod_num), false)
-					modules_GL[mod_num].Stop = false
-					var start_func = _MAP_MOD_NUM_START[mod_num]
-					if start_func != nil {
-						start_func(&modules_GL[mod_num])
+			// Start the modules (the dependencies first)
+			for _, mod_num := range mods_order {
+				// The dependencies were either already running or were started before in this loop
+				if !modules_to_start[mod_num] || !modules_GL[mod_num].IsEnabled() {
+					continue
+				}
+				var dep_num int = getModDepNotRunning(mod_num)
+				setModHeldBack(mod_num, dep_num)
+				if -1 != dep_num {
+					continue
+				}
+
+				setModActive(mod_num, true)
+				registerModStart(mod_num)
+				var start_func = _MAP_MOD_NUM_START[mod_num].start
+				if start_func != nil {
+					start_func(&modules_GL[mod_num])
+				}
+			}
+
+			if !ready_notified {
+				// The first modules were started, so systemd can consider VISOR started
+				_ = Utils.SdNotifySYSTEMD("READY=1")
+				ready_notified = true
+			}
+
+			checkPlugins()
+
+			// Modules depending on ones being stopped must be stopped too
+			for _, mod_num := range mods_order {
+				for _, dep_num := range _MAP_MOD_NUM_START[mod_num].deps {
+					if modules_to_stop[dep_num] && isModRunning(mod_num) {
+						modules_to_stop[mod_num] = true
 					}
 				}
 			}
 
-			// Stop the modules
-			for mod_num := 0; mod_num < Utils.MODS_ARRAY_SIZE; mod_num++ {
-				if modules_to_stop[mod_num] {
-					var value *Registry.Value = Registry.GetValue(ClientRegKeys.K_MODULES_ACTIVE)
-					value.SetLong(value.GetLong(true) & ^(1 << mod_num), false)
-					modules_GL[mod_num].Stop = true
+			// Stop the modules (the dependents first - a module is only stopped after all its dependents stopped, on a
+			// later check, and after the ones only using it if they're being stopped too)
+			for i := len(mods_order) - 1; i >= 0; i-- {
+				var mod_num int = mods_order[i]
+				if modules_to_stop[mod_num] && !hasRunningDependents(mod_num, modules_to_stop) {
+					setModActive(mod_num, false)
+					modules_GL[mod_num].TransitionState(Utils.MOD_STATE_STOPPING)
 				}
 			}
 
 			//////////////////////////////////////////////////////////////////
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
+			// Wait for the next check, but do it right away if any module changes state (like crashing)
+			if _, stop := Utils.WaitModStateChangeMODULES(mod_states_ch, module_stop, _TIME_SLEEP_S); stop {
+				stopAllModules(mod_states_ch)
+				stopAllPlugins(mod_states_ch)
+
 				return
 			}
 		}
 	}
 }
 
+/*
+syncRegistry synchronizes the Registry with the server every _TIME_SLEEP_S seconds until the context is cancelled
+(which also cancels a synchronization in progress).
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context of the module
+*/
+func syncRegistry(ctx context.Context) {
+	for {
+		if err := Registry.SyncWithServer(ctx); nil != err && nil == ctx.Err() {
+			moduleInfo_GL.Log.Debug("Error synchronizing the Registry with the server", "error", err)
+		}
+
+		if Utils.WaitWithCtxTIMEDATE(ctx, _TIME_SLEEP_S) {
+			return
+		}
+	}
+}
+
 func isModRunning(mod_num int) bool {
-	return !modules_GL[mod_num].Stopped
+	return modules_GL[mod_num].IsRunning()
+}
+
+/*
+setModActive adds or removes a module from the active modules Registry value.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - active – true to add the module, false to remove it
+ */
+func setModActive(mod_num int, active bool) {
+	mods_active_mutex_GL.Lock()
+	defer mods_active_mutex_GL.Unlock()
+
+	var value *Registry.Value = Registry.GetValue(ClientRegKeys.K_MODULES_ACTIVE)
+	active_mods, _ := Registry.GetTyped[[]string](value, true)
+
+	// Keep them in the order of the module numbers
+	var new_active_mods []string = []string{}
+	for i := 0; i < Utils.MODS_ARRAY_SIZE; i++ {
+		if _, ok := Utils.MOD_NUMS_NAMES[i]; !ok {
+			continue
+		}
+
+		var mod_name string = Utils.GetModNameMODULES(i)
+		if (i == mod_num && active) || (i != mod_num && Utils.ContainsSLICES(active_mods, mod_name)) {
+			new_active_mods = append(new_active_mods, mod_name)
+		}
+	}
+	Registry.SetTyped(value, new_active_mods, false)
 }
@@ -64,11 +64,13 @@ var (
 )
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(60)
+
 		device_info_GL = ULComm.DeviceInfo{
-			Device_id:    Utils.User_settings_GL.PersonalConsts.Device_ID,
+			Device_id:    Utils.GetUserSettingsSETTINGS().PersonalConsts.Device_ID,
 		}
 		var curr_mouse_position _MousePosition
 		for {
@@ -132,6 +134,8 @@ func init() {realMain =
 			device_info_GL.Last_comm = time.Now().Unix()
 			_ = device_info_GL.SendInfo()
 
+			moduleInfo_GL.Heartbeat()
+
 			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
 				return
 			}
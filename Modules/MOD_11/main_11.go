@@ -25,6 +25,7 @@ import (
 	"Registry/Registry"
 	"Utils"
 	"VISOR_Client/ClientRegKeys"
+	"context"
 	porcupine "github.com/Picovoice/porcupine/binding/go/v3"
 	"github.com/gordonklaus/portaudio"
 )
@@ -38,16 +39,18 @@ var (
 
 type _MGI any
 var (
-	realMain        Utils.RealMain = nil
+	realMain        Utils.RealMainCtx = nil
 	moduleInfo_GL   Utils.ModuleInfo[_MGI]
 )
-func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
+func Start(module *Utils.Module) {Utils.ModStartupCtx[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(60)
+
 		porcupine_ := porcupine.Porcupine{
-			AccessKey: Utils.User_settings_GL.PersonalConsts.Picovoice_API_key, // from Picovoice Console (https://console.picovoice.ai/)
+			AccessKey: Utils.GetUserSettingsSETTINGS().PersonalConsts.Picovoice_API_key, // from Picovoice Console (https://console.picovoice.ai/)
 			KeywordPaths: []string{
 				moduleInfo_GL.ModDirsInfo.ProgramData.Add2(false, "Hey-Visor_en_windows_v3_0_0.ppn").GPathToStringConversion(),
 			},
@@ -73,26 +76,39 @@ func init() {realMain =
 			panic(err)
 		}
 
+		go func() {
+			// Abort the stream as soon as the module is signalled to stop, in case it's blocked waiting for audio.
+			<-ctx.Done()
+
+			_ = stream.Abort()
+		}()
+
 		for {
-			keywordIndex, _ := porcupine_.Process(getNextFrameAudio())
-			if keywordIndex >= 0 {
-				Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG).SetData(true, false)
+			frame, err := getNextFrameAudio()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				panic(err)
 			}
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, 0) {
-				return
+			moduleInfo_GL.Heartbeat()
+
+			keywordIndex, _ := porcupine_.Process(frame)
+			if keywordIndex >= 0 {
+				Registry.GetValue(ClientRegKeys.K_SHOW_APP_SIG).SetData(true, false)
 			}
 		}
 	}
 }
 
-func getNextFrameAudio() []int16 {
+func getNextFrameAudio() ([]int16, error) {
 	err := stream.Read()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return in
+	return in, nil
 }
 
 func closeAudio() {
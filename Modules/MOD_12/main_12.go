@@ -23,12 +23,12 @@ package MOD_12
 
 import (
 	"GPT/GPT"
+	"Registry/Registry"
 	"ULComm/ULComm"
 	"Utils"
 	"Utils/UtilsSWA"
-	"log"
+	"VISOR_Server/ServerRegKeys"
 	"sort"
-	"time"
 )
 
 // User Locator //
@@ -56,15 +56,21 @@ var (
 )
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(60)
+
 		var user_location ULComm.UserLocation
 		var user_location_json Utils.GPath = Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, "user_location.json")
 		if user_location_json.Exists() {
 			_ = Utils.FromJsonGENERAL(user_location_json.ReadFile(), &user_location)
 		}
 
+		var subscription *Utils.Subscription[Utils.DeviceInfoUpdate] = Utils.TOPIC_DEVICES_INFO.Subscribe(
+			Utils.GetModSubscriberEVENTS(Utils.NUM_MOD_UserLocator))
+		defer subscription.Close()
+
 		var device_infos []*_IntDeviceInfo = nil
 		for {
 			var modUserInfo _ModUserInfo
@@ -72,17 +78,31 @@ func init() {realMain =
 				panic(err)
 			}
 
+			// Store the information sent by the devices (the files in the devices folder are the latest information of
+			// each device)
+			for event := subscription.TryNext(); nil != event; event = subscription.TryNext() {
+				var device_file Utils.GPath = moduleInfo_GL.ModDirsInfo.UserData.Add2(false, "devices",
+					event.Payload.Device_id + ".json")
+				if err := device_file.WriteTextFile(event.Payload.Info_json, false); nil != err {
+					moduleInfo_GL.Log.Warning("Error writing device file", "device_id", event.Payload.Device_id,
+						"error", err)
+
+					// Try again later
+					break
+				}
+				subscription.Ack(event)
+			}
+
 			Device_infos_ULComm_GL = nil
 			for _, file_info := range moduleInfo_GL.ModDirsInfo.UserData.Add2(true, "devices").GetFileList() {
 				var device ULComm.DeviceInfo
 				if err := Utils.FromJsonGENERAL(file_info.GPath.ReadFile(), &device); err == nil {
 					Device_infos_ULComm_GL = append(Device_infos_ULComm_GL, device)
 				} else {
-					log.Println("Error reading device file", file_info.GPath, ":", err)
+					moduleInfo_GL.Log.Warning("Error reading device file", "file", file_info.Name, "error", err)
 				}
 			}
 
-			log.Println("--------------------------------")
 			for _, device_ULComm := range Device_infos_ULComm_GL {
 				var device_info *_IntDeviceInfo
 				for _, device_info1 := range device_infos {
@@ -135,7 +155,7 @@ func init() {realMain =
 							var distance int = UtilsSWA.GetRealDistanceRssiLOCRELATIVE(beacon.RSSI, UtilsSWA.DEFAULT_TX_POWER)
 
 							if distance <= location_info.Max_distance &&
-									device_info.Last_comm + location_info.Last_detection >= time.Now().Unix() {
+									device_info.Last_comm + location_info.Last_detection >= Utils.GetClockTIMEDATE().Now().Unix() {
 								// If the device was near the location and the last communication was recent, then the
 								// user is near the location.
 								device_info.Curr_location = location_info.Location
@@ -150,15 +170,19 @@ func init() {realMain =
 					device_info.Last_known_location = device_info.Curr_location
 				}
 
-				log.Println("device_info:", device_info)
+				moduleInfo_GL.Log.Debug("Device info", "device_info", *device_info)
 
 				device_infos = append(device_infos, device_info)
 			}
 
 			var curr_user_location string = getUserLocation(modUserInfo, device_infos)
-			log.Println("Current user location:", curr_user_location)
+			moduleInfo_GL.Log.Debug("Current user location", "location", curr_user_location)
 			updateUserLocation(&user_location, curr_user_location)
 			_ = user_location_json.WriteTextFile(*Utils.ToJsonGENERAL(user_location), false)
+			// For the clients too, through the Registry synchronization
+			if value := Registry.GetValue(ServerRegKeys.K_USER_LOCATION); value != nil {
+				value.SetData(user_location, false)
+			}
 
 			// TODO: Also check if the location changed on some device. The user must be with it then, even if not using
 			//  it.
@@ -166,6 +190,8 @@ func init() {realMain =
 			// TODO: Give priorities to devices. You're always with the phone even if not using it, but not with the
 			//  computer.
 
+			moduleInfo_GL.Heartbeat()
+
 			if Utils.WaitWithStopTIMEDATE(module_stop, TIME_SLEEP_S) {
 				return
 			}
@@ -182,7 +208,7 @@ func IsDeviceActive(device_id string) bool {
 	if device_id == GPT.ALL_DEVICES_ID {
 		// Check if any device is active
 		for _, device_info := range device_infos {
-			if time.Now().Unix() - device_info.Last_comm <= LAST_COMM_MAX {
+			if Utils.GetClockTIMEDATE().Now().Unix() - device_info.Last_comm <= LAST_COMM_MAX {
 				return true
 			}
 		}
@@ -192,7 +218,7 @@ func IsDeviceActive(device_id string) bool {
 
 	for _, device_info := range device_infos {
 		if device_info.Device_id == device_id {
-			return time.Now().Unix() - device_info.Last_comm <= LAST_COMM_MAX
+			return Utils.GetClockTIMEDATE().Now().Unix() - device_info.Last_comm <= LAST_COMM_MAX
 		}
 	}
 
@@ -211,7 +237,9 @@ func getIntDeviceInfos() []*_IntDeviceInfo {
 				Curr_location: UNKNOWN_LOCATION,
 			})
 		} else {
-			log.Println("Error reading device file", file_info.GPath, ":", err)
+			// Not always called from inside the module, so its ModuleInfo may not be set
+			Utils.GetModLoggerLOGS(Utils.NUM_MOD_UserLocator).Warning("Error reading device file", "file",
+				file_info.Name, "error", err)
 		}
 	}
 
@@ -231,7 +259,7 @@ func getUserLocation(modUserInfo _ModUserInfo, devices []*_IntDeviceInfo) string
 	sortDevicesByLastUsed(devices)
 	var curr_location string = UNKNOWN_LOCATION
 	for _, device := range devices {
-		if device.Curr_location != UNKNOWN_LOCATION && device.Last_time_used + 5*60 >= time.Now().Unix() {
+		if device.Curr_location != UNKNOWN_LOCATION && device.Last_time_used + 5*60 >= Utils.GetClockTIMEDATE().Now().Unix() {
 			curr_location = device.Curr_location
 
 			break
@@ -258,5 +286,5 @@ func updateUserLocation(user_location *ULComm.UserLocation, new_location string)
 
 		user_location.Curr_location = new_location
 	}
-	user_location.Last_time_checked = time.Now().Unix()
+	user_location.Last_time_checked = Utils.GetClockTIMEDATE().Now().Unix()
 }
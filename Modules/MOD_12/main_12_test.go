@@ -0,0 +1,160 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_12
+
+import (
+	"ULComm/ULComm"
+	"Utils"
+	"Utils/UtilsTest"
+	"testing"
+	"time"
+)
+
+func newHarness(t *testing.T, always_with_device_id string) *UtilsTest.TestHarness[_MGI] {
+	var harness *UtilsTest.TestHarness[_MGI] = UtilsTest.NewTestHarnessTESTING[_MGI](Utils.NUM_MOD_UserLocator,
+		Utils.UserSettings{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	t.Cleanup(harness.Close)
+
+	if err := harness.SetModUserInfo(_ModUserInfo{
+		Devices_info: _DevicesInfo{
+			AlwaysWith_device_id: always_with_device_id,
+		},
+		Locs_info: []_LocInfo{
+			{
+				Type:           "wifi",
+				Name:           "HomeNet",
+				Last_detection: 60,
+				Max_distance:   3,
+				Location:       "home",
+			},
+			{
+				Type:           "wifi",
+				Address:        "00:11:22:33:44:55",
+				Last_detection: 60,
+				Max_distance:   10,
+				Location:       "work",
+			},
+		},
+	}); nil != err {
+		t.Fatal(err)
+	}
+
+	return harness
+}
+
+// publishDeviceInfo publishes the information of a device that communicated now, seeing the given Wi-Fi network.
+func publishDeviceInfo(t *testing.T, device_id string, last_used_ago time.Duration, wifi_network ULComm.ExtBeacon) {
+	var now time.Time = Utils.GetClockTIMEDATE().Now()
+	var device_info ULComm.DeviceInfo = ULComm.DeviceInfo{
+		Device_id:      device_id,
+		Last_comm:      now.Unix(),
+		Last_time_used: now.Add(-last_used_ago).Unix(),
+	}
+	device_info.System_state.Connectivity_info.Wifi_networks = []ULComm.ExtBeacon{wifi_network}
+
+	if err := Utils.TOPIC_DEVICES_INFO.Publish(Utils.DeviceInfoUpdate{
+		Device_id: device_id,
+		Info_json: *Utils.ToJsonGENERAL(device_info),
+	}); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func getUserLocationFile(t *testing.T) ULComm.UserLocation {
+	var user_location ULComm.UserLocation
+	if err := Utils.FromJsonGENERAL(Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, "user_location.json").ReadFile(),
+			&user_location); nil != err {
+		t.Fatal(err)
+	}
+
+	return user_location
+}
+
+func TestWifiLocation(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, "")
+	publishDeviceInfo(t, "phone", 0, ULComm.ExtBeacon{Name: "HomeNet", Address: "AA:BB:CC:DD:EE:FF", RSSI: -60})
+
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+	if location := getUserLocationFile(t).Curr_location; "home" != location {
+		t.Fatalf("expected the user at home, got %s", location)
+	}
+}
+
+func TestFarWifiIsNoLocation(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, "")
+	// About 5 meters away
+	publishDeviceInfo(t, "phone", 0, ULComm.ExtBeacon{Name: "HomeNet", RSSI: -80})
+
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+	if location := getUserLocationFile(t).Curr_location; UNKNOWN_LOCATION != location {
+		t.Fatalf("expected an unknown location, got %s", location)
+	}
+}
+
+func TestAlwaysWithDevicePreferred(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, "")
+	// The phone wasn't used for a while, but it's always with the user - the laptop was left at work
+	publishDeviceInfo(t, "phone", time.Hour, ULComm.ExtBeacon{Name: "HomeNet", RSSI: -60})
+	publishDeviceInfo(t, "laptop", 0, ULComm.ExtBeacon{Name: "WorkNet", Address: "00:11:22:33:44:55", RSSI: -60})
+
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+	if location := getUserLocationFile(t).Curr_location; "work" != location {
+		t.Fatalf("expected the location of the most recently used device (work), got %s", location)
+	}
+
+	harness = newHarness(t, "phone")
+	publishDeviceInfo(t, "phone", time.Hour, ULComm.ExtBeacon{Name: "HomeNet", RSSI: -60})
+	publishDeviceInfo(t, "laptop", 0, ULComm.ExtBeacon{Name: "WorkNet", Address: "00:11:22:33:44:55", RSSI: -60})
+
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+	if location := getUserLocationFile(t).Curr_location; "home" != location {
+		t.Fatalf("expected the location of the device always with the user (home), got %s", location)
+	}
+}
+
+func TestLocationLostWithoutCommunication(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, "")
+	publishDeviceInfo(t, "phone", 0, ULComm.ExtBeacon{Name: "HomeNet", RSSI: -60})
+
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+
+	// No communication for longer than the locations' Last_detection
+	harness.Clock.Advance(2 * time.Minute)
+	if err := harness.Run(realMain, 2); nil != err {
+		t.Fatal(err)
+	}
+	var user_location ULComm.UserLocation = getUserLocationFile(t)
+	if UNKNOWN_LOCATION != user_location.Curr_location || "home" != user_location.Prev_location {
+		t.Fatalf("expected an unknown location after home, got %s after %s", user_location.Curr_location,
+			user_location.Prev_location)
+	}
+}
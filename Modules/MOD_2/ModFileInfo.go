@@ -36,8 +36,13 @@ type _DiskInfo struct {
 
 // _ModGenInfo is the format of the custom generated information about this specific module.
 type _ModGenInfo struct {
-	// Disks_info is the information about the disks. It maps the disk serial number to an array with the first element
-	// being the last short test timestamp and the second element being the last long test timestamp. The timestamps are
-	// in seconds.
-	Disks_info map[string][]int64
+	// Disks_info is the information about the disks. It maps the disk serial number to the disk information struct.
+	Disks_info map[string]*_DiskGenInfo
+}
+
+type _DiskGenInfo struct {
+	// Last_short_test is the timestamp of the last short test in seconds
+	Last_short_test int64
+	// Last_long_test is the timestamp of the last long test in seconds
+	Last_long_test int64
 }
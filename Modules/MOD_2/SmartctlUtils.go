@@ -23,12 +23,16 @@ package MOD_2
 
 import (
 	"Utils"
+	"context"
 	"strconv"
 	"strings"
 )
 
-func getHTMLReport(disk_partition string) string {
-	stdOutErrCmd, _ := Utils.ExecCmdSHELL([]string{"smartctl{{EXE}} -x " + disk_partition})
+func getHTMLReport(ctx context.Context, disk_partition string) string {
+	stdOutErrCmd, err := Utils.ExecCmdSHELL(ctx, []string{"smartctl{{EXE}} -x " + disk_partition})
+	if nil != err {
+		return ""
+	}
 
 	var output []string = strings.Split(stdOutErrCmd.Stdout_str, "\n")
 
@@ -117,12 +121,15 @@ getAllAvailablePartitions gets all the available partitions on the device.
 
 -----------------------------------------------------------
 
+– Params:
+  - ctx – the context of the module
+
 – Returns:
   - a list with all the available partitions on the device or nil if smartmontools is not installed
 */
-func getAllAvailablePartitions() []string {
+func getAllAvailablePartitions(ctx context.Context) []string {
 	var partitions_list []string
-	stdOutErrCmd, _ := Utils.ExecCmdSHELL([]string{"smartctl{{EXE}} --scan"})
+	stdOutErrCmd, _ := Utils.ExecCmdSHELL(ctx, []string{"smartctl{{EXE}} --scan"})
 
 	var output_lines []string = strings.Split(stdOutErrCmd.Stdout_str, "\n")
 	output_lines = output_lines[:len(output_lines)-1]
@@ -143,16 +150,17 @@ getActiveDisks gets the disks that are spinning (means on either Active or Idle
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - partitions – a list of the partitions of the available disks
 
 – Returns:
   - a list of the partitions associated with the disks that are spinning or nil if none are spinning or if smartmontools
 	is not installed
 */
-func getActiveDisks(partitions_list []string) []string {
+func getActiveDisks(ctx context.Context, partitions_list []string) []string {
 	var which_disks_active []string = nil
 	for _, partition := range partitions_list {
-		stdOutErrCmd, err := Utils.ExecCmdSHELL([]string{"smartctl{{EXE}} -n standby " + partition})
+		stdOutErrCmd, err := Utils.ExecCmdSHELL(ctx, []string{"smartctl{{EXE}} -n standby " + partition})
 		if nil != err {
 			continue
 		}
@@ -170,14 +178,18 @@ getDiskSerialPartitions gets the serial number of the disks associated with the
 
 -----------------------------------------------------------
 
+– Params:
+  - ctx – the context of the module
+  - partitions_list – a list of the partitions of the available disks
+
 – Returns:
   - a map of disks serial numbers to their partition names or nil if smartmontools is not installed
 */
-func getDiskSerialPartitions(partitions_list []string) map[string]string {
+func getDiskSerialPartitions(ctx context.Context, partitions_list []string) map[string]string {
 	var disk_serial_partitions map[string]string = make(map[string]string)
 	for _, partition := range partitions_list {
 		//log.Println("Partition: " + partition)
-		stdOutErrCmd, err := Utils.ExecCmdSHELL([]string{"smartctl{{EXE}} -i " + partition})
+		stdOutErrCmd, err := Utils.ExecCmdSHELL(ctx, []string{"smartctl{{EXE}} -i " + partition})
 		if nil != err {
 			continue
 		}
@@ -205,13 +217,14 @@ initiateTest initiates a test on the disk associated with the specified partitio
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - long_test – true for long test, false for short test
   - partition – partition of the disk on which the test is to be started
 
 – Returns:
   - the number of minutes the chosen test will take or -1 if it failed
 */
-func initiateTest(long_test bool, partition string) int {
+func initiateTest(ctx context.Context, long_test bool, partition string) int {
 	// We need to try some times because sometimes it fails to start the test at first.
 	var num_tries int = 3 // 3 tries because I think it's enough most of the times
 	for i := 0; i < num_tries; i++ {
@@ -222,7 +235,7 @@ func initiateTest(long_test bool, partition string) int {
 			cmd = []string{"smartctl{{EXE}} -t short " + partition}
 		}
 
-		stdOutErrCmd, err := Utils.ExecCmdSHELL(cmd)
+		stdOutErrCmd, err := Utils.ExecCmdSHELL(ctx, cmd)
 		if nil != err {
 			continue
 		}
@@ -237,7 +250,7 @@ func initiateTest(long_test bool, partition string) int {
 			return s
 		} else { // elif "Can't start self-test without aborting current test " in output: --> Doesn't matter, always try to abort.
 			cmd = []string{"smartctl{{EXE}} -X " + partition}
-			_, _ = Utils.ExecCmdSHELL(cmd)
+			_, _ = Utils.ExecCmdSHELL(ctx, cmd)
 		}
 	}
 
@@ -250,13 +263,14 @@ checkDiskInTest checks if the disk associated with the specified partition is in
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - partition – partition of the disk on which the test is to be checked
 
 – Returns:
   - true if the disk is in test, false otherwise
 */
-func checkDiskInTest(partition string) bool {
-	stdOutErrCmd, err := Utils.ExecCmdSHELL([]string{"smartctl{{EXE}} -c " + partition})
+func checkDiskInTest(ctx context.Context, partition string) bool {
+	stdOutErrCmd, err := Utils.ExecCmdSHELL(ctx, []string{"smartctl{{EXE}} -c " + partition})
 	if nil != err {
 		return false
 	}
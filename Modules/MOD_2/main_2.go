@@ -22,6 +22,7 @@
 package MOD_2
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strconv"
@@ -40,14 +41,35 @@ const _TIME_SLEEP_S int = 60
 
 type _MGI _ModGenInfo
 var (
-	realMain        Utils.RealMain = nil
+	realMain        Utils.RealMainCtx = nil
 	moduleInfo_GL   Utils.ModuleInfo[_MGI]
 )
-func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
-func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+func Start(module *Utils.Module) {Utils.ModStartupCtx[_MGI](realMain, module)}
+func init() {
+	// Version 0 -> 1: Disks_info changed from serial -> [last short test, last long test] to serial -> _DiskGenInfo
+	Utils.RegisterGenInfoMigrationMODULES(Utils.NUM_MOD_SMARTChecker, 0, func(gen_info map[string]any) (map[string]any, error) {
+		var old_disks_info, _ = gen_info["Disks_info"].(map[string]any)
+		var new_disks_info map[string]any = make(map[string]any, len(old_disks_info))
+		for disk_serial, old_disk_info := range old_disks_info {
+			var timestamps []any
+			if timestamps, _ = old_disk_info.([]any); len(timestamps) != 2 {
+				return nil, errors.New("invalid disk information for disk " + disk_serial)
+			}
+			new_disks_info[disk_serial] = map[string]any{
+				"Last_short_test": timestamps[0],
+				"Last_long_test":  timestamps[1],
+			}
+		}
+		gen_info["Disks_info"] = new_disks_info
+
+		return gen_info, nil
+	})
+
+	realMain = func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(15*60)
+
 		if !Utils.RunningAsAdminPROCESSES() {
 			panic(errors.New("this program must be run as administrator/root"))
 		}
@@ -88,8 +110,11 @@ func init() {realMain =
 				//log.Println("Disk serial: " + disk_serial)
 				//log.Println("Disk label: " + disk_user_info.Label)
 				//log.Println()
-				var partitions_list []string = getAllAvailablePartitions()
-				var disksSerialPartitions map[string]string = getDiskSerialPartitions(partitions_list)
+				var partitions_list []string = getAllAvailablePartitions(ctx)
+				var disksSerialPartitions map[string]string = getDiskSerialPartitions(ctx, partitions_list)
+				if ctx.Err() != nil {
+					return
+				}
 
 				disk_partition, ok := disksSerialPartitions[disk_serial]
 				if !ok {
@@ -98,24 +123,21 @@ func init() {realMain =
 					continue
 				}
 
-				// Fill the map with spots for the 2 elements that must be in them
 				if moduleInfo_GL.ModGenInfo.Disks_info == nil {
-					moduleInfo_GL.ModGenInfo.Disks_info = make(map[string][]int64, 2)
+					moduleInfo_GL.ModGenInfo.Disks_info = make(map[string]*_DiskGenInfo)
 				}
 
 				// Check which test to execute, or execute none if the time hasn't passed yet.
-				var disk_gen_info []int64 = moduleInfo_GL.ModGenInfo.Disks_info[disk_serial]
+				var disk_gen_info *_DiskGenInfo = moduleInfo_GL.ModGenInfo.Disks_info[disk_serial]
 				if disk_gen_info == nil {
-					disk_gen_info = make([]int64, 2)
-					disk_gen_info[SHORT_TEST] = 0
-					disk_gen_info[LONG_TEST] = 0
+					disk_gen_info = &_DiskGenInfo{}
 					moduleInfo_GL.ModGenInfo.Disks_info[disk_serial] = disk_gen_info
 				}
 				var test_type int = NO_TEST
 				if !no_test {
-					if time.Now().Unix()-disk_gen_info[LONG_TEST] > _LONG_TEST_EACH_S {
+					if time.Now().Unix()-disk_gen_info.Last_long_test > _LONG_TEST_EACH_S {
 						test_type = LONG_TEST
-					} else if time.Now().Unix()-disk_gen_info[SHORT_TEST] > _SHORT_TEST_EACH_S {
+					} else if time.Now().Unix()-disk_gen_info.Last_short_test > _SHORT_TEST_EACH_S {
 						test_type = SHORT_TEST
 					} else {
 						//log.Println("Time has not passed yet for the tests to be executed.")
@@ -125,7 +147,7 @@ func init() {realMain =
 
 					if disk_user_info.Is_HDD {
 						// If enough time passed already, check if the disk is spinning or not.
-						if !Utils.ContainsSLICES(getActiveDisks(partitions_list), disk_partition) {
+						if !Utils.ContainsSLICES(getActiveDisks(ctx, partitions_list), disk_partition) {
 							// If disk is not spinning skip short test or status check, but never skip if it's a long test.
 							if test_type != LONG_TEST {
 								//log.Println("Disk not spinning, skipping test.")
@@ -140,7 +162,10 @@ func init() {realMain =
 
 				if test_type != NO_TEST {
 					// Start the test and retrieve the test time
-					var test_time int = initiateTest(test_type == LONG_TEST, disk_partition)
+					var test_time int = initiateTest(ctx, test_type == LONG_TEST, disk_partition)
+					if ctx.Err() != nil {
+						return
+					}
 
 					// The total waiting time is the time the test will take in minutes + some time to make sure it's
 					// finished.
@@ -199,27 +224,36 @@ func init() {realMain =
 
 					// Wait for the test to finish (it can finish before the supposed time, so this checks every minute).
 					for {
-						if !checkDiskInTest(disk_partition) {
+						if !checkDiskInTest(ctx, disk_partition) {
 							break
 						}
 
-						if Utils.WaitWithStopTIMEDATE(module_stop, 60) {
+						moduleInfo_GL.Heartbeat()
+
+						if Utils.WaitWithCtxTIMEDATE(ctx, 60) {
 							return
 						}
 					}
+					if ctx.Err() != nil {
+						// Not finished - the check was interrupted
+						return
+					}
 
 					//log.Println("Test finished.")
 
 					// Update the timestamp
 					if test_type == SHORT_TEST {
-						disk_gen_info[SHORT_TEST] = time.Now().Unix()
+						disk_gen_info.Last_short_test = time.Now().Unix()
 					} else {
-						disk_gen_info[LONG_TEST] = time.Now().Unix()
+						disk_gen_info.Last_long_test = time.Now().Unix()
 					}
 					moduleInfo_GL.UpdateGenInfo()
 				}
 
-				html_report := getHTMLReport(disk_partition)
+				html_report := getHTMLReport(ctx, disk_partition)
+				if ctx.Err() != nil {
+					return
+				}
 
 				var things_replace = map[string]string{
 					Utils.MODEL_DISKS_SMART_DISK_LABEL_EMAIL:       disk_user_info.Label,
@@ -241,7 +275,9 @@ func init() {realMain =
 				return
 			}
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
+			moduleInfo_GL.Heartbeat()
+
+			if Utils.WaitWithCtxTIMEDATE(ctx, _TIME_SLEEP_S) {
 				return
 			}
 		}
@@ -45,9 +45,12 @@ var (
 )
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		// Speaking a long speech blocks the loop for a while
+		moduleInfo_GL.SetHeartbeatPeriod(5*60)
+
 		_ = ole.CoInitialize(0)
 		defer ole.CoUninitialize()
 
@@ -203,6 +206,8 @@ func init() {realMain =
 				}
 			}
 
+			moduleInfo_GL.Heartbeat()
+
 			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
 				kill_ch <- true
 
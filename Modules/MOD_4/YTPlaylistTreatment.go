@@ -22,6 +22,7 @@
 package MOD_4
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 	"time"
@@ -54,6 +55,7 @@ the latest videos - because this function is to be used *only* if scrapingNeeded
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - playlist_id – the ID of the playlist
   - item_num – the number of the item to get (0 is the last, 1 is the second to last, etc.)
   - item_count – the number of items in the playlist RSS feed
@@ -61,7 +63,7 @@ the latest videos - because this function is to be used *only* if scrapingNeeded
 – Returns:
   - the video info or all fields with _GEN_ERROR on them if any error occurs (check that with the video ID)
 */
-func ytPlaylistScraping(playlist_id string, item_num int, item_count int) _VideoInfo {
+func ytPlaylistScraping(ctx context.Context, playlist_id string, item_num int, item_count int) _VideoInfo {
 	var videoInfo _VideoInfo = _VideoInfo{
 		id:     _GEN_ERROR,
 		title:  _GEN_ERROR,
@@ -73,7 +75,7 @@ func ytPlaylistScraping(playlist_id string, item_num int, item_count int) _Video
 	// This is here to make sure the page is only scraped once
 	if playlistPage_GL.id != playlist_id {
 		var playlist_url string = "https://www.youtube.com/playlist?list=" + playlist_id
-		var page_html *string = Utils.GetPageHtmlWEBPAGES(playlist_url)
+		var page_html *string = Utils.GetPageHtmlWEBPAGES(ctx, playlist_url)
 		if page_html == nil {
 			playlistPage_GL.id = ""
 
@@ -22,6 +22,7 @@
 package MOD_4
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"time"
@@ -44,6 +45,7 @@ youTubeTreatment processes the YouTube feed.
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - feedType – the type of the feed
   - parsed_feed – the parsed feed
   - item_num – the number of the current item in the feed
@@ -57,7 +59,7 @@ title_url_only is true. In the 1st case, the _NewsInfo fields are also empty. In
 still filled with the video info. To check for errors, check if the video URL is empty on NewsInfo (that one must always
 have a value).
 */
-func youTubeTreatment(feedType _FeedType, parsed_feed *gofeed.Feed, item_num int, title_url_only bool) (Utils.EmailInfo,
+func youTubeTreatment(ctx context.Context, feedType _FeedType, parsed_feed *gofeed.Feed, item_num int, title_url_only bool) (Utils.EmailInfo,
 			_NewsInfo) {
 	const (
 		VIDEO_COLOR string = "#212121" // Default video color (sort of black)
@@ -80,7 +82,7 @@ func youTubeTreatment(feedType _FeedType, parsed_feed *gofeed.Feed, item_num int
 		Utils.MODEL_YT_VIDEO_SUBSCRIPTION_NAME_EMAIL: parsed_feed.Title,
 	}
 	if !title_url_only {
-		things_replace[Utils.MODEL_YT_VIDEO_CHANNEL_IMAGE_EMAIL] = getChannelImageUrl(things_replace[Utils.MODEL_YT_VIDEO_CHANNEL_CODE_EMAIL])
+		things_replace[Utils.MODEL_YT_VIDEO_CHANNEL_IMAGE_EMAIL] = getChannelImageUrl(ctx, things_replace[Utils.MODEL_YT_VIDEO_CHANNEL_CODE_EMAIL])
 	}
 
 	if feedType.type_2 == _TYPE_2_YT_CHANNEL {
@@ -95,7 +97,7 @@ func youTubeTreatment(feedType _FeedType, parsed_feed *gofeed.Feed, item_num int
 		// Scraping is only needed for video information. The feed has the rest.
 		// For scraping we only use the number of the item to guide through the video array. The rest comes from the
 		// playlist page.
-		var video_info _VideoInfo = ytPlaylistScraping(things_replace[Utils.MODEL_YT_VIDEO_PLAYLIST_CODE_EMAIL], item_num, len(parsed_feed.Items))
+		var video_info _VideoInfo = ytPlaylistScraping(ctx, things_replace[Utils.MODEL_YT_VIDEO_PLAYLIST_CODE_EMAIL], item_num, len(parsed_feed.Items))
 		if video_info.id == _GEN_ERROR {
 			return Utils.EmailInfo{}, _NewsInfo{}
 		}
@@ -121,7 +123,7 @@ func youTubeTreatment(feedType _FeedType, parsed_feed *gofeed.Feed, item_num int
 		things_replace[Utils.MODEL_YT_VIDEO_VIDEO_IMAGE_EMAIL] = feed_item.Extensions["media"]["group"][0].Children["thumbnail"][0].Attrs["url"]
 		things_replace[Utils.MODEL_YT_VIDEO_VIDEO_DESCRIPTION_EMAIL] = feed_item.Extensions["media"]["group"][0].Children["description"][0].Value
 		if !title_url_only {
-			things_replace[Utils.MODEL_YT_VIDEO_VIDEO_TIME_EMAIL] = getVideoDuration(feed_item.Link)
+			things_replace[Utils.MODEL_YT_VIDEO_VIDEO_TIME_EMAIL] = getVideoDuration(ctx, feed_item.Link)
 		}
 	}
 
@@ -193,13 +195,14 @@ The format returned is the same as the one from the SecondsToTimeStr() function.
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - video_url – the URL of the video
 
 – Returns:
   - the duration of the video if it was found, _VID_TIME_DEF otherwise
 */
-func getVideoDuration(video_url string) string {
-	var p_page_html *string = Utils.GetPageHtmlWEBPAGES(video_url)
+func getVideoDuration(ctx context.Context, video_url string) string {
+	var p_page_html *string = Utils.GetPageHtmlWEBPAGES(ctx, video_url)
 	if p_page_html == nil {
 		return _VID_TIME_DEF
 	}
@@ -250,13 +253,14 @@ getChannelImageUrl gets the URL of the channel image of by getting the channel's
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context of the module
   - channel_code – the code of the channel
 
 – Returns:
   - the URL of the channel image if it was found, _GEN_ERROR otherwise
 */
-func getChannelImageUrl(channel_code string) string {
-	var p_page_html *string = Utils.GetPageHtmlWEBPAGES("https://www.youtube.com/channel/" + channel_code)
+func getChannelImageUrl(ctx context.Context, channel_code string) string {
+	var p_page_html *string = Utils.GetPageHtmlWEBPAGES(ctx, "https://www.youtube.com/channel/" + channel_code)
 	if p_page_html == nil {
 		return _GEN_ERROR
 	}
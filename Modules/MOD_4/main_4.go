@@ -80,153 +80,221 @@ type _NewsInfo struct {
 // 15 for YT - 100 seems perfect).
 const _MAX_URLS_STORED int = 100
 
+// _DEF_FEED_CHECK_EACH_S is the default interval between checks of each feed.
+const _DEF_FEED_CHECK_EACH_S int64 = 2*60
+// _FEED_CHECK_JITTER_S is the maximum random delay added to each check, so that the feeds aren't all checked together.
+const _FEED_CHECK_JITTER_S int64 = 30
+
 const _TIME_SLEEP_S int = 2*60
 
 type _MGI any
 var (
-	realMain Utils.RealMain = nil
+	realMain Utils.RealMainCtx = nil
 	moduleInfo_GL Utils.ModuleInfo[_MGI]
 )
-func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
+func Start(module *Utils.Module) {Utils.ModStartupCtx[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(_TIME_SLEEP_S + 5*60)
+
+		// Reload the feeds right away when they're changed in the user settings (the new ones are checked right away)
+		var settings_ch chan struct{} = moduleInfo_GL.SubscribeModUserInfo()
+		defer Utils.UnsubscribeSettingsSETTINGS(settings_ch)
+
+		var scheduler *Utils.Scheduler = Utils.NewSchedulerSCHEDULER(Utils.NUM_MOD_RssFeedNotifier)
+		defer scheduler.Close()
+
 		for {
 			var modUserInfo _ModUserInfo
 			if err := moduleInfo_GL.GetModUserInfo(&modUserInfo); err != nil {
 				panic(err)
 			}
 
+			// Each feed is polled on its own schedule (which can be overridden in the user settings by the feed item ID)
+			var feeds_info map[string]_FeedInfo = make(map[string]_FeedInfo, len(modUserInfo.Feeds_info))
+			var def_schedules map[string]Utils.Schedule = make(map[string]Utils.Schedule, len(modUserInfo.Feeds_info))
 			for _, feedInfo := range modUserInfo.Feeds_info {
-				// if feedInfo.Feed_num != 8 {
-				//	continue
-				// }
-				//log.Println("__________________________BEGINNING__________________________")
+				var item_id string = getFeedItemId(feedInfo.Feed_num)
+				feeds_info[item_id] = feedInfo
+				def_schedules[item_id] = Utils.Schedule{
+					Interval_s: _DEF_FEED_CHECK_EACH_S,
+					Jitter_s:   _FEED_CHECK_JITTER_S,
+					Catch_up:   Utils.CATCH_UP_ONCE,
+				}
+			}
+			scheduler.SetItems(def_schedules)
 
-				var feedType _FeedType = getFeedType(feedInfo.Feed_type)
+			due_items, stop := scheduler.WaitDue(ctx, settings_ch, _TIME_SLEEP_S)
+			if stop {
+				return
+			}
+			for _, item_id := range due_items {
+				if ctx.Err() != nil {
+					return
+				}
+				moduleInfo_GL.Heartbeat()
 
-				if !Utils.ContainsSLICES(allowed_feed_types_1_GL, feedType.type_1) {
-					//log.Println("Feed type not allowed: " + feedInfo.Feed_type)
-					//log.Println("__________________________ENDING__________________________")
+				checkFeed(ctx, feeds_info[item_id], modUserInfo.Mails_to)
+				scheduler.RunDone(item_id)
+			}
 
-					continue
-				}
+			moduleInfo_GL.Heartbeat()
+		}
+	}
+}
 
-				if feedType.type_1 == _TYPE_1_YOUTUBE {
-					// If the feed is a YouTube feed, the feed URL is the channel or playlist ID, so we need to change it to
-					// the correct URL.
-					if feedType.type_2 == _TYPE_2_YT_CHANNEL {
-						feedInfo.Feed_url = "https://www.youtube.com/feeds/videos.xml?channel_id=" + feedInfo.Feed_url
-					} else if feedType.type_2 == _TYPE_2_YT_PLAYLIST {
-						feedInfo.Feed_url = "https://www.youtube.com/feeds/videos.xml?playlist_id=" + feedInfo.Feed_url
-					}
-				}
+/*
+checkFeed checks a feed for news and queues an email about each one.
 
-				//log.Println("feed_num: " + strconv.Itoa(feedInfo.Feed_num))
-				//log.Println("feed_url: " + feedInfo.Feed_url)
-				//log.Println("feed_type: " + feedInfo.Feed_type)
-				//log.Println("feedType.type_1: " + feedType.type_1)
-				//log.Println("feedType.type_2: " + feedType.type_2)
-				//log.Println("feedType.type_3: " + feedType.type_3)
-
-				var notif_news_file_path Utils.GPath = moduleInfo_GL.ModDirsInfo.UserData.Add2(true, "notified_news",
-					strconv.Itoa(feedInfo.Feed_num)+".json")
-				var newsInfo_list []_NewsInfo = nil
-				if notif_news_file_path.Exists() {
-					var notified_news_json []byte = notif_news_file_path.ReadFile()
-					Utils.FromJsonGENERAL(notified_news_json, &newsInfo_list)
-				}
+-----------------------------------------------------------
 
-				var new_feed bool = false
-				if len(newsInfo_list) == 0 {
-					new_feed = true
-				}
+– Params:
+  - ctx – the context of the module
+  - feedInfo – the information about the feed
+  - mails_to – the email addresses to send the news to
+*/
+func checkFeed(ctx context.Context, feedInfo _FeedInfo, mails_to []string) {
+	// if feedInfo.Feed_num != 8 {
+	//	return
+	// }
+	//log.Println("__________________________BEGINNING__________________________")
 
-				ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-				parsed_feed, err := gofeed.NewParser().ParseURLWithContext(feedInfo.Feed_url, ctx)
-				cancel()
-				if nil != err {
-					//log.Println("Error parsing feed: " + err.Error())
-					continue
-				}
+	var feedType _FeedType = getFeedType(feedInfo.Feed_type)
 
-				var notified_news_list_modified bool = false
-				for item_num, item := range parsed_feed.Items {
-
-					var check_skipping_later bool = true
-
-					// Check if the news is new, and if it's not, skip it. But only if it's not a YouTube playlist, because
-					// those may need the order of the items reversed and so the ones got from this loop are wrong. Or if it
-					// is playlist, then only if the feed item ordering is correct (no scraping needed).
-					// This is also here and not just in the end to prevent useless item processing (optimized).
-					if feedType.type_2 != _TYPE_2_YT_PLAYLIST || !scrapingNeeded(parsed_feed) {
-						check_skipping_later = false
-						if !isNewNews(newsInfo_list, item.Title, item.Link) {
-							// If the news is not new, don't notify.
-							continue
-						}
-					}
-
-					var email_info Utils.EmailInfo = Utils.EmailInfo{}
-					var newsInfo _NewsInfo = _NewsInfo{}
-
-					switch feedType.type_1 {
-						case _TYPE_1_YOUTUBE: {
-							email_info, newsInfo = youTubeTreatment(feedType, parsed_feed, item_num, new_feed)
-						}
-						case _TYPE_1_GENERAL: {
-							email_info, newsInfo = generalTreatment(parsed_feed, item_num, new_feed,
-								feedInfo.Custom_msg_subject)
-						}
-						default: {
-							//log.Println("Unknown feed type_1: " + feedType.type_1)
-							continue
-						}
-					}
-
-					var ignore_video bool = "" == email_info.Html
-
-					if "" == newsInfo.Url { // Some error occurred
-						continue
-					}
-
-					if check_skipping_later && !isNewNews(newsInfo_list, newsInfo.Title, newsInfo.Url) {
-						// If the news is not new, don't notify.
-						continue
-					}
-
-					var error_notifying bool = false
-
-					//log.Println("New news: " + newsInfo.Title)
-					if !new_feed && !ignore_video {
-						// If the feed is a newly added one, don't send emails for ALL the items in the feed - which are
-						// being treated for the first time.
-						//log.Println("Queuing email: " + email_info.Subject)
-						error_notifying = !queueEmailAllRecps(email_info.Sender, email_info.Subject, email_info.Html,
-							modUserInfo.Mails_to)
-					}
-
-					if !error_notifying {
-						newsInfo_list = append(newsInfo_list, newsInfo)
-						if len(newsInfo_list) > _MAX_URLS_STORED {
-							newsInfo_list = newsInfo_list[1:]
-						}
-						notified_news_list_modified = true
-					}
-				}
-				if notified_news_list_modified {
-					_ = notif_news_file_path.WriteTextFile(*Utils.ToJsonGENERAL(newsInfo_list), false)
-				}
+	if !Utils.ContainsSLICES(allowed_feed_types_1_GL, feedType.type_1) {
+		//log.Println("Feed type not allowed: " + feedInfo.Feed_type)
+		//log.Println("__________________________ENDING__________________________")
+
+		return
+	}
+
+	if feedType.type_1 == _TYPE_1_YOUTUBE {
+		// If the feed is a YouTube feed, the feed URL is the channel or playlist ID, so we need to change it to
+		// the correct URL.
+		if feedType.type_2 == _TYPE_2_YT_CHANNEL {
+			feedInfo.Feed_url = "https://www.youtube.com/feeds/videos.xml?channel_id=" + feedInfo.Feed_url
+		} else if feedType.type_2 == _TYPE_2_YT_PLAYLIST {
+			feedInfo.Feed_url = "https://www.youtube.com/feeds/videos.xml?playlist_id=" + feedInfo.Feed_url
+		}
+	}
+
+	//log.Println("feed_num: " + strconv.Itoa(feedInfo.Feed_num))
+	//log.Println("feed_url: " + feedInfo.Feed_url)
+	//log.Println("feed_type: " + feedInfo.Feed_type)
+	//log.Println("feedType.type_1: " + feedType.type_1)
+	//log.Println("feedType.type_2: " + feedType.type_2)
+	//log.Println("feedType.type_3: " + feedType.type_3)
+
+	var notif_news_file_path Utils.GPath = moduleInfo_GL.ModDirsInfo.UserData.Add2(false, "notified_news",
+		strconv.Itoa(feedInfo.Feed_num)+".json")
+	var newsInfo_list []_NewsInfo = nil
+	if notif_news_file_path.Exists() {
+		var notified_news_json []byte = notif_news_file_path.ReadFile()
+		Utils.FromJsonGENERAL(notified_news_json, &newsInfo_list)
+	}
+
+	var new_feed bool = false
+	if len(newsInfo_list) == 0 {
+		new_feed = true
+	}
 
-				//log.Println("__________________________ENDING__________________________")
+	feed_ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	var p_feed_xml *string = Utils.GetPageHtmlWEBPAGES(feed_ctx, feedInfo.Feed_url)
+	cancel()
+	if nil == p_feed_xml {
+		//log.Println("Error getting feed")
+		return
+	}
+	parsed_feed, err := gofeed.NewParser().ParseString(*p_feed_xml)
+	if nil != err {
+		//log.Println("Error parsing feed: " + err.Error())
+		return
+	}
+
+	var notified_news_list_modified bool = false
+	for item_num, item := range parsed_feed.Items {
+
+		var check_skipping_later bool = true
+
+		// Check if the news is new, and if it's not, skip it. But only if it's not a YouTube playlist, because
+		// those may need the order of the items reversed and so the ones got from this loop are wrong. Or if it
+		// is playlist, then only if the feed item ordering is correct (no scraping needed).
+		// This is also here and not just in the end to prevent useless item processing (optimized).
+		if feedType.type_2 != _TYPE_2_YT_PLAYLIST || !scrapingNeeded(parsed_feed) {
+			check_skipping_later = false
+			if !isNewNews(newsInfo_list, item.Title, item.Link) {
+				// If the news is not new, don't notify.
+				continue
 			}
+		}
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
-				return
+		var email_info Utils.EmailInfo = Utils.EmailInfo{}
+		var newsInfo _NewsInfo = _NewsInfo{}
+
+		switch feedType.type_1 {
+			case _TYPE_1_YOUTUBE: {
+				email_info, newsInfo = youTubeTreatment(ctx, feedType, parsed_feed, item_num, new_feed)
+			}
+			case _TYPE_1_GENERAL: {
+				email_info, newsInfo = generalTreatment(parsed_feed, item_num, new_feed,
+					feedInfo.Custom_msg_subject)
+			}
+			default: {
+				//log.Println("Unknown feed type_1: " + feedType.type_1)
+				continue
+			}
+		}
+
+		var ignore_video bool = "" == email_info.Html
+
+		if "" == newsInfo.Url { // Some error occurred
+			continue
+		}
+
+		if check_skipping_later && !isNewNews(newsInfo_list, newsInfo.Title, newsInfo.Url) {
+			// If the news is not new, don't notify.
+			continue
+		}
+
+		var error_notifying bool = false
+
+		//log.Println("New news: " + newsInfo.Title)
+		if !new_feed && !ignore_video {
+			// If the feed is a newly added one, don't send emails for ALL the items in the feed - which are
+			// being treated for the first time.
+			//log.Println("Queuing email: " + email_info.Subject)
+			error_notifying = !queueEmailAllRecps(email_info.Sender, email_info.Subject, email_info.Html,
+				mails_to)
+		}
+
+		if !error_notifying {
+			newsInfo_list = append(newsInfo_list, newsInfo)
+			if len(newsInfo_list) > _MAX_URLS_STORED {
+				newsInfo_list = newsInfo_list[1:]
 			}
+			notified_news_list_modified = true
 		}
 	}
+	if notified_news_list_modified {
+		_ = notif_news_file_path.WriteTextFile(*Utils.ToJsonGENERAL(newsInfo_list), false)
+	}
+}
+
+/*
+getFeedItemId gets the ID of the scheduler item of a feed.
+
+-----------------------------------------------------------
+
+– Params:
+  - feed_num – the number of the feed
+
+– Returns:
+  - the ID of the item
+*/
+func getFeedItemId(feed_num int) string {
+	return "MOD_" + strconv.Itoa(Utils.NUM_MOD_RssFeedNotifier) + ".feed_" + strconv.Itoa(feed_num)
 }
 
 /*
@@ -0,0 +1,115 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_4
+
+import (
+	"Utils"
+	"Utils/UtilsTest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const _TEST_FEED_URL string = "https://news.visor.test/feed.xml"
+const _TEST_MAIL_TO string = "user@visor.test"
+
+// getFeedXml gets the XML of an RSS feed with the given items titles (whose links are made from the titles).
+func getFeedXml(titles ...string) []byte {
+	var feed_xml string = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+<title>VISOR News</title>
+<link>https://news.visor.test</link>
+<description>News</description>`
+	for _, title := range titles {
+		feed_xml += `
+<item>
+<title>` + title + `</title>
+<link>https://news.visor.test/` + strings.ReplaceAll(title, " ", "_") + `</link>
+<description>About ` + title + `</description>
+<pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+</item>`
+	}
+	feed_xml += `
+</channel>
+</rss>`
+
+	return []byte(feed_xml)
+}
+
+func TestNewsDeduplication(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = UtilsTest.NewTestHarnessTESTING[_MGI](Utils.NUM_MOD_RssFeedNotifier,
+		Utils.UserSettings{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	t.Cleanup(harness.Close)
+	if err := harness.LoadProgramData("../../data/ProgramData"); nil != err {
+		t.Fatal(err)
+	}
+	if err := harness.SetModUserInfo(_ModUserInfo{
+		Mails_to:   []string{_TEST_MAIL_TO},
+		Feeds_info: []_FeedInfo{{
+			Feed_num:           1,
+			Feed_url:           _TEST_FEED_URL,
+			Feed_type:          _TYPE_1_GENERAL,
+			Custom_msg_subject: "News",
+		}},
+	}); nil != err {
+		t.Fatal(err)
+	}
+
+	// A new feed - its current news are only stored
+	harness.Website_client.SetPage(_TEST_FEED_URL, getFeedXml("First news", "Second news"))
+	if err := harness.RunCtx(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+	if forms := harness.Website_client.GetForms("Email"); 0 != len(forms) {
+		t.Fatalf("expected no emails for a new feed, got %d", len(forms))
+	}
+	var newsInfo_list []_NewsInfo = nil
+	if err := Utils.FromJsonGENERAL(harness.ModuleInfo.ModDirsInfo.UserData.Add2(false, "notified_news", "1.json").
+			ReadFile(), &newsInfo_list); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(newsInfo_list) {
+		t.Fatalf("expected the 2 news stored, got %d", len(newsInfo_list))
+	}
+
+	// One more news - only that one is notified, even if the feed is checked more than once
+	harness.Website_client.SetPage(_TEST_FEED_URL, getFeedXml("Third news", "First news", "Second news"))
+	if err := harness.RunCtx(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+	var forms []Utils.WebsiteForm = harness.Website_client.GetForms("Email")
+	if 1 != len(forms) {
+		t.Fatalf("expected 1 email for the new news, got %d", len(forms))
+	}
+	if _TEST_MAIL_TO != forms[0].Text1 || !strings.Contains(forms[0].Text2, "Third news") {
+		t.Fatalf("unexpected email to %s:\n%s", forms[0].Text1, forms[0].Text2)
+	}
+
+	// And after a restart, nothing new
+	if err := harness.RunCtx(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+	if forms = harness.Website_client.GetForms("Email"); 1 != len(forms) {
+		t.Fatalf("expected no more emails, got %d in total", len(forms))
+	}
+}
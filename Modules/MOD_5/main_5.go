@@ -22,10 +22,8 @@
 package MOD_5
 
 import (
-	"errors"
-	"os"
+	"context"
 	"strings"
-	"time"
 
 	"Utils"
 )
@@ -38,6 +36,9 @@ const _MAX_EMAILS_HOUR = 15
 
 const _TIME_SLEEP_S int = 5
 
+// sendEmail sends the emails (replaceable on the tests).
+var sendEmail func(message_eml string, mail_to string, emergency_email bool) error = Utils.SendEmailEMAIL
+
 type emailSent struct {
 	email  string
 	time_s int64
@@ -45,82 +46,93 @@ type emailSent struct {
 
 type _MGI _ModGenInfo
 var (
-	realMain        Utils.RealMain = nil
+	realMain        Utils.RealMainCtx = nil
 	moduleInfo_GL Utils.ModuleInfo[_MGI]
 )
-func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
+func Start(module *Utils.Module) {Utils.ModStartupCtx[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
-		var to_send_dir Utils.GPath = moduleInfo_GL.ModDirsInfo.UserData.Add2(true, Utils.TO_SEND_REL_FOLDER)
+		moduleInfo_GL.SetHeartbeatPeriod(5*60)
+
+		var subscription *Utils.Subscription[Utils.QueuedEmail] = Utils.TOPIC_EMAILS_TO_SEND.Subscribe(
+			Utils.GetModSubscriberEVENTS(Utils.NUM_MOD_EmailSender))
+		defer subscription.Close()
 
-		//log.Println("Checking for emails to send in \"" + to_send_dir.GPathToStringConversion() + "\"...")
+		// The EML files dropped in the to_send folder are queued too
+		Utils.TOPIC_EMAILS_TO_SEND.StartFolderAdapter(ctx,
+			moduleInfo_GL.ModDirsInfo.UserData.Add2(true, Utils.TO_SEND_REL_FOLDER),
+			func(file_info Utils.FileInfo) (Utils.QueuedEmail, bool) {
+				var eml *string = file_info.GPath.ReadTextFile()
+				var mail_to string = strings.TrimSuffix(file_info.Name, ".eml")
+				if nil == eml || len(mail_to) <= Utils.RAND_STR_LEN {
+					return Utils.QueuedEmail{}, false
+				}
 
+				return Utils.QueuedEmail{
+					Mail_to: mail_to[Utils.RAND_STR_LEN:],
+					Eml:     *eml,
+				}, true
+			})
+
+		var last_email_sent emailSent
 		for {
-			var files_to_send []Utils.FileInfo = to_send_dir.GetFileList()
-			if files_to_send == nil {
-				panic(errors.New("couldn't read directory \"" + to_send_dir.GPathToStringConversion() + "\""))
+			moduleInfo_GL.Heartbeat()
+
+			event, stop := subscription.Next(ctx, _TIME_SLEEP_S)
+			if stop {
+				return
+			}
+			if nil == event {
+				continue
 			}
 
-			var last_file_sent emailSent
-			for len(files_to_send) > 0 {
-				file_to_send, idx_to_remove := Utils.GetOldestFileFILESDIRS(files_to_send)
-				if *file_to_send.GPath.ReadTextFile() == last_file_sent.email && time.Now().Unix() - last_file_sent.time_s < 60 {
-					// Don't send the same email twice or more in a row.
-					Utils.DelElemSLICES(&files_to_send, idx_to_remove)
+			if event.Payload.Eml == last_email_sent.email &&
+					Utils.GetClockTIMEDATE().Now().Unix() - last_email_sent.time_s < 60 {
+				// Don't send the same email twice or more in a row.
+				subscription.Ack(event)
 
-					continue
-				}
+				continue
+			}
 
-				// ... and send it.
-				var mail_to string = strings.TrimSuffix(file_to_send.Name, ".eml")
-				mail_to = mail_to[Utils.RAND_STR_LEN:]
-
-				//log.Println("--------------------")
-				//log.Println("Sending email file " + file_to_send.Name + " to " + mail_to + "...")
-
-				if !reachedMaxEmailsHour() {
-					if err := Utils.SendEmailEMAIL(*file_to_send.GPath.ReadTextFile(), mail_to, false); err == nil {
-						if time.Now().Hour() != moduleInfo_GL.ModGenInfo.Hour {
-							moduleInfo_GL.ModGenInfo.Hour = time.Now().Hour()
-							moduleInfo_GL.ModGenInfo.Num_emails_hour = 0
-						}
-						moduleInfo_GL.ModGenInfo.Num_emails_hour++
-						_ = moduleInfo_GL.UpdateGenInfo()
-						//log.Println("Email sent successfully.")
-
-						last_file_sent.email = *file_to_send.GPath.ReadTextFile()
-						last_file_sent.time_s = time.Now().Unix()
-
-						// Remove the file
-						Utils.DelElemSLICES(&files_to_send, idx_to_remove)
-						if os.Remove(file_to_send.GPath.GPathToStringConversion()) == nil {
-							//log.Println("File deleted successfully.")
-						} else {
-							//log.Println("Error deleting file.")
-						}
-					} else {
-						//log.Println("Error sending email with error\n" + Utils.GetFullErrorMsgGENERAL(err))
-
-						panic(err)
-					}
-				} else {
-					//log.Println("The maximum number of emails per hour has been reached (" +
-					//	strconv.Itoa(_MAX_EMAILS_HOUR) + "). Waiting for the next hour...")
-
-					goto end_loop
-				}
+			//log.Println("--------------------")
+			//log.Println("Sending email to " + event.Payload.Mail_to + "...")
+
+			if reachedMaxEmailsHour() {
+				//log.Println("The maximum number of emails per hour has been reached (" +
+				//	strconv.Itoa(_MAX_EMAILS_HOUR) + "). Waiting for the next hour...")
 
-				// No mega fast email spamming - don't want the account blocked.
-				if Utils.WaitWithStopTIMEDATE(module_stop, 1) {
+				subscription.Nack(event)
+				if Utils.WaitWithCtxTIMEDATE(ctx, _TIME_SLEEP_S) {
 					return
 				}
+
+				continue
 			}
 
-			end_loop:
+			if err := sendEmail(event.Payload.Eml, event.Payload.Mail_to, false); err != nil {
+				//log.Println("Error sending email with error\n" + Utils.GetFullErrorMsgGENERAL(err))
+
+				// The email is not acknowledged, so it will be sent again after the restart
+				panic(err)
+			}
+
+			if Utils.GetClockTIMEDATE().Now().Hour() != moduleInfo_GL.ModGenInfo.Hour {
+				moduleInfo_GL.ModGenInfo.Hour = Utils.GetClockTIMEDATE().Now().Hour()
+				moduleInfo_GL.ModGenInfo.Num_emails_hour = 0
+			}
+			moduleInfo_GL.ModGenInfo.Num_emails_hour++
+			_ = moduleInfo_GL.UpdateGenInfo()
+			//log.Println("Email sent successfully.")
+
+			last_email_sent.email = event.Payload.Eml
+			last_email_sent.time_s = Utils.GetClockTIMEDATE().Now().Unix()
+
+			subscription.Ack(event)
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
+			// No mega fast email spamming - don't want the account blocked.
+			if Utils.WaitWithCtxTIMEDATE(ctx, 1) {
 				return
 			}
 		}
@@ -137,5 +149,5 @@ reachedMaxEmailsHour returns true if the maximum number of emails per hour has b
  */
 func reachedMaxEmailsHour() bool {
 	return moduleInfo_GL.ModGenInfo.Num_emails_hour >= _MAX_EMAILS_HOUR &&
-		time.Now().Hour() == moduleInfo_GL.ModGenInfo.Hour
+		Utils.GetClockTIMEDATE().Now().Hour() == moduleInfo_GL.ModGenInfo.Hour
 }
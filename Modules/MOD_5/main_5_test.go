@@ -0,0 +1,92 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_5
+
+import (
+	"Utils"
+	"Utils/UtilsTest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHourlyRateLimit(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = UtilsTest.NewTestHarnessTESTING[_MGI](Utils.NUM_MOD_EmailSender,
+		Utils.UserSettings{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	t.Cleanup(harness.Close)
+	// The main loop and the folder adapter
+	harness.Waiters = 2
+
+	var mutex sync.Mutex
+	var emails_sent []string = nil
+	var prev_sendEmail = sendEmail
+	sendEmail = func(message_eml string, mail_to string, emergency_email bool) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		emails_sent = append(emails_sent, message_eml)
+
+		return nil
+	}
+	t.Cleanup(func() {
+		sendEmail = prev_sendEmail
+	})
+	var getNumEmailsSent = func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return len(emails_sent)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := Utils.TOPIC_EMAILS_TO_SEND.Publish(Utils.QueuedEmail{
+			Mail_to: "user@visor.test",
+			Eml:     "Email " + strconv.Itoa(i),
+		}); nil != err {
+			t.Fatal(err)
+		}
+	}
+
+	if err := harness.RunCtx(realMain, 40); nil != err {
+		t.Fatal(err)
+	}
+	if num_emails := getNumEmailsSent(); _MAX_EMAILS_HOUR != num_emails {
+		t.Fatalf("expected %d emails sent in the first hour, got %d", _MAX_EMAILS_HOUR, num_emails)
+	}
+
+	// The count must survive a restart in the same hour
+	if err := harness.RunCtx(realMain, 10); nil != err {
+		t.Fatal(err)
+	}
+	if num_emails := getNumEmailsSent(); _MAX_EMAILS_HOUR != num_emails {
+		t.Fatalf("expected no more emails sent in the first hour after a restart, got %d", num_emails)
+	}
+
+	harness.Clock.Advance(time.Hour)
+	if err := harness.RunCtx(realMain, 20); nil != err {
+		t.Fatal(err)
+	}
+	if num_emails := getNumEmailsSent(); 20 != num_emails {
+		t.Fatalf("expected all the 20 emails sent in the next hour, got %d", num_emails)
+	}
+}
@@ -109,9 +109,11 @@ const _TIME_SLEEP_S int = 15*60
 
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(_TIME_SLEEP_S + 5*60)
+
 		for {
 			var modUserInfo _ModUserInfo
 			if err := moduleInfo_GL.GetModUserInfo(&modUserInfo); err != nil {
@@ -166,6 +168,8 @@ func init() {realMain =
 			_ = service.Stop()
 
 
+			moduleInfo_GL.Heartbeat()
+
 			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
 				return
 			}
@@ -189,7 +193,7 @@ RetrieveWolframAlpha retrieves the information from the given query using Wolfra
 func RetrieveWolframAlpha(query string) (string, bool) {
 	//Initialize a new client
 	c := &wolfram.Client{
-		AppID: Utils.User_settings_GL.PersonalConsts.WolframAlpha_AppID,
+		AppID: Utils.GetUserSettingsSETTINGS().PersonalConsts.WolframAlpha_AppID,
 	}
 
 	//Get a result without additional parameters
@@ -26,10 +26,11 @@ import (
 	MOD_12 "UserLocator"
 	"Utils"
 	"bufio"
-	"os"
+	"context"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,18 +43,38 @@ const SEARCH_WIKIPEDIA string = "/searchWikipedia "
 
 const _TIME_SLEEP_S int = 1
 
+// _LLAMA_MAX_SILENCE_S is the maximum time Llama can go without writing anything while it has something to answer
+// before it's considered stuck.
+const _LLAMA_MAX_SILENCE_S int64 = 2*60
+// _LLAMA_MAX_START_S is the same as _LLAMA_MAX_SILENCE_S but until Llama writes something for the first time, since
+// loading the model can take long (and its logs go to stderr, which isn't read).
+const _LLAMA_MAX_START_S int64 = 20*60
+
 var is_writing_GL bool = false
 
+// answering_GL is true from the time a text is sent to Llama until it finishes answering it.
+var answering_GL atomic.Bool
+// llama_started_GL is true after Llama wrote something for the first time.
+var llama_started_GL atomic.Bool
+// last_output_time_GL is the time in milliseconds Llama last wrote something or was sent a text to answer.
+var last_output_time_GL atomic.Int64
+
 type _MGI any
 var (
-	realMain        Utils.RealMain = nil
+	realMain        Utils.RealMainCtx = nil
 	moduleInfo_GL   Utils.ModuleInfo[_MGI]
 )
-func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
+func Start(module *Utils.Module) {Utils.ModStartupCtx[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(ctx context.Context, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(60)
+
+		// Subscribe before reading the information so that no change is missed
+		var settings_ch chan struct{} = moduleInfo_GL.SubscribeModUserInfo()
+		defer Utils.UnsubscribeSettingsSETTINGS(settings_ch)
+
 		var modUserInfo _ModUserInfo
 		if err := moduleInfo_GL.GetModUserInfo(&modUserInfo); err != nil {
 			panic(err)
@@ -62,14 +83,25 @@ func init() {realMain =
 		// Force stop Llama to start fresh, in case for any reason it's running without the module being running too,
 		// like a force-stop on the module which doesn't call forceStopLlama().
 		forceStopLlama()
+		// From a previous run, if it was stopped in the middle of an answer
+		answering_GL.Store(false)
+		llama_started_GL.Store(false)
 
-		cmd := exec.Command(Utils.GetShell("", ""))
+		cmd := exec.CommandContext(ctx, Utils.GetShell("", ""))
 		stdin, _ := cmd.StdinPipe()
 		stdout, _ := cmd.StdoutPipe()
 		_ = cmd.Start()
 
+		go func() {
+			// Kill Llama as soon as the module is signalled to stop, even if it's in the middle of writing (the shell is
+			// killed by the context, but not its children).
+			<-ctx.Done()
+
+			forceStopLlama()
+		}()
+
 		// Begin with the server ID (to say the first hello)
-		var device_id string = Utils.User_settings_GL.PersonalConsts.Device_ID
+		var device_id string = Utils.GetUserSettingsSETTINGS().PersonalConsts.Device_ID
 
 		var gpt_text_txt Utils.GPath = Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, "gpt_text.txt")
 		// Start a goroutine to print to the screen and write to a file the output of the LLM model
@@ -86,6 +118,8 @@ func init() {realMain =
 
 					return
 				}
+				last_output_time_GL.Store(time.Now().UnixMilli())
+				llama_started_GL.Store(true)
 
 				var one_byte_str string = string(one_byte)
 				last_answer += one_byte_str
@@ -113,6 +147,7 @@ func init() {realMain =
 					is_writing_GL = false
 
 					_ = gpt_text_txt.WriteTextFile(getEndString(), true)
+					answering_GL.Store(false)
 
 					last_word = ""
 					last_answer = ""
@@ -129,6 +164,11 @@ func init() {realMain =
 		_ = writer.Flush()
 
 		sendToGPT := func(to_send string) {
+			if !answering_GL.Swap(true) {
+				// The silence only counts from now
+				last_output_time_GL.Store(time.Now().UnixMilli())
+				llama_started_GL.Store(true)
+			}
 			_, _ = writer.WriteString(Utils.RemoveNonGraphicChars(to_send) + "\n")
 			_ = writer.Flush()
 		}
@@ -137,16 +177,31 @@ func init() {realMain =
 		// something else.
 		sendToGPT("hello")
 
-		// Process the files to input to the LLM model
+		// Process the texts to input to the LLM model (the files dropped in the to_process folder are published too)
+		var subscription *Utils.Subscription[string] = Utils.TOPIC_GPT_TO_PROCESS.Subscribe(
+			Utils.GetModSubscriberEVENTS(Utils.NUM_MOD_GPTCommunicator))
+		defer subscription.Close()
+		Utils.TOPIC_GPT_TO_PROCESS.StartFolderAdapter(ctx,
+			moduleInfo_GL.ModDirsInfo.UserData.Add2(true, _TO_PROCESS_REL_FOLDER),
+			func(file_info Utils.FileInfo) (string, bool) {
+				var to_process *string = file_info.GPath.ReadTextFile()
+				if nil == to_process || "" == *to_process {
+					return "", false
+				}
+
+				return *to_process, true
+			})
 		for {
-			var to_process_dir Utils.GPath = moduleInfo_GL.ModDirsInfo.UserData.Add2(false, _TO_PROCESS_REL_FOLDER)
-			var file_list []Utils.FileInfo = to_process_dir.GetFileList()
-			for len(file_list) > 0 {
-				var shut_down bool = false
-				file_to_process, idx_to_remove := Utils.GetOldestFileFILESDIRS(file_list)
-				var file_path Utils.GPath = to_process_dir.Add2(false, file_to_process.Name)
+			event, stop := subscription.Next(ctx, _TIME_SLEEP_S)
+			if stop {
+				forceStopLlama()
+				_ = stdout.Close()
 
-				var to_process string = *file_path.ReadTextFile()
+				return
+			}
+			if nil != event {
+				var shut_down bool = false
+				var to_process string = event.Payload
 				if to_process != "" {
 					// It comes like: "[device_id]text"
 					device_id = to_process[1:strings.Index(to_process, "]")]
@@ -181,8 +236,8 @@ func init() {realMain =
 					}
 				}
 
-				Utils.DelElemSLICES(&file_list, idx_to_remove)
-				_ = os.Remove(file_path.GPathToStringConversion())
+				// Acknowledge before stopping, or the text would be processed again after the restart
+				subscription.Ack(event)
 
 				if shut_down {
 					forceStopLlama()
@@ -192,16 +247,55 @@ func init() {realMain =
 				}
 			}
 
-			if Utils.WaitWithStopTIMEDATE(module_stop, _TIME_SLEEP_S) {
-				forceStopLlama()
-				_ = stdout.Close()
+			// If Llama died or got stuck (or the output goroutine did), nothing will be answered anymore - so stop sending
+			// heartbeats to have the module restarted.
+			if isLlamaResponsive() {
+				moduleInfo_GL.Heartbeat()
+			}
 
-				return
+			select {
+				case <-settings_ch:
+					var new_modUserInfo _ModUserInfo
+					if err := moduleInfo_GL.GetModUserInfo(&new_modUserInfo); err == nil && new_modUserInfo != modUserInfo {
+						// Llama must be started again with the new model or configuration, so stop the module (the
+						// Manager will restart it)
+						moduleInfo_GL.Log.Info("Model settings changed - restarting Llama", "model_loc",
+							new_modUserInfo.Model_loc)
+
+						forceStopLlama()
+						_ = stdout.Close()
+
+						return
+					}
+				default:
+					// No changes
 			}
 		}
 	}
 }
 
+/*
+isLlamaResponsive checks if Llama is running and, if it has something to answer, if it wrote something in the last
+_LLAMA_MAX_SILENCE_S seconds (or _LLAMA_MAX_START_S while it didn't write anything yet).
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if Llama is responsive, false otherwise
+*/
+func isLlamaResponsive() bool {
+	if !Utils.IsProcessRunningPROCESSES("llama-cli") {
+		return false
+	}
+
+	var max_silence_s int64 = _LLAMA_MAX_SILENCE_S
+	if !llama_started_GL.Load() {
+		max_silence_s = _LLAMA_MAX_START_S
+	}
+
+	return !answering_GL.Load() || time.Now().UnixMilli() - last_output_time_GL.Load() < max_silence_s*1000
+}
+
 const NO_ERRORS int = 0
 const ALREADY_WRITING int = 1
 const DEVICE_NOT_ACTIVE int = 2
@@ -265,7 +359,7 @@ func reduceGptTextTxt(gpt_text_txt Utils.GPath) {
 forceStopLlama stops the LLM model by killing its processes.
  */
 func forceStopLlama() {
-	_, _ = Utils.ExecCmdSHELL([]string{"killall llama-cli"})
+	_, _ = Utils.ExecCmdSHELL(context.Background(), []string{"killall llama-cli"})
 }
 
 func getStartString(device_id string) string {
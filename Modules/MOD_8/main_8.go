@@ -22,15 +22,19 @@
 package MOD_8
 
 import (
+	"Registry/Registry"
 	"Utils"
 	"crypto/md5"
 	Tcef "github.com/Edw590/TryCatch-go"
-	"log"
 	"net/http"
+	"strconv"
 )
 
 // Website Backend //
 
+// _MAX_LOG_ENTRIES is the maximum number of log entries sent on each logs request.
+const _MAX_LOG_ENTRIES int = 500
+
 type _MGI any
 var (
 	realMain Utils.RealMain = nil
@@ -38,7 +42,7 @@ var (
 )
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
 		var srv *http.Server = nil
@@ -84,12 +88,13 @@ func formHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch type_ {
 		case "GPT":
-			log.Println("GPT")
+			moduleInfo_GL.Log.Debug("Form received", "type", type_)
 			// Text1 is the text to process
-			_ = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_GPTCommunicator).Add2(false, "to_process", "test.txt").
-				WriteTextFile(text1, false)
+			if err := Utils.TOPIC_GPT_TO_PROCESS.Publish(text1); nil != err {
+				moduleInfo_GL.Log.Error("Error publishing the text to process", "error", err)
+			}
 		case "Email":
-			log.Println("Email")
+			moduleInfo_GL.Log.Debug("Form received", "type", type_)
 			// Text1 is the email address to send to
 			// Text2 is the EML file to send
 			_ = Utils.QueueEmailEMAIL(Utils.EmailInfo{
@@ -99,9 +104,13 @@ func formHandler(w http.ResponseWriter, r *http.Request) {
 		case "UserLocator":
 			// Text1 is the device ID
 			// Text2 is the JSON data to write
-			log.Println("UserLocator")
-			_ = Utils.GetUserDataDirMODULES(Utils.NUM_MOD_UserLocator).Add2(false, "devices", text1 + ".json").
-				WriteTextFile(text2, false)
+			moduleInfo_GL.Log.Debug("Form received", "type", type_)
+			if err := Utils.TOPIC_DEVICES_INFO.Publish(Utils.DeviceInfoUpdate{
+				Device_id: text1,
+				Info_json: text2,
+			}); nil != err {
+				moduleInfo_GL.Log.Error("Error publishing the device information", "error", err)
+			}
 		case "GET":
 			// Text1 is true if it's to get a file, false if it's to get its MD5 hash
 			// Text2 is the file path
@@ -112,6 +121,29 @@ func formHandler(w http.ResponseWriter, r *http.Request) {
 				var hash [16]byte = md5.Sum(file_bytes)
 				_, _ = w.Write(hash[:])
 			}
+		case "Logs":
+			// Text1 is the number of the module to get the logs of (-1 for all modules)
+			// Text2 is the minimum log level of the entries
+			mod_num, err1 := strconv.Atoi(text1)
+			min_level, err2 := strconv.Atoi(text2)
+			if nil != err1 || nil != err2 {
+				http.Error(w, "Invalid module number or log level", http.StatusBadRequest)
+
+				return
+			}
+			var log_entries []Utils.LogEntry = Utils.QueryLogsLOGS(mod_num, min_level, _MAX_LOG_ENTRIES)
+			_, _ = w.Write([]byte(*Utils.ToJsonGENERAL(log_entries)))
+		case "Registry":
+			// Text1 is the device ID
+			// Text2 is the Registry.SyncRequest in JSON
+			var request Registry.SyncRequest
+			if "" == text1 || nil != Utils.FromJsonGENERAL([]byte(text2), &request) {
+				http.Error(w, "Invalid device ID or synchronization request", http.StatusBadRequest)
+
+				return
+			}
+			var updates []Registry.ValueUpdate = Registry.HandleSyncRequest(text1, request)
+			_, _ = w.Write([]byte(*Utils.ToJsonGENERAL(updates)))
 		default:
 			// Do nothing
 	}
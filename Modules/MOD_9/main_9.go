@@ -31,8 +31,9 @@ import (
 	"Utils"
 	"VISOR_Client/ClientRegKeys"
 	"bytes"
+	"context"
+	"fmt"
 	"github.com/apaxa-go/eval"
-	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -42,6 +43,19 @@ import (
 
 const TIME_SLEEP_S int = 1
 
+// condition_vars_GL has the keys of the Registry values each variable of the device conditions stands for.
+var condition_vars_GL map[string]string = map[string]string{
+	Utils.CONDITION_VAR_POWER_CONNECTED:   ClientRegKeys.K_POWER_CONNECTED,
+	Utils.CONDITION_VAR_BATTERY_LEVEL:     ClientRegKeys.K_BATTERY_LEVEL,
+	Utils.CONDITION_VAR_SCREEN_BRIGHTNESS: ClientRegKeys.K_SCREEN_BRIGHTNESS,
+	Utils.CONDITION_VAR_SOUND_VOLUME:      ClientRegKeys.K_SOUND_VOLUME,
+	Utils.CONDITION_VAR_SOUND_MUTED:       ClientRegKeys.K_SOUND_MUTED,
+}
+
+// _BATTERY_DROP_PERIOD_S is the period of Utils.CONDITION_VAR_BATTERY_DROP_1H in seconds (the drop is from the maximum
+// the battery level had in it to the current one).
+const _BATTERY_DROP_PERIOD_S int64 = 60*60
+
 // TODO: Use the new Command attribute of _ModUserInfo
 
 type _MGI _ModGenInfo
@@ -51,9 +65,11 @@ var (
 )
 func Start(module *Utils.Module) {Utils.ModStartup[_MGI](realMain, module)}
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		moduleInfo_GL = moduleInfo_any.(Utils.ModuleInfo[_MGI])
 
+		moduleInfo_GL.SetHeartbeatPeriod(60)
+
 		var p_user_location *ULComm.UserLocation = ULComm.GetUserLocation()
 		var user_location ULComm.UserLocation
 		if p_user_location != nil {
@@ -66,7 +82,7 @@ func init() {realMain =
 		var prev_curr_last_known_user_loc string = user_location.Curr_location
 		var prev_prev_last_known_user_loc string = user_location.Prev_location
 		for {
-			var new_md5 []byte = Utils.GetFileContentsWEBSITE("reminders.json", true)
+			var new_md5 []byte = Utils.GetFileContentsWEBSITE(context.Background(), "reminders.json", true)
 			if new_md5 != nil && !bytes.Equal(new_md5, last_md5) {
 				updateLocalReminders()
 
@@ -75,6 +91,9 @@ func init() {realMain =
 
 			var reminders []RRComm.Reminder = moduleInfo_GL.ModGenInfo.Reminders
 
+			// Read once per check instead of for each condition
+			var condition_values map[string]string = getConditionValues()
+
 			// Add each reminder to the internal reminders list
 			var list_modified bool = false
 			var reminders_info_list map[string]int64 = moduleInfo_GL.ModGenInfo.Reminders_info
@@ -124,12 +143,12 @@ func init() {realMain =
 						continue
 					}
 
-					var condition bool = checkCondition(reminder, notifs_were_true)
+					var condition bool = checkCondition(reminder, notifs_were_true, condition_values)
 
 					if condition_loc && condition {
 						MOD_3.QueueSpeech(reminder.Message, SpeechQueue.PRIORITY_HIGH, SpeechQueue.MODE1_ALWAYS_NOTIFY)
 
-						log.Println("Reminder! Message: " + reminder.Message)
+						moduleInfo_GL.Log.Info("Reminder triggered", "message", reminder.Message)
 					}
 				}
 			}
@@ -140,7 +159,7 @@ func init() {realMain =
 				var test_time int64 = 0
 				// If the reminder has no time set, skip it
 				if reminder.Time != "" {
-					var curr_time int64 = time.Now().Unix() / 60
+					var curr_time int64 = Utils.GetClockTIMEDATE().Now().Unix() / 60
 					var reminder_time string = reminder.Time
 					var format string = "2006-01-02 -- 15:04:05"
 					t, _ := time.ParseInLocation(format, reminder_time, time.Local)
@@ -174,12 +193,12 @@ func init() {realMain =
 					condition_loc = true
 				}
 
-				var condition bool = checkCondition(reminder, notifs_were_true)
+				var condition bool = checkCondition(reminder, notifs_were_true, condition_values)
 
 				if condition_time && condition_loc && condition {
 					MOD_3.QueueSpeech(reminder.Message, SpeechQueue.PRIORITY_HIGH, SpeechQueue.MODE1_ALWAYS_NOTIFY)
 
-					log.Println("Reminder! Message: " + reminder.Message)
+					moduleInfo_GL.Log.Info("Reminder triggered", "message", reminder.Message)
 
 					// Set the last reminded time to the test time
 					reminders_info_list[reminder.Id] = test_time
@@ -188,6 +207,8 @@ func init() {realMain =
 				}
 			}
 
+			moduleInfo_GL.Heartbeat()
+
 			if Utils.WaitWithStopTIMEDATE(module_stop, TIME_SLEEP_S) {
 				return
 			}
@@ -207,8 +228,8 @@ func checkLocation(reminder_loc string, location string) bool {
 	return reminder_loc == location
 }
 
-func computeCondition(condition string) bool {
-	condition = formatCondition(condition)
+func computeCondition(condition string, condition_values map[string]string) bool {
+	condition = formatCondition(condition, condition_values)
 	//log.Println("Condition:", condition)
 	expr, err := eval.ParseString(condition, "")
 	if err != nil {
@@ -222,30 +243,68 @@ func computeCondition(condition string) bool {
 	return r.(bool)
 }
 
-func formatCondition(condition string) string {
-	var power_connected bool = Registry.GetValue(ClientRegKeys.K_POWER_CONNECTED).GetData(true, nil).(bool)
-	var battery_level int = Registry.GetValue(ClientRegKeys.K_BATTERY_LEVEL).GetData(true, nil).(int)
-	var screen_brightness int = Registry.GetValue(ClientRegKeys.K_SCREEN_BRIGHTNESS).GetData(true, nil).(int)
-	var sound_volume int = Registry.GetValue(ClientRegKeys.K_SOUND_VOLUME).GetData(true, nil).(int)
-	var sound_muted bool = Registry.GetValue(ClientRegKeys.K_SOUND_MUTED).GetData(true, nil).(bool)
-
-	condition = strings.Replace(condition, "power_connected", strconv.FormatBool(power_connected), -1)
-	condition = strings.Replace(condition, "battery_level", strconv.Itoa(battery_level), -1)
-	condition = strings.Replace(condition, "screen_brightness", strconv.Itoa(screen_brightness), -1)
-	condition = strings.Replace(condition, "sound_volume", strconv.Itoa(sound_volume), -1)
-	condition = strings.Replace(condition, "sound_muted", strconv.FormatBool(sound_muted), -1)
+func formatCondition(condition string, condition_values map[string]string) string {
+	for condition_var, condition_value := range condition_values {
+		condition = strings.Replace(condition, condition_var, condition_value, -1)
+	}
 
 	return condition
 }
 
-func checkCondition(reminder RRComm.Reminder, notifs_were_true map[string]bool) bool {
+/*
+getConditionValues gets the current values of the device condition variables from the Registry, and the ones computed
+from the history of the values.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the values of the variables that are registered, by variable
+*/
+func getConditionValues() map[string]string {
+	var condition_values map[string]string = make(map[string]string)
+	for condition_var, key := range condition_vars_GL {
+		if value := Registry.GetValue(key); value != nil {
+			condition_values[condition_var] = fmt.Sprint(value.GetData(true, nil))
+		}
+	}
+	condition_values[Utils.CONDITION_VAR_BATTERY_DROP_1H] = strconv.Itoa(getBatteryDrop())
+
+	return condition_values
+}
+
+/*
+getBatteryDrop gets how much the battery level dropped in the last _BATTERY_DROP_PERIOD_S seconds, from the maximum it
+had to the current one.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the drop or 0 if the battery level or its history isn't available
+*/
+func getBatteryDrop() int {
+	var value *Registry.Value = Registry.GetValue(ClientRegKeys.K_BATTERY_LEVEL)
+	if value == nil {
+		return 0
+	}
+
+	var now int64 = Utils.GetClockTIMEDATE().Now().UnixMilli()
+	var stats Registry.HistoryStats = value.GetHistoryStats(now - _BATTERY_DROP_PERIOD_S*1000, now)
+	var drop int = int(stats.Max) - value.GetInt(true)
+	if stats.Num_states == 0 || drop < 0 {
+		return 0
+	}
+
+	return drop
+}
+
+func checkCondition(reminder RRComm.Reminder, notifs_were_true map[string]bool, condition_values map[string]string) bool {
 	var condition bool = false
 	if reminder.Device_condition != "" {
 		if ok := notifs_were_true[reminder.Id]; !ok {
 			notifs_were_true[reminder.Id] = false
 		}
 
-		if computeCondition(reminder.Device_condition) {
+		if computeCondition(reminder.Device_condition, condition_values) {
 			if !notifs_were_true[reminder.Id] {
 				notifs_were_true[reminder.Id] = true
 
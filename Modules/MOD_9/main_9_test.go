@@ -0,0 +1,246 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package MOD_9
+
+import (
+	"RRComm/RRComm"
+	"Registry/Registry"
+	"SpeechQueue/SpeechQueue"
+	"ULComm/ULComm"
+	"Utils"
+	"Utils/UtilsTest"
+	"VISOR_Client/ClientRegKeys"
+	"testing"
+	"time"
+)
+
+var start_time_GL time.Time = time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+func newHarness(t *testing.T, reminders []RRComm.Reminder) *UtilsTest.TestHarness[_MGI] {
+	var harness *UtilsTest.TestHarness[_MGI] = UtilsTest.NewTestHarnessTESTING[_MGI](Utils.NUM_MOD_RemindersReminder,
+		Utils.UserSettings{}, start_time_GL)
+	t.Cleanup(harness.Close)
+	getSpeeches()
+
+	if err := Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, "reminders.json").
+			WriteTextFile(*Utils.ToJsonGENERAL(reminders), false); nil != err {
+		t.Fatal(err)
+	}
+
+	return harness
+}
+
+func setUserLocation(t *testing.T, curr_location string, prev_location string) {
+	var user_location ULComm.UserLocation = ULComm.UserLocation{
+		Curr_location: curr_location,
+		Prev_location: prev_location,
+	}
+	if err := Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, "user_location.json").
+			WriteTextFile(*Utils.ToJsonGENERAL(user_location), false); nil != err {
+		t.Fatal(err)
+	}
+}
+
+// getSpeeches gets and removes the texts of the speeches the module queued.
+func getSpeeches() []string {
+	var texts []string = nil
+	for {
+		var speech *SpeechQueue.Speech = SpeechQueue.GetNextSpeech(SpeechQueue.PRIORITY_HIGH)
+		if nil == speech {
+			return texts
+		}
+		texts = append(texts, speech.GetText())
+		SpeechQueue.RemoveSpeech(speech.GetID())
+	}
+}
+
+func TestTimeReminderTriggersOnce(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, []RRComm.Reminder{{
+		Id:      "1",
+		Message: "Take the pills",
+		Time:    start_time_GL.Add(time.Minute).Format("2006-01-02 -- 15:04:05"),
+	}})
+
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 0 != len(speeches) {
+		t.Fatalf("reminder triggered before its time: %v", speeches)
+	}
+
+	harness.On_iteration = func(iteration int) {
+		if 1 == iteration {
+			harness.Clock.Advance(time.Minute)
+		}
+	}
+	if err := harness.Run(realMain, 5); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 1 != len(speeches) || "Take the pills" != speeches[0] {
+		t.Fatalf("expected the reminder to trigger once, got %v", speeches)
+	}
+
+	// After a restart it must remember it was already triggered
+	harness.On_iteration = nil
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 0 != len(speeches) {
+		t.Fatalf("reminder triggered again: %v", speeches)
+	}
+}
+
+func TestRepeatingReminderTriggersEachPeriod(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, []RRComm.Reminder{{
+		Id:          "1",
+		Message:     "Drink water",
+		Time:        start_time_GL.Format("2006-01-02 -- 15:04:05"),
+		Repeat_each: 60,
+	}})
+
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 1 != len(speeches) {
+		t.Fatalf("expected the reminder to trigger once, got %v", speeches)
+	}
+
+	harness.Clock.Advance(30 * time.Minute)
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 0 != len(speeches) {
+		t.Fatalf("reminder triggered before the period ended: %v", speeches)
+	}
+
+	harness.Clock.Advance(30 * time.Minute)
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 1 != len(speeches) {
+		t.Fatalf("expected the reminder to trigger again after the period, got %v", speeches)
+	}
+}
+
+func TestLocationReminderTriggersOnArrival(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, []RRComm.Reminder{
+		{
+			Id:            "1",
+			Message:       "Welcome home",
+			User_location: "+home",
+		},
+		{
+			Id:            "2",
+			Message:       "Left work",
+			User_location: "-work*",
+		},
+	})
+	setUserLocation(t, "work_office", "")
+
+	harness.On_iteration = func(iteration int) {
+		if 2 == iteration {
+			setUserLocation(t, "home", "work_office")
+		}
+	}
+	if err := harness.Run(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+
+	var speeches []string = getSpeeches()
+	if 2 != len(speeches) {
+		t.Fatalf("expected both reminders to trigger once, got %v", speeches)
+	}
+	for _, message := range []string{"Welcome home", "Left work"} {
+		if !Utils.ContainsSLICES(speeches, message) {
+			t.Fatalf("reminder \"%s\" didn't trigger, got %v", message, speeches)
+		}
+	}
+}
+
+func TestConditionReminderTriggersOnRegistryChange(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, []RRComm.Reminder{{
+		Id:               "1",
+		Message:          "Charge the battery",
+		Device_condition: "battery_level < 20",
+	}})
+	var battery_level *Registry.Value = Registry.RegisterValue(ClientRegKeys.K_BATTERY_LEVEL, "Battery level",
+		"The battery level", Registry.TYPE_INT, false)
+	battery_level.SetInt(50, false)
+
+	harness.On_iteration = func(iteration int) {
+		if 2 == iteration {
+			battery_level.SetInt(10, false)
+		}
+	}
+	if err := harness.Run(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 1 != len(speeches) || "Charge the battery" != speeches[0] {
+		t.Fatalf("expected the reminder to trigger once, got %v", speeches)
+	}
+}
+
+func TestBatteryDropReminder(t *testing.T) {
+	var harness *UtilsTest.TestHarness[_MGI] = newHarness(t, []RRComm.Reminder{{
+		Id:               "1",
+		Message:          "The battery is draining fast",
+		Device_condition: Utils.CONDITION_VAR_BATTERY_DROP_1H + " >= 20",
+	}})
+	var battery_level *Registry.Value = Registry.RegisterValue(ClientRegKeys.K_BATTERY_LEVEL, "Battery level",
+		"The battery level", Registry.TYPE_INT, false)
+	battery_level.EnableHistory(100, 24*60*60, false)
+	battery_level.SetInt(90, false)
+	harness.Clock.Advance(30 * time.Minute)
+	battery_level.SetInt(75, false)
+
+	if err := harness.Run(realMain, 3); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 0 != len(speeches) {
+		t.Fatalf("reminder triggered with a drop of 15: %v", speeches)
+	}
+
+	harness.On_iteration = func(iteration int) {
+		if 2 == iteration {
+			battery_level.SetInt(68, false)
+		}
+	}
+	if err := harness.Run(realMain, 4); nil != err {
+		t.Fatal(err)
+	}
+	if speeches := getSpeeches(); 1 != len(speeches) || "The battery is draining fast" != speeches[0] {
+		t.Fatalf("expected the reminder to trigger once, got %v", speeches)
+	}
+}
+
+func TestConditionVarsAllHandled(t *testing.T) {
+	for _, condition_var := range Utils.CONDITION_VARS {
+		if _, ok := condition_vars_GL[condition_var]; !ok && Utils.CONDITION_VAR_BATTERY_DROP_1H != condition_var {
+			t.Errorf("the variable %q allowed in the conditions has no value", condition_var)
+		}
+	}
+	for condition_var := range condition_vars_GL {
+		if !Utils.ContainsSLICES(Utils.CONDITION_VARS, condition_var) {
+			t.Errorf("the variable %q isn't allowed in the conditions", condition_var)
+		}
+	}
+}
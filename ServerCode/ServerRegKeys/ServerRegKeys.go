@@ -21,14 +21,39 @@
 
 package ServerRegKeys
 
-import "Registry/Registry"
+import (
+	"Registry/Registry"
+	"ULComm/ULComm"
+)
 
-// Type: int64
+// Type: []string (the names of the modules, in the order of their numbers)
 const K_MODULES_ACTIVE string = "MODULES_ACTIVE"
+// Type: string (JSON of a map of the module numbers to their MOD_1.ModCrashHistory)
+const K_MODULES_CRASH_HISTORY string = "MODULES_CRASH_HISTORY"
+
+// Type: ULComm.UserLocation (synchronized to the clients)
+const K_USER_LOCATION string = "USER_LOCATION"
+
+// Type: int (accepted from the clients - kept for each device with Registry.GetDeviceValueKey())
+const K_BATTERY_LEVEL string = "BATTERY_LEVEL"
+// Type: bool (accepted from the clients - kept for each device with Registry.GetDeviceValueKey())
+const K_POWER_CONNECTED string = "POWER_CONNECTED"
 
 /*
 RegisterValues registers the server values in the registry.
  */
 func RegisterValues() {
-	Registry.RegisterValue(K_MODULES_ACTIVE, "Modules active", "The modules that are active (in binary)", Registry.TYPE_LONG)
+	Registry.RegisterValue(K_MODULES_ACTIVE, "Modules active", "The modules that are active",
+		Registry.TYPE_STRING_LIST, false)
+	Registry.RegisterValue(K_MODULES_CRASH_HISTORY, "Modules crash history", "The crash history of the modules",
+		Registry.TYPE_STRING, false)
+
+	Registry.RegisterJsonValue[ULComm.UserLocation](K_USER_LOCATION, "User location",
+		"The location of the user, computed by the User Locator", false).EnableSync(Registry.SYNC_TO_CLIENTS)
+
+	// Only declare the values the clients can send - the data of each device is kept in its own value
+	Registry.RegisterValue(K_BATTERY_LEVEL, "Battery level", "The battery level of the devices", Registry.TYPE_INT,
+		false).EnableSync(Registry.SYNC_TO_SERVER)
+	Registry.RegisterValue(K_POWER_CONNECTED, "Power connected", "Whether the power is connected on the devices",
+		Registry.TYPE_BOOL, false).EnableSync(Registry.SYNC_TO_SERVER)
 }
@@ -24,12 +24,13 @@ package main
 import (
 	MOD_1 "ModManager"
 	"Utils"
+	"Utils/UtilsSWA"
 	"VISOR_Server/ServerRegKeys"
 	"log"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"strconv"
-	"syscall"
+	"strings"
 )
 
 type _MGI any
@@ -37,18 +38,60 @@ var (
 	realMain        Utils.RealMain = nil
 	moduleInfo_GL   Utils.ModuleInfo[_MGI]
 )
+var modules_GL []Utils.Module = nil
+var exit_code_GL int = Utils.EXIT_CODE_OK
 func main() {
-	var module Utils.Module = Utils.Module{
-		Num:     Utils.NUM_MOD_VISOR,
-		Name:    Utils.GetModNameMODULES(Utils.NUM_MOD_VISOR),
-		Stop:    false,
-		Stopped: false,
-		Enabled: true,
+	if len(os.Args) > 1 && "secrets" == os.Args[1] {
+		os.Exit(UtilsSWA.RunSecretsCmdSECRETS(os.Args[2:]))
 	}
-	Utils.ModStartup2[_MGI](realMain, &module, true)
+
+	// Unlock the secrets before reading the settings, which may reference them
+	if err := UtilsSWA.UnlockSecretsSECRETS(); nil != err {
+		log.Println("Error unlocking the secrets store: " + err.Error())
+	}
+
+	if Utils.WasArgUsedGENERAL(os.Args, "--check-config") {
+		if !Utils.CheckUserSettingsSETTINGS(true) {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if Utils.WasArgUsedGENERAL(os.Args, "--install-systemd-unit") {
+		// Optionally followed by the path of the unit file
+		var unit_file string = Utils.SYSTEMD_UNIT_FILE
+		for i, arg := range os.Args {
+			if "--install-systemd-unit" == arg && i + 1 < len(os.Args) && !strings.HasPrefix(os.Args[i + 1], "--") {
+				unit_file = os.Args[i + 1]
+			}
+		}
+		if err := Utils.InstallUnitSYSTEMD(unit_file); nil != err {
+			log.Println("Error installing the systemd unit: " + err.Error())
+			os.Exit(1)
+		}
+		log.Println("Unit written to " + unit_file + ". Run \"systemctl daemon-reload\" and " +
+			"\"systemctl enable --now " + filepath.Base(unit_file) + "\" to start it.")
+
+		return
+	}
+
+	if Utils.WasArgUsedGENERAL(os.Args, "--journald") {
+		Utils.EnableJournaldLOGS()
+	}
+
+	modules_GL = make([]Utils.Module, Utils.MODS_ARRAY_SIZE)
+	for i := 0; i < Utils.MODS_ARRAY_SIZE; i++ {
+		modules_GL[i].Num = i
+		modules_GL[i].Name = Utils.GetModNameMODULES(i)
+		modules_GL[i].SetEnabled(true)
+	}
+	Utils.ModStartup2[_MGI](realMain, &modules_GL[Utils.NUM_MOD_VISOR], true)
+
+	os.Exit(exit_code_GL)
 }
 func init() {realMain =
-	func(module_stop *bool, moduleInfo_any any) {
+	func(module_stop Utils.StopSignal, moduleInfo_any any) {
 		if !Utils.RunningAsAdminPROCESSES() {
 			log.Println("Not running as administrator/root. Exiting...")
 
@@ -57,63 +100,64 @@ func init() {realMain =
 
 		ServerRegKeys.RegisterValues()
 
-		var modules []Utils.Module
-		for i := 0; i < Utils.MODS_ARRAY_SIZE; i++ {
-			modules = append(modules, Utils.Module{
-				Num:     i,
-				Name:    Utils.GetModNameMODULES(i),
-				Stop:    true,
-				Stopped: true,
-				Enabled: true,
-			})
-		}
-		// Just for it to print that VISOR is running
-		modules[Utils.NUM_MOD_VISOR].Stop = false
-		modules[Utils.NUM_MOD_VISOR].Stopped = false
+		var mod_states_ch chan Utils.ModStateChange = Utils.SubscribeModStatesMODULES()
+
 		// The Manager needs to be started first. It'll handle the others.
-		modules[Utils.NUM_MOD_ModManager].Stop = false
+		MOD_1.Start(modules_GL)
 
-		MOD_1.Start(modules)
+		// Let systemd know if the Manager gets stuck
+		Utils.StartWatchdogSYSTEMD(modules_GL)
 
-		handleCtrlCGracefully(module_stop)
+		// Stop all the modules and then VISOR on Ctrl+C or SIGTERM
+		Utils.HandleStopSignalsMODULES(func() {
+			_ = Utils.SdNotifySYSTEMD("STOPPING=1\nSTATUS=Stopping the modules")
+			// Stop the modules in order first, and only then VISOR itself
+			_ = Utils.ShutdownMODULES(modules_GL)
+			modules_GL[Utils.NUM_MOD_VISOR].TransitionState(Utils.MOD_STATE_STOPPING)
+		})
 
 		var no_status bool = Utils.WasArgUsedGENERAL(os.Args, "--nostatus")
 
+		if !no_status {
+			printModulesStatus(modules_GL)
+		}
+		Utils.NotifyModulesStatusSYSTEMD(modules_GL)
 		for {
-			// Wait forever while the other modules do their work
-			if !no_status {
-				printModulesStatus(modules)
-			}
-
-			if Utils.WaitWithStopTIMEDATE(module_stop, 1) {
+			// Wait forever while the other modules do their work, printing their status when any of them changes
+			change, stop := Utils.WaitModStateChangeMODULES(mod_states_ch, module_stop, 1)
+			if stop {
 				break
 			}
+			if change != nil {
+				if !no_status {
+					printModulesStatus(modules_GL)
+				}
+				Utils.NotifyModulesStatusSYSTEMD(modules_GL)
+			}
 		}
 
-		Utils.SignalModulesStopMODULES(modules)
+		Utils.UnsubscribeModStatesMODULES(mod_states_ch)
 
-		return
-	}
-}
+		_ = Utils.SdNotifySYSTEMD("STOPPING=1\nSTATUS=Stopping the modules")
 
-func handleCtrlCGracefully(module_stop *bool) {
-	// Copied from https://gist.github.com/jnovack/297cee036f3e5a430aa9444c0ae1b06d
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
+		exit_code_GL = Utils.ShutdownMODULES(modules_GL)
 
-		*module_stop = true
-	}()
+		return
+	}
 }
 
 func printModulesStatus(modules []Utils.Module) {
 	log.Println("--------------------------------")
-	for _, module := range modules {
+	for i := range modules {
+		var module *Utils.Module = &modules[i]
 		log.Println("--- " + module.Name + " ---")
-		log.Println("- Enabled: " + strconv.FormatBool(module.Enabled))
-		log.Println("- To stop: " + strconv.FormatBool(module.Stop))
+		log.Println("- Enabled: " + strconv.FormatBool(module.IsEnabled()))
+		state, time_state := module.GetStateAndTime()
+		var state_str string = Utils.MOD_STATES_NAMES[state]
+		if time_state != 0 {
+			state_str += " (since " + Utils.GetDateTimeStrTIMEDATE(time_state) + ")"
+		}
+		log.Println("- State: " + state_str)
 		log.Println("- Support: " + strconv.FormatBool(Utils.IsModSupportedMODULES(module.Num)))
-		log.Println("- Running: " + strconv.FormatBool(!module.Stopped))
 	}
 }
@@ -24,9 +24,21 @@
 package Utils
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"golang.org/x/sys/unix"
 )
 
+// _CONTROL_ROOT_DIR is the directory of the control sockets when VISOR runs as root.
+const _CONTROL_ROOT_DIR string = "/run/visor"
+
 /*
 RunningAsAdminPROCESSES checks if the program is running as administrator/root.
 
@@ -36,7 +48,7 @@ RunningAsAdminPROCESSES checks if the program is running as administrator/root.
   - true if the program is running as admin, false otherwise
 */
 func RunningAsAdminPROCESSES() bool {
-	stdOutErrCmd, err := ExecCmdSHELL([]string{"id -u"})
+	stdOutErrCmd, err := ExecCmdSHELL(context.Background(), []string{"id -u"})
 	if nil != err {
 		return false
 	}
@@ -57,3 +69,224 @@ new Windows Terminal). So use StartConAppPROCESSES() to start the program with c
 func HideConsoleWindowPROCESSES() {
 	// TODO See if it's needed on Linux too and find a way
 }
+
+// _UnixControlListener is the control endpoint on a Unix socket.
+type _UnixControlListener struct {
+	listener net.Listener
+}
+
+func (listener _UnixControlListener) Accept() (io.ReadWriteCloser, error) {
+	for {
+		conn, err := listener.listener.Accept()
+		if nil != err {
+			return nil, err
+		}
+
+		peer_uid, err := getControlPeerUidCONTROL(conn)
+		if nil == err && isControlUidAllowedCONTROL(peer_uid) {
+			return conn, nil
+		}
+		GetModLoggerLOGS(NUM_MOD_VISOR).Warning("Refused a control connection from another user", "uid", peer_uid,
+			"error", err)
+		_ = conn.Close()
+	}
+}
+
+func (listener _UnixControlListener) Close() error {
+	return listener.listener.Close()
+}
+
+/*
+getUserIdCONTROL gets the ID of the user running VISOR, to have one client control endpoint per user.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the ID of the user
+*/
+func getUserIdCONTROL() string {
+	return strconv.Itoa(os.Getuid())
+}
+
+/*
+getControlAddrCONTROL gets the address of a control endpoint: a Unix socket in a directory only the user running VISOR
+can change - /run/visor for root, else $XDG_RUNTIME_DIR/visor or, without it, a visor-<user ID> directory in the
+temporary directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the endpoint
+
+– Returns:
+  - the address of the endpoint
+*/
+func getControlAddrCONTROL(name string) string {
+	var dir string
+	if 0 == os.Getuid() {
+		dir = _CONTROL_ROOT_DIR
+	} else if runtime_dir := os.Getenv("XDG_RUNTIME_DIR"); "" != runtime_dir {
+		dir = filepath.Join(runtime_dir, "visor")
+	} else {
+		dir = filepath.Join(os.TempDir(), "visor-" + getUserIdCONTROL())
+	}
+
+	return filepath.Join(dir, name + ".sock")
+}
+
+/*
+listenControlCONTROL creates a control endpoint on a Unix socket only the user running VISOR (and root) can use.
+
+The directory of the socket is created if it doesn't exist and must not be changeable by other users, or they could
+create the socket first and pretend to be VISOR.
+
+-----------------------------------------------------------
+
+– Params:
+  - addr – the path of the socket
+
+– Returns:
+  - the endpoint
+  - an error if another VISOR is using the socket or it couldn't be created, nil otherwise
+*/
+func listenControlCONTROL(addr string) (_ControlListener, error) {
+	if err := os.MkdirAll(filepath.Dir(addr), 0o700); nil != err {
+		return nil, err
+	}
+	if err := checkControlDirCONTROL(filepath.Dir(addr)); nil != err {
+		return nil, err
+	}
+
+	if conn, err := net.Dial("unix", addr); nil == err {
+		_ = conn.Close()
+
+		return nil, errors.New("another VISOR is already listening on " + addr)
+	}
+	// Left by a VISOR that didn't exit cleanly
+	_ = os.Remove(addr)
+
+	listener, err := net.Listen("unix", addr)
+	if nil != err {
+		return nil, err
+	}
+	if err = os.Chmod(addr, 0o600); nil != err {
+		_ = listener.Close()
+
+		return nil, err
+	}
+
+	return _UnixControlListener{listener: listener}, nil
+}
+
+/*
+dialControlCONTROL connects to a control endpoint, making sure it's from the same user (or root).
+
+-----------------------------------------------------------
+
+– Params:
+  - addr – the path of the socket
+
+– Returns:
+  - the connection
+  - an error if the connection failed or the endpoint is from another user, nil otherwise
+*/
+func dialControlCONTROL(addr string) (io.ReadWriteCloser, error) {
+	conn, err := net.Dial("unix", addr)
+	if nil != err {
+		return nil, err
+	}
+
+	peer_uid, err := getControlPeerUidCONTROL(conn)
+	if nil != err {
+		_ = conn.Close()
+
+		return nil, err
+	}
+	if !isControlUidAllowedCONTROL(peer_uid) {
+		_ = conn.Close()
+
+		return nil, errors.New(addr + " belongs to another user (" + strconv.Itoa(peer_uid) + ") - refusing to use it")
+	}
+
+	return conn, nil
+}
+
+/*
+checkControlDirCONTROL checks that the directory of a control socket is a directory owned by the user running VISOR (or
+root) that other users can't write to.
+
+-----------------------------------------------------------
+
+– Params:
+  - dir – the path of the directory
+
+– Returns:
+  - nil if the directory is safe to use, an error otherwise
+*/
+func checkControlDirCONTROL(dir string) error {
+	var stat unix.Stat_t
+	if err := unix.Lstat(dir, &stat); nil != err {
+		return err
+	}
+
+	if unix.S_IFDIR != stat.Mode & unix.S_IFMT {
+		return errors.New(dir + " is not a directory")
+	}
+	if !isControlUidAllowedCONTROL(int(stat.Uid)) || 0 != stat.Mode & 0o022 {
+		return errors.New("other users can change " + dir + " - refusing to use it for the control endpoint")
+	}
+
+	return nil
+}
+
+/*
+getControlPeerUidCONTROL gets the ID of the user of the process on the other side of a control connection.
+
+-----------------------------------------------------------
+
+– Params:
+  - conn – the connection
+
+– Returns:
+  - the ID of the user
+  - an error if it couldn't be obtained, nil otherwise
+*/
+func getControlPeerUidCONTROL(conn net.Conn) (int, error) {
+	unix_conn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1, errors.New("not a Unix socket connection")
+	}
+	raw_conn, err := unix_conn.SyscallConn()
+	if nil != err {
+		return -1, err
+	}
+
+	var ucred *unix.Ucred = nil
+	var ucred_err error = nil
+	if err = raw_conn.Control(func(fd uintptr) {
+		ucred, ucred_err = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); nil != err {
+		return -1, err
+	}
+	if nil != ucred_err {
+		return -1, ucred_err
+	}
+
+	return int(ucred.Uid), nil
+}
+
+/*
+isControlUidAllowedCONTROL checks if a user can be on the other side of the control endpoint: only the user running
+VISOR and root.
+
+-----------------------------------------------------------
+
+– Params:
+  - uid – the ID of the user
+
+– Returns:
+  - true if the user is allowed, false otherwise
+*/
+func isControlUidAllowedCONTROL(uid int) bool {
+	return uid == os.Getuid() || 0 == uid
+}
@@ -24,6 +24,7 @@ package Utils
 import (
 	"errors"
 	"os"
+	"reflect"
 	"strings"
 )
 
@@ -100,6 +101,11 @@ func (personalConsts *PersonalConsts) Init(server bool) error {
 	if err := FromJsonGENERAL(bytes, &struct_file_format); err != nil {
 		return err
 	}
+	var problems []SettingsProblem = nil
+	resolveSecretRefsSETTINGS(reflect.ValueOf(&struct_file_format).Elem(), "", &problems)
+	if err := settingsProblemsToError(PERSONAL_CONSTS_FILE, problems); err != nil {
+		return err
+	}
 
 	// Set the global variables
 
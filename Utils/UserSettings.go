@@ -23,16 +23,17 @@ package Utils
 
 import "OnlineInfoChk/OICNews"
 
-var User_settings_GL UserSettings
-
 type UserSettings struct {
 	PersonalConsts _PersonalConsts
+	Scheduler _Scheduler
 	MOD_2  _MOD_2
 	MOD_4  _MOD_4
 	MOD_6  _MOD_6
 	MOD_7  _MOD_7
 	MOD_10 _MOD_10
 	MOD_12 _MOD_12
+	// Plugins maps the names of the plugin modules to their settings, in any format (each plugin gets its own as is)
+	Plugins map[string]any
 }
 
 ///////////////////////////////////////////////////////////////
@@ -71,6 +72,17 @@ type _PersonalConsts struct {
 
 ///////////////////////////////////////////////////////////////
 
+type _Scheduler struct {
+	// Quiet_hours is the range of local time in the format "HH:MM-HH:MM" in which no scheduled work is done (unless the
+	// item has its own quiet hours)
+	Quiet_hours string
+	// Items maps the IDs of scheduled items (like "MOD_4.feed_3") to schedules that override the modules' defaults.
+	// Only the fields that are set are overridden.
+	Items map[string]Schedule
+}
+
+///////////////////////////////////////////////////////////////
+
 type _MOD_2 struct {
 	// Disks_info is the information about the disks. It maps the disk serial number to the disk information struct.
 	Disks_info _DiskInfo
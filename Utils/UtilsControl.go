@@ -0,0 +1,455 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CONTROL_ADDR_ENV is the environment variable that overrides the address of the control endpoint (the path of the
+// Unix socket or of the Windows named pipe).
+const CONTROL_ADDR_ENV string = "VISOR_CONTROL_ADDR"
+
+// _CONTROL_MAX_MSG_SIZE is the maximum size in bytes of a message on the control endpoint.
+const _CONTROL_MAX_MSG_SIZE int = 1024*1024
+
+// _CONTROL_LOGS_DEF_ENTRIES is the number of log entries shown by the "logs" command by default.
+const _CONTROL_LOGS_DEF_ENTRIES int = 20
+
+// ControlRequest is a request sent to the control endpoint, as a JSON line.
+type ControlRequest struct {
+	// Cmd is the command to run
+	Cmd string
+	// Args are the arguments of the command
+	Args []string
+}
+
+// ControlResponse is a message sent by the control endpoint, as a JSON line. A command sends any number of messages
+// with Output and then one with Done (and Error if it failed). Empty messages are only to check the client is still
+// there and must be ignored.
+type ControlResponse struct {
+	// Output is a line of output of the command
+	Output string `json:",omitempty"`
+	// Error is the error of the command, if it failed
+	Error string `json:",omitempty"`
+	// Done is true on the last message of the command
+	Done bool `json:",omitempty"`
+}
+
+/*
+ControlCmd is the type of the functions that handle the commands of the control endpoint.
+
+-----------------------------------------------------------
+
+– Params:
+  - args – the arguments of the command
+  - output – a function that sends a line of output to the client. It returns false if the client is gone (for
+    commands that keep running, like following the logs). An empty line isn't shown - it's just to check the client.
+
+– Returns:
+  - an error if the command failed, nil otherwise
+*/
+type ControlCmd func(args []string, output func(line string) bool) error
+
+// _ControlListener is the endpoint waiting for control connections (a Unix socket or a Windows named pipe).
+type _ControlListener interface {
+	// Accept waits for the next connection
+	Accept() (io.ReadWriteCloser, error)
+	// Close stops waiting for connections
+	Close() error
+}
+
+var control_cmds_GL map[string]ControlCmd = map[string]ControlCmd{
+	"reload": controlReloadCONTROL,
+	"logs":   controlLogsCONTROL,
+}
+var control_cmds_mutex_GL sync.Mutex
+
+/*
+RegisterControlCmdCONTROL registers a command of the control endpoint, for the ones that need things Utils can't import
+(like the Registry).
+
+-----------------------------------------------------------
+
+– Params:
+  - cmd – the name of the command
+  - handler – the function that handles the command
+*/
+func RegisterControlCmdCONTROL(cmd string, handler ControlCmd) {
+	control_cmds_mutex_GL.Lock()
+	defer control_cmds_mutex_GL.Unlock()
+
+	control_cmds_GL[cmd] = handler
+}
+
+/*
+GetControlAddrCONTROL gets the address of the control endpoint: the value of CONTROL_ADDR_ENV if it's set, else a
+Unix socket in a directory private to the user on Linux or a named pipe on Windows. The client's one is per user.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true for the server version's endpoint, false for the client version's one
+
+– Returns:
+  - the address of the control endpoint
+*/
+func GetControlAddrCONTROL(server bool) string {
+	if addr := os.Getenv(CONTROL_ADDR_ENV); "" != addr {
+		return addr
+	}
+
+	var name string = "visor_server"
+	if !server {
+		name = "visor_client_" + getUserIdCONTROL()
+	}
+
+	return getControlAddrCONTROL(name)
+}
+
+/*
+StartControlServerCONTROL starts listening for commands on the control endpoint.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true if it's the server version running, false if it's the client version
+
+– Returns:
+  - a function that stops listening
+  - an error if the endpoint couldn't be created (like when another VISOR is using it), nil otherwise
+*/
+func StartControlServerCONTROL(server bool) (func(), error) {
+	listener, err := listenControlCONTROL(GetControlAddrCONTROL(server))
+	if nil != err {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if nil != err {
+				return
+			}
+
+			go handleControlConnCONTROL(conn)
+		}
+	}()
+
+	return func() {
+		_ = listener.Close()
+	}, nil
+}
+
+/*
+handleControlConnCONTROL runs the command requested on a control connection and sends its output.
+
+-----------------------------------------------------------
+
+– Params:
+  - conn – the connection
+*/
+func handleControlConnCONTROL(conn io.ReadWriteCloser) {
+	defer conn.Close()
+
+	var scanner *bufio.Scanner = bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), _CONTROL_MAX_MSG_SIZE)
+	if !scanner.Scan() {
+		return
+	}
+
+	var write_mutex sync.Mutex
+	var client_gone bool = false
+	var send = func(response ControlResponse) bool {
+		write_mutex.Lock()
+		defer write_mutex.Unlock()
+
+		if client_gone {
+			return false
+		}
+		response_json, _ := json.Marshal(response)
+		if _, err := conn.Write(append(response_json, '\n')); nil != err {
+			client_gone = true
+		}
+
+		return !client_gone
+	}
+
+	var request ControlRequest
+	if err := json.Unmarshal(scanner.Bytes(), &request); nil != err {
+		send(ControlResponse{Error: "invalid request: " + err.Error(), Done: true})
+
+		return
+	}
+
+	var handler ControlCmd = nil
+	if "help" == request.Cmd {
+		handler = controlHelpCONTROL
+	} else {
+		control_cmds_mutex_GL.Lock()
+		handler = control_cmds_GL[request.Cmd]
+		control_cmds_mutex_GL.Unlock()
+	}
+	if nil == handler {
+		send(ControlResponse{Error: "unknown command \"" + request.Cmd + "\" (try \"help\")", Done: true})
+
+		return
+	}
+
+	var response ControlResponse = ControlResponse{Done: true}
+	if err := runControlCmdCONTROL(request.Cmd, handler, request.Args, func(line string) bool {
+		return send(ControlResponse{Output: line})
+	}); nil != err {
+		response.Error = err.Error()
+	}
+	send(response)
+}
+
+/*
+runControlCmdCONTROL runs the handler of a control command, turning a panic in it into an error so that VISOR keeps
+running and the client still gets a final response.
+
+-----------------------------------------------------------
+
+– Params:
+  - cmd – the name of the command
+  - handler – the handler of the command
+  - args – the arguments of the command
+  - output – the function to send output lines to the client
+
+– Returns:
+  - the error returned by the handler, or an error describing its panic
+*/
+func runControlCmdCONTROL(cmd string, handler ControlCmd, args []string, output func(line string) bool) (err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			GetModLoggerLOGS(NUM_MOD_VISOR).Error("The control command panicked", "command", cmd, "error",
+				GetFullErrorMsgGENERAL(r))
+			err = fmt.Errorf("the command panicked: %v", r)
+		}
+	}()
+
+	return handler(args, output)
+}
+
+/*
+RunControlCmdCONTROL runs a command on the control endpoint of a running VISOR.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true to use the server version's endpoint, false to use the client version's one
+  - cmd – the command
+  - args – the arguments of the command
+  - output – where to write the output of the command, one line at a time
+
+– Returns:
+  - an error if VISOR couldn't be reached or the command failed, nil otherwise
+*/
+func RunControlCmdCONTROL(server bool, cmd string, args []string, output io.Writer) error {
+	conn, err := dialControlCONTROL(GetControlAddrCONTROL(server))
+	if nil != err {
+		return errors.New("couldn't connect to VISOR (is it running?): " + err.Error())
+	}
+	defer conn.Close()
+
+	request_json, _ := json.Marshal(ControlRequest{
+		Cmd:  cmd,
+		Args: args,
+	})
+	if _, err = conn.Write(append(request_json, '\n')); nil != err {
+		return err
+	}
+
+	var scanner *bufio.Scanner = bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), _CONTROL_MAX_MSG_SIZE)
+	for scanner.Scan() {
+		var response ControlResponse
+		if err = json.Unmarshal(scanner.Bytes(), &response); nil != err {
+			return errors.New("invalid response: " + err.Error())
+		}
+		if response.Done {
+			if "" != response.Error {
+				return errors.New(response.Error)
+			}
+
+			return nil
+		}
+		if "" == response.Output {
+			continue
+		}
+		_, _ = io.WriteString(output, response.Output + "\n")
+	}
+
+	return errors.New("VISOR closed the connection before the command finished")
+}
+
+/*
+FindModNumCONTROL finds a module by its number ("4"), its folder name ("MOD_4") or its name (any case).
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_id – the identification of the module
+
+– Returns:
+  - the number of the module or -1 if there's no such module
+*/
+func FindModNumCONTROL(mod_id string) int {
+	var mod_num_str string = strings.TrimPrefix(strings.ToUpper(mod_id), _MOD_FOLDER_PREFFIX)
+	if mod_num, err := strconv.Atoi(mod_num_str); nil == err {
+		if _, ok := MOD_NUMS_NAMES[mod_num]; ok {
+			return mod_num
+		}
+
+		return -1
+	}
+
+	for mod_num, mod_name := range MOD_NUMS_NAMES {
+		if strings.EqualFold(mod_name, mod_id) {
+			return mod_num
+		}
+	}
+
+	return -1
+}
+
+/*
+controlHelpCONTROL handles the "help" command: lists the available commands.
+*/
+func controlHelpCONTROL(args []string, output func(line string) bool) error {
+	control_cmds_mutex_GL.Lock()
+	var cmds []string = []string{"help"}
+	for cmd := range control_cmds_GL {
+		cmds = append(cmds, cmd)
+	}
+	control_cmds_mutex_GL.Unlock()
+
+	sort.Strings(cmds)
+	output("Commands: " + strings.Join(cmds, ", "))
+
+	return nil
+}
+
+/*
+controlReloadCONTROL handles the "reload" command: reloads the user settings file.
+*/
+func controlReloadCONTROL(args []string, output func(line string) bool) error {
+	if err := ReloadUserSettingsMODULES(); nil != err {
+		return err
+	}
+	output(USER_SETTINGS_FILE + " reloaded")
+
+	return nil
+}
+
+/*
+controlLogsCONTROL handles the "logs" command: "logs [-n <entries>] [-l <min level>] [-f] [module]" shows the last
+entries of the logs of a module (or all) and, with -f, keeps showing the new ones.
+*/
+func controlLogsCONTROL(args []string, output func(line string) bool) error {
+	var max_entries int = _CONTROL_LOGS_DEF_ENTRIES
+	var min_level int = LOG_LEVEL_DEBUG
+	var follow bool = false
+	var mod_num int = -1
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+			case "-f":
+				follow = true
+			case "-n", "-l":
+				if i + 1 >= len(args) {
+					return errors.New(args[i] + " needs a value")
+				}
+				i++
+				if "-n" == args[i - 1] {
+					num, err := strconv.Atoi(args[i])
+					if nil != err || num < 0 {
+						return errors.New("invalid number of entries \"" + args[i] + "\"")
+					}
+					max_entries = num
+				} else {
+					min_level = -1
+					for level, level_name := range LOG_LEVELS_NAMES {
+						if strings.EqualFold(level_name, args[i]) {
+							min_level = level
+						}
+					}
+					if -1 == min_level {
+						return errors.New("invalid log level \"" + args[i] + "\"")
+					}
+				}
+			default:
+				mod_num = FindModNumCONTROL(args[i])
+				if -1 == mod_num {
+					return errors.New("unknown module \"" + args[i] + "\"")
+				}
+		}
+	}
+
+	// Subscribe before getting the last entries so that none is lost in between
+	var logs_ch chan LogEntry = nil
+	if follow {
+		logs_ch = SubscribeLogsLOGS()
+		defer UnsubscribeLogsLOGS(logs_ch)
+	}
+
+	var last_time int64 = 0
+	if max_entries > 0 {
+		for _, log_entry := range QueryLogsLOGS(mod_num, min_level, max_entries) {
+			if !output(log_entry.String()) {
+				return nil
+			}
+			last_time = log_entry.Time
+		}
+	}
+
+	for follow {
+		select {
+			case log_entry := <-logs_ch:
+				if log_entry.Time < last_time || log_entry.Level < min_level ||
+						(-1 != mod_num && log_entry.Mod_num != mod_num) {
+					continue
+				}
+				if !output(log_entry.String()) {
+					return nil
+				}
+			case <-time.After(time.Second):
+				// Just to check if the client is still there
+				if !output("") {
+					return nil
+				}
+		}
+	}
+
+	return nil
+}
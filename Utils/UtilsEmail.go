@@ -23,9 +23,9 @@ package Utils
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"mime/quotedprintable"
-	"os"
 	"strings"
 )
 
@@ -88,6 +88,8 @@ const (
 
 const RAND_STR_LEN int = 10
 
+// TO_SEND_REL_FOLDER is the folder of the Email Sender module where EML files named "<RAND_STR_LEN random
+// characters><email address>.eml" can be dropped to be sent (compatibility with the queue before TOPIC_EMAILS_TO_SEND).
 const TO_SEND_REL_FOLDER string = "to_send"
 const _EMAIL_MODELS_FOLDER string = "email_models"
 
@@ -98,6 +100,18 @@ const MODEL_FILE_RSS string = "model_email_rss.html"
 const MODEL_FILE_YT_VIDEO string = "model_email_video_YouTube.html"
 const MODEL_FILE_DISKS_SMART string = "model_email_disks_smart.html"
 const _MODEL_FILE_MESSAGE_EML string = "model_message.eml"
+// QueuedEmail is an email queued to be sent by the Email Sender module.
+type QueuedEmail struct {
+	// Mail_to is the email address to send the email to
+	Mail_to string
+	// Eml is the email in EML format
+	Eml     string
+}
+
+// TOPIC_EMAILS_TO_SEND has the emails queued to be sent by the Email Sender module.
+var TOPIC_EMAILS_TO_SEND *Topic[QueuedEmail] = NewTopicEVENTS[QueuedEmail]("emails_to_send", true,
+	GetModSubscriberEVENTS(NUM_MOD_EmailSender))
+
 /*
 GetModelFileEMAIL returns the contents of an email model file.
 
@@ -133,7 +147,7 @@ func GetModelFileEMAIL(file_name string, things_replace map[string]string) Email
 
 	return EmailInfo{
 		Sender:     sender,
-		Mail_to:    User_settings_GL.PersonalConsts.User_email_addr,
+		Mail_to:    GetUserSettingsSETTINGS().PersonalConsts.User_email_addr,
 		Subject:    "",
 		Html:       msg_html,
 		Multiparts: nil,
@@ -143,7 +157,8 @@ func GetModelFileEMAIL(file_name string, things_replace map[string]string) Email
 /*
 QueueEmailEMAIL queues an email to be sent by the Email Sender module.
 
-If
+On the server, the email is published on TOPIC_EMAILS_TO_SEND. On the client, it's sent to the server through the
+website.
 
 -----------------------------------------------------------
 
@@ -165,23 +180,13 @@ func QueueEmailEMAIL(emailInfo EmailInfo) error {
 		message_eml = eml
 	}
 
-	if User_settings_GL.PersonalConsts.VISOR_server {
-		// Keep trying to create a file with a unique name.
-		var file_name string = ""
-		var to_send_dir GPath = GetUserDataDirMODULES(NUM_MOD_EmailSender).Add2(true, TO_SEND_REL_FOLDER)
-		for {
-			var rand_string string = RandStringGENERAL(RAND_STR_LEN)
-			_, err := os.ReadFile(to_send_dir.Add2(false, rand_string+emailInfo.Mail_to+".eml").
-				GPathToStringConversion())
-			if nil != err {
-				// If the file doesn't exist, choose that name.
-				file_name = rand_string + emailInfo.Mail_to + ".eml"
-
-				return to_send_dir.Add2(false, file_name).WriteTextFile(message_eml, false)
-			}
-		}
+	if GetUserSettingsSETTINGS().PersonalConsts.VISOR_server {
+		return TOPIC_EMAILS_TO_SEND.Publish(QueuedEmail{
+			Mail_to: emailInfo.Mail_to,
+			Eml:     message_eml,
+		})
 	} else {
-		_, err := SubmitFormWEBSITE(WebsiteForm{
+		_, err := SubmitFormWEBSITE(context.Background(), WebsiteForm{
 			Type:  "Email",
 			Text1: emailInfo.Mail_to,
 			Text2: message_eml,
@@ -211,7 +216,7 @@ func SendEmailEMAIL(message_eml string, mail_to string, emergency_email bool) er
 	if err := getModTempDirMODULES(NUM_MOD_EmailSender).Add2(false, _TEMP_EML_FILE).WriteTextFile(message_eml, false); nil != err {
 		return err
 	}
-	_, err := ExecCmdSHELL([]string{getCurlStringEMAIL(mail_to, emergency_email)})
+	_, err := ExecCmdSHELL(context.Background(), []string{getCurlStringEMAIL(mail_to, emergency_email)})
 
 	return err
 }
@@ -317,8 +322,10 @@ func getCurlStringEMAIL(mail_to string, emergency_email bool) string {
 		timeout = "100000"
 	}
 
+	var personal_consts _PersonalConsts = GetUserSettingsSETTINGS().PersonalConsts
+
 	return "curl{{EXE}} --location --connect-timeout " + timeout + " --verbose \"smtp://smtp.gmail.com:587\" --user \"" +
-		User_settings_GL.PersonalConsts.VISOR_email_addr + ":" + User_settings_GL.PersonalConsts.VISOR_email_pw +
+		personal_consts.VISOR_email_addr + ":" + personal_consts.VISOR_email_pw +
 		"\" --mail-rcpt \"" + mail_to + "\" --upload-file \"" +
 		getModTempDirMODULES(NUM_MOD_EmailSender).Add2(false, _TEMP_EML_FILE).GPathToStringConversion() + "\" --ssl-reqd"
 }
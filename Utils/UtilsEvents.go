@@ -0,0 +1,512 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// _EVENTS_REL_DIR is the directory inside VISOR's user data directory with the journals of the events.
+const _EVENTS_REL_DIR string = "events"
+// _EVENT_REDELIVERY_S is the time after which an event delivered but not acknowledged is delivered again.
+const _EVENT_REDELIVERY_S int64 = 10*60
+// _FOLDER_ADAPTER_CHECK_S is the time between checks of the folders of the compatibility adapters.
+const _FOLDER_ADAPTER_CHECK_S int = 1
+
+// TOPIC_GPT_TO_PROCESS has the texts for the GPT Communicator module to process, in the format "[device_id]text".
+var TOPIC_GPT_TO_PROCESS *Topic[string] = NewTopicEVENTS[string]("gpt_to_process", true,
+	GetModSubscriberEVENTS(NUM_MOD_GPTCommunicator))
+
+// DeviceInfoUpdate is the information sent by a device for the User Locator module.
+type DeviceInfoUpdate struct {
+	// Device_id is the ID of the device
+	Device_id string
+	// Info_json is the information about the device (a ULComm.DeviceInfo in JSON)
+	Info_json string
+}
+
+// TOPIC_DEVICES_INFO has the information sent by the devices for the User Locator module.
+var TOPIC_DEVICES_INFO *Topic[DeviceInfoUpdate] = NewTopicEVENTS[DeviceInfoUpdate]("devices_info", true,
+	GetModSubscriberEVENTS(NUM_MOD_UserLocator))
+
+/*
+Topic is a topic of the event bus, with events of type T.
+
+Each event published is delivered to each subscriber of the topic at least once: until a subscriber acknowledges an
+event, it's delivered again if the subscriber closes its subscription or doesn't acknowledge it in _EVENT_REDELIVERY_S.
+The durable subscribers get the events published while they're not subscribed too, and if the topic is journaled,
+their events are kept on disk until acknowledged, so they survive restarts.
+*/
+type Topic[T any] struct {
+	name         string
+	journaled    bool
+	durable_subs []string
+}
+
+// Event is an event of the event bus.
+type Event[T any] struct {
+	// Id is the unique ID of the event (the order of the IDs is the order of publication)
+	Id string
+	// Time is the time of publication in milliseconds
+	Time int64
+	// Payload is the data of the event
+	Payload T
+}
+
+// Subscription is a subscription to a Topic. It is not safe for concurrent use.
+type Subscription[T any] struct {
+	topic *Topic[T]
+	queue *_BusQueue
+	// durable is true if the subscriber is a durable subscriber of the topic
+	durable bool
+}
+
+// _BusEvent is an event in a queue of the bus. It's also the format of the files of the journals.
+type _BusEvent struct {
+	Id      string
+	Time    int64
+	Payload json.RawMessage
+
+	// delivered_at is the time of the last delivery in milliseconds, or 0 if the event is waiting to be delivered
+	delivered_at int64
+}
+
+// _BusQueue is the queue of the events of a topic for a subscriber.
+type _BusQueue struct {
+	// journal_dir is the directory of the journal of the queue, or an empty GPath if the queue is not journaled
+	journal_dir GPath
+	events      []*_BusEvent
+	// signal receives a value when events are added to the queue
+	signal chan struct{}
+}
+
+// bus_queues_GL maps each topic name to the queues of its subscribers.
+var bus_queues_GL map[string]map[string]*_BusQueue = make(map[string]map[string]*_BusQueue)
+var bus_mutex_GL sync.Mutex
+var bus_event_seq_GL atomic.Int64
+
+/*
+NewTopicEVENTS creates a topic of the event bus. Declare the topics as global variables.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the topic (unique and usable as a directory name)
+  - journaled – true to keep the events of the durable subscribers on disk until they're acknowledged
+  - durable_subs – the names of the durable subscribers of the topic (use GetModSubscriberEVENTS() for modules)
+
+– Returns:
+  - the topic
+*/
+func NewTopicEVENTS[T any](name string, journaled bool, durable_subs ...string) *Topic[T] {
+	return &Topic[T]{
+		name:         name,
+		journaled:    journaled,
+		durable_subs: durable_subs,
+	}
+}
+
+/*
+GetModSubscriberEVENTS gets the name of a module as a subscriber of the event bus.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the name of the subscriber
+*/
+func GetModSubscriberEVENTS(mod_num int) string {
+	return getModSettingsSectionNameSETTINGS(mod_num)
+}
+
+/*
+Publish publishes an event on the topic, delivering it to the durable subscribers and to the current subscribers.
+
+-----------------------------------------------------------
+
+– Params:
+  - payload – the data of the event
+
+– Returns:
+  - nil if the event was published, an error if it couldn't be encoded or written to a journal
+*/
+func (topic *Topic[T]) Publish(payload T) error {
+	payload_json, err := json.Marshal(payload)
+	if nil != err {
+		return err
+	}
+
+	var now int64 = GetClockTIMEDATE().Now().UnixMilli()
+	var event_id string = fmt.Sprintf("%013d_%06d", now, bus_event_seq_GL.Add(1) % 1000000)
+
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	topic.createDurableQueues()
+
+	var errs []string = nil
+	for subscriber, queue := range bus_queues_GL[topic.name] {
+		var event *_BusEvent = &_BusEvent{
+			Id:      event_id,
+			Time:    now,
+			Payload: payload_json,
+		}
+		if "" != queue.journal_dir.p {
+			var event_path GPath = queue.journal_dir.Add2(false, event_id + ".json")
+			if err = writeFileSyncedMODULES(event_path.GPathToStringConversion(), []byte(*ToJsonGENERAL(event))); nil != err {
+				errs = append(errs, subscriber + ": " + err.Error())
+			}
+		}
+		queue.events = append(queue.events, event)
+
+		select {
+			case queue.signal <- struct{}{}:
+			default:
+				// Already signalled
+		}
+	}
+	if nil != errs {
+		return errors.New("error journaling the event of topic " + topic.name + " - " + strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+/*
+Subscribe subscribes to the topic. If the subscriber is durable, it gets the events not acknowledged yet, including the
+ones delivered before and not acknowledged.
+
+Call Close() on the subscription when it's no longer needed.
+
+-----------------------------------------------------------
+
+– Params:
+  - subscriber – the name of the subscriber (at most one subscription per subscriber at a time)
+
+– Returns:
+  - the subscription
+*/
+func (topic *Topic[T]) Subscribe(subscriber string) *Subscription[T] {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	topic.createDurableQueues()
+
+	var queue *_BusQueue = bus_queues_GL[topic.name][subscriber]
+	if nil == queue {
+		queue = &_BusQueue{
+			signal: make(chan struct{}, 1),
+		}
+		bus_queues_GL[topic.name][subscriber] = queue
+	}
+	// The events delivered to a previous subscription and not acknowledged must be delivered again
+	for _, event := range queue.events {
+		event.delivered_at = 0
+	}
+
+	return &Subscription[T]{
+		topic:   topic,
+		queue:   queue,
+		durable: ContainsSLICES(topic.durable_subs, subscriber),
+	}
+}
+
+/*
+StartFolderAdapter starts a compatibility adapter that publishes on the topic the files dropped into a folder (oldest
+first), deleting them after. It stops when the context is cancelled.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context of the adapter
+  - dir – the folder
+  - fileToPayload – the function that converts a file into the data of an event, returning false if the file is
+    invalid (it's deleted without being published)
+*/
+func (topic *Topic[T]) StartFolderAdapter(ctx context.Context, dir GPath, fileToPayload func(file_info FileInfo) (T, bool)) {
+	go func() {
+		for {
+			var files_info []FileInfo = dir.GetFileList()
+			for len(files_info) > 0 {
+				file_info, idx_to_remove := GetOldestFileFILESDIRS(files_info)
+				DelElemSLICES(&files_info, idx_to_remove)
+
+				if payload, ok := fileToPayload(file_info); ok {
+					if err := topic.Publish(payload); nil != err {
+						// Keep the file to try again later
+						continue
+					}
+				}
+				_ = file_info.GPath.Remove()
+			}
+
+			if WaitWithCtxTIMEDATE(ctx, _FOLDER_ADAPTER_CHECK_S) {
+				return
+			}
+		}
+	}()
+}
+
+/*
+createDurableQueues creates the queues of the durable subscribers of the topic that don't exist yet, loading the
+journaled events. Call with bus_mutex_GL locked.
+*/
+func (topic *Topic[T]) createDurableQueues() {
+	if nil == bus_queues_GL[topic.name] {
+		bus_queues_GL[topic.name] = make(map[string]*_BusQueue)
+	}
+
+	for _, subscriber := range topic.durable_subs {
+		if nil != bus_queues_GL[topic.name][subscriber] {
+			continue
+		}
+
+		var queue *_BusQueue = &_BusQueue{
+			signal: make(chan struct{}, 1),
+		}
+		if topic.journaled {
+			queue.journal_dir = GetUserDataDirMODULES(NUM_MOD_VISOR).Add2(true, _EVENTS_REL_DIR, topic.name, subscriber)
+			_ = queue.journal_dir.Create(false)
+			queue.events = readJournalEVENTS(queue.journal_dir)
+		}
+		bus_queues_GL[topic.name][subscriber] = queue
+	}
+}
+
+/*
+Next gets the next event of the subscription, waiting for one if there's none.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to wait on
+  - max_wait_s – the maximum time to wait in seconds (0 to not wait)
+
+– Returns:
+  - the event or nil if there was none in the maximum time or if the context was cancelled
+  - whether the context was cancelled
+*/
+func (subscription *Subscription[T]) Next(ctx context.Context, max_wait_s int) (*Event[T], bool) {
+	var clock Clock = GetClockTIMEDATE()
+	var time_end time.Time = clock.Now().Add(time.Duration(max_wait_s) * time.Second)
+
+	for {
+		if ctx.Err() != nil {
+			return nil, true
+		}
+
+		if event := subscription.TryNext(); nil != event {
+			return event, false
+		}
+
+		var time_left time.Duration = time_end.Sub(clock.Now())
+		if time_left <= 0 {
+			return nil, false
+		}
+
+		switch clock.Wait(ctx, time_left, subscription.queue.signal) {
+			case WAIT_CANCELLED:
+				return nil, true
+			case WAIT_TIMED_OUT:
+				return subscription.TryNext(), false
+			default:
+				// Check again
+		}
+	}
+}
+
+/*
+TryNext gets the next event of the subscription without waiting.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the event or nil if there's none
+*/
+func (subscription *Subscription[T]) TryNext() *Event[T] {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	var now int64 = GetClockTIMEDATE().Now().UnixMilli()
+	for i := 0; i < len(subscription.queue.events); i++ {
+		var bus_event *_BusEvent = subscription.queue.events[i]
+		if 0 != bus_event.delivered_at && now - bus_event.delivered_at < _EVENT_REDELIVERY_S*1000 {
+			continue
+		}
+
+		var event Event[T] = Event[T]{
+			Id:   bus_event.Id,
+			Time: bus_event.Time,
+		}
+		if err := json.Unmarshal(bus_event.Payload, &event.Payload); nil != err {
+			// It will never be decodable, so drop it
+			GetModLoggerLOGS(NUM_MOD_VISOR).Error("Dropping undecodable event", "topic", subscription.topic.name,
+				"id", bus_event.Id, "error", err)
+			subscription.removeEvent(i)
+			i--
+
+			continue
+		}
+		bus_event.delivered_at = now
+
+		return &event
+	}
+
+	return nil
+}
+
+/*
+Ack acknowledges an event, removing it from the subscription (and its journal). It won't be delivered again.
+
+-----------------------------------------------------------
+
+– Params:
+  - event – the event
+*/
+func (subscription *Subscription[T]) Ack(event *Event[T]) {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	for i, bus_event := range subscription.queue.events {
+		if bus_event.Id == event.Id {
+			subscription.removeEvent(i)
+
+			return
+		}
+	}
+}
+
+/*
+Nack gives an event back to the subscription without acknowledging it, so that it's delivered again right away.
+
+-----------------------------------------------------------
+
+– Params:
+  - event – the event
+*/
+func (subscription *Subscription[T]) Nack(event *Event[T]) {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	for _, bus_event := range subscription.queue.events {
+		if bus_event.Id == event.Id {
+			bus_event.delivered_at = 0
+
+			return
+		}
+	}
+}
+
+/*
+Close closes the subscription. The events delivered and not acknowledged are delivered again on the next subscription,
+if the subscriber is durable - else they're discarded, as are the events published from then on.
+*/
+func (subscription *Subscription[T]) Close() {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	if subscription.durable {
+		for _, event := range subscription.queue.events {
+			event.delivered_at = 0
+		}
+
+		return
+	}
+
+	for subscriber, queue := range bus_queues_GL[subscription.topic.name] {
+		if queue == subscription.queue {
+			delete(bus_queues_GL[subscription.topic.name], subscriber)
+
+			break
+		}
+	}
+}
+
+/*
+removeEvent removes an event from the queue of the subscription and from its journal. Call with bus_mutex_GL locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - idx – the index of the event in the queue
+*/
+func (subscription *Subscription[T]) removeEvent(idx int) {
+	var queue *_BusQueue = subscription.queue
+	if "" != queue.journal_dir.p {
+		_ = queue.journal_dir.Add2(false, queue.events[idx].Id + ".json").Remove()
+	}
+	queue.events = append(queue.events[:idx], queue.events[idx + 1:]...)
+}
+
+/*
+readJournalEVENTS reads the events of a journal, in order of publication.
+
+-----------------------------------------------------------
+
+– Params:
+  - journal_dir – the directory of the journal
+
+– Returns:
+  - the events
+*/
+func readJournalEVENTS(journal_dir GPath) []*_BusEvent {
+	var events []*_BusEvent = nil
+	for _, file_info := range journal_dir.GetFileList() {
+		var event _BusEvent
+		if err := json.Unmarshal(file_info.GPath.ReadFile(), &event); nil != err || "" == event.Id {
+			// Probably written partially on a crash
+			GetModLoggerLOGS(NUM_MOD_VISOR).Warning("Discarding invalid journaled event", "file",
+				file_info.GPath.GPathToStringConversion(), "error", err)
+			_ = file_info.GPath.Remove()
+
+			continue
+		}
+		events = append(events, &event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Id < events[j].Id
+	})
+
+	return events
+}
+
+/*
+resetBusEVENTS removes all the queues of the event bus, so that they're created again from the journals of the
+FileSystem in use.
+*/
+func resetBusEVENTS() {
+	bus_mutex_GL.Lock()
+	defer bus_mutex_GL.Unlock()
+
+	bus_queues_GL = make(map[string]map[string]*_BusQueue)
+}
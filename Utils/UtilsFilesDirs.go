@@ -23,10 +23,12 @@ package Utils
 
 import (
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 /*
@@ -56,6 +58,73 @@ type FileInfo struct {
 	GPath      GPath
 }
 
+/*
+FileSystem is what the files are read from and written to. It's the disk everywhere except on the tests, which swap it
+for a FakeFileSystem.
+*/
+type FileSystem interface {
+	// Stat gets the information about a file or directory
+	Stat(name string) (fs.FileInfo, error)
+	// ReadFile reads the contents of a file
+	ReadFile(name string) ([]byte, error)
+	// ReadDir reads the entries of a directory, sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// WriteFile writes the contents of a file, creating it if it doesn't exist (not on append) and syncing it to the
+	// disk before returning if synced is true
+	WriteFile(name string, data []byte, append bool, synced bool) error
+	// Mkdir creates a directory (not its parents)
+	Mkdir(name string) error
+	// Chmod changes the permissions of a file or directory
+	Chmod(name string, mode fs.FileMode) error
+	// Rename renames (moves) a file or directory
+	Rename(old_name string, new_name string) error
+	// Remove removes a file or an empty directory
+	Remove(name string) error
+}
+
+// _OsFileSystem is the FileSystem of the disk.
+type _OsFileSystem struct{}
+
+// file_system_GL is the FileSystem in use. Only replace it through SetFileSystemFILESDIRS().
+var file_system_GL atomic.Pointer[FileSystem]
+
+/*
+GetFileSystemFILESDIRS gets the FileSystem in use.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the FileSystem in use
+*/
+func GetFileSystemFILESDIRS() FileSystem {
+	if p_file_system := file_system_GL.Load(); nil != p_file_system {
+		return *p_file_system
+	}
+
+	return _OsFileSystem{}
+}
+
+/*
+SetFileSystemFILESDIRS replaces the FileSystem in use. Use ONLY on tests.
+
+The queues of the event bus are removed too, so that they're created again from the journals of the new FileSystem.
+
+-----------------------------------------------------------
+
+– Params:
+  - file_system – the new FileSystem
+
+– Returns:
+  - the previous FileSystem, to be able to restore it
+*/
+func SetFileSystemFILESDIRS(file_system FileSystem) FileSystem {
+	var prev_file_system FileSystem = GetFileSystemFILESDIRS()
+	file_system_GL.Store(&file_system)
+	resetBusEVENTS()
+
+	return prev_file_system
+}
+
 /*
 PathFILESDIRS combines a path from the given subpaths of type string or GPath (ONLY) into a GPath.
 
@@ -96,9 +165,14 @@ func PathFILESDIRS(describes_dir bool, separator string, sub_paths ...any) GPath
 	}
 
 	if describes_dir {
-		// If the path describes a directory, make sure it ends with a path separator.
-		if !strings.HasSuffix(sub_paths_str[len(sub_paths_str)-1], separator) {
-			sub_paths_str[len(sub_paths_str)-1] += separator
+		// If the path describes a directory, make sure it ends with a path separator (any one, they're all converted
+		// below).
+		var dir_separator string = separator
+		if "" == dir_separator {
+			dir_separator = string(os.PathSeparator)
+		}
+		if !strings.HasSuffix(sub_paths_str[len(sub_paths_str)-1], dir_separator) {
+			sub_paths_str[len(sub_paths_str)-1] += dir_separator
 		}
 	}
 
@@ -138,8 +212,11 @@ func PathFILESDIRS(describes_dir bool, separator string, sub_paths ...any) GPath
 		}
 	} else {
 		// As last resort, check through the last character on the subpaths list (project convention).
-		if ends_in_separator && !strings.HasSuffix(gPath.p, gPath.s) {
-			gPath.p += gPath.s
+		if ends_in_separator {
+			if !strings.HasSuffix(gPath.p, gPath.s) {
+				gPath.p += gPath.s
+			}
+			gPath.dir = true
 		}
 	}
 
@@ -218,7 +295,7 @@ func (gPath GPath) ReadTextFile() *string {
 		return nil
 	}
 
-	data, err := os.ReadFile(gPath.p)
+	data, err := GetFileSystemFILESDIRS().ReadFile(gPath.p)
 	if nil != err {
 		return nil
 	}
@@ -243,7 +320,7 @@ func (gPath GPath) ReadFile() []byte {
 		return nil
 	}
 
-	data, err := os.ReadFile(gPath.p)
+	data, err := GetFileSystemFILESDIRS().ReadFile(gPath.p)
 	if nil != err {
 		return nil
 	}
@@ -295,28 +372,14 @@ func (gPath GPath) WriteFile(content []byte, append bool) error {
 		return errors.New("the path describes a directory or it couldn't be created")
 	}
 
-	if append {
-		var flags int = os.O_WRONLY | os.O_APPEND
-
-		file, err := os.OpenFile(gPath.p, flags, 0o777)
-		if nil != err {
-			return err
-		}
-		defer file.Close()
-
-		_, err = file.Write(content)
-	} else {
-		// This way is here too because the other one doesn't seem to work well when it's not to append. Sometimes it
-		// adds stuff to the file who knows why. This way here doesn't at least.
-		err := os.WriteFile(gPath.p, content, 0o777)
-		if nil != err {
-			return err
-		}
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+	if err := file_system.WriteFile(gPath.p, content, append, false); nil != err {
+		return err
 	}
 
-	// Set the permissions to 777 after writing the file to be sure the file is accessible (OpenFile only sets the
-	// permissions for the file creation).
-	_ = os.Chmod(gPath.p, 0o777)
+	// Set the permissions to 777 after writing the file to be sure the file is accessible (the file creation only sets
+	// the permissions for the file creation).
+	_ = file_system.Chmod(gPath.p, 0o777)
 
 	return nil
 }
@@ -335,7 +398,7 @@ doesn't is a file.
   - true if the path describes a directory, false if it describes a file
  */
 func (gPath GPath) DescribesDir() bool {
-	file_info, err := os.Stat(gPath.p)
+	file_info, err := GetFileSystemFILESDIRS().Stat(gPath.p)
 	if err == nil {
 		return file_info.IsDir()
 	}
@@ -356,7 +419,7 @@ func (gPath GPath) Exists() bool {
 		return false
 	}
 
-	_, err := os.Stat(gPath.p)
+	_, err := GetFileSystemFILESDIRS().Stat(gPath.p)
 	return err == nil
 }
 
@@ -376,7 +439,13 @@ func (gPath GPath) Create(create_file bool) error {
 		return err
 	}
 
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+
 	var path_list []string = strings.Split(gPath.p, gPath.s)
+	if len(path_list) > 1 && "" == path_list[len(path_list) - 1] {
+		// A directory ending in the path separator - the last element is not a subpath.
+		path_list = path_list[:len(path_list) - 1]
+	}
 	var describes_file bool = false
 	if !gPath.dir {
 		// If the path is a file, remove the file part of the file from the list so that it describes a directory only,
@@ -400,8 +469,8 @@ func (gPath GPath) Create(create_file bool) error {
 			current_path.p += sub_path + gPath.s
 
 			if !current_path.Exists() {
-				if err := os.Mkdir(current_path.p, 0o777); err == nil {
-					_ = os.Chmod(current_path.p, 0o777)
+				if err := file_system.Mkdir(current_path.p); err == nil {
+					_ = file_system.Chmod(current_path.p, 0o777)
 				} else {
 					return err
 				}
@@ -411,12 +480,10 @@ func (gPath GPath) Create(create_file bool) error {
 
 	// Create the file if the path represents a file.
 	if create_file && describes_file && !gPath.Exists() {
-		file, err := os.Create(gPath.p)
-		if nil != err {
+		if err := file_system.WriteFile(gPath.p, nil, false, false); nil != err {
 			return err
 		}
-		_ = os.Chmod(gPath.p, 0o777)
-		_ = file.Close()
+		_ = file_system.Chmod(gPath.p, 0o777)
 	}
 
 	return nil
@@ -435,7 +502,7 @@ func (gPath GPath) Remove() error {
 		return err
 	}
 
-	return os.Remove(gPath.p)
+	return GetFileSystemFILESDIRS().Remove(gPath.p)
 }
 
 /*
@@ -485,7 +552,8 @@ func (gPath GPath) GetFileList() []FileInfo {
 		return nil
 	}
 
-	files, err := os.ReadDir(gPath.GPathToStringConversion())
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+	files, err := file_system.ReadDir(gPath.GPathToStringConversion())
 	if nil != err {
 		return nil
 	}
@@ -493,7 +561,11 @@ func (gPath GPath) GetFileList() []FileInfo {
 	var files_to_send []FileInfo = make([]FileInfo, 0, len(files))
 	for _, file := range files {
 		var file_path GPath = gPath.Add2(false, file.Name())
-		file_stats, _ := os.Stat(file_path.GPathToStringConversion())
+		file_stats, err := file_system.Stat(file_path.GPathToStringConversion())
+		if nil != err {
+			// Removed meanwhile
+			continue
+		}
 		files_to_send = append(files_to_send, FileInfo{
 			Name:       file.Name(),
 			Modif_time: file_stats.ModTime().UnixNano(),
@@ -542,7 +614,7 @@ getVISORDirFILESDIRS gets the full path to the VISOR directory.
   - the full path to the VISOR directory
  */
 func getVISORDirFILESDIRS() GPath {
-	return PathFILESDIRS(true, "", User_settings_GL.PersonalConsts.VISOR_dir)
+	return PathFILESDIRS(true, "", GetUserSettingsSETTINGS().PersonalConsts.VISOR_dir)
 }
 
 /*
@@ -566,5 +638,161 @@ GetWebsiteFilesDirFILESDIRS gets the full path to the website files directory.
   - the full path to the website files directory
 */
 func GetWebsiteFilesDirFILESDIRS() GPath {
-	return PathFILESDIRS(true, "", User_settings_GL.PersonalConsts.Website_dir, _WEBSITE_FILES_REL_DIR)
+	return PathFILESDIRS(true, "", GetUserSettingsSETTINGS().PersonalConsts.Website_dir, _WEBSITE_FILES_REL_DIR)
+}
+
+/*
+Stat gets the information about a file or directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+
+– Returns:
+  - the information about the file or directory
+  - nil if the information was got, an error otherwise
+*/
+func (_OsFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+/*
+ReadFile reads the contents of a file.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file
+
+– Returns:
+  - the contents of the file
+  - nil if the file was read, an error otherwise
+*/
+func (_OsFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+/*
+ReadDir reads the entries of a directory, sorted by name.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the directory
+
+– Returns:
+  - the entries of the directory
+  - nil if the directory was read, an error otherwise
+*/
+func (_OsFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+/*
+WriteFile writes the contents of a file.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file
+  - data – the contents to write
+  - append – true to append to the end of the file (which must exist), false to replace its contents or create it
+  - synced – true to sync the file to the disk before returning
+
+– Returns:
+  - nil if the file was written, an error otherwise
+*/
+func (_OsFileSystem) WriteFile(name string, data []byte, append bool, synced bool) error {
+	if !append && !synced {
+		// This way is here because the other one doesn't seem to work well when it's not to append. Sometimes it adds
+		// stuff to the file who knows why. This way here doesn't at least.
+		return os.WriteFile(name, data, 0o777)
+	}
+
+	var flags int = os.O_WRONLY | os.O_APPEND
+	if !append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(name, flags, 0o777)
+	if nil != err {
+		return err
+	}
+
+	if _, err = file.Write(data); nil != err {
+		_ = file.Close()
+
+		return err
+	}
+	if synced {
+		if err = file.Sync(); nil != err {
+			_ = file.Close()
+
+			return err
+		}
+	}
+
+	return file.Close()
+}
+
+/*
+Mkdir creates a directory (not its parents).
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the directory
+
+– Returns:
+  - nil if the directory was created, an error otherwise
+*/
+func (_OsFileSystem) Mkdir(name string) error {
+	return os.Mkdir(name, 0o777)
+}
+
+/*
+Chmod changes the permissions of a file or directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+  - mode – the new permissions
+
+– Returns:
+  - nil if the permissions were changed, an error otherwise
+*/
+func (_OsFileSystem) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+/*
+Rename renames (moves) a file or directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - old_name – the current path
+  - new_name – the new path
+
+– Returns:
+  - nil if the file or directory was renamed, an error otherwise
+*/
+func (_OsFileSystem) Rename(old_name string, new_name string) error {
+	return os.Rename(old_name, new_name)
+}
+
+/*
+Remove removes a file or an empty directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+
+– Returns:
+  - nil if the file or directory was removed, an error otherwise
+*/
+func (_OsFileSystem) Remove(name string) error {
+	return os.Remove(name)
 }
@@ -0,0 +1,605 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LOG_LEVEL_DEBUG is the level of detailed information only useful for debugging.
+	LOG_LEVEL_DEBUG int = iota
+	// LOG_LEVEL_INFO is the level of normal information about the working of a module.
+	LOG_LEVEL_INFO
+	// LOG_LEVEL_WARNING is the level of something unexpected that the module could handle.
+	LOG_LEVEL_WARNING
+	// LOG_LEVEL_ERROR is the level of an error that prevented the module from doing something.
+	LOG_LEVEL_ERROR
+)
+
+// LOG_LEVELS_NAMES is a map of the log levels to their names.
+var LOG_LEVELS_NAMES map[int]string = map[int]string{
+	LOG_LEVEL_DEBUG:   "DEBUG",
+	LOG_LEVEL_INFO:    "INFO",
+	LOG_LEVEL_WARNING: "WARNING",
+	LOG_LEVEL_ERROR:   "ERROR",
+}
+
+// _LOGS_REL_DIR is the relative path to the logs directory from PersonalConsts._VISOR_DIR.
+const _LOGS_REL_DIR string = _DATA_REL_DIR + "/Logs"
+
+const (
+	// _LOG_CURR_FILE is the name of the log file currently being written to.
+	_LOG_CURR_FILE string = "log.jsonl"
+	// _LOG_ROTATED_PREFFIX is the preffix of the names of the rotated log files (followed by the rotation time in
+	// milliseconds and the extension).
+	_LOG_ROTATED_PREFFIX string = "log_"
+	// _LOG_FILES_EXT is the extension of the log files.
+	_LOG_FILES_EXT string = ".jsonl"
+)
+
+const (
+	// _LOG_MAX_FILE_SIZE is the size in bytes after which the current log file is rotated.
+	_LOG_MAX_FILE_SIZE int64 = 1024*1024
+	// _LOG_MAX_FILE_AGE_S is the time after which the current log file is rotated even if it's not full.
+	_LOG_MAX_FILE_AGE_S int64 = 24*60*60
+	// _LOG_RETENTION_S is the time after which rotated log files are deleted.
+	_LOG_RETENTION_S int64 = 7*24*60*60
+	// _LOG_MAX_ROTATED_FILES is the maximum number of rotated log files kept per module.
+	_LOG_MAX_ROTATED_FILES int = 10
+)
+
+// LogEntry is an entry of a module log.
+type LogEntry struct {
+	// Time is the time of the entry in milliseconds
+	Time int64
+	// Mod_num is the number of the module that logged the entry
+	Mod_num int
+	// Level is the LOG_LEVEL_-started constant of the entry
+	Level int
+	// Msg is the message of the entry
+	Msg string
+	// Fields are the additional key-value pairs of the entry
+	Fields map[string]any `json:",omitempty"`
+}
+
+// ModLogger is a leveled and structured logger of a module. It writes JSON lines to a rotating file and prints entries
+// of level LOG_LEVEL_INFO and above to the console too.
+type ModLogger struct {
+	mod_num int
+	mutex   sync.Mutex
+	// file_start is the time the current log file was started in milliseconds, or 0 if it wasn't checked yet
+	file_start int64
+}
+
+var mod_loggers_GL [MODS_ARRAY_SIZE]*ModLogger
+var mod_loggers_mutex_GL sync.Mutex
+
+// _LOGS_CHAN_SIZE is the size of the buffer of the channels returned by SubscribeLogsLOGS().
+const _LOGS_CHAN_SIZE int = 100
+
+var logs_subs_GL []chan LogEntry = nil
+var logs_subs_mutex_GL sync.Mutex
+
+// journald_enabled_GL is true if the entries are sent to journald instead of being printed to the console.
+var journald_enabled_GL atomic.Bool
+
+/*
+EnableJournaldLOGS makes the loggers send the entries to journald instead of printing them to the console (they're
+still written to the log files). Useful when running as a systemd service.
+*/
+func EnableJournaldLOGS() {
+	journald_enabled_GL.Store(true)
+}
+
+/*
+GetModLoggerLOGS gets the logger of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the logger of the module (always the same one for the same module)
+*/
+func GetModLoggerLOGS(mod_num int) *ModLogger {
+	mod_loggers_mutex_GL.Lock()
+	defer mod_loggers_mutex_GL.Unlock()
+
+	if nil == mod_loggers_GL[mod_num] {
+		mod_loggers_GL[mod_num] = &ModLogger{
+			mod_num: mod_num,
+		}
+	}
+
+	return mod_loggers_GL[mod_num]
+}
+
+/*
+Debug logs a message with the LOG_LEVEL_DEBUG level.
+
+-----------------------------------------------------------
+
+– Params:
+  - msg – the message
+  - key_values – pairs of keys (strings) and values to add as fields of the entry
+*/
+func (logger *ModLogger) Debug(msg string, key_values ...any) {
+	logger.log(LOG_LEVEL_DEBUG, msg, key_values)
+}
+
+/*
+Info logs a message with the LOG_LEVEL_INFO level.
+
+-----------------------------------------------------------
+
+– Params:
+  - msg – the message
+  - key_values – pairs of keys (strings) and values to add as fields of the entry
+*/
+func (logger *ModLogger) Info(msg string, key_values ...any) {
+	logger.log(LOG_LEVEL_INFO, msg, key_values)
+}
+
+/*
+Warning logs a message with the LOG_LEVEL_WARNING level.
+
+-----------------------------------------------------------
+
+– Params:
+  - msg – the message
+  - key_values – pairs of keys (strings) and values to add as fields of the entry
+*/
+func (logger *ModLogger) Warning(msg string, key_values ...any) {
+	logger.log(LOG_LEVEL_WARNING, msg, key_values)
+}
+
+/*
+Error logs a message with the LOG_LEVEL_ERROR level.
+
+-----------------------------------------------------------
+
+– Params:
+  - msg – the message
+  - key_values – pairs of keys (strings) and values to add as fields of the entry
+*/
+func (logger *ModLogger) Error(msg string, key_values ...any) {
+	logger.log(LOG_LEVEL_ERROR, msg, key_values)
+}
+
+/*
+log logs a message with the given level.
+
+-----------------------------------------------------------
+
+– Params:
+  - level – the LOG_LEVEL_-started constant
+  - msg – the message
+  - key_values – pairs of keys (strings) and values to add as fields of the entry
+*/
+func (logger *ModLogger) log(level int, msg string, key_values []any) {
+	var log_entry LogEntry = LogEntry{
+		Time:    time.Now().UnixMilli(),
+		Mod_num: logger.mod_num,
+		Level:   level,
+		Msg:     msg,
+	}
+	if len(key_values) > 0 {
+		log_entry.Fields = make(map[string]any, (len(key_values) + 1) / 2)
+		for i := 0; i < len(key_values); i += 2 {
+			var key string = fmt.Sprint(key_values[i])
+			if i + 1 < len(key_values) {
+				var value any = key_values[i + 1]
+				if err, ok := value.(error); ok {
+					// Errors are marshalled as empty objects otherwise
+					value = err.Error()
+				}
+				log_entry.Fields[key] = value
+			} else {
+				log_entry.Fields[key] = nil
+			}
+		}
+	}
+
+	notifyLogEntryLOGS(log_entry)
+
+	if journald_enabled_GL.Load() {
+		if nil != sendToJournaldSYSTEMD(log_entry) {
+			// Print to the console instead, which systemd also sends to the journal
+			journald_enabled_GL.Store(false)
+		}
+	}
+	if level >= LOG_LEVEL_INFO && !journald_enabled_GL.Load() {
+		var console_msg string = GetModNameMODULES(logger.mod_num) + " [" + LOG_LEVELS_NAMES[level] + "] " + msg
+		for key, value := range log_entry.Fields {
+			console_msg += " " + key + "=" + fmt.Sprint(value)
+		}
+		log.Println(console_msg)
+	}
+
+	if GetUserSettingsSETTINGS().PersonalConsts.VISOR_dir == "" {
+		// No user settings yet, so nowhere to write the logs to
+		return
+	}
+
+	entry_json, err := json.Marshal(log_entry)
+	if nil != err {
+		return
+	}
+	entry_json = append(entry_json, '\n')
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	logger.rotateIfNeeded()
+	_ = getModLogsDirLOGS(logger.mod_num).Add2(false, _LOG_CURR_FILE).WriteFile(entry_json, true)
+}
+
+/*
+rotateIfNeeded rotates the current log file if it's too big or too old and deletes the old rotated files.
+
+Call it with the logger mutex locked.
+*/
+func (logger *ModLogger) rotateIfNeeded() {
+	var logs_dir GPath = getModLogsDirLOGS(logger.mod_num)
+	var curr_file GPath = logs_dir.Add2(false, _LOG_CURR_FILE)
+
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+	file_stats, err := file_system.Stat(curr_file.GPathToStringConversion())
+	if nil != err {
+		logger.file_start = time.Now().UnixMilli()
+
+		return
+	}
+
+	if 0 == logger.file_start {
+		// The file may be from before VISOR started
+		logger.file_start = getLogFileStartLOGS(curr_file, file_stats.ModTime().UnixMilli())
+	}
+
+	var time_now int64 = time.Now().UnixMilli()
+	if file_stats.Size() < _LOG_MAX_FILE_SIZE && time_now - logger.file_start < _LOG_MAX_FILE_AGE_S*1000 {
+		return
+	}
+
+	var rotated_file GPath = logs_dir.Add2(false, _LOG_ROTATED_PREFFIX + strconv.FormatInt(time_now, 10) +
+		_LOG_FILES_EXT)
+	if nil != file_system.Rename(curr_file.GPathToStringConversion(), rotated_file.GPathToStringConversion()) {
+		return
+	}
+	logger.file_start = time_now
+
+	deleteOldLogFilesLOGS(logs_dir, time_now)
+}
+
+/*
+deleteOldFiles deletes the rotated log files of the module that are too old or too many. They're deleted on each
+rotation, but a module that logs little rarely rotates, so call this when it starts too.
+*/
+func (logger *ModLogger) deleteOldFiles() {
+	if GetUserSettingsSETTINGS().PersonalConsts.VISOR_dir == "" {
+		return
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	deleteOldLogFilesLOGS(getModLogsDirLOGS(logger.mod_num), time.Now().UnixMilli())
+}
+
+/*
+deleteOldLogFilesLOGS deletes the rotated log files older than _LOG_RETENTION_S and the oldest ones over
+_LOG_MAX_ROTATED_FILES.
+
+-----------------------------------------------------------
+
+– Params:
+  - logs_dir – the logs directory of a module
+  - time_now – the current time in milliseconds
+*/
+func deleteOldLogFilesLOGS(logs_dir GPath, time_now int64) {
+	var rotated_files []FileInfo = getRotatedLogFiles(logs_dir)
+	for i, file_info := range rotated_files {
+		if i < len(rotated_files) - _LOG_MAX_ROTATED_FILES ||
+				time_now - file_info.Modif_time/1e6 > _LOG_RETENTION_S*1000 {
+			_ = file_info.GPath.Remove()
+		}
+	}
+}
+
+/*
+getLogFileStartLOGS gets the time a log file was started: the time of its first entry.
+
+-----------------------------------------------------------
+
+– Params:
+  - file – the log file
+  - modif_time – the modification time of the file in milliseconds, used if the first entry can't be read
+
+– Returns:
+  - the time in milliseconds
+*/
+func getLogFileStartLOGS(file GPath, modif_time int64) int64 {
+	var file_bytes []byte = file.ReadFile()
+	if nil == file_bytes {
+		return modif_time
+	}
+	if i := bytes.IndexByte(file_bytes, '\n'); -1 != i {
+		file_bytes = file_bytes[:i]
+	}
+
+	var log_entry LogEntry
+	if nil != json.Unmarshal(file_bytes, &log_entry) || 0 == log_entry.Time {
+		return modif_time
+	}
+
+	return log_entry.Time
+}
+
+/*
+String formats the log entry in one line, like "<date and time> - <module> [<level>] <message> <key>=<value>...".
+
+-----------------------------------------------------------
+
+– Returns:
+  - the formatted entry
+*/
+func (log_entry LogEntry) String() string {
+	var text string = GetDateTimeStrTIMEDATE(log_entry.Time) + " - " + GetModNameMODULES(log_entry.Mod_num) + " [" +
+		LOG_LEVELS_NAMES[log_entry.Level] + "] " + log_entry.Msg
+	var keys []string = nil
+	for key := range log_entry.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		text += " " + key + "=" + fmt.Sprint(log_entry.Fields[key])
+	}
+
+	return text
+}
+
+/*
+SubscribeLogsLOGS subscribes to the entries logged by all the modules from now on.
+
+The channel is buffered and an entry is dropped for a subscriber whose buffer is full, so don't take too long to
+receive them.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the channel on which the entries will be sent
+*/
+func SubscribeLogsLOGS() chan LogEntry {
+	logs_subs_mutex_GL.Lock()
+	defer logs_subs_mutex_GL.Unlock()
+
+	var channel chan LogEntry = make(chan LogEntry, _LOGS_CHAN_SIZE)
+	logs_subs_GL = append(logs_subs_GL, channel)
+
+	return channel
+}
+
+/*
+UnsubscribeLogsLOGS cancels a subscription made with SubscribeLogsLOGS().
+
+-----------------------------------------------------------
+
+– Params:
+  - channel – the channel returned by SubscribeLogsLOGS()
+*/
+func UnsubscribeLogsLOGS(channel chan LogEntry) {
+	logs_subs_mutex_GL.Lock()
+	defer logs_subs_mutex_GL.Unlock()
+
+	for i, sub := range logs_subs_GL {
+		if sub == channel {
+			logs_subs_GL = append(logs_subs_GL[:i], logs_subs_GL[i+1:]...)
+
+			break
+		}
+	}
+}
+
+/*
+notifyLogEntryLOGS sends a log entry to all the subscribers.
+
+-----------------------------------------------------------
+
+– Params:
+  - log_entry – the log entry
+*/
+func notifyLogEntryLOGS(log_entry LogEntry) {
+	logs_subs_mutex_GL.Lock()
+	defer logs_subs_mutex_GL.Unlock()
+
+	for _, channel := range logs_subs_GL {
+		select {
+			case channel <- log_entry:
+			default:
+				// Buffer full - drop it instead of blocking the module
+		}
+	}
+}
+
+/*
+SyncLogsLOGS writes to disk the log files of all modules, so that no entry is lost if the machine goes down right after
+(like when shutting down).
+*/
+func SyncLogsLOGS() {
+	if GetUserSettingsSETTINGS().PersonalConsts.VISOR_dir == "" {
+		return
+	}
+
+	for mod_num := 0; mod_num < MODS_ARRAY_SIZE; mod_num++ {
+		var logger *ModLogger = GetModLoggerLOGS(mod_num)
+		logger.mutex.Lock()
+		file, err := os.OpenFile(getModLogsDirLOGS(mod_num).Add2(false, _LOG_CURR_FILE).GPathToStringConversion(),
+			os.O_WRONLY, 0o777)
+		if nil == err {
+			_ = file.Sync()
+			_ = file.Close()
+		}
+		logger.mutex.Unlock()
+	}
+}
+
+/*
+QueryLogsLOGS reads back the most recent log entries of one or all modules.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module or -1 for all modules
+  - min_level – the minimum LOG_LEVEL_-started constant of the entries to get
+  - max_entries – the maximum number of entries to get
+
+– Returns:
+  - the most recent entries that match the filters, from the oldest to the newest
+*/
+func QueryLogsLOGS(mod_num int, min_level int, max_entries int) []LogEntry {
+	var mods_nums []int = nil
+	if -1 == mod_num {
+		for i := 0; i < MODS_ARRAY_SIZE; i++ {
+			mods_nums = append(mods_nums, i)
+		}
+	} else if mod_num >= 0 && mod_num < MODS_ARRAY_SIZE {
+		mods_nums = append(mods_nums, mod_num)
+	}
+
+	var log_entries []LogEntry = nil
+	for _, num := range mods_nums {
+		var logs_dir GPath = getModLogsDirLOGS(num)
+
+		// Read from the newest file to the oldest one and stop when there are enough entries of the module
+		var files []GPath = []GPath{logs_dir.Add2(false, _LOG_CURR_FILE)}
+		var rotated_files []FileInfo = getRotatedLogFiles(logs_dir)
+		for i := len(rotated_files) - 1; i >= 0; i-- {
+			files = append(files, rotated_files[i].GPath)
+		}
+
+		var mod_entries []LogEntry = nil
+		for _, file := range files {
+			var file_entries []LogEntry = readLogFile(file, min_level)
+			mod_entries = append(file_entries, mod_entries...)
+			if len(mod_entries) >= max_entries {
+				break
+			}
+		}
+		log_entries = append(log_entries, mod_entries...)
+	}
+
+	sort.SliceStable(log_entries, func(i, j int) bool {
+		return log_entries[i].Time < log_entries[j].Time
+	})
+	if len(log_entries) > max_entries {
+		log_entries = log_entries[len(log_entries) - max_entries:]
+	}
+
+	return log_entries
+}
+
+/*
+readLogFile reads the entries of a log file.
+
+-----------------------------------------------------------
+
+– Params:
+  - file – the log file
+  - min_level – the minimum LOG_LEVEL_-started constant of the entries to get
+
+– Returns:
+  - the entries of the file with at least the given level, ignoring lines that can't be parsed
+*/
+func readLogFile(file GPath, min_level int) []LogEntry {
+	var file_bytes []byte = file.ReadFile()
+	if nil == file_bytes {
+		return nil
+	}
+
+	var log_entries []LogEntry = nil
+	var scanner *bufio.Scanner = bufio.NewScanner(bytes.NewReader(file_bytes))
+	scanner.Buffer(nil, int(_LOG_MAX_FILE_SIZE))
+	for scanner.Scan() {
+		var log_entry LogEntry
+		// A line being written at the same time may be incomplete - just skip it
+		if nil == json.Unmarshal(scanner.Bytes(), &log_entry) && log_entry.Level >= min_level {
+			log_entries = append(log_entries, log_entry)
+		}
+	}
+
+	return log_entries
+}
+
+/*
+getRotatedLogFiles gets the rotated log files of a logs directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - logs_dir – the logs directory of a module
+
+– Returns:
+  - the rotated log files, from the oldest to the newest
+*/
+func getRotatedLogFiles(logs_dir GPath) []FileInfo {
+	var rotated_files []FileInfo = nil
+	for _, file_info := range logs_dir.GetFileList() {
+		if strings.HasPrefix(file_info.Name, _LOG_ROTATED_PREFFIX) && strings.HasSuffix(file_info.Name, _LOG_FILES_EXT) {
+			rotated_files = append(rotated_files, file_info)
+		}
+	}
+
+	// The names have the rotation time, so sorting them sorts the files by time (all times have the same length)
+	sort.Slice(rotated_files, func(i, j int) bool {
+		return rotated_files[i].Name < rotated_files[j].Name
+	})
+
+	return rotated_files
+}
+
+/*
+getModLogsDirLOGS gets the full path to the logs directory of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the full path to the logs directory of the module
+*/
+func getModLogsDirLOGS(mod_num int) GPath {
+	return getVISORDirFILESDIRS().Add2(true, _LOGS_REL_DIR, _MOD_FOLDER_PREFFIX + strconv.Itoa(mod_num))
+}
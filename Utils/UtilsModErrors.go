@@ -0,0 +1,246 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// _ERR_EMAIL_SUPPRESS_S is the time after an error email is sent during which repeats of the same error are not emailed
+// on their own but only counted for the summary email.
+const _ERR_EMAIL_SUPPRESS_S int64 = 60*60
+// _ERR_EMAIL_SUMMARY_S is the time between summary emails of the repeated errors.
+const _ERR_EMAIL_SUMMARY_S int64 = 60*60
+// _ERR_FINGERPRINT_EXPIRY_S is the time after the last occurrence of an error after which it's forgotten.
+const _ERR_FINGERPRINT_EXPIRY_S int64 = 24*60*60
+
+// _ErrFingerprintInfo is the information about the occurrences of an error with a given fingerprint.
+type _ErrFingerprintInfo struct {
+	// mod_num is the number of the module in which the error occurred
+	mod_num int
+	// err_str is the error message of the first occurrence
+	err_str string
+	// first_seen is the time of the first occurrence in milliseconds
+	first_seen int64
+	// last_seen is the time of the last occurrence in milliseconds
+	last_seen int64
+	// last_sent is the time the last email about the error was sent in milliseconds
+	last_sent int64
+	// suppressed is the number of occurrences not emailed yet
+	suppressed int
+}
+
+var err_fingerprints_GL map[string]*_ErrFingerprintInfo = make(map[string]*_ErrFingerprintInfo)
+var err_fingerprints_mutex_GL sync.Mutex
+var err_summary_once_GL sync.Once
+
+var (
+	// err_hex_regex_GL matches memory addresses and other hexadecimal values
+	err_hex_regex_GL *regexp.Regexp = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	// err_num_regex_GL matches line numbers and other numbers of the stack trace
+	err_num_regex_GL *regexp.Regexp = regexp.MustCompile(`[0-9]+`)
+	// err_frame_regex_GL matches a stack frame line ("path/file.go:123 package.Function()") of the stack trace
+	err_frame_regex_GL *regexp.Regexp = regexp.MustCompile(`(?m)^.+\.go:[0-9]+ .+\)$`)
+)
+
+/*
+SendModErrorEmailMODULES sends an email to the developer with the error message, unless the same error was already
+emailed recently.
+
+The first occurrence of an error is emailed right away. Repeats of it (same module and same normalized error message and
+stack) inside _ERR_EMAIL_SUPPRESS_S are only counted and included in a summary email sent each _ERR_EMAIL_SUMMARY_S.
+
+This function does *not* use any modules to do anything. Only utility functions. So it can be used from any
+module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module from which the error occurred
+  - error – the error message
+
+– Returns:
+  - nil if the email was sent or suppressed successfully, otherwise an error
+*/
+func SendModErrorEmailMODULES(mod_num int, err_str string) error {
+	var fingerprint string = getErrFingerprintMODULES(mod_num, err_str)
+	var time_now int64 = time.Now().UnixMilli()
+
+	err_fingerprints_mutex_GL.Lock()
+	var fingerprint_info *_ErrFingerprintInfo = err_fingerprints_GL[fingerprint]
+	if nil == fingerprint_info {
+		fingerprint_info = &_ErrFingerprintInfo{
+			mod_num:    mod_num,
+			err_str:    err_str,
+			first_seen: time_now,
+		}
+		err_fingerprints_GL[fingerprint] = fingerprint_info
+	}
+	fingerprint_info.last_seen = time_now
+
+	if time_now - fingerprint_info.last_sent < _ERR_EMAIL_SUPPRESS_S*1000 {
+		fingerprint_info.suppressed++
+		err_fingerprints_mutex_GL.Unlock()
+
+		err_summary_once_GL.Do(func() {
+			go sendErrSummariesMODULES()
+		})
+
+		return nil
+	}
+	fingerprint_info.last_sent = time_now
+	err_fingerprints_mutex_GL.Unlock()
+
+	return sendErrEmailMODULES(mod_num, "Error in module: " + GetModNameMODULES(mod_num),
+		"Error fingerprint: " + fingerprint + "\n\n" + err_str)
+}
+
+/*
+FlushModErrorsSummaryMODULES sends right away the summary email of the repeated errors not emailed yet, if there are any.
+*/
+func FlushModErrorsSummaryMODULES() {
+	var time_now int64 = time.Now().UnixMilli()
+
+	err_fingerprints_mutex_GL.Lock()
+	var fingerprints []string = nil
+	for fingerprint, fingerprint_info := range err_fingerprints_GL {
+		if fingerprint_info.suppressed > 0 {
+			fingerprints = append(fingerprints, fingerprint)
+		} else if time_now - fingerprint_info.last_seen > _ERR_FINGERPRINT_EXPIRY_S*1000 {
+			delete(err_fingerprints_GL, fingerprint)
+		}
+	}
+	if len(fingerprints) == 0 {
+		err_fingerprints_mutex_GL.Unlock()
+
+		return
+	}
+	sort.Strings(fingerprints)
+
+	var summary string = "The following errors repeated since they were last emailed:\n\n"
+	var mod_num int = -1
+	for _, fingerprint := range fingerprints {
+		var fingerprint_info *_ErrFingerprintInfo = err_fingerprints_GL[fingerprint]
+		summary += "- " + GetModNameMODULES(fingerprint_info.mod_num) + " (fingerprint " + fingerprint + ")\n" +
+			"  Repeats: " + strconv.Itoa(fingerprint_info.suppressed) + "\n" +
+			"  First seen: " + GetDateTimeStrTIMEDATE(fingerprint_info.first_seen) + "\n" +
+			"  Last seen: " + GetDateTimeStrTIMEDATE(fingerprint_info.last_seen) + "\n" +
+			"  Error: " + fingerprint_info.err_str + "\n\n"
+
+		if NUM_MOD_EmailSender == fingerprint_info.mod_num {
+			// If the Email Sender is failing, the summary must not depend on it
+			mod_num = NUM_MOD_EmailSender
+		}
+
+		fingerprint_info.suppressed = 0
+		fingerprint_info.last_sent = time_now
+	}
+	err_fingerprints_mutex_GL.Unlock()
+
+	if err := sendErrEmailMODULES(mod_num, "Summary of repeated module errors", summary); nil != err {
+		log.Println("Error sending the summary of repeated module errors:\n" + GetFullErrorMsgGENERAL(err))
+	}
+}
+
+/*
+sendErrSummariesMODULES sends the summary email of the repeated errors each _ERR_EMAIL_SUMMARY_S. Never returns.
+*/
+func sendErrSummariesMODULES() {
+	for {
+		time.Sleep(time.Duration(_ERR_EMAIL_SUMMARY_S) * time.Second)
+
+		FlushModErrorsSummaryMODULES()
+	}
+}
+
+/*
+sendErrEmailMODULES sends an error email to the developer - directly if it's about the Email Sender module, else through
+its queue.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module the email is about, or -1 if none in particular
+  - subject – the subject of the email
+  - body – the body of the email
+
+– Returns:
+  - nil if the email was sent or queued successfully, otherwise an error
+*/
+func sendErrEmailMODULES(mod_num int, subject string, body string) error {
+	var things_replace map[string]string = map[string]string{
+		MODEL_INFO_MSG_BODY_EMAIL : body,
+		MODEL_INFO_DATE_TIME_EMAIL: GetDateTimeStrTIMEDATE(-1),
+	}
+	var email_info = GetModelFileEMAIL(MODEL_FILE_INFO, things_replace)
+	email_info.Subject = subject
+
+	if mod_num == NUM_MOD_EmailSender {
+		// Send the email directly
+		message_eml, mail_to, success := prepareEmlEMAIL(email_info)
+		if !success {
+			return errors.New("error preparing email")
+		}
+
+		return SendEmailEMAIL(message_eml, mail_to, true)
+	} else {
+		// Queue the email
+		return QueueEmailEMAIL(email_info)
+	}
+}
+
+/*
+getErrFingerprintMODULES gets the fingerprint of an error, which is the same for errors of the same module with the same
+message and stack except for hexadecimal values (addresses) and for the numbers of the stack trace (line numbers).
+
+Numbers in the message itself are kept, since they usually tell different errors apart.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module from which the error occurred
+  - err_str – the error message, as from GetFullErrorMsgGENERAL()
+
+– Returns:
+  - the fingerprint of the error
+*/
+func getErrFingerprintMODULES(mod_num int, err_str string) string {
+	var normalized string = err_hex_regex_GL.ReplaceAllString(err_str, "0x?")
+
+	// Only the stack trace (which starts on the first frame line) has its numbers normalized
+	var stack_idx []int = err_frame_regex_GL.FindStringIndex(normalized)
+	if nil != stack_idx {
+		normalized = normalized[:stack_idx[0]] + err_num_regex_GL.ReplaceAllString(normalized[stack_idx[0]:], "?")
+	}
+
+	var hash [20]byte = sha1.Sum([]byte(strconv.Itoa(mod_num) + "\n" + normalized))
+
+	return hex.EncodeToString(hash[:])[:12]
+}
@@ -0,0 +1,392 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Utils
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// _GEN_INFO_BACKUPS is the number of previous versions of the generated information file kept as backups.
+const _GEN_INFO_BACKUPS int = 3
+// _GEN_INFO_BAK_EXT is the extension of the backups of the generated information file (followed by the backup number,
+// 1 being the newest).
+const _GEN_INFO_BAK_EXT string = ".bak"
+
+// GenInfoMigration is a function that upgrades the generated information of a module from a schema version to the next
+// one. It gets the information as decoded from JSON and returns the upgraded information.
+type GenInfoMigration func(gen_info map[string]any) (map[string]any, error)
+
+// _GenInfoFile is the format of the generated information files.
+type _GenInfoFile struct {
+	// Schema_version is the version of the format of ModGenInfo
+	Schema_version int
+	// ModGenInfo is the generated information of the module
+	ModGenInfo json.RawMessage
+}
+
+var gen_info_migrations_GL [MODS_ARRAY_SIZE][]GenInfoMigration
+var gen_info_migrations_mutex_GL sync.Mutex
+
+/*
+RegisterGenInfoMigrationMODULES registers a migration of the generated information of a module. Call it on the init()
+of the module, in order, from version 0 (the files from before the versioning) upwards.
+
+The current schema version of a module is the number of migrations registered for it. Files of older versions are
+upgraded when loaded.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - from_version – the version the migration upgrades from (to from_version + 1)
+  - migration – the migration function
+*/
+func RegisterGenInfoMigrationMODULES(mod_num int, from_version int, migration GenInfoMigration) {
+	gen_info_migrations_mutex_GL.Lock()
+	defer gen_info_migrations_mutex_GL.Unlock()
+
+	if from_version != len(gen_info_migrations_GL[mod_num]) {
+		panic(errors.New("generated information migrations of module " + strconv.Itoa(mod_num) +
+			" registered out of order (expected from version " + strconv.Itoa(len(gen_info_migrations_GL[mod_num])) +
+			", got " + strconv.Itoa(from_version) + ")"))
+	}
+
+	gen_info_migrations_GL[mod_num] = append(gen_info_migrations_GL[mod_num], migration)
+}
+
+/*
+getGenInfoVersionMODULES gets the current schema version of the generated information of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the current schema version
+*/
+func getGenInfoVersionMODULES(mod_num int) int {
+	gen_info_migrations_mutex_GL.Lock()
+	defer gen_info_migrations_mutex_GL.Unlock()
+
+	return len(gen_info_migrations_GL[mod_num])
+}
+
+/*
+UpdateGenInfo updates the information about the module in its generated information file.
+
+The file is written to a temporary file first, synced to disk and only then renamed over the current one, whose previous
+versions are kept as backups.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the update was successful, an error otherwise
+*/
+func (moduleInfo *ModuleInfo[T]) UpdateGenInfo() error {
+	json_data, err := json.MarshalIndent(struct {
+		Schema_version int
+		ModGenInfo     *T
+	}{
+		Schema_version: getGenInfoVersionMODULES(moduleInfo.Num),
+		ModGenInfo:     &moduleInfo.ModGenInfo,
+	}, "", "\t")
+	if nil != err {
+		return err
+	}
+
+	moduleInfo.module.gen_info_mutex.Lock()
+	defer moduleInfo.module.gen_info_mutex.Unlock()
+
+	var user_data_dir GPath = GetUserDataDirMODULES(moduleInfo.Num)
+	var file_path_curr string = user_data_dir.Add2(false, _MOD_GEN_INFO_JSON).GPathToStringConversion()
+	var file_path_new string = user_data_dir.Add2(false, _MOD_GEN_INFO_JSON_TMP).GPathToStringConversion()
+
+	if err = user_data_dir.Create(false); nil != err {
+		return err
+	}
+	if err = writeFileSyncedMODULES(file_path_new, json_data); nil != err {
+		return err
+	}
+
+	// Keep the current version as the newest backup
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+	if _, err = file_system.Stat(file_path_curr); nil == err {
+		for i := _GEN_INFO_BACKUPS - 1; i >= 1; i-- {
+			_ = file_system.Rename(file_path_curr + _GEN_INFO_BAK_EXT + strconv.Itoa(i),
+				file_path_curr + _GEN_INFO_BAK_EXT + strconv.Itoa(i + 1))
+		}
+		if err = file_system.Rename(file_path_curr, file_path_curr + _GEN_INFO_BAK_EXT + "1"); nil != err {
+			return err
+		}
+	}
+
+	if err = file_system.Rename(file_path_new, file_path_curr); nil != err {
+		return err
+	}
+
+	syncDirMODULES(user_data_dir.GPathToStringConversion())
+
+	return nil
+}
+
+/*
+LoadGenInfo loads the information about the module from its generated information file into ModGenInfo, upgrading it
+to the current schema version if needed. It's loaded when the module starts, so this is only needed to read it again.
+
+If the file is corrupted, the newest good backup is used instead. If none of the files can be used, the unusable current
+file is kept aside (so that the information can be recovered manually) and the module starts with no information.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the information was loaded or there was none yet, an error otherwise
+*/
+func (moduleInfo *ModuleInfo[T]) LoadGenInfo() error {
+	moduleInfo.module.gen_info_mutex.Lock()
+	defer moduleInfo.module.gen_info_mutex.Unlock()
+
+	var user_data_dir GPath = moduleInfo.ModDirsInfo.UserData
+	// The temporary file first - if it exists, the module stopped before renaming it, so it's the newest one (and it's
+	// only used if it's complete)
+	var files_names []string = []string{_MOD_GEN_INFO_JSON_TMP, _MOD_GEN_INFO_JSON}
+	for i := 1; i <= _GEN_INFO_BACKUPS; i++ {
+		files_names = append(files_names, _MOD_GEN_INFO_JSON + _GEN_INFO_BAK_EXT + strconv.Itoa(i))
+	}
+
+	var first_err error = nil
+	var any_file bool = false
+	for _, file_name := range files_names {
+		var p_info []byte = user_data_dir.Add2(false, file_name).ReadFile()
+		if nil == p_info {
+			continue
+		}
+		any_file = true
+
+		var mod_gen_info T
+		if err := decodeGenInfoMODULES(moduleInfo.Num, p_info, &mod_gen_info); nil != err {
+			if nil == first_err && _MOD_GEN_INFO_JSON_TMP != file_name {
+				first_err = errors.New(file_name + ": " + err.Error())
+			}
+
+			continue
+		}
+
+		moduleInfo.ModGenInfo = mod_gen_info
+		if nil != first_err {
+			moduleInfo.Log.Warning("Generated information file unusable - restored from a backup", "error",
+				first_err, "backup", file_name)
+
+			// Keep the unusable file aside or it would become the newest backup on the next update
+			moveGenInfoAsideMODULES(user_data_dir)
+		}
+
+		return nil
+	}
+
+	if !any_file {
+		// New module, no information yet
+		return nil
+	}
+
+	// Keep the unusable file for manual recovery, or it would be replaced with empty information on the next update
+	moveGenInfoAsideMODULES(user_data_dir)
+
+	if nil == first_err {
+		first_err = errors.New("no usable generated information file")
+	}
+
+	return first_err
+}
+
+/*
+decodeGenInfoMODULES decodes the contents of a generated information file, upgrading them to the current schema
+version of the module if needed.
+
+Files from before the versioning have no Schema_version and are of version 0.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+  - file_data – the contents of the file
+  - mod_gen_info – a pointer to where to decode the information to
+
+– Returns:
+  - nil if the information was decoded, an error otherwise
+*/
+func decodeGenInfoMODULES(mod_num int, file_data []byte, mod_gen_info any) error {
+	var file_fields map[string]json.RawMessage
+	if err := json.Unmarshal(file_data, &file_fields); nil != err {
+		return err
+	}
+
+	var genInfoFile _GenInfoFile
+	if _, ok := file_fields["Schema_version"]; ok {
+		if err := json.Unmarshal(file_data, &genInfoFile); nil != err {
+			return err
+		}
+	} else {
+		genInfoFile.ModGenInfo = file_data
+	}
+
+	var curr_version int = getGenInfoVersionMODULES(mod_num)
+	if genInfoFile.Schema_version > curr_version {
+		return errors.New("the file is of schema version " + strconv.Itoa(genInfoFile.Schema_version) +
+			", newer than the module's (" + strconv.Itoa(curr_version) + ")")
+	}
+
+	if genInfoFile.Schema_version < curr_version {
+		var gen_info map[string]any
+		if err := json.Unmarshal(genInfoFile.ModGenInfo, &gen_info); nil != err {
+			return err
+		}
+
+		gen_info_migrations_mutex_GL.Lock()
+		var migrations []GenInfoMigration = gen_info_migrations_GL[mod_num]
+		gen_info_migrations_mutex_GL.Unlock()
+
+		for version := genInfoFile.Schema_version; version < curr_version; version++ {
+			var err error
+			if gen_info, err = migrations[version](gen_info); nil != err {
+				return errors.New("error migrating from schema version " + strconv.Itoa(version) + ": " + err.Error())
+			}
+		}
+
+		migrated, err := json.Marshal(gen_info)
+		if nil != err {
+			return err
+		}
+		genInfoFile.ModGenInfo = migrated
+	}
+
+	if len(genInfoFile.ModGenInfo) == 0 || "null" == string(genInfoFile.ModGenInfo) {
+		return nil
+	}
+
+	return json.Unmarshal(genInfoFile.ModGenInfo, mod_gen_info)
+}
+
+/*
+moveGenInfoAsideMODULES renames the current generated information file of a module to keep it for manual recovery.
+
+-----------------------------------------------------------
+
+– Params:
+  - user_data_dir – the user data directory of the module
+*/
+func moveGenInfoAsideMODULES(user_data_dir GPath) {
+	var file_path_curr string = user_data_dir.Add2(false, _MOD_GEN_INFO_JSON).GPathToStringConversion()
+	_ = GetFileSystemFILESDIRS().Rename(file_path_curr, file_path_curr + ".bad_" +
+		strconv.FormatInt(GetClockTIMEDATE().Now().UnixMilli(), 10))
+}
+
+/*
+writeFileSyncedMODULES writes a file and syncs it to disk before returning.
+
+-----------------------------------------------------------
+
+– Params:
+  - file_path – the path of the file
+  - content – the contents to write
+
+– Returns:
+  - nil if the file was written and synced, an error otherwise
+*/
+func writeFileSyncedMODULES(file_path string, content []byte) error {
+	return GetFileSystemFILESDIRS().WriteFile(file_path, content, false, true)
+}
+
+/*
+ReplaceFileSyncedMODULES replaces the contents of a file without ever leaving it half-written: the contents are written
+to a temporary file next to it with the given permissions, synced to disk and only then renamed over the file.
+
+The permissions are set on the temporary file before anything is written to it and again on the final file, so they
+also apply if the file already existed with other ones.
+
+-----------------------------------------------------------
+
+– Params:
+  - file_path – the path of the file (its directory must exist)
+  - content – the contents to write
+  - mode – the permissions of the file
+
+– Returns:
+  - nil if the file was replaced, an error otherwise
+*/
+func ReplaceFileSyncedMODULES(file_path string, content []byte, mode fs.FileMode) error {
+	var file_system FileSystem = GetFileSystemFILESDIRS()
+	var file_path_tmp string = file_path + ".tmp"
+
+	// Start from an empty temporary file with the right permissions, so the contents are never readable by others
+	_ = file_system.Remove(file_path_tmp)
+	if err := file_system.WriteFile(file_path_tmp, nil, false, false); nil != err {
+		return err
+	}
+	if err := file_system.Chmod(file_path_tmp, mode); nil != err {
+		_ = file_system.Remove(file_path_tmp)
+
+		return err
+	}
+	if err := writeFileSyncedMODULES(file_path_tmp, content); nil != err {
+		_ = file_system.Remove(file_path_tmp)
+
+		return err
+	}
+
+	if err := file_system.Rename(file_path_tmp, file_path); nil != err {
+		_ = file_system.Remove(file_path_tmp)
+
+		return err
+	}
+	if err := file_system.Chmod(file_path, mode); nil != err {
+		return err
+	}
+
+	syncDirMODULES(filepath.Dir(file_path))
+
+	return nil
+}
+
+/*
+syncDirMODULES syncs a directory to disk, so that renames inside it are persisted. Not supported on Windows, where it
+does nothing.
+
+-----------------------------------------------------------
+
+– Params:
+  - dir_path – the path of the directory
+*/
+func syncDirMODULES(dir_path string) {
+	dir, err := os.Open(dir_path)
+	if nil != err {
+		return
+	}
+	_ = dir.Sync()
+	_ = dir.Close()
+}
@@ -0,0 +1,277 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Exit codes of VISOR
+const (
+	// EXIT_CODE_OK means VISOR exited normally
+	EXIT_CODE_OK int = 0
+	// EXIT_CODE_SHUTDOWN_TIMEOUT means some modules didn't stop in time on the shutdown and were abandoned
+	EXIT_CODE_SHUTDOWN_TIMEOUT int = 3
+	// EXIT_CODE_SIGNAL_BASE plus the signal number is the exit code when VISOR is forced to exit by a second stop
+	// signal during the shutdown (the usual shell convention)
+	EXIT_CODE_SIGNAL_BASE int = 128
+)
+
+// _MOD_STOP_TIMEOUT_S is the default maximum time a module has to stop on the shutdown before being abandoned.
+const _MOD_STOP_TIMEOUT_S int = 10
+
+// _MOD_STOP_TIMEOUTS_S are the maximum times to stop of the modules that need more than _MOD_STOP_TIMEOUT_S.
+var _MOD_STOP_TIMEOUTS_S map[int]int = map[int]int{
+	NUM_MOD_SMARTChecker:    30,
+	NUM_MOD_OnlineInfoChk:   30,
+	NUM_MOD_GPTCommunicator: 15,
+}
+
+// _MOD_CHILD_PROCESSES are the names of the child processes of each module, killed on the shutdown if they're still
+// running (only the ones started by VISOR).
+var _MOD_CHILD_PROCESSES map[int][]string = map[int][]string{
+	NUM_MOD_SMARTChecker:    {"smartctl"},
+	NUM_MOD_OnlineInfoChk:   {"chromedriver"},
+	NUM_MOD_GPTCommunicator: {"llama-cli"},
+}
+
+var shutdown_hooks_GL []func() = nil
+var shutdown_hooks_mutex_GL sync.Mutex
+
+var shutdown_once_GL sync.Once
+var shutdown_exit_code_GL int = EXIT_CODE_OK
+
+/*
+AddShutdownHookMODULES adds a function to be called on the shutdown after all the modules stopped, like to write some
+state to disk. The hooks are called in the order they were added.
+
+-----------------------------------------------------------
+
+– Params:
+  - hook – the function
+*/
+func AddShutdownHookMODULES(hook func()) {
+	shutdown_hooks_mutex_GL.Lock()
+	defer shutdown_hooks_mutex_GL.Unlock()
+
+	shutdown_hooks_GL = append(shutdown_hooks_GL, hook)
+}
+
+/*
+HandleStopSignalsMODULES calls a function when VISOR receives SIGINT (Ctrl+C) or SIGTERM, which should start the
+shutdown. If a second signal comes while shutting down, VISOR exits right away with EXIT_CODE_SIGNAL_BASE plus the
+signal number.
+
+-----------------------------------------------------------
+
+– Params:
+  - onStop – the function to call on the first signal (called on a different goroutine)
+*/
+func HandleStopSignalsMODULES(onStop func()) {
+	var signals_ch chan os.Signal = make(chan os.Signal, 1)
+	signal.Notify(signals_ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		var sig os.Signal = <-signals_ch
+		log.Println("Received " + sig.String() + " - shutting down (send it again to force the exit)...")
+
+		go onStop()
+
+		sig = <-signals_ch
+		log.Println("Received " + sig.String() + " again - forcing the exit")
+
+		var exit_code int = EXIT_CODE_SIGNAL_BASE
+		if sys_sig, ok := sig.(syscall.Signal); ok {
+			exit_code += int(sys_sig)
+		}
+		os.Exit(exit_code)
+	}()
+}
+
+/*
+ShutdownMODULES stops all the modules and prepares VISOR to exit.
+
+The Manager is stopped first, which stops the other modules in order (the ones depending on others first). Each module
+has a maximum time to stop, after which it's abandoned. Only after that are all the modules' contexts cancelled at
+once. Then the child processes and plugins still running are killed and the shutdown hooks, the pending error
+summaries and the logs are flushed.
+
+Call it directly on a stop signal (not through VISOR's state), so that the modules are stopped in order.
+
+Only the first call does the shutdown. The others wait for it to finish and return the same.
+
+-----------------------------------------------------------
+
+– Params:
+  - modules – the list of modules
+
+– Returns:
+  - the exit code VISOR should exit with (one of the EXIT_CODE_ constants)
+*/
+func ShutdownMODULES(modules []Module) int {
+	shutdown_once_GL.Do(func() {
+		shutdown_exit_code_GL = shutdownMODULES(modules)
+	})
+
+	return shutdown_exit_code_GL
+}
+
+/*
+shutdownMODULES does the work of ShutdownMODULES().
+
+-----------------------------------------------------------
+
+– Params:
+  - modules – the list of modules
+
+– Returns:
+  - the exit code
+*/
+func shutdownMODULES(modules []Module) int {
+	var exit_code int = EXIT_CODE_OK
+
+	var mod_states_ch chan ModStateChange = SubscribeModStatesMODULES()
+	defer UnsubscribeModStatesMODULES(mod_states_ch)
+
+	var was_running [MODS_ARRAY_SIZE]bool
+	for i := 1; i < MODS_ARRAY_SIZE; i++ {
+		was_running[i] = modules[i].IsRunning()
+	}
+
+	// Stop the Manager first. It stops the modules it handles in order (the ones depending on others first) before it
+	// stops itself.
+	modules[NUM_MOD_ModManager].TransitionState(MOD_STATE_STOPPING)
+	for {
+		var manager_running bool = modules[NUM_MOD_ModManager].IsRunning()
+		var all_stopped bool = true
+		// VISOR doesn't count - of course it's running, else we wouldn't be here.
+		for i := 1; i < MODS_ARRAY_SIZE; i++ {
+			var module *Module = &modules[i]
+			if !module.IsRunning() {
+				continue
+			}
+			all_stopped = false
+
+			if module.GetState() != MOD_STATE_STOPPING && module.GetState() != MOD_STATE_HUNG {
+				if !manager_running {
+					// Without the Manager, stop the remaining modules directly
+					module.TransitionState(MOD_STATE_STOPPING)
+				}
+
+				continue
+			}
+
+			if time.Now().UnixMilli() - module.GetStateTime() > int64(getModStopTimeoutMODULES(i))*1000 {
+				GetModLoggerLOGS(NUM_MOD_VISOR).Error("Module didn't stop in time on the shutdown - abandoning it",
+					"module", module.Name, "timeout_s", getModStopTimeoutMODULES(i))
+
+				// Kill its child processes in case it's waiting on them and mark it as stopped so that the Manager can
+				// stop the modules it depends on
+				killModChildProcessesMODULES(i)
+				module.TransitionState(MOD_STATE_HUNG)
+				module.ForceStop()
+
+				exit_code = EXIT_CODE_SHUTDOWN_TIMEOUT
+			}
+		}
+
+		if all_stopped {
+			break
+		}
+
+		// Check again as soon as any module changes state
+		_, _ = WaitModStateChangeMODULES(mod_states_ch, func() bool {return false}, 1)
+	}
+
+	// All the modules stopped or were abandoned. Cancel whatever is still using a module context (only the abandoned
+	// modules could be).
+	process_ctx_cancel_GL()
+
+	for i := 1; i < MODS_ARRAY_SIZE; i++ {
+		if was_running[i] {
+			killModChildProcessesMODULES(i)
+		}
+	}
+	killPluginsPLUGINS()
+
+	shutdown_hooks_mutex_GL.Lock()
+	for _, hook := range shutdown_hooks_GL {
+		hook()
+	}
+	shutdown_hooks_mutex_GL.Unlock()
+
+	FlushModErrorsSummaryMODULES()
+	SyncLogsLOGS()
+
+	return exit_code
+}
+
+/*
+getModStopTimeoutMODULES gets the maximum time a module has to stop on the shutdown.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the time in seconds
+*/
+func getModStopTimeoutMODULES(mod_num int) int {
+	if NUM_MOD_ModManager == mod_num {
+		// The Manager stops the other modules one after the other and then the plugins before stopping itself
+		var timeout_s int = _MOD_STOP_TIMEOUT_S + _PLUGIN_STOP_TIMEOUT_S
+		for i := 2; i < MODS_ARRAY_SIZE; i++ {
+			timeout_s += getModStopTimeoutMODULES(i)
+		}
+
+		return timeout_s
+	}
+
+	if timeout_s, ok := _MOD_STOP_TIMEOUTS_S[mod_num]; ok {
+		return timeout_s
+	}
+
+	return _MOD_STOP_TIMEOUT_S
+}
+
+/*
+killModChildProcessesMODULES kills the child processes of a module that are still running.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+*/
+func killModChildProcessesMODULES(mod_num int) {
+	for _, process_name := range _MOD_CHILD_PROCESSES[mod_num] {
+		if num_killed := KillChildProcessesPROCESSES(process_name); num_killed > 0 {
+			GetModLoggerLOGS(mod_num).Warning("Killed child processes on the shutdown", "process", process_name,
+				"count", num_killed)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ModState is a state of the lifecycle of a module.
+type ModState int32
+
+const (
+	// MOD_STATE_STOPPED is the state of a module that is not running (the initial state of all modules).
+	MOD_STATE_STOPPED ModState = iota
+	// MOD_STATE_DISABLED is the state of a module that is not running and won't be started because it's disabled.
+	MOD_STATE_DISABLED
+	// MOD_STATE_STARTING is the state of a module that is doing its startup routine.
+	MOD_STATE_STARTING
+	// MOD_STATE_RUNNING is the state of a module whose realMain() is running.
+	MOD_STATE_RUNNING
+	// MOD_STATE_STOPPING is the state of a module that was signalled to stop but hasn't stopped yet.
+	MOD_STATE_STOPPING
+	// MOD_STATE_CRASHED is the state of a module that stopped because of an error in its realMain().
+	MOD_STATE_CRASHED
+	// MOD_STATE_HUNG is the state of a module that missed its heartbeats. It's signalled to stop like on
+	// MOD_STATE_STOPPING and it's forcibly restarted if it doesn't stop in time.
+	MOD_STATE_HUNG
+)
+// MOD_STATES_NAMES is a map of the module states and their names. Use with the MOD_STATE_ constants.
+var MOD_STATES_NAMES map[ModState]string = map[ModState]string{
+	MOD_STATE_STOPPED:  "Stopped",
+	MOD_STATE_DISABLED: "Disabled",
+	MOD_STATE_STARTING: "Starting",
+	MOD_STATE_RUNNING:  "Running",
+	MOD_STATE_STOPPING: "Stopping",
+	MOD_STATE_CRASHED:  "Crashed",
+	MOD_STATE_HUNG:     "Hung",
+}
+
+// _MOD_STATES_TRANSITIONS is a map of the states of a module and the states it can go to from each of them.
+var _MOD_STATES_TRANSITIONS map[ModState][]ModState = map[ModState][]ModState{
+	MOD_STATE_STOPPED:  {MOD_STATE_STARTING, MOD_STATE_DISABLED},
+	MOD_STATE_DISABLED: {MOD_STATE_STARTING, MOD_STATE_STOPPED},
+	MOD_STATE_STARTING: {MOD_STATE_RUNNING, MOD_STATE_STOPPING, MOD_STATE_STOPPED, MOD_STATE_CRASHED},
+	MOD_STATE_RUNNING:  {MOD_STATE_STOPPING, MOD_STATE_STOPPED, MOD_STATE_CRASHED, MOD_STATE_HUNG},
+	MOD_STATE_STOPPING: {MOD_STATE_STOPPED, MOD_STATE_CRASHED, MOD_STATE_HUNG},
+	MOD_STATE_CRASHED:  {MOD_STATE_STARTING, MOD_STATE_DISABLED, MOD_STATE_STOPPED},
+	MOD_STATE_HUNG:     {MOD_STATE_STOPPED, MOD_STATE_CRASHED},
+}
+
+// _MOD_STATES_CHAN_SIZE is the size of the buffer of the channels returned by SubscribeModStatesMODULES().
+const _MOD_STATES_CHAN_SIZE int = 100
+
+// ModStateChange is the information about a transition of the state of a module.
+type ModStateChange struct {
+	// Mod_num is the number of the module
+	Mod_num int
+	// Old_state is the state the module was in
+	Old_state ModState
+	// New_state is the state the module went to
+	New_state ModState
+	// Time is the time of the transition in milliseconds
+	Time int64
+}
+
+// _MODS_ENABLED_FILE is the file in VISOR's user data directory with the modules enabled or disabled persistently.
+const _MODS_ENABLED_FILE string = "modules_enabled.json"
+
+// PLUGIN_ENABLED_KEY_PREFFIX is the preffix of the keys of the plugins in the _MODS_ENABLED_FILE (followed by the name).
+const PLUGIN_ENABLED_KEY_PREFFIX string = "plugin:"
+
+var mod_states_subs_GL []chan ModStateChange = nil
+var mod_states_subs_mutex_GL sync.Mutex
+var mods_enabled_mutex_GL sync.Mutex
+
+// process_ctx_GL is the parent context of all modules' contexts. It's only cancelled at the end of the shutdown, as a
+// last resort for whatever didn't stop in time - the modules are stopped one by one (each with its own context) before.
+var process_ctx_GL, process_ctx_cancel_GL = context.WithCancel(context.Background())
+
+// _ModStateInfo is a state of a module with the time it was entered, so that both always change together.
+type _ModStateInfo struct {
+	state ModState
+	// time is the time of the transition to the state in milliseconds
+	time int64
+}
+
+/*
+StopSignal is a function that returns true if a stop was requested.
+*/
+type StopSignal func() bool
+
+/*
+GetState gets the current state of the module.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the current state of the module
+*/
+func (module *Module) GetState() ModState {
+	state, _ := module.GetStateAndTime()
+
+	return state
+}
+
+/*
+GetStateTime gets the time of the last state transition of the module.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the time of the last transition in milliseconds or 0 if the module never changed state
+*/
+func (module *Module) GetStateTime() int64 {
+	_, time_state := module.GetStateAndTime()
+
+	return time_state
+}
+
+/*
+GetStateAndTime gets the current state of the module and the time of the transition to it, read together.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the current state of the module
+  - the time of the last transition in milliseconds or 0 if the module never changed state
+*/
+func (module *Module) GetStateAndTime() (ModState, int64) {
+	var p_state_info *_ModStateInfo = module.state.Load()
+	if nil == p_state_info {
+		return MOD_STATE_STOPPED, 0
+	}
+
+	return p_state_info.state, p_state_info.time
+}
+
+/*
+TransitionState atomically changes the state of the module to the given one, if the transition is allowed from the
+current state, and notifies all the subscribers of the change.
+
+-----------------------------------------------------------
+
+– Params:
+  - new_state – the state to go to
+
+– Returns:
+  - true if the transition was made, false if it's not allowed from the current state
+*/
+func (module *Module) TransitionState(new_state ModState) bool {
+	for {
+		var p_old_state_info *_ModStateInfo = module.state.Load()
+		var old_state ModState = MOD_STATE_STOPPED
+		if nil != p_old_state_info {
+			old_state = p_old_state_info.state
+		}
+		if !isModStateTransitionAllowed(old_state, new_state) {
+			return false
+		}
+
+		var time_transition int64 = time.Now().UnixMilli()
+		if module.state.CompareAndSwap(p_old_state_info, &_ModStateInfo{state: new_state, time: time_transition}) {
+
+			if !module.IsRunning() || module.IsStopRequested() {
+				module.cancelCtx()
+			}
+
+			notifyModStateChange(ModStateChange{
+				Mod_num:   module.Num,
+				Old_state: old_state,
+				New_state: new_state,
+				Time:      time_transition,
+			})
+
+			return true
+		}
+	}
+}
+
+/*
+IsRunning checks if the module is running (starting, running, stopping or hung).
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the module is running, false otherwise
+*/
+func (module *Module) IsRunning() bool {
+	switch module.GetState() {
+		case MOD_STATE_STARTING, MOD_STATE_RUNNING, MOD_STATE_STOPPING, MOD_STATE_HUNG:
+			return true
+	}
+
+	return false
+}
+
+/*
+IsStopRequested checks if the module was signalled to stop. This is the StopSignal given to the realMain() of the
+module.
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the module should stop, false otherwise
+*/
+func (module *Module) IsStopRequested() bool {
+	var state ModState = module.GetState()
+
+	return state == MOD_STATE_STOPPING || state == MOD_STATE_HUNG
+}
+
+/*
+IsEnabled checks if the module is enabled.
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the module is enabled, false otherwise
+*/
+func (module *Module) IsEnabled() bool {
+	return module.enabled.Load()
+}
+
+/*
+SetEnabled enables or disables the module. The Modules Manager is the one starting or stopping the module accordingly.
+
+-----------------------------------------------------------
+
+– Params:
+  - enabled – true to enable the module, false to disable it
+*/
+func (module *Module) SetEnabled(enabled bool) {
+	module.enabled.Store(enabled)
+}
+
+/*
+GetModEnabledKeyMODULES gets the key of a module in the file of the modules enabled or disabled persistently.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the key of the module, like "MOD_4"
+*/
+func GetModEnabledKeyMODULES(mod_num int) string {
+	return _MOD_FOLDER_PREFFIX + strconv.Itoa(mod_num)
+}
+
+/*
+GetSavedModsEnabledMODULES gets the modules (and plugins) enabled or disabled persistently with SaveModEnabledMODULES().
+
+-----------------------------------------------------------
+
+– Returns:
+  - the enabled state by key (GetModEnabledKeyMODULES() or PLUGIN_ENABLED_KEY_PREFFIX + plugin name). Modules not in
+    the map have the default state (enabled).
+*/
+func GetSavedModsEnabledMODULES() map[string]bool {
+	var mods_enabled map[string]bool = make(map[string]bool)
+	var p_json *string = GetUserDataDirMODULES(NUM_MOD_VISOR).Add2(false, _MODS_ENABLED_FILE).ReadTextFile()
+	if nil != p_json {
+		_ = json.Unmarshal([]byte(*p_json), &mods_enabled)
+	}
+
+	return mods_enabled
+}
+
+/*
+SaveModEnabledMODULES saves if a module (or plugin) is enabled or disabled, so that it stays like that after VISOR is
+restarted.
+
+-----------------------------------------------------------
+
+– Params:
+  - key – the key of the module (GetModEnabledKeyMODULES() or PLUGIN_ENABLED_KEY_PREFFIX + plugin name)
+  - enabled – true if the module is enabled, false otherwise
+
+– Returns:
+  - nil if the state was saved, an error otherwise
+*/
+func SaveModEnabledMODULES(key string, enabled bool) error {
+	mods_enabled_mutex_GL.Lock()
+	defer mods_enabled_mutex_GL.Unlock()
+
+	var mods_enabled map[string]bool = GetSavedModsEnabledMODULES()
+	mods_enabled[key] = enabled
+
+	if err := GetUserDataDirMODULES(NUM_MOD_VISOR).Create(false); nil != err {
+		return err
+	}
+
+	return writeFileSyncedMODULES(GetUserDataDirMODULES(NUM_MOD_VISOR).Add2(false, _MODS_ENABLED_FILE).
+		GPathToStringConversion(), []byte(*ToJsonGENERAL(mods_enabled)))
+}
+
+/*
+GetCrashError gets the error message of the last crash of the module.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the error message of the last crash or an empty string if the module never crashed
+*/
+func (module *Module) GetCrashError() string {
+	var p_crash_error *string = module.crash_error.Load()
+	if p_crash_error == nil {
+		return ""
+	}
+
+	return *p_crash_error
+}
+
+/*
+GetHeartbeat gets the information about the heartbeats of the module.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the time of the last heartbeat in milliseconds (or of the start of the module if it never sent one)
+  - the maximum time between heartbeats declared by the module in seconds, or 0 if it didn't declare any (no watchdog)
+*/
+func (module *Module) GetHeartbeat() (int64, int) {
+	return module.heartbeat_time.Load(), int(module.heartbeat_period_s.Load())
+}
+
+/*
+ForceStop forcibly marks a hung module as stopped so it can be started again. The goroutine of the hung instance can't
+be killed, so it's just abandoned (its context is cancelled) and anything it does to the module state from then on is
+ignored.
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the module was hung and was forcibly stopped, false otherwise
+*/
+func (module *Module) ForceStop() bool {
+	if module.GetState() != MOD_STATE_HUNG {
+		return false
+	}
+
+	module.run_id.Add(1)
+
+	return module.TransitionState(MOD_STATE_STOPPED)
+}
+
+/*
+transitionStateRun is the same as TransitionState but only does the transition if the given run of the module is still
+the current one (the module wasn't forcibly stopped meanwhile).
+
+-----------------------------------------------------------
+
+– Params:
+  - run_id – the ID of the run of the module
+  - new_state – the state to go to
+
+– Returns:
+  - true if the transition was made, false otherwise
+*/
+func (module *Module) transitionStateRun(run_id int64, new_state ModState) bool {
+	if module.run_id.Load() != run_id {
+		return false
+	}
+
+	return module.TransitionState(new_state)
+}
+
+/*
+newCtx creates a new context for the module, to be given to its realMain, cancelling the previous one if it exists.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the new context of the module
+*/
+func (module *Module) newCtx() context.Context {
+	ctx, cancel := context.WithCancel(process_ctx_GL)
+	if p_old_cancel := module.ctx_cancel.Swap(&cancel); p_old_cancel != nil {
+		(*p_old_cancel)()
+	}
+
+	if module.IsStopRequested() {
+		// In case it was signalled to stop before the context was stored
+		cancel()
+	}
+
+	return ctx
+}
+
+/*
+cancelCtx cancels the current context of the module, if there's any.
+*/
+func (module *Module) cancelCtx() {
+	if p_cancel := module.ctx_cancel.Load(); p_cancel != nil {
+		(*p_cancel)()
+	}
+}
+
+/*
+SubscribeModStatesMODULES subscribes to the state changes of all modules.
+
+The channel is buffered and a change is dropped for a subscriber whose buffer is full, so don't take too long to
+receive them.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the channel on which the state changes will be sent
+*/
+func SubscribeModStatesMODULES() chan ModStateChange {
+	mod_states_subs_mutex_GL.Lock()
+	defer mod_states_subs_mutex_GL.Unlock()
+
+	var channel chan ModStateChange = make(chan ModStateChange, _MOD_STATES_CHAN_SIZE)
+	mod_states_subs_GL = append(mod_states_subs_GL, channel)
+
+	return channel
+}
+
+/*
+UnsubscribeModStatesMODULES cancels a subscription made with SubscribeModStatesMODULES().
+
+-----------------------------------------------------------
+
+– Params:
+  - channel – the channel returned by SubscribeModStatesMODULES()
+*/
+func UnsubscribeModStatesMODULES(channel chan ModStateChange) {
+	mod_states_subs_mutex_GL.Lock()
+	defer mod_states_subs_mutex_GL.Unlock()
+
+	for i, sub := range mod_states_subs_GL {
+		if sub == channel {
+			mod_states_subs_GL = append(mod_states_subs_GL[:i], mod_states_subs_GL[i+1:]...)
+
+			break
+		}
+	}
+}
+
+/*
+WaitModStateChangeMODULES waits for a state change of any module or until the time passes or a stop signal is received
+(checked every second).
+
+-----------------------------------------------------------
+
+– Params:
+  - channel – the channel returned by SubscribeModStatesMODULES()
+  - stop – the stop signal
+  - time_wait_s – the maximum time to wait in seconds
+
+– Returns:
+  - the state change or nil if none happened
+  - whether the wait was stopped (true) or not (false)
+*/
+func WaitModStateChangeMODULES(channel chan ModStateChange, stop StopSignal, time_wait_s int) (*ModStateChange, bool) {
+	var time_end int64 = time.Now().Unix() + int64(time_wait_s)
+	for {
+		if stop() {
+			return nil, true
+		}
+		if time.Now().Unix() >= time_end {
+			return nil, false
+		}
+
+		select {
+			case change := <-channel:
+				return &change, false
+			case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+/*
+isModStateTransitionAllowed checks if a module can go from a state to another.
+
+-----------------------------------------------------------
+
+– Params:
+  - old_state – the current state
+  - new_state – the state to go to
+
+– Returns:
+  - true if the transition is allowed, false otherwise
+*/
+func isModStateTransitionAllowed(old_state ModState, new_state ModState) bool {
+	for _, state := range _MOD_STATES_TRANSITIONS[old_state] {
+		if state == new_state {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+notifyModStateChange sends a state change to all the subscribers.
+
+-----------------------------------------------------------
+
+– Params:
+  - change – the state change
+*/
+func notifyModStateChange(change ModStateChange) {
+	mod_states_subs_mutex_GL.Lock()
+	defer mod_states_subs_mutex_GL.Unlock()
+
+	for _, channel := range mod_states_subs_GL {
+		select {
+			case channel <- change:
+			default:
+				// Buffer full - drop it instead of blocking the module
+		}
+	}
+}
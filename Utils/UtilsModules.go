@@ -22,6 +22,7 @@
 package Utils
 
 import (
+	"context"
 	"errors"
 	Tcef "github.com/Edw590/TryCatch-go"
 	"github.com/shirou/gopsutil/v4/process"
@@ -30,6 +31,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -144,6 +147,11 @@ type ModuleInfo[T any] struct {
 	ModGenInfo T
 	// ModDirsInfo is the information about the directories of the module.
 	ModDirsInfo _ModDirsInfo
+	// Log is the logger of the module.
+	Log *ModLogger
+
+	// module is the Module struct of the module, for the heartbeats.
+	module *Module
 }
 
 type Module struct {
@@ -151,12 +159,24 @@ type Module struct {
 	Num int
 	// Name is the name of the module.
 	Name string
-	// Stop is set to true if the module should stop.
-	Stop    bool
-	// Stopped is set to true if the module has stopped.
-	Stopped bool
-	// Enabled is set to true if the module is enabled.
-	Enabled bool
+
+	// state is the current ModState of the module with the time it was entered, or nil before the first transition.
+	// Only change it through TransitionState().
+	state atomic.Pointer[_ModStateInfo]
+	// enabled is true if the module is enabled.
+	enabled atomic.Bool
+	// crash_error is the error message of the last crash of the module.
+	crash_error atomic.Pointer[string]
+	// ctx_cancel is the function that cancels the context given to the module's realMain.
+	ctx_cancel atomic.Pointer[context.CancelFunc]
+	// run_id is the ID of the current run of the module. It changes when a hung module is forcibly stopped.
+	run_id atomic.Int64
+	// heartbeat_time is the time of the last heartbeat of the module in milliseconds.
+	heartbeat_time atomic.Int64
+	// heartbeat_period_s is the maximum time between heartbeats declared by the module in seconds (0 for none).
+	heartbeat_period_s atomic.Int64
+	// gen_info_mutex is the mutex of the generated information files of the module.
+	gen_info_mutex sync.Mutex
 }
 
 /*
@@ -167,11 +187,54 @@ realMain is the function that does the actual work of a module (it's equivalent
 -----------------------------------------------------------
 
 – Params:
-  - module_stop – a pointer to a boolean that is set to true if the module should stop
+  - module_stop – the stop signal of the module (true if the module should stop)
+  - moduleInfo_any – the ModuleInfo struct of the module with the ModuleInfo.ModGenInfo.ModSpecInfo field of the
+    requested type by the module
+*/
+type RealMain func(module_stop StopSignal, moduleInfo_any any)
+
+/*
+RealMainCtx is the same as RealMain, but the realMain() function receives a context instead of a stop signal. Use it
+for modules doing long blocking work (commands, network requests...), which can then be interrupted right away.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context of the module, cancelled when the module is signalled to stop (by the Modules Manager, by a STOP
+    file or because the process is shutting down)
   - moduleInfo_any – the ModuleInfo struct of the module with the ModuleInfo.ModGenInfo.ModSpecInfo field of the
     requested type by the module
 */
-type RealMain func(module_stop *bool, moduleInfo_any any)
+type RealMainCtx func(ctx context.Context, moduleInfo_any any)
+
+/*
+NewModuleInfoMODULES creates the ModuleInfo of a module, with no generated information loaded (ModStartup() creates it
+itself - this is for the tests).
+
+-----------------------------------------------------------
+
+– Generic params:
+  - T – the type of the ModuleInfo.ModGenInfo field of the module
+
+– Params:
+  - module – a pointer to the Module struct of the module
+
+– Returns:
+  - the ModuleInfo
+*/
+func NewModuleInfoMODULES[T any](module *Module) ModuleInfo[T] {
+	return ModuleInfo[T]{
+		Name:       module.Name,
+		Num:        module.Num,
+		ModDirsInfo: _ModDirsInfo{
+			ProgramData: getProgramDataDirMODULES(module.Num),
+			UserData:    GetUserDataDirMODULES(module.Num),
+			Temp:        getModTempDirMODULES(module.Num),
+		},
+		Log:    GetModLoggerLOGS(module.Num),
+		module: module,
+	}
+}
 
 /*
 ModStartup does the startup routine for a module and executes its realMain() function, catching any fatal errors and
@@ -192,6 +255,21 @@ func ModStartup[T any](realMain RealMain, module *Module) {
 	ModStartup2[T](realMain, module, false)
 }
 /*
+ModStartupCtx is the same as ModStartup but for modules whose realMain() is a RealMainCtx.
+
+-----------------------------------------------------------
+
+– Generic params:
+  - T – the type of the ModuleInfo.ModGenInfo field of the requested type by the module
+
+– Params:
+  - realMainCtx – a pointer to the realMain() function of the module
+  - module – a pointer to the Module struct of the module
+*/
+func ModStartupCtx[T any](realMainCtx RealMainCtx, module *Module) {
+	modStartup[T](nil, realMainCtx, module, false)
+}
+/*
 ModStartup2 is the main function for ModStartup. Read everything there, except one different parameter.
 
 -----------------------------------------------------------
@@ -200,6 +278,12 @@ ModStartup2 is the main function for ModStartup. Read everything there, except o
   - server – true if the version running is the server version, false if it's the client version
  */
 func ModStartup2[T any](realMain RealMain, module *Module, server bool) {
+	modStartup[T](realMain, nil, module, server)
+}
+/*
+modStartup is the main function for all the ModStartup functions. Only one of realMain and realMainCtx must be given.
+ */
+func modStartup[T any](realMain RealMain, realMainCtx RealMainCtx, module *Module, server bool) {
 	// Module startup routine //
 
 	var mod_num = module.Num
@@ -208,53 +292,54 @@ func ModStartup2[T any](realMain RealMain, module *Module, server bool) {
 	if mod_num == NUM_MOD_VISOR {
 		printStartupSequenceMODULES(mod_name)
 
-		if !getUserSettings() {
-			panic(errors.New("CRITICAL ERROR: Error obtaining user settings - aborting"))
+		if err := getUserSettings(server); nil != err {
+			panic(errors.New("CRITICAL ERROR: Error obtaining user settings - aborting\n" + err.Error()))
 		}
 
-		// Set the internal VISOR_server UserSettings attribute
-		User_settings_GL.PersonalConsts.VISOR_server = server
-
-		go func() {
-			// Keep updating the user settings global variable in case it's MOD_0 that's running
-			for {
-				getUserSettings()
-
-				time.Sleep(5 * time.Second)
-			}
-		}()
+		// Keep the user settings updated whenever the file changes
+		go watchUserSettingsSETTINGS(server)
 	}
 
 	if !IsModSupportedMODULES(mod_num) {
 		panic(errors.New("module " + strconv.Itoa(mod_num) + " is not supported on this system"))
 	}
 
-	var moduleInfo ModuleInfo[T] = ModuleInfo[T]{
-		Name:       mod_name,
-		Num:        mod_num,
-		ModDirsInfo: _ModDirsInfo{
-			ProgramData: getProgramDataDirMODULES(mod_num),
-			UserData:    GetUserDataDirMODULES(mod_num),
-			Temp:        getModTempDirMODULES(mod_num),
-		},
-	}
+	var moduleInfo ModuleInfo[T] = NewModuleInfoMODULES[T](module)
 
 	var errs bool = false
 	var to_do func()
+	var run_id int64
+
+	if !module.TransitionState(MOD_STATE_STARTING) {
+		moduleInfo.Log.Warning("Not in a state to be started - exiting", "state", MOD_STATES_NAMES[module.GetState()])
+
+		return
+	}
+	run_id = module.run_id.Load()
+	// No watchdog until the module declares its heartbeat period
+	module.heartbeat_period_s.Store(0)
+	module.heartbeat_time.Store(time.Now().UnixMilli())
+
+	// Even if the module logs nothing this time
+	moduleInfo.Log.deleteOldFiles()
 
 	if moduleInfo.signalledToStop() {
 		log.Println("Module " + strconv.Itoa(mod_num) + " was signalled to stop before starting. Exiting...")
 
+		module.transitionStateRun(run_id, MOD_STATE_STOPPED)
+
 		goto end
 	}
 
-	_ = moduleInfo.getGenInfo()
+	if err := moduleInfo.LoadGenInfo(); nil != err {
+		moduleInfo.Log.Error("Error loading the generated information - starting with none", "error", err)
+	}
 
 	// Start the loopSleep() routine asynchronously
 	go func() {
-		for {
+		for module.IsRunning() && module.run_id.Load() == run_id {
 			if moduleInfo.loopSleep() {
-				module.Stop = true
+				module.transitionStateRun(run_id, MOD_STATE_STOPPING)
 
 				break
 			}
@@ -262,18 +347,28 @@ func ModStartup2[T any](realMain RealMain, module *Module, server bool) {
 	}()
 
 	to_do = func() {
-		module.Stopped = false
+		var ctx context.Context = module.newCtx()
+
+		module.transitionStateRun(run_id, MOD_STATE_RUNNING)
 
 		Tcef.Tcef{
 			Try: func() {
 				// Execute realMain()
-				realMain(&module.Stop, moduleInfo)
+				if realMainCtx != nil {
+					realMainCtx(ctx, moduleInfo)
+				} else {
+					realMain(module.IsStopRequested, moduleInfo)
+				}
 			},
 			Catch: func(e Tcef.Exception) {
 				errs = true
 
 				var str_error string = GetFullErrorMsgGENERAL(e)
 
+				if module.run_id.Load() == run_id {
+					module.crash_error.Store(&str_error)
+				}
+
 				// Print the error and send an email with it
 				log.Println(str_error)
 				if err := SendModErrorEmailMODULES(mod_num, str_error); nil != err {
@@ -282,7 +377,11 @@ func ModStartup2[T any](realMain RealMain, module *Module, server bool) {
 			},
 		}.Do()
 
-		module.Stopped = true
+		if errs {
+			module.transitionStateRun(run_id, MOD_STATE_CRASHED)
+		} else {
+			module.transitionStateRun(run_id, MOD_STATE_STOPPED)
+		}
 	}
 
 	if mod_num == NUM_MOD_VISOR {
@@ -291,6 +390,8 @@ func ModStartup2[T any](realMain RealMain, module *Module, server bool) {
 		if isModRunningMODULES(mod_num) {
 			log.Println("Module " + strconv.Itoa(mod_num) + " is already running. Exiting...")
 
+			module.TransitionState(MOD_STATE_STOPPED)
+
 			goto end
 		}
 
@@ -336,43 +437,6 @@ func GetModNameMODULES(mod_num int) string {
 	return "INVALID MODULE NUMBER"
 }
 
-/*
-SendModErrorEmailMODULES directly sends an email to the developer with the error message.
-
-This function does *not* use any modules to do anything. Only utility functions. So it can be used from any
-module.
-
------------------------------------------------------------
-
-– Params:
-  - mod_num – the number of the module from which the error occurred
-  - error – the error message
-
-– Returns:
-  - nil if the email was sent successfully, otherwise an error
-*/
-func SendModErrorEmailMODULES(mod_num int, err_str string) error {
-	var things_replace map[string]string = map[string]string{
-		MODEL_INFO_MSG_BODY_EMAIL : err_str,
-		MODEL_INFO_DATE_TIME_EMAIL: GetDateTimeStrTIMEDATE(-1),
-	}
-	var email_info = GetModelFileEMAIL(MODEL_FILE_INFO, things_replace)
-	email_info.Subject = "Error in module: " + GetModNameMODULES(mod_num)
-
-	if mod_num == NUM_MOD_EmailSender {
-		// Send the email directly
-		message_eml, mail_to, success := prepareEmlEMAIL(email_info)
-		if !success {
-			return errors.New("error preparing email")
-		}
-
-		return SendEmailEMAIL(message_eml, mail_to, true)
-	} else {
-		// Queue the email
-		return QueueEmailEMAIL(email_info)
-	}
-}
-
 /*
 LoopSleep sleeps for _LOOP_TIME_S seconds and checks if the module was signalled to stop.
 
@@ -392,7 +456,31 @@ func (moduleInfo *ModuleInfo[T]) loopSleep() bool {
 }
 
 /*
-GetModUserInfo gets the information about the module from the user info file.
+SetHeartbeatPeriod declares the maximum time between heartbeats of the module. If the module doesn't call Heartbeat()
+within that time, the Modules Manager considers it hung, signals it to stop and forcibly restarts it if it doesn't stop.
+
+Call it at the beginning of realMain() and call Heartbeat() on each iteration of the module's loop.
+
+-----------------------------------------------------------
+
+– Params:
+  - max_period_s – the maximum time between heartbeats in seconds, or 0 to disable the watchdog
+*/
+func (moduleInfo *ModuleInfo[T]) SetHeartbeatPeriod(max_period_s int) {
+	moduleInfo.module.heartbeat_time.Store(time.Now().UnixMilli())
+	moduleInfo.module.heartbeat_period_s.Store(int64(max_period_s))
+}
+
+/*
+Heartbeat tells the Modules Manager the module is still working properly.
+*/
+func (moduleInfo *ModuleInfo[T]) Heartbeat() {
+	moduleInfo.module.heartbeat_time.Store(time.Now().UnixMilli())
+}
+
+/*
+GetModUserInfo gets the information about the module from its section of the user settings file or, if it has none
+there, from its own user info file.
 
 -----------------------------------------------------------
 
@@ -403,7 +491,11 @@ GetModUserInfo gets the information about the module from the user info file.
   - true if the file was read successfully, false otherwise
 */
 func (moduleInfo *ModuleInfo[T]) GetModUserInfo(v any) error {
-	var p_json_file *string = moduleInfo.ModDirsInfo.UserData.Add2(false, _MOD_USER_INFO_JSON).ReadTextFile()
+	var p_json_file *string = getModSettingsSectionSETTINGS(moduleInfo.Num)
+	if p_json_file == nil {
+		// No section for the module in the user settings, so use its own file
+		p_json_file = moduleInfo.ModDirsInfo.UserData.Add2(false, _MOD_USER_INFO_JSON).ReadTextFile()
+	}
 	if p_json_file == nil {
 		return errors.New("error reading the user info file")
 	}
@@ -411,6 +503,22 @@ func (moduleInfo *ModuleInfo[T]) GetModUserInfo(v any) error {
 	return FromJsonGENERAL([]byte(*p_json_file), v)
 }
 
+/*
+SubscribeModUserInfo subscribes to changes of the module's section of the user settings file (like MOD_4 for the RSS
+Feed Notifier), so that the module can get the new information with GetModUserInfo() right away.
+
+Unsubscribe with UnsubscribeSettingsSETTINGS() when the channel is no longer needed.
+
+-----------------------------------------------------------
+
+– Returns:
+  - a channel that receives a value each time the section changes (multiple changes before the value is received are
+    merged into one)
+*/
+func (moduleInfo *ModuleInfo[T]) SubscribeModUserInfo() chan struct{} {
+	return SubscribeSettingsSETTINGS(getModSettingsSectionNameSETTINGS(moduleInfo.Num))
+}
+
 /*
 signalledToStop checks if the module was signalled to stop.
 
@@ -439,71 +547,45 @@ func (moduleInfo *ModuleInfo[T]) signalledToStop() bool {
 }
 
 /*
-UpdateGenInfo updates the information about the module in its generated information file.
+getUserSettings gets the user settings from the UserSettings_EOG.json file, only replacing the current ones if the new
+general settings are valid. Problems in the settings of modules are only logged.
 
 -----------------------------------------------------------
 
-– Returns:
-  - nil if the update was successful, an error otherwise
-*/
-func (moduleInfo *ModuleInfo[T]) UpdateGenInfo() error {
-	var json_str string = *ToJsonGENERAL(&moduleInfo.ModGenInfo)
-
-	var file_path_curr GPath = GetUserDataDirMODULES(moduleInfo.Num).Add2(false, _MOD_GEN_INFO_JSON)
-	var file_path_new GPath = GetUserDataDirMODULES(moduleInfo.Num).Add2(false, _MOD_GEN_INFO_JSON_TMP)
-
-	var err error = file_path_new.WriteTextFile(json_str, false)
-	if nil != err {
-		return err
-	}
-
-	return os.Rename(file_path_new.GPathToStringConversion(), file_path_curr.GPathToStringConversion())
-}
+– Params:
+  - server – true if it's the server version running, false if it's the client version
 
-/*
-getGenInfo gets the information about the module from its generated information file.
+– Returns:
+  - nil if the user settings were obtained successfully, an error describing all the problems found otherwise
  */
-func (moduleInfo *ModuleInfo[T]) getGenInfo() error {
-	// Get information from the existing mod_gen_info.json file
-	// Check first if the temporary file exists
-	var p_info []byte = moduleInfo.ModDirsInfo.UserData.Add2(false, _MOD_GEN_INFO_JSON_TMP).ReadFile()
-	if p_info == nil {
-		// If not, check if the main file exists
-		p_info = moduleInfo.ModDirsInfo.UserData.Add2(false, _MOD_GEN_INFO_JSON).ReadFile()
-		if p_info == nil {
-			// If not, empty struct (new file)
-
-			goto new_file
-		}
+func getUserSettings(server bool) error {
+	user_settings, problems := ReadUserSettingsSETTINGS(server)
+	general_problems, mods_problems := splitSettingsProblems(problems)
+	if len(general_problems) > 0 {
+		return settingsProblemsToError(USER_SETTINGS_FILE, general_problems)
 	}
 
-	return FromJsonGENERAL(p_info, &moduleInfo.ModGenInfo)
+	// Set the internal VISOR_server UserSettings attribute
+	user_settings.PersonalConsts.VISOR_server = server
+	SetUserSettingsSETTINGS(user_settings)
 
-	new_file:
+	// Problems in the settings of a module only affect that module
+	warnModsSettingsProblemsSETTINGS(mods_problems)
 
 	return nil
 }
 
 /*
-getUserSettings gets the user settings from the UserSettings_EOG.json file.
+ReloadUserSettingsMODULES reloads the user settings from the UserSettings_EOG.json file right away (they're also
+reloaded automatically when the file changes), only replacing the current ones if the new ones are valid.
 
 -----------------------------------------------------------
 
 – Returns:
-  - true if the user settings were obtained successfully, false otherwise
+  - nil if the user settings were reloaded, an error describing all the problems found otherwise
  */
-func getUserSettings() bool {
-	const USER_SETTINGS_FILE string = "UserSettings_EOG.json"
-	bytes, err := os.ReadFile(USER_SETTINGS_FILE)
-	if err != nil {
-		return false
-	}
-
-	if err = FromJsonGENERAL(bytes, &User_settings_GL); err != nil {
-		return false
-	}
-
-	return true
+func ReloadUserSettingsMODULES() error {
+	return getUserSettings(GetUserSettingsSETTINGS().PersonalConsts.VISOR_server)
 }
 
 /*
@@ -601,7 +683,7 @@ Use ONLY with the main program! This uses files.
 func (moduleInfo *ModuleInfo[T]) updateModRunInfo() {
 	var mod_num int = moduleInfo.Num
 
-	files, _ := os.ReadDir(GetUserDataDirMODULES(mod_num).GPathToStringConversion())
+	files, _ := GetFileSystemFILESDIRS().ReadDir(GetUserDataDirMODULES(mod_num).GPathToStringConversion())
 
 	var curr_pid string = strconv.Itoa(os.Getpid())
 	var file_exists bool = false
@@ -641,7 +723,7 @@ Use ONLY with the main program! This uses files.
 */
 func isModRunningMODULES(mod_num int) bool {
 	var curr_pid int = os.Getpid()
-	files, err := os.ReadDir(GetUserDataDirMODULES(mod_num).GPathToStringConversion())
+	files, err := GetFileSystemFILESDIRS().ReadDir(GetUserDataDirMODULES(mod_num).GPathToStringConversion())
 	if nil != err {
 		return false
 	}
@@ -688,7 +770,7 @@ func IsModSupportedMODULES(mod_num int) bool {
 			return true
 		case NUM_MOD_SMARTChecker:
 			// Check if the command "smartctl" is available
-			output, err := ExecCmdSHELL([]string{"smartctl{{EXE}} --version"})
+			output, err := ExecCmdSHELL(context.Background(), []string{"smartctl{{EXE}} --version"})
 			if err != nil {
 				return false
 			}
@@ -700,7 +782,7 @@ func IsModSupportedMODULES(mod_num int) bool {
 			return true
 		case NUM_MOD_EmailSender:
 			// Check if the command "curl" is available
-			output, err := ExecCmdSHELL([]string{"curl{{EXE}} --version"})
+			output, err := ExecCmdSHELL(context.Background(), []string{"curl{{EXE}} --version"})
 			if err != nil {
 				return false
 			}
@@ -708,7 +790,7 @@ func IsModSupportedMODULES(mod_num int) bool {
 			return output.Exit_code == 0
 		case NUM_MOD_OnlineInfoChk:
 			// Check if the command "chromedriver" is available
-			output, err := ExecCmdSHELL([]string{"chromedriver{{EXE}} --version"})
+			output, err := ExecCmdSHELL(context.Background(), []string{"chromedriver{{EXE}} --version"})
 			if err != nil {
 				return false
 			}
@@ -716,7 +798,7 @@ func IsModSupportedMODULES(mod_num int) bool {
 			return output.Exit_code == 0
 		case NUM_MOD_GPTCommunicator:
 			// Check if the command "llama-cli" is available
-			output, err := ExecCmdSHELL([]string{"llama-cli{{EXE}} --version"})
+			output, err := ExecCmdSHELL(context.Background(), []string{"llama-cli{{EXE}} --version"})
 			if err != nil {
 				return false
 			}
@@ -736,37 +818,3 @@ func IsModSupportedMODULES(mod_num int) bool {
 			return false
 	}
 }
-
-/*
-SignalModulesStopMODULES signals all the modules to stop and waits for them to stop.
-
------------------------------------------------------------
-
-– Params:
-  - modules – the list of modules
-*/
-func SignalModulesStopMODULES(modules []Module) {
-	// Stop the modules gracefully before forcing an exit and wait for them to stop
-	for {
-		// Begin with the Manager (i := 1). VISOR doesn't count - of course it's running, else we wouldn't be here.
-
-		for i := 1; i < MODS_ARRAY_SIZE; i++ {
-			modules[i].Stop = true
-		}
-
-		var all_stopped bool = true
-		for i := 1; i < MODS_ARRAY_SIZE; i++ {
-			if !modules[i].Stopped {
-				all_stopped = false
-
-				break
-			}
-		}
-
-		if all_stopped {
-			break
-		}
-
-		time.Sleep(1 * time.Second)
-	}
-}
@@ -21,9 +21,25 @@
 
 package Utils
 
+// NOTIFS_REL_FOLDER is the folder of VISOR where text files named "<title>-<anything>.txt" can be dropped to be shown
+// as notifications (compatibility with the queue before TOPIC_NOTIFICATIONS).
 const NOTIFS_REL_FOLDER string = "notifs"
 
+// Notification is a notification to show to the user.
+type Notification struct {
+	// Title is the title of the notification
+	Title string
+	// Text is the text of the notification
+	Text  string
+}
+
+// TOPIC_NOTIFICATIONS has the notifications to show to the user.
+var TOPIC_NOTIFICATIONS *Topic[Notification] = NewTopicEVENTS[Notification]("notifications", true,
+	GetModSubscriberEVENTS(NUM_MOD_VISOR))
+
 func QueueNotificationNOTIFS(title string, text string) {
-	var file_name string = title + "-" + RandStringGENERAL(20) + ".txt"
-	_ = GetUserDataDirMODULES(NUM_MOD_VISOR).Add2(false, NOTIFS_REL_FOLDER, file_name).WriteTextFile(text, false)
+	_ = TOPIC_NOTIFICATIONS.Publish(Notification{
+		Title: title,
+		Text:  text,
+	})
 }
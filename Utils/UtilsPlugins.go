@@ -0,0 +1,693 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NUM_MOD_PLUGIN is the module number of all plugin modules (they're not in the modules array).
+const NUM_MOD_PLUGIN int = -1
+
+// _PLUGINS_REL_DIR is the relative path to the plugins' directory from PersonalConsts._VISOR_DIR. Each plugin is in a
+// subdirectory with its PLUGIN_MANIFEST_FILE.
+const _PLUGINS_REL_DIR string = "plugins"
+// PLUGIN_MANIFEST_FILE is the name of the manifest file of a plugin.
+const PLUGIN_MANIFEST_FILE string = "plugin.json"
+
+// _PLUGIN_STOP_TIMEOUT_S is the time a plugin has to exit after being signalled to stop before being killed.
+const _PLUGIN_STOP_TIMEOUT_S int = 10
+// _PLUGIN_MAX_MSG_SIZE is the maximum size in bytes of a message sent by a plugin.
+const _PLUGIN_MAX_MSG_SIZE int = 4*1024*1024
+
+const (
+	PLUGIN_SUPPORT_CLIENT string = "client"
+	PLUGIN_SUPPORT_SERVER string = "server"
+	PLUGIN_SUPPORT_BOTH   string = "both"
+)
+
+// PLUGIN_METHOD_STOP is the method of the message sent to a plugin to tell it to exit.
+const PLUGIN_METHOD_STOP string = "Stop"
+
+// PluginManifest is the manifest of a plugin module, in the PLUGIN_MANIFEST_FILE of its directory.
+type PluginManifest struct {
+	// Name is the unique name of the plugin
+	Name string
+	// Description is what the plugin does
+	Description string
+	// Support is one of the PLUGIN_SUPPORT_ constants
+	Support string
+	// Supported_os are the operating systems the plugin runs on, as in Go's runtime.GOOS ("linux", "windows"...), or
+	// empty for all
+	Supported_os []string
+	// Command is the command line of the plugin. A relative executable path is relative to the plugin's directory.
+	Command []string
+	// Heartbeat_s is the maximum time in seconds between "Heartbeat" calls of the plugin, or 0 for no watchdog
+	Heartbeat_s int
+}
+
+// PluginRequest is a request sent by a plugin to VISOR, as a JSON line on its stdout.
+type PluginRequest struct {
+	// Id is the ID of the request, repeated in the response
+	Id int64
+	// Method is the name of the method to call
+	Method string
+	// Params are the parameters of the method
+	Params json.RawMessage
+}
+
+// PluginResponse is a message sent by VISOR to a plugin, as a JSON line on its stdin: either the response to a request
+// or, with a Method and no Id, a message from VISOR (like PLUGIN_METHOD_STOP).
+type PluginResponse struct {
+	// Id is the ID of the request being responded to
+	Id int64 `json:",omitempty"`
+	// Method is the method of a message from VISOR
+	Method string `json:",omitempty"`
+	// Result is the result of the method
+	Result any `json:",omitempty"`
+	// Error is the error of the method, if any
+	Error string `json:",omitempty"`
+}
+
+/*
+PluginMethod is the type of the functions that handle the methods plugins can call.
+
+-----------------------------------------------------------
+
+– Params:
+  - plugin – the plugin that called the method
+  - params – the parameters of the call in JSON
+
+– Returns:
+  - the result of the method (marshalled to JSON)
+  - an error if the call failed, nil otherwise
+*/
+type PluginMethod func(plugin *Plugin, params json.RawMessage) (any, error)
+
+// Plugin is a plugin module: an executable that is supervised like the other modules and talks to VISOR through its
+// stdin and stdout with JSON lines (PluginRequest and PluginResponse). Its stderr goes to the logs.
+type Plugin struct {
+	// Manifest is the manifest of the plugin
+	Manifest PluginManifest
+	// Dir is the directory of the plugin
+	Dir string
+	// Module is the module of the plugin, with its state
+	Module Module
+
+	// removed is true if the plugin's directory or manifest was removed while it was running
+	removed atomic.Bool
+
+	mutex sync.Mutex
+	// cmd is the current process of the plugin, or nil if it's not running
+	cmd *exec.Cmd
+	// stdin is the stdin of the current process of the plugin
+	stdin io.WriteCloser
+}
+
+var plugins_GL []*Plugin = nil
+var plugins_mutex_GL sync.Mutex
+
+var plugin_methods_GL map[string]PluginMethod = map[string]PluginMethod{
+	"Heartbeat":   pluginHeartbeatPLUGINS,
+	"Log":         pluginLogPLUGINS,
+	"GetSettings": pluginGetSettingsPLUGINS,
+	"QueueEmail":  pluginQueueEmailPLUGINS,
+}
+var plugin_methods_mutex_GL sync.Mutex
+
+/*
+RegisterPluginMethodPLUGINS registers a method plugins can call, for the ones that need things Utils can't import (like
+the Registry).
+
+-----------------------------------------------------------
+
+– Params:
+  - method – the name of the method
+  - handler – the function that handles the calls
+*/
+func RegisterPluginMethodPLUGINS(method string, handler PluginMethod) {
+	plugin_methods_mutex_GL.Lock()
+	defer plugin_methods_mutex_GL.Unlock()
+
+	plugin_methods_GL[method] = handler
+}
+
+/*
+LoadPluginsPLUGINS (re)loads the manifests of the plugins from the plugins' directory.
+
+Plugins already loaded keep their state and get the new manifest, which is used on their next start. Plugins whose
+manifest is gone are forgotten once they're not running (until then, IsRemoved() returns true).
+
+-----------------------------------------------------------
+
+– Returns:
+  - the problems with the manifests that couldn't be loaded, or nil if there are none
+*/
+func LoadPluginsPLUGINS() []error {
+	var plugins_dir string = getVISORDirFILESDIRS().Add2(true, _PLUGINS_REL_DIR).GPathToStringConversion()
+
+	var manifests map[string]PluginManifest = make(map[string]PluginManifest)
+	var dirs map[string]string = make(map[string]string)
+	var problems []error = nil
+	entries, _ := os.ReadDir(plugins_dir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		var plugin_dir string = filepath.Join(plugins_dir, entry.Name())
+		manifest, err := readPluginManifestPLUGINS(filepath.Join(plugin_dir, PLUGIN_MANIFEST_FILE))
+		if nil != err {
+			if !errors.Is(err, os.ErrNotExist) {
+				problems = append(problems, errors.New(entry.Name() + ": " + err.Error()))
+			}
+
+			continue
+		}
+		if _, ok := manifests[manifest.Name]; ok {
+			problems = append(problems, errors.New(entry.Name() + ": repeated plugin name \"" + manifest.Name + "\""))
+
+			continue
+		}
+
+		manifests[manifest.Name] = manifest
+		dirs[manifest.Name] = plugin_dir
+	}
+
+	plugins_mutex_GL.Lock()
+	defer plugins_mutex_GL.Unlock()
+
+	var new_plugins []*Plugin = nil
+	for _, plugin := range plugins_GL {
+		if manifest, ok := manifests[plugin.Manifest.Name]; ok {
+			plugin.mutex.Lock()
+			plugin.Manifest = manifest
+			plugin.Dir = dirs[manifest.Name]
+			plugin.mutex.Unlock()
+			plugin.removed.Store(false)
+			delete(manifests, manifest.Name)
+		} else if plugin.Module.IsRunning() {
+			plugin.removed.Store(true)
+		} else {
+			continue
+		}
+		new_plugins = append(new_plugins, plugin)
+	}
+	var mods_enabled map[string]bool = nil
+	if len(manifests) > 0 {
+		mods_enabled = GetSavedModsEnabledMODULES()
+	}
+	for name, manifest := range manifests {
+		var plugin *Plugin = &Plugin{
+			Manifest: manifest,
+			Dir:      dirs[name],
+		}
+		plugin.Module.Num = NUM_MOD_PLUGIN
+		plugin.Module.Name = name
+		enabled, ok := mods_enabled[PLUGIN_ENABLED_KEY_PREFFIX + name]
+		plugin.Module.SetEnabled(!ok || enabled)
+		new_plugins = append(new_plugins, plugin)
+	}
+	sort.Slice(new_plugins, func(i, j int) bool {
+		return new_plugins[i].Manifest.Name < new_plugins[j].Manifest.Name
+	})
+	plugins_GL = new_plugins
+
+	return problems
+}
+
+/*
+GetPluginsPLUGINS gets the loaded plugins.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the plugins, sorted by name
+*/
+func GetPluginsPLUGINS() []*Plugin {
+	plugins_mutex_GL.Lock()
+	defer plugins_mutex_GL.Unlock()
+
+	return append([]*Plugin(nil), plugins_GL...)
+}
+
+/*
+GetPluginPLUGINS gets a loaded plugin by its name.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the plugin
+
+– Returns:
+  - the plugin or nil if there's no plugin with that name
+*/
+func GetPluginPLUGINS(name string) *Plugin {
+	for _, plugin := range GetPluginsPLUGINS() {
+		if plugin.Manifest.Name == name {
+			return plugin
+		}
+	}
+
+	return nil
+}
+
+/*
+readPluginManifestPLUGINS reads and checks a plugin manifest file.
+
+-----------------------------------------------------------
+
+– Params:
+  - file_path – the path to the manifest file
+
+– Returns:
+  - the manifest
+  - an error if the file couldn't be read or the manifest is invalid, nil otherwise
+*/
+func readPluginManifestPLUGINS(file_path string) (PluginManifest, error) {
+	var manifest PluginManifest
+	contents, err := os.ReadFile(file_path)
+	if nil != err {
+		return manifest, err
+	}
+	if err = json.Unmarshal(contents, &manifest); nil != err {
+		return manifest, errors.New("invalid JSON: " + err.Error())
+	}
+
+	if "" == manifest.Name {
+		return manifest, errors.New("Name is required")
+	}
+	switch manifest.Support {
+		case PLUGIN_SUPPORT_CLIENT, PLUGIN_SUPPORT_SERVER, PLUGIN_SUPPORT_BOTH:
+		default:
+			return manifest, errors.New("Support must be \"" + PLUGIN_SUPPORT_CLIENT + "\", \"" +
+				PLUGIN_SUPPORT_SERVER + "\" or \"" + PLUGIN_SUPPORT_BOTH + "\"")
+	}
+	if len(manifest.Command) == 0 || "" == manifest.Command[0] {
+		return manifest, errors.New("Command is required")
+	}
+	if manifest.Heartbeat_s < 0 {
+		return manifest, errors.New("Heartbeat_s must not be negative")
+	}
+
+	return manifest, nil
+}
+
+/*
+IsSupported checks if the plugin can run on this version of VISOR (client or server) and operating system.
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the plugin is supported, false otherwise
+*/
+func (plugin *Plugin) IsSupported() bool {
+	plugin.mutex.Lock()
+	var manifest PluginManifest = plugin.Manifest
+	plugin.mutex.Unlock()
+
+	var server bool = GetUserSettingsSETTINGS().PersonalConsts.VISOR_server
+	if (server && PLUGIN_SUPPORT_CLIENT == manifest.Support) || (!server && PLUGIN_SUPPORT_SERVER == manifest.Support) {
+		return false
+	}
+
+	return len(manifest.Supported_os) == 0 || ContainsSLICES(manifest.Supported_os, runtime.GOOS)
+}
+
+/*
+IsRemoved checks if the plugin's manifest was removed while the plugin was running.
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if the plugin was removed and should be stopped, false otherwise
+*/
+func (plugin *Plugin) IsRemoved() bool {
+	return plugin.removed.Load()
+}
+
+/*
+Start starts the process of the plugin.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the plugin was started, an error otherwise (in which case the plugin is marked as crashed, unless it was
+    already running)
+*/
+func (plugin *Plugin) Start() error {
+	if !plugin.Module.TransitionState(MOD_STATE_STARTING) {
+		return errors.New("the plugin is already running")
+	}
+
+	plugin.mutex.Lock()
+	defer plugin.mutex.Unlock()
+
+	var crash = func(err error) error {
+		var str_error string = "Error starting the plugin: " + err.Error()
+		plugin.Module.crash_error.Store(&str_error)
+		plugin.Module.TransitionState(MOD_STATE_CRASHED)
+
+		return err
+	}
+
+	var executable string = plugin.Manifest.Command[0]
+	if !filepath.IsAbs(executable) && strings.ContainsAny(executable, "/\\") {
+		executable = filepath.Join(plugin.Dir, executable)
+	}
+	var data_dir string = GetUserDataDirMODULES(NUM_MOD_ModManager).Add2(true, _PLUGINS_REL_DIR,
+		plugin.Manifest.Name).GPathToStringConversion()
+	if err := os.MkdirAll(data_dir, 0o755); nil != err {
+		return crash(err)
+	}
+
+	var cmd *exec.Cmd = exec.Command(executable, plugin.Manifest.Command[1:]...)
+	cmd.Dir = plugin.Dir
+	cmd.Env = append(os.Environ(), "VISOR_PLUGIN_NAME=" + plugin.Manifest.Name, "VISOR_PLUGIN_DATA_DIR=" + data_dir)
+	stdin, err := cmd.StdinPipe()
+	if nil != err {
+		return crash(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if nil != err {
+		return crash(err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if nil != err {
+		return crash(err)
+	}
+	if err = cmd.Start(); nil != err {
+		return crash(err)
+	}
+
+	plugin.cmd = cmd
+	plugin.stdin = stdin
+	plugin.Module.heartbeat_time.Store(time.Now().UnixMilli())
+	plugin.Module.heartbeat_period_s.Store(int64(plugin.Manifest.Heartbeat_s))
+	plugin.Module.TransitionState(MOD_STATE_RUNNING)
+
+	go plugin.logStderr(stderr)
+	go func() {
+		plugin.serveRequests(cmd, stdout)
+		plugin.exited(cmd, cmd.Wait())
+	}()
+
+	return nil
+}
+
+/*
+Stop signals the plugin to stop (with a PLUGIN_METHOD_STOP message and closing its stdin) and kills it if it doesn't
+exit in _PLUGIN_STOP_TIMEOUT_S.
+*/
+func (plugin *Plugin) Stop() {
+	plugin.Module.TransitionState(MOD_STATE_STOPPING)
+
+	plugin.mutex.Lock()
+	var cmd *exec.Cmd = plugin.cmd
+	plugin.mutex.Unlock()
+	if nil == cmd {
+		return
+	}
+
+	_ = plugin.send(PluginResponse{Method: PLUGIN_METHOD_STOP})
+	plugin.mutex.Lock()
+	if plugin.cmd == cmd {
+		_ = plugin.stdin.Close()
+	}
+	plugin.mutex.Unlock()
+
+	go func() {
+		time.Sleep(time.Duration(_PLUGIN_STOP_TIMEOUT_S) * time.Second)
+		plugin.kill(cmd)
+	}()
+}
+
+/*
+kill kills the process of the plugin if it's still the given one.
+
+-----------------------------------------------------------
+
+– Params:
+  - cmd – the process to kill
+*/
+func (plugin *Plugin) kill(cmd *exec.Cmd) {
+	plugin.mutex.Lock()
+	defer plugin.mutex.Unlock()
+
+	if plugin.cmd == cmd && nil != cmd.Process {
+		GetModLoggerLOGS(NUM_MOD_ModManager).Warning("Killing plugin", "plugin", plugin.Manifest.Name)
+		_ = cmd.Process.Kill()
+	}
+}
+
+/*
+exited updates the state of the plugin after its process exited.
+
+-----------------------------------------------------------
+
+– Params:
+  - cmd – the process that exited
+  - err – the error returned by cmd.Wait()
+*/
+func (plugin *Plugin) exited(cmd *exec.Cmd, err error) {
+	plugin.mutex.Lock()
+	if plugin.cmd == cmd {
+		plugin.cmd = nil
+		plugin.stdin = nil
+	}
+	plugin.mutex.Unlock()
+
+	switch plugin.Module.GetState() {
+		case MOD_STATE_STOPPING:
+			plugin.Module.TransitionState(MOD_STATE_STOPPED)
+		case MOD_STATE_HUNG:
+			var str_error string = "The plugin hung and was stopped"
+			plugin.Module.crash_error.Store(&str_error)
+			plugin.Module.TransitionState(MOD_STATE_CRASHED)
+		default:
+			if nil == err {
+				plugin.Module.TransitionState(MOD_STATE_STOPPED)
+			} else {
+				var str_error string = "The plugin exited unexpectedly: " + err.Error()
+				plugin.Module.crash_error.Store(&str_error)
+				plugin.Module.TransitionState(MOD_STATE_CRASHED)
+			}
+	}
+}
+
+/*
+serveRequests handles the requests of the plugin until its stdout is closed.
+
+-----------------------------------------------------------
+
+– Params:
+  - cmd – the process of the plugin
+  - stdout – the stdout of the plugin
+*/
+func (plugin *Plugin) serveRequests(cmd *exec.Cmd, stdout io.Reader) {
+	var scanner *bufio.Scanner = bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), _PLUGIN_MAX_MSG_SIZE)
+	for scanner.Scan() {
+		var line []byte = scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var request PluginRequest
+		if err := json.Unmarshal(line, &request); nil != err {
+			_ = plugin.send(PluginResponse{Error: "invalid request: " + err.Error()})
+
+			continue
+		}
+
+		plugin_methods_mutex_GL.Lock()
+		var handler PluginMethod = plugin_methods_GL[request.Method]
+		plugin_methods_mutex_GL.Unlock()
+
+		var response PluginResponse = PluginResponse{Id: request.Id}
+		if nil == handler {
+			response.Error = "unknown method \"" + request.Method + "\""
+		} else if result, err := handler(plugin, request.Params); nil != err {
+			response.Error = err.Error()
+		} else {
+			response.Result = result
+		}
+		_ = plugin.send(response)
+	}
+	if err := scanner.Err(); nil != err {
+		GetModLoggerLOGS(NUM_MOD_ModManager).Error("Error reading from plugin", "plugin", plugin.Manifest.Name,
+			"error", err)
+		plugin.kill(cmd)
+	}
+}
+
+/*
+send sends a message to the plugin.
+
+-----------------------------------------------------------
+
+– Params:
+  - response – the message
+
+– Returns:
+  - nil if the message was sent, an error otherwise
+*/
+func (plugin *Plugin) send(response PluginResponse) error {
+	response_json, err := json.Marshal(response)
+	if nil != err {
+		return err
+	}
+
+	plugin.mutex.Lock()
+	defer plugin.mutex.Unlock()
+
+	if nil == plugin.stdin {
+		return errors.New("the plugin is not running")
+	}
+	_, err = plugin.stdin.Write(append(response_json, '\n'))
+
+	return err
+}
+
+/*
+logStderr logs the lines the plugin writes to its stderr until it's closed.
+
+-----------------------------------------------------------
+
+– Params:
+  - stderr – the stderr of the plugin
+*/
+func (plugin *Plugin) logStderr(stderr io.Reader) {
+	var scanner *bufio.Scanner = bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		GetModLoggerLOGS(NUM_MOD_ModManager).Info(scanner.Text(), "plugin", plugin.Manifest.Name)
+	}
+}
+
+/*
+killPluginsPLUGINS kills the processes of all the plugins still running.
+*/
+func killPluginsPLUGINS() {
+	for _, plugin := range GetPluginsPLUGINS() {
+		plugin.mutex.Lock()
+		var cmd *exec.Cmd = plugin.cmd
+		plugin.mutex.Unlock()
+		if nil != cmd {
+			plugin.kill(cmd)
+		}
+	}
+}
+
+/*
+DecodePluginParamsPLUGINS decodes the parameters of a plugin method call.
+
+-----------------------------------------------------------
+
+– Params:
+  - params – the parameters in JSON (may be empty)
+  - v – a pointer to where to decode them to
+
+– Returns:
+  - nil if the parameters were decoded, an error otherwise
+*/
+func DecodePluginParamsPLUGINS(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); nil != err {
+		return errors.New("invalid parameters: " + err.Error())
+	}
+
+	return nil
+}
+
+// pluginHeartbeatPLUGINS handles the "Heartbeat" method: the plugin is still working properly.
+func pluginHeartbeatPLUGINS(plugin *Plugin, params json.RawMessage) (any, error) {
+	plugin.Module.heartbeat_time.Store(time.Now().UnixMilli())
+
+	return nil, nil
+}
+
+// pluginLogPLUGINS handles the "Log" method: {Level, Msg, Fields}, with Level one of the LOG_LEVELS_NAMES (any case).
+func pluginLogPLUGINS(plugin *Plugin, params json.RawMessage) (any, error) {
+	var log_params struct {
+		Level  string
+		Msg    string
+		Fields map[string]any
+	}
+	if err := DecodePluginParamsPLUGINS(params, &log_params); nil != err {
+		return nil, err
+	}
+
+	var level int = -1
+	for level_num, level_name := range LOG_LEVELS_NAMES {
+		if strings.EqualFold(level_name, log_params.Level) {
+			level = level_num
+		}
+	}
+	if -1 == level {
+		return nil, errors.New("unknown log level \"" + log_params.Level + "\"")
+	}
+
+	var key_values []any = []any{"plugin", plugin.Manifest.Name}
+	for key, value := range log_params.Fields {
+		key_values = append(key_values, key, value)
+	}
+	GetModLoggerLOGS(NUM_MOD_ModManager).log(level, log_params.Msg, key_values)
+
+	return nil, nil
+}
+
+// pluginGetSettingsPLUGINS handles the "GetSettings" method: returns the plugin's section of the Plugins settings.
+func pluginGetSettingsPLUGINS(plugin *Plugin, params json.RawMessage) (any, error) {
+	return GetUserSettingsSETTINGS().Plugins[plugin.Manifest.Name], nil
+}
+
+// pluginQueueEmailPLUGINS handles the "QueueEmail" method: {Mail_to, Subject, Html}. Without Mail_to, the email goes
+// to the user.
+func pluginQueueEmailPLUGINS(plugin *Plugin, params json.RawMessage) (any, error) {
+	var emailInfo EmailInfo
+	if err := DecodePluginParamsPLUGINS(params, &emailInfo); nil != err {
+		return nil, err
+	}
+	if "" == emailInfo.Mail_to {
+		emailInfo.Mail_to = GetUserSettingsSETTINGS().PersonalConsts.User_email_addr
+	}
+	if "" == emailInfo.Sender {
+		emailInfo.Sender = plugin.Manifest.Name
+	}
+
+	return nil, QueueEmailEMAIL(emailInfo)
+}
@@ -22,8 +22,11 @@
 package Utils
 
 import (
+	"github.com/shirou/gopsutil/v4/process"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 )
 
 /*
@@ -55,3 +58,93 @@ func StartConAppPROCESSES(path GPath, arg string) bool {
 
 	return true
 }
+
+/*
+IsProcessRunningPROCESSES checks if there's any process running with the given name.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the process, without the ".exe" extension on Windows
+
+– Returns:
+  - true if a process with the given name is running, false otherwise
+*/
+func IsProcessRunningPROCESSES(name string) bool {
+	processes, err := process.Processes()
+	if err != nil {
+		return false
+	}
+
+	for _, proc := range processes {
+		proc_name, err := proc.Name()
+		if err == nil && strings.TrimSuffix(proc_name, ".exe") == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+KillChildProcessesPROCESSES kills the processes with the given name that were started by this process (directly or
+through other processes, like a shell), together with their own child processes. Processes with the name not started
+by this process are left alone.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the processes, without the ".exe" extension on Windows
+
+– Returns:
+  - the number of processes with the given name killed
+*/
+func KillChildProcessesPROCESSES(name string) int {
+	self_proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return 0
+	}
+
+	var num_killed int = 0
+	for _, proc := range getDescendantsPROCESSES(self_proc) {
+		proc_name, err := proc.Name()
+		if err == nil && strings.TrimSuffix(proc_name, ".exe") == name {
+			// Kill its children first, or they'd be left running without a parent (like Chrome for chromedriver)
+			for _, child_proc := range getDescendantsPROCESSES(proc) {
+				_ = child_proc.Kill()
+			}
+			if proc.Kill() == nil {
+				num_killed++
+			}
+		}
+	}
+
+	return num_killed
+}
+
+/*
+getDescendantsPROCESSES gets the child processes of a process, and their child processes, recursively.
+
+-----------------------------------------------------------
+
+– Params:
+  - proc – the process
+
+– Returns:
+  - the descendant processes, each one before its own child processes
+*/
+func getDescendantsPROCESSES(proc *process.Process) []*process.Process {
+	children, err := proc.Children()
+	if err != nil {
+		// Including when it has no children
+		return nil
+	}
+
+	var descendants []*process.Process = nil
+	for _, child_proc := range children {
+		descendants = append(descendants, child_proc)
+		descendants = append(descendants, getDescendantsPROCESSES(child_proc)...)
+	}
+
+	return descendants
+}
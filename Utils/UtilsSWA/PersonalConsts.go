@@ -34,7 +34,9 @@ InitPersonalConsts initializes the personal constants.
   - website_pw – the password of VISOR's website
  */
 func InitPersonalConsts(device_id string, website_url string, website_pw string) {
-	Utils.User_settings_GL.PersonalConsts.Device_ID = device_id
-	Utils.User_settings_GL.PersonalConsts.Website_url = website_url
-	Utils.User_settings_GL.PersonalConsts.Website_pw = website_pw
+	var user_settings Utils.UserSettings = *Utils.GetUserSettingsSETTINGS()
+	user_settings.PersonalConsts.Device_ID = device_id
+	user_settings.PersonalConsts.Website_url = website_url
+	user_settings.PersonalConsts.Website_pw = website_pw
+	Utils.SetUserSettingsSETTINGS(user_settings)
 }
@@ -28,7 +28,6 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha512"
-	"log"
 	"math"
 
 	"golang.org/x/crypto/scrypt"
@@ -148,12 +147,6 @@ func EncryptBytesCRYPTOENDECRYPT(raw_password1 []byte, raw_password2 []byte, raw
 
 	randomized_raw_data_padded := pkcs5Padding(randomized_raw_data, cipher_block.BlockSize())
 
-	log.Println("-------------------")
-	log.Println(len(iv))
-	log.Println(len(keys))
-	log.Println(len(keys[0]))
-	log.Println("---")
-
 	cipher_text := make([]byte, len(randomized_raw_data_padded))
 	cipher_block.CryptBlocks(cipher_text, randomized_raw_data_padded)
 
@@ -313,7 +306,11 @@ getAADReady gets the Authenticated Associated Data (AAD) ready for use with corr
   - the ready AAD byte vector
  */
 func getAADReady(raw_aad_suffix []byte) []byte {
-	return append(_RAW_AAD_PREFIX, raw_aad_suffix...)
+	// Copy the prefix first or append() may write the suffix into the array of the global variable
+	var raw_aad_ready []byte = make([]byte, 0, len(_RAW_AAD_PREFIX) + len(raw_aad_suffix))
+	raw_aad_ready = append(raw_aad_ready, _RAW_AAD_PREFIX...)
+
+	return append(raw_aad_ready, raw_aad_suffix...)
 }
 
 /*
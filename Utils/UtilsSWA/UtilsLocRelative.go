@@ -23,6 +23,7 @@ package UtilsSWA
 
 import (
 	"Utils"
+	"context"
 	"log"
 	"math"
 	"strconv"
@@ -219,7 +220,7 @@ func GetAveragePingRttLOCRELATIVE(ip string) (float64, error) {
 	const NUM_PACKETS = 50 // 50 packets, each with 0.5 seconds delay, so 25 seconds of waiting time
 	// 248 + 8 header = 256 bytes each packet
 	command_str := "ping{{EXE}} -c " + strconv.Itoa(NUM_PACKETS) + " -i 0.5 -n -s 248 -t 1 -v " + ip
-	output, err := Utils.ExecCmdSHELL([]string{command_str})
+	output, err := Utils.ExecCmdSHELL(context.Background(), []string{command_str})
 	if err != nil {
 		return -1.0, err
 	}
@@ -0,0 +1,447 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package UtilsSWA
+
+import (
+	"Utils"
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/cention-sany/utf7"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SECRETS_FILE is the name of the secrets store file, in the current working directory (like the settings files).
+const SECRETS_FILE string = "Secrets_EOG.json"
+// SECRETS_KEY_ENV_VAR is the environment variable that can have the master password of the secrets store, to unlock it
+// without asking for it.
+const SECRETS_KEY_ENV_VAR string = "VISOR_SECRETS_KEY"
+
+// _SECRETS_PW2 is the second password used to encrypt the secrets (the first is the master password).
+const _SECRETS_PW2 string = ASSISTANT_NAME_WO_DOTS + " secrets store"
+// _SECRETS_CHECK_NAME is the AAD of the check text, which is used to know if the master password is correct.
+const _SECRETS_CHECK_NAME string = "[check]"
+
+// _SecretsFile is the format of the secrets store file.
+type _SecretsFile struct {
+	// Check is a known text encrypted with the master password, to know if a master password is correct
+	Check string
+	// Secrets maps the names of the secrets to their encrypted values
+	Secrets map[string]_Secret
+}
+
+// _Secret is a secret of the secrets store.
+type _Secret struct {
+	// Value is the encrypted value of the secret in Base64
+	Value string
+	// Updated is the time the secret was last added or rotated in milliseconds
+	Updated int64
+}
+
+// secrets_GL are the decrypted secrets. They're only kept in memory.
+var secrets_GL map[string]string = nil
+var secrets_mutex_GL sync.Mutex
+
+/*
+UnlockSecretsSECRETS decrypts the secrets store to memory and makes its secrets available to the settings files.
+
+The master password is got from the SECRETS_KEY_ENV_VAR environment variable or, if it's not set, asked on the
+terminal.
+
+-----------------------------------------------------------
+
+– Returns:
+  - nil if the store was unlocked or doesn't exist, an error otherwise
+*/
+func UnlockSecretsSECRETS() error {
+	secrets_file, err := readSecretsFile()
+	if nil != err || nil == secrets_file {
+		return err
+	}
+
+	master_pw, err := getMasterPassword(false)
+	if nil != err {
+		return err
+	}
+
+	if !isMasterPasswordCorrect(secrets_file, master_pw) {
+		return errors.New("wrong master password for " + SECRETS_FILE)
+	}
+
+	var secrets map[string]string = make(map[string]string, len(secrets_file.Secrets))
+	for name, secret := range secrets_file.Secrets {
+		value, ok := decryptSecret(master_pw, name, secret.Value)
+		if !ok {
+			return errors.New("the secret \"" + name + "\" of " + SECRETS_FILE + " is corrupted")
+		}
+		secrets[name] = value
+	}
+
+	secrets_mutex_GL.Lock()
+	secrets_GL = secrets
+	secrets_mutex_GL.Unlock()
+
+	Utils.SetSecretsResolverSETTINGS(getSecret)
+
+	return nil
+}
+
+/*
+RunSecretsCmdSECRETS runs the "secrets" subcommand of the main programs:
+
+  - secrets list – lists the names of the secrets and when they were last updated
+  - secrets add <name> – adds a secret (creating the store if it doesn't exist)
+  - secrets rotate <name> – replaces the value of an existing secret
+
+The values and the master password are asked on the terminal (the master password can also be given in the
+SECRETS_KEY_ENV_VAR environment variable).
+
+-----------------------------------------------------------
+
+– Params:
+  - args – the arguments after "secrets"
+
+– Returns:
+  - the exit code of the subcommand
+*/
+func RunSecretsCmdSECRETS(args []string) int {
+	const USAGE string = "Usage: secrets list | secrets add <name> | secrets rotate <name>\n" +
+		"Reference the secrets in the settings files with \"" + Utils.SECRET_REF_PREFFIX + "<name>\"."
+
+	if len(args) == 1 && "list" == args[0] {
+		secrets_file, err := readSecretsFile()
+		if nil != err {
+			fmt.Println(err)
+
+			return 1
+		}
+		if nil == secrets_file || len(secrets_file.Secrets) == 0 {
+			fmt.Println("No secrets stored.")
+
+			return 0
+		}
+
+		var names []string = nil
+		for name := range secrets_file.Secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println("- " + name + " (updated on " +
+				Utils.GetDateTimeStrTIMEDATE(secrets_file.Secrets[name].Updated) + ")")
+		}
+
+		return 0
+	}
+
+	if len(args) != 2 || ("add" != args[0] && "rotate" != args[0]) || "" == args[1] {
+		fmt.Println(USAGE)
+
+		return 1
+	}
+
+	if err := setSecret(args[1], "add" == args[0]); nil != err {
+		fmt.Println(err)
+
+		return 1
+	}
+	fmt.Println("Secret \"" + args[1] + "\" saved. Use it with \"" + Utils.SECRET_REF_PREFFIX + args[1] + "\".")
+
+	return 0
+}
+
+/*
+setSecret adds or rotates a secret, asking for the master password and the value on the terminal.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the secret
+  - add – true to add a new secret, false to rotate an existing one
+
+– Returns:
+  - nil if the secret was saved, an error otherwise
+*/
+func setSecret(name string, add bool) error {
+	secrets_file, err := readSecretsFile()
+	if nil != err {
+		return err
+	}
+
+	var new_store bool = nil == secrets_file
+	if new_store {
+		if !add {
+			return errors.New(SECRETS_FILE + " doesn't exist yet")
+		}
+		secrets_file = &_SecretsFile{Secrets: make(map[string]_Secret)}
+	}
+
+	_, exists := secrets_file.Secrets[name]
+	if add && exists {
+		return errors.New("the secret \"" + name + "\" already exists - rotate it instead")
+	} else if !add && !exists {
+		return errors.New("the secret \"" + name + "\" doesn't exist - add it instead")
+	}
+
+	master_pw, err := getMasterPassword(new_store)
+	if nil != err {
+		return err
+	}
+	if new_store {
+		secrets_file.Check = encryptSecret(master_pw, _SECRETS_CHECK_NAME, ASSISTANT_NAME_WO_DOTS)
+	} else if !isMasterPasswordCorrect(secrets_file, master_pw) {
+		return errors.New("wrong master password for " + SECRETS_FILE)
+	}
+
+	value, err := readHiddenLine("Value of the secret \"" + name + "\": ")
+	if nil != err {
+		return err
+	}
+	if "" == value {
+		return errors.New("empty value - nothing saved")
+	}
+
+	secrets_file.Secrets[name] = _Secret{
+		Value:   encryptSecret(master_pw, name, value),
+		Updated: time.Now().UnixMilli(),
+	}
+
+	// Only the owner can read the file
+	if err = Utils.ReplaceFileSyncedMODULES(SECRETS_FILE, []byte(*Utils.ToJsonGENERAL(secrets_file)), 0o600); nil != err {
+		return err
+	}
+
+	return nil
+}
+
+/*
+getSecret gets the value of an unlocked secret.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the secret
+
+– Returns:
+  - the value of the secret
+  - true if the secret exists, false otherwise
+*/
+func getSecret(name string) (string, bool) {
+	secrets_mutex_GL.Lock()
+	defer secrets_mutex_GL.Unlock()
+
+	value, ok := secrets_GL[name]
+
+	return value, ok
+}
+
+/*
+readSecretsFile reads the secrets store file.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the contents of the file, or nil if it doesn't exist
+  - nil if the file doesn't exist or was read successfully, an error otherwise
+*/
+func readSecretsFile() (*_SecretsFile, error) {
+	file_bytes, err := os.ReadFile(SECRETS_FILE)
+	if nil != err {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var secrets_file _SecretsFile
+	if err = Utils.FromJsonGENERAL(file_bytes, &secrets_file); nil != err {
+		return nil, errors.New("invalid " + SECRETS_FILE + ": " + err.Error())
+	}
+	if nil == secrets_file.Secrets {
+		secrets_file.Secrets = make(map[string]_Secret)
+	}
+
+	return &secrets_file, nil
+}
+
+/*
+isMasterPasswordCorrect checks if a master password is the one the secrets store was created with.
+
+-----------------------------------------------------------
+
+– Params:
+  - secrets_file – the contents of the secrets store file
+  - master_pw – the master password
+
+– Returns:
+  - true if the master password is correct, false otherwise
+*/
+func isMasterPasswordCorrect(secrets_file *_SecretsFile, master_pw string) bool {
+	check, ok := decryptSecret(master_pw, _SECRETS_CHECK_NAME, secrets_file.Check)
+
+	return ok && ASSISTANT_NAME_WO_DOTS == check
+}
+
+/*
+encryptSecret encrypts the value of a secret with EncryptBytesCRYPTOENDECRYPT(), using the name of the secret as the
+AAD suffix so that values can't be swapped between secrets.
+
+-----------------------------------------------------------
+
+– Params:
+  - master_pw – the master password
+  - name – the name of the secret
+  - value – the value of the secret
+
+– Returns:
+  - the encrypted value in Base64
+*/
+func encryptSecret(master_pw string, name string, value string) string {
+	var encrypted []byte = EncryptBytesCRYPTOENDECRYPT([]byte(master_pw), []byte(_SECRETS_PW2),
+		utf7.UTF7EncodeBytes([]byte(value)), []byte(name))
+
+	return base64.StdEncoding.EncodeToString(encrypted)
+}
+
+/*
+decryptSecret decrypts a value encrypted with encryptSecret().
+
+-----------------------------------------------------------
+
+– Params:
+  - master_pw – the master password
+  - name – the name of the secret
+  - encrypted_value – the encrypted value in Base64
+
+– Returns:
+  - the value of the secret
+  - true if the value was decrypted, false if the master password is wrong or the value was tampered with
+*/
+func decryptSecret(master_pw string, name string, encrypted_value string) (string, bool) {
+	encrypted, err := base64.StdEncoding.DecodeString(encrypted_value)
+	if nil != err {
+		return "", false
+	}
+
+	var decrypted []byte = DecryptBytesCRYPTOENDECRYPT([]byte(master_pw), []byte(_SECRETS_PW2), encrypted,
+		[]byte(name))
+	if nil == decrypted {
+		return "", false
+	}
+
+	decrypted, err = utf7.UTF7DecodeBytes(decrypted)
+	if nil != err {
+		return "", false
+	}
+
+	return string(decrypted), true
+}
+
+/*
+getMasterPassword gets the master password of the secrets store from the SECRETS_KEY_ENV_VAR environment variable or,
+if it's not set, asks for it on the terminal.
+
+-----------------------------------------------------------
+
+– Params:
+  - confirm – true to ask for the password twice (for new stores)
+
+– Returns:
+  - the master password
+  - nil if the password was got, an error otherwise
+*/
+func getMasterPassword(confirm bool) (string, error) {
+	if master_pw, ok := os.LookupEnv(SECRETS_KEY_ENV_VAR); ok && "" != master_pw {
+		return master_pw, nil
+	}
+
+	master_pw, err := readHiddenLine("Master password of " + SECRETS_FILE + ": ")
+	if nil != err {
+		return "", err
+	}
+	if "" == master_pw {
+		return "", errors.New("empty master password")
+	}
+
+	if confirm {
+		master_pw2, err := readHiddenLine("Repeat the master password: ")
+		if nil != err {
+			return "", err
+		}
+		if master_pw2 != master_pw {
+			return "", errors.New("the master passwords don't match")
+		}
+	}
+
+	return master_pw, nil
+}
+
+/*
+readHiddenLine asks for a line on the terminal without showing what's typed (on Unix-like systems - not possible on
+Windows without more dependencies).
+
+-----------------------------------------------------------
+
+– Params:
+  - prompt – the text to show before reading
+
+– Returns:
+  - the line read, without the line ending
+  - nil if the line was read, an error otherwise (like if there's no terminal)
+*/
+func readHiddenLine(prompt string) (string, error) {
+	file_stats, err := os.Stdin.Stat()
+	if nil != err || file_stats.Mode() & os.ModeCharDevice == 0 {
+		return "", errors.New("no terminal to ask for the input - for the master password, set the " +
+			SECRETS_KEY_ENV_VAR + " environment variable")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+
+	if "windows" != runtime.GOOS {
+		var cmd *exec.Cmd = exec.Command("stty", "-echo")
+		cmd.Stdin = os.Stdin
+		if nil == cmd.Run() {
+			defer func() {
+				cmd = exec.Command("stty", "echo")
+				cmd.Stdin = os.Stdin
+				_ = cmd.Run()
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if nil != err && "" == line {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -24,6 +24,7 @@ package UtilsSWA
 import (
 	"Utils"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"strings"
 )
@@ -62,7 +63,7 @@ func ExecCmdSHELL(attempt_su bool, commands_list string) ([]byte, error) {
 	if attempt_su && IsRootAvailableROOT() {
 		commands_list = "su\n" + commands_list
 	}
-	cmd_output, err := Utils.ExecCmdMainSHELL(strings.Split(commands_list, "\n"), "", ANDROID_SH)
+	cmd_output, err := Utils.ExecCmdMainSHELL(context.Background(), strings.Split(commands_list, "\n"), "", ANDROID_SH)
 
 	exit_code := make([]byte, 4)
 	binary.BigEndian.PutUint32(exit_code, uint32(cmd_output.Exit_code))
@@ -0,0 +1,744 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Catch-up policies for the runs missed while VISOR was not running (or too busy to run them)
+const (
+	// CATCH_UP_SKIP skips the missed runs and waits for the next one
+	CATCH_UP_SKIP string = "skip"
+	// CATCH_UP_ONCE runs once right away, no matter how many runs were missed
+	CATCH_UP_ONCE string = "once"
+	// CATCH_UP_ALL runs once right away for each missed run (up to _SCHED_MAX_CATCH_UP_RUNS)
+	CATCH_UP_ALL  string = "all"
+)
+var _CATCH_UP_POLICIES []string = []string{CATCH_UP_SKIP, CATCH_UP_ONCE, CATCH_UP_ALL}
+
+// _SCHED_MAX_CATCH_UP_RUNS is the maximum number of missed runs executed with the CATCH_UP_ALL policy.
+const _SCHED_MAX_CATCH_UP_RUNS int = 100
+// _SCHED_LAST_RUNS_FILE is the name of the file inside the module's user data directory where the last run times of
+// its scheduled items are kept.
+const _SCHED_LAST_RUNS_FILE string = "scheduler_last_runs.json"
+// _CRON_MAX_SEARCH_YEARS is how far in the future the next time matching a cron expression is searched for (for
+// expressions like "0 0 30 2 *" which never match).
+const _CRON_MAX_SEARCH_YEARS int = 5
+
+// Schedule is the schedule of a periodic work item.
+type Schedule struct {
+	// Cron is a cron expression ("minute hour day month weekday", or @hourly, @daily, @weekly, @monthly or @yearly).
+	// If set, Interval_s is ignored.
+	Cron string
+	// Interval_s is the fixed interval between runs in seconds
+	Interval_s int64
+	// Jitter_s is the maximum random delay in seconds added to each run, to avoid all items running at the same time
+	Jitter_s int64
+	// Catch_up is the policy for the missed runs (one of the CATCH_UP_ constants - CATCH_UP_ONCE if empty)
+	Catch_up string
+	// Quiet_hours is a range of local time in the format "HH:MM-HH:MM" (can go through midnight) in which nothing runs.
+	// The runs that fall inside it are postponed to its end. If empty, the Scheduler.Quiet_hours user setting is used.
+	Quiet_hours string
+}
+
+// _CronSchedule is a parsed cron expression. Each field has the bit of each allowed value set.
+type _CronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// days_star and weekdays_star are true if the respective field begins with a "*", in which case both fields must
+	// match (else only one of them needs to, like in the standard cron)
+	days_star     bool
+	weekdays_star bool
+}
+
+// _QuietHours is a parsed quiet hours range, in minutes of the day.
+type _QuietHours struct {
+	start_min int
+	end_min   int
+}
+
+// _SchedItem is an item of a Scheduler.
+type _SchedItem struct {
+	// def_schedule is the default schedule given by the module
+	def_schedule Schedule
+	// schedule is the effective schedule (the default one with the user settings applied)
+	schedule Schedule
+	cron        *_CronSchedule
+	quiet_hours *_QuietHours
+	// last_run is the time of the last run in milliseconds, or 0 if the item never ran
+	last_run int64
+	// next_run is when the next run is due, or the zero time if never
+	next_run time.Time
+	// due_runs is the number of runs to do when next_run arrives (more than 1 when catching up with CATCH_UP_ALL)
+	due_runs int
+}
+
+/*
+Scheduler decides when the periodic work items of a module must run, keeping the time of the last run of each item
+across restarts.
+
+Create it with NewSchedulerSCHEDULER(), set the items with SetItems(), wait for them with WaitDue() and call RunDone()
+after each run. It is not safe for concurrent use.
+*/
+type Scheduler struct {
+	mod_num     int
+	items       map[string]*_SchedItem
+	last_runs   map[string]int64
+	settings_ch chan struct{}
+}
+
+/*
+NewSchedulerSCHEDULER creates a scheduler for a module, loading the last run times of its items.
+
+Call Close() on it when it's no longer needed.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the scheduler
+*/
+func NewSchedulerSCHEDULER(mod_num int) *Scheduler {
+	var scheduler *Scheduler = &Scheduler{
+		mod_num:     mod_num,
+		items:       make(map[string]*_SchedItem),
+		last_runs:   make(map[string]int64),
+		settings_ch: SubscribeSettingsSETTINGS("Scheduler"),
+	}
+
+	var last_runs_path GPath = GetUserDataDirMODULES(mod_num).Add2(false, _SCHED_LAST_RUNS_FILE)
+	if last_runs_path.Exists() {
+		if err := FromJsonGENERAL(last_runs_path.ReadFile(), &scheduler.last_runs); nil != err {
+			GetModLoggerLOGS(mod_num).Warning("Error reading the scheduler last run times - starting with none",
+				"error", err)
+		}
+	}
+
+	return scheduler
+}
+
+/*
+Close stops the scheduler from following the user settings.
+*/
+func (scheduler *Scheduler) Close() {
+	UnsubscribeSettingsSETTINGS(scheduler.settings_ch)
+}
+
+/*
+SetItems sets the items of the scheduler, keeping the state of the ones that already existed. The items not given are
+removed.
+
+The schedules are the module's defaults: each one can be overridden in the user settings (Scheduler.Items) by the item
+ID.
+
+-----------------------------------------------------------
+
+– Params:
+  - def_schedules – the item IDs mapped to their default schedules. The IDs must be unique among all modules, so begin
+    them with "MOD_n." (for example "MOD_4.feed_3").
+*/
+func (scheduler *Scheduler) SetItems(def_schedules map[string]Schedule) {
+	var last_runs_modified bool = false
+	for item_id := range scheduler.items {
+		if _, ok := def_schedules[item_id]; !ok {
+			delete(scheduler.items, item_id)
+			delete(scheduler.last_runs, item_id)
+			last_runs_modified = true
+		}
+	}
+	if last_runs_modified {
+		scheduler.saveLastRuns()
+	}
+
+	for item_id, def_schedule := range def_schedules {
+		var item *_SchedItem = scheduler.items[item_id]
+		if nil == item {
+			item = &_SchedItem{
+				def_schedule: def_schedule,
+				last_run:     scheduler.last_runs[item_id],
+			}
+			scheduler.items[item_id] = item
+			scheduler.updateItem(item_id, item, true)
+		} else if item.def_schedule != def_schedule {
+			item.def_schedule = def_schedule
+			scheduler.updateItem(item_id, item, false)
+		}
+	}
+}
+
+/*
+WaitDue waits until at least one item is due to run, the context is cancelled, the channel receives a value or the
+maximum time passes - whatever comes first.
+
+The due items are returned sorted by how late they are. An item appears more than once when it must catch up with
+various missed runs. Call RunDone() after each run - until then, the item keeps being returned.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to wait on
+  - channel – a channel to wait on too (for example to know about user settings changes), or nil
+  - max_wait_s – the maximum time to wait in seconds (to be able to send heartbeats, for example)
+
+– Returns:
+  - the IDs of the items due to run, or nil if none is due yet
+  - whether the context was cancelled
+*/
+func (scheduler *Scheduler) WaitDue(ctx context.Context, channel chan struct{}, max_wait_s int) ([]string, bool) {
+	for waited := false; ; waited = true {
+		if ctx.Err() != nil {
+			return nil, true
+		}
+
+		var now time.Time = GetClockTIMEDATE().Now()
+		var wake_time time.Time = now.Add(time.Duration(max_wait_s) * time.Second)
+		var due_items []string = nil
+		for item_id, item := range scheduler.items {
+			if item.next_run.IsZero() {
+				continue
+			}
+			if !item.next_run.After(now) {
+				for i := 0; i < item.due_runs; i++ {
+					due_items = append(due_items, item_id)
+				}
+			} else if item.next_run.Before(wake_time) {
+				wake_time = item.next_run
+			}
+		}
+		if len(due_items) > 0 || waited {
+			sort.SliceStable(due_items, func(i, j int) bool {
+				return scheduler.items[due_items[i]].next_run.Before(scheduler.items[due_items[j]].next_run)
+			})
+
+			return due_items, false
+		}
+
+		switch GetClockTIMEDATE().Wait(ctx, wake_time.Sub(now), channel, scheduler.settings_ch) {
+			case WAIT_CANCELLED:
+				return nil, true
+			case 0:
+				return nil, false
+			case 1:
+				for item_id, item := range scheduler.items {
+					scheduler.updateItem(item_id, item, false)
+				}
+		}
+	}
+}
+
+/*
+RunDone records that an item ran, saving the time of the run and scheduling the next one.
+
+-----------------------------------------------------------
+
+– Params:
+  - item_id – the ID of the item
+*/
+func (scheduler *Scheduler) RunDone(item_id string) {
+	var item *_SchedItem = scheduler.items[item_id]
+	if nil == item {
+		return
+	}
+
+	item.last_run = GetClockTIMEDATE().Now().UnixMilli()
+	scheduler.last_runs[item_id] = item.last_run
+	scheduler.saveLastRuns()
+
+	if item.due_runs > 1 {
+		item.due_runs--
+
+		return
+	}
+
+	item.plan(GetClockTIMEDATE().Now())
+}
+
+/*
+updateItem applies the user settings to the default schedule of an item and plans its next run if the effective
+schedule changed.
+
+-----------------------------------------------------------
+
+– Params:
+  - item_id – the ID of the item
+  - item – the item
+  - force – true to plan the next run even if the schedule didn't change
+*/
+func (scheduler *Scheduler) updateItem(item_id string, item *_SchedItem, force bool) {
+	var schedule Schedule = getEffectiveScheduleSCHEDULER(item_id, item.def_schedule)
+	if !force && schedule == item.schedule {
+		return
+	}
+
+	cron, quiet_hours, err := parseScheduleSCHEDULER(schedule)
+	if nil != err {
+		GetModLoggerLOGS(scheduler.mod_num).Warning("Invalid schedule in the user settings - using the default one",
+			"item", item_id, "error", err)
+
+		schedule = item.def_schedule
+		if cron, quiet_hours, err = parseScheduleSCHEDULER(schedule); nil != err {
+			panic(errors.New("invalid default schedule of item " + item_id + ": " + err.Error()))
+		}
+	}
+	item.schedule = schedule
+	item.cron = cron
+	item.quiet_hours = quiet_hours
+
+	item.plan(GetClockTIMEDATE().Now())
+}
+
+/*
+saveLastRuns writes the last run times of the items to the module's user data directory, through a synced temporary
+file so that a crash or power loss in the middle doesn't lose all of them.
+*/
+func (scheduler *Scheduler) saveLastRuns() {
+	var user_data_dir GPath = GetUserDataDirMODULES(scheduler.mod_num)
+	var err error = user_data_dir.Create(false)
+	if nil == err {
+		err = ReplaceFileSyncedMODULES(user_data_dir.Add2(false, _SCHED_LAST_RUNS_FILE).GPathToStringConversion(),
+			[]byte(*ToJsonGENERAL(scheduler.last_runs)), 0o777)
+	}
+	if nil != err {
+		GetModLoggerLOGS(scheduler.mod_num).Warning("Error saving the scheduler last run times", "error", err)
+	}
+}
+
+/*
+plan calculates when the item must run next, based on its last run and its catch-up policy.
+
+-----------------------------------------------------------
+
+– Params:
+  - now – the current time
+*/
+func (item *_SchedItem) plan(now time.Time) {
+	item.due_runs = 1
+
+	var next_run time.Time
+	if 0 == item.last_run {
+		if nil == item.cron {
+			// Never ran and no specific times to run at, so run right away
+			next_run = now
+		} else {
+			next_run = item.nextOccurrence(now)
+		}
+	} else {
+		next_run = item.nextOccurrence(time.UnixMilli(item.last_run))
+		if !next_run.IsZero() && !next_run.After(now) {
+			// Runs were missed
+			switch item.schedule.Catch_up {
+				case CATCH_UP_SKIP:
+					next_run = item.nextOccurrence(now)
+				case CATCH_UP_ALL:
+					var missed_runs int = 0
+					for missed_run := next_run; !missed_run.IsZero() && !missed_run.After(now) &&
+							missed_runs < _SCHED_MAX_CATCH_UP_RUNS; missed_run = item.nextOccurrence(missed_run) {
+						missed_runs++
+					}
+					item.due_runs = missed_runs
+					next_run = now
+				default:
+					next_run = now
+			}
+		}
+	}
+
+	if !next_run.IsZero() {
+		if item.schedule.Jitter_s > 0 {
+			next_run = next_run.Add(time.Duration(rand.Int63n(item.schedule.Jitter_s * 1000)) * time.Millisecond)
+		}
+		if nil != item.quiet_hours {
+			next_run = item.quiet_hours.postpone(next_run)
+		}
+	}
+
+	item.next_run = next_run
+}
+
+/*
+nextOccurrence gets the next time the item's schedule says it must run, without jitter and quiet hours.
+
+-----------------------------------------------------------
+
+– Params:
+  - after – the time after which to search
+
+– Returns:
+  - the next time or the zero time if there is none
+*/
+func (item *_SchedItem) nextOccurrence(after time.Time) time.Time {
+	if nil != item.cron {
+		return item.cron.next(after)
+	}
+	if item.schedule.Interval_s <= 0 {
+		return time.Time{}
+	}
+
+	return after.Add(time.Duration(item.schedule.Interval_s) * time.Second)
+}
+
+/*
+getEffectiveScheduleSCHEDULER applies the user settings to the default schedule of an item.
+
+-----------------------------------------------------------
+
+– Params:
+  - item_id – the ID of the item
+  - def_schedule – the default schedule of the item
+
+– Returns:
+  - the schedule to use
+*/
+func getEffectiveScheduleSCHEDULER(item_id string, def_schedule Schedule) Schedule {
+	var schedule Schedule = def_schedule
+
+	var user_settings *UserSettings = GetUserSettingsSETTINGS()
+	if nil == user_settings {
+		return schedule
+	}
+
+	if "" == schedule.Quiet_hours {
+		schedule.Quiet_hours = user_settings.Scheduler.Quiet_hours
+	}
+
+	override, ok := user_settings.Scheduler.Items[item_id]
+	if !ok {
+		return schedule
+	}
+	if "" != override.Cron || 0 != override.Interval_s {
+		schedule.Cron = override.Cron
+		schedule.Interval_s = override.Interval_s
+	}
+	if 0 != override.Jitter_s {
+		schedule.Jitter_s = override.Jitter_s
+	}
+	if "" != override.Catch_up {
+		schedule.Catch_up = override.Catch_up
+	}
+	if "" != override.Quiet_hours {
+		schedule.Quiet_hours = override.Quiet_hours
+	}
+
+	return schedule
+}
+
+/*
+parseScheduleSCHEDULER checks a schedule and parses its cron expression and quiet hours.
+
+-----------------------------------------------------------
+
+– Params:
+  - schedule – the schedule
+
+– Returns:
+  - the parsed cron expression or nil if the schedule uses an interval
+  - the parsed quiet hours or nil if there are none
+  - an error if the schedule is invalid
+*/
+func parseScheduleSCHEDULER(schedule Schedule) (*_CronSchedule, *_QuietHours, error) {
+	var cron *_CronSchedule = nil
+	var quiet_hours *_QuietHours = nil
+	var err error = nil
+
+	if "" != schedule.Cron {
+		if cron, err = parseCronSCHEDULER(schedule.Cron); nil != err {
+			return nil, nil, err
+		}
+	} else if schedule.Interval_s <= 0 {
+		return nil, nil, errors.New("either a cron expression or an interval above 0 is required")
+	}
+	if schedule.Jitter_s < 0 {
+		return nil, nil, errors.New("the jitter must not be negative")
+	}
+	if "" != schedule.Catch_up && !ContainsSLICES(_CATCH_UP_POLICIES, schedule.Catch_up) {
+		return nil, nil, errors.New("unknown catch-up policy \"" + schedule.Catch_up + "\" (allowed: " +
+			strings.Join(_CATCH_UP_POLICIES, ", ") + ")")
+	}
+	if "" != schedule.Quiet_hours {
+		if quiet_hours, err = parseQuietHoursSCHEDULER(schedule.Quiet_hours); nil != err {
+			return nil, nil, err
+		}
+	}
+
+	return cron, quiet_hours, nil
+}
+
+/*
+parseCronSCHEDULER parses a cron expression.
+
+The expression has 5 fields: minute (0-59), hour (0-23), day of the month (1-31), month (1-12) and day of the week (0-7,
+0 and 7 being Sunday). Each field is a list of values separated by commas, each being "*", a number or a range "a-b",
+optionally followed by a step "/n". The macros @hourly, @daily (or @midnight), @weekly, @monthly and @yearly (or
+@annually) are also supported.
+
+-----------------------------------------------------------
+
+– Params:
+  - expression – the cron expression
+
+– Returns:
+  - the parsed expression
+  - an error if the expression is invalid
+*/
+func parseCronSCHEDULER(expression string) (*_CronSchedule, error) {
+	switch expression {
+		case "@hourly":
+			expression = "0 * * * *"
+		case "@daily", "@midnight":
+			expression = "0 0 * * *"
+		case "@weekly":
+			expression = "0 0 * * 0"
+		case "@monthly":
+			expression = "0 0 1 * *"
+		case "@yearly", "@annually":
+			expression = "0 0 1 1 *"
+	}
+
+	var fields []string = strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("the cron expression \"" + expression + "\" must have 5 fields, not " +
+			strconv.Itoa(len(fields)))
+	}
+
+	var cron _CronSchedule
+	var fields_ptrs []*uint64 = []*uint64{&cron.minutes, &cron.hours, &cron.days, &cron.months, &cron.weekdays}
+	var fields_names []string = []string{"minute", "hour", "day of the month", "month", "day of the week"}
+	var fields_mins []int = []int{0, 0, 1, 1, 0}
+	var fields_maxs []int = []int{59, 23, 31, 12, 7}
+	for i, field := range fields {
+		field_bits, err := parseCronFieldSCHEDULER(field, fields_mins[i], fields_maxs[i])
+		if nil != err {
+			return nil, errors.New("invalid " + fields_names[i] + " in the cron expression \"" + expression + "\": " +
+				err.Error())
+		}
+		*fields_ptrs[i] = field_bits
+	}
+	// Sunday can be both 0 and 7
+	if cron.weekdays & (1 << 7) != 0 {
+		cron.weekdays |= 1
+	}
+	cron.days_star = strings.HasPrefix(fields[2], "*")
+	cron.weekdays_star = strings.HasPrefix(fields[4], "*")
+
+	return &cron, nil
+}
+
+/*
+parseCronFieldSCHEDULER parses a field of a cron expression.
+
+-----------------------------------------------------------
+
+– Params:
+  - field – the field
+  - min_value – the minimum value of the field
+  - max_value – the maximum value of the field
+
+– Returns:
+  - the field with the bit of each allowed value set
+  - an error if the field is invalid
+*/
+func parseCronFieldSCHEDULER(field string, min_value int, max_value int) (uint64, error) {
+	var field_bits uint64 = 0
+	for _, part := range strings.Split(field, ",") {
+		range_str, step_str, has_step := strings.Cut(part, "/")
+		var step int = 1
+		if has_step {
+			var err error
+			if step, err = strconv.Atoi(step_str); nil != err || step < 1 {
+				return 0, errors.New("invalid step \"" + step_str + "\"")
+			}
+		}
+
+		var start int = min_value
+		var end int = max_value
+		if "*" != range_str {
+			start_str, end_str, is_range := strings.Cut(range_str, "-")
+			var err error
+			if start, err = strconv.Atoi(start_str); nil != err {
+				return 0, errors.New("invalid value \"" + start_str + "\"")
+			}
+			if is_range {
+				if end, err = strconv.Atoi(end_str); nil != err {
+					return 0, errors.New("invalid value \"" + end_str + "\"")
+				}
+			} else if !has_step {
+				end = start
+			}
+		}
+		if start > end {
+			return 0, errors.New("\"" + range_str + "\" is an empty range")
+		}
+		if start < min_value || end > max_value {
+			return 0, errors.New("\"" + range_str + "\" is outside " + strconv.Itoa(min_value) + "-" +
+				strconv.Itoa(max_value))
+		}
+
+		for value := start; value <= end; value += step {
+			field_bits |= 1 << uint(value)
+		}
+	}
+
+	return field_bits, nil
+}
+
+/*
+next gets the next time matching the cron expression.
+
+-----------------------------------------------------------
+
+– Params:
+  - after – the time after which to search (the matching times are at the beginning of a minute)
+
+– Returns:
+  - the next matching time or the zero time if there is none in the next _CRON_MAX_SEARCH_YEARS years
+*/
+func (cron *_CronSchedule) next(after time.Time) time.Time {
+	var loc *time.Location = after.Location()
+	var t time.Time = time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, loc)
+	var limit time.Time = t.AddDate(_CRON_MAX_SEARCH_YEARS, 0, 0)
+	for t.Before(limit) {
+		if cron.months & (1 << uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+
+			continue
+		}
+		if !cron.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+
+			continue
+		}
+		if cron.hours & (1 << uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+
+			continue
+		}
+		if cron.minutes & (1 << uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+/*
+dayMatches checks if the day of a time matches the day of the month and day of the week fields.
+
+-----------------------------------------------------------
+
+– Params:
+  - t – the time
+
+– Returns:
+  - true if the day matches, false otherwise
+*/
+func (cron *_CronSchedule) dayMatches(t time.Time) bool {
+	var day_matches bool = cron.days & (1 << uint(t.Day())) != 0
+	var weekday_matches bool = cron.weekdays & (1 << uint(t.Weekday())) != 0
+	if cron.days_star || cron.weekdays_star {
+		return day_matches && weekday_matches
+	}
+
+	return day_matches || weekday_matches
+}
+
+/*
+parseQuietHoursSCHEDULER parses a quiet hours range in the format "HH:MM-HH:MM".
+
+-----------------------------------------------------------
+
+– Params:
+  - quiet_hours_str – the range
+
+– Returns:
+  - the parsed range
+  - an error if the range is invalid
+*/
+func parseQuietHoursSCHEDULER(quiet_hours_str string) (*_QuietHours, error) {
+	start_str, end_str, ok := strings.Cut(quiet_hours_str, "-")
+	if !ok {
+		return nil, errors.New("the quiet hours \"" + quiet_hours_str + "\" are not in the format HH:MM-HH:MM")
+	}
+	start, err1 := time.Parse("15:04", strings.TrimSpace(start_str))
+	end, err2 := time.Parse("15:04", strings.TrimSpace(end_str))
+	if nil != err1 || nil != err2 {
+		return nil, errors.New("the quiet hours \"" + quiet_hours_str + "\" are not in the format HH:MM-HH:MM")
+	}
+
+	var quiet_hours _QuietHours = _QuietHours{
+		start_min: start.Hour()*60 + start.Minute(),
+		end_min:   end.Hour()*60 + end.Minute(),
+	}
+	if quiet_hours.start_min == quiet_hours.end_min {
+		return nil, errors.New("the quiet hours \"" + quiet_hours_str + "\" begin and end at the same time")
+	}
+
+	return &quiet_hours, nil
+}
+
+/*
+postpone moves a time inside the quiet hours to their end.
+
+-----------------------------------------------------------
+
+– Params:
+  - t – the time
+
+– Returns:
+  - the same time if it's outside the quiet hours, else the end of the quiet hours
+*/
+func (quiet_hours *_QuietHours) postpone(t time.Time) time.Time {
+	var minute_of_day int = t.Hour()*60 + t.Minute()
+	var inside bool
+	if quiet_hours.start_min < quiet_hours.end_min {
+		inside = minute_of_day >= quiet_hours.start_min && minute_of_day < quiet_hours.end_min
+	} else {
+		// Goes through midnight
+		inside = minute_of_day >= quiet_hours.start_min || minute_of_day < quiet_hours.end_min
+	}
+	if !inside {
+		return t
+	}
+
+	var end time.Time = time.Date(t.Year(), t.Month(), t.Day(), 0, quiet_hours.end_min, 0, 0, t.Location())
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end
+}
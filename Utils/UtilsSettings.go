@@ -0,0 +1,845 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+package Utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// USER_SETTINGS_FILE is the name of the user settings file, in the current working directory.
+const USER_SETTINGS_FILE string = "UserSettings_EOG.json"
+
+// _SETTINGS_DEBOUNCE_MS is the time to wait after the last change to the user settings file before reloading it (editors
+// may write it in more than one step).
+const _SETTINGS_DEBOUNCE_MS int64 = 500
+// _SETTINGS_POLL_S is the time between checks of the user settings file in case it can't be watched.
+const _SETTINGS_POLL_S int = 5
+
+// SECRET_REF_PREFFIX is the preffix of the settings values that are references to secrets of the secrets store (like
+// "secret:email_pw").
+const SECRET_REF_PREFFIX string = "secret:"
+
+// secrets_resolver_GL is the function that gets the value of a secret. Set it with SetSecretsResolverSETTINGS().
+var secrets_resolver_GL atomic.Pointer[func(name string) (string, bool)]
+
+// user_settings_GL are the current user settings. Only replace them through SetUserSettingsSETTINGS().
+var user_settings_GL atomic.Pointer[UserSettings]
+
+// _SettingsSub is a subscription to the changes of a section of the user settings.
+type _SettingsSub struct {
+	section string
+	channel chan struct{}
+}
+
+var settings_subs_GL []_SettingsSub = nil
+var settings_subs_mutex_GL sync.Mutex
+
+func init() {
+	user_settings_GL.Store(&UserSettings{})
+}
+
+// Variables that can be used in the device conditions (replaced by their values by MOD_9).
+const (
+	CONDITION_VAR_POWER_CONNECTED   string = "power_connected"
+	CONDITION_VAR_BATTERY_LEVEL     string = "battery_level"
+	CONDITION_VAR_SCREEN_BRIGHTNESS string = "screen_brightness"
+	CONDITION_VAR_SOUND_VOLUME      string = "sound_volume"
+	CONDITION_VAR_SOUND_MUTED       string = "sound_muted"
+	// CONDITION_VAR_BATTERY_DROP_1H is how much the battery level dropped in the last hour
+	CONDITION_VAR_BATTERY_DROP_1H   string = "battery_drop_1h"
+)
+
+// CONDITION_VARS are all the variables that can be used in the device conditions. MOD_9 must give a value to each of
+// them.
+var CONDITION_VARS []string = []string{CONDITION_VAR_POWER_CONNECTED, CONDITION_VAR_BATTERY_LEVEL,
+	CONDITION_VAR_SCREEN_BRIGHTNESS, CONDITION_VAR_SOUND_VOLUME, CONDITION_VAR_SOUND_MUTED,
+	CONDITION_VAR_BATTERY_DROP_1H}
+
+// Keep these in sync with the ones used by the modules.
+var (
+	// _FEED_TYPES_1 are the allowed first words of MOD_4's Feed_type
+	_FEED_TYPES_1 []string = []string{"General", "YouTube"}
+	// _FEED_TYPES_2_YT are the allowed second words of MOD_4's Feed_type for YouTube feeds
+	_FEED_TYPES_2_YT []string = []string{"CH", "PL"}
+	// _FEED_TYPES_3_YT are the allowed third words of MOD_4's Feed_type for YouTube feeds
+	_FEED_TYPES_3_YT []string = []string{"+S"}
+	// _BEACON_TYPES are the allowed values of MOD_12's Locs_info[].Type
+	_BEACON_TYPES []string = []string{"wifi", "bluetooth"}
+	// _SETTINGS_MODS_PATHS maps the paths of the general settings that are only used by one module to that module
+	_SETTINGS_MODS_PATHS map[string]int = map[string]int{
+		"PersonalConsts.WolframAlpha_AppID": NUM_MOD_OnlineInfoChk,
+		"PersonalConsts.Picovoice_API_key":  NUM_MOD_SpeechRecognition,
+	}
+)
+
+// _MAC_ADDRESS_REGEX matches addresses in the format XX:XX:XX:XX:XX:XX.
+var _MAC_ADDRESS_REGEX *regexp.Regexp = regexp.MustCompile(`^([0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}$`)
+
+// SettingsProblem is a problem found in the user settings.
+type SettingsProblem struct {
+	// Path is the JSON path to the problematic value (e.g. "MOD_4.Feeds_info[2].Feed_url")
+	Path string
+	// Msg is the description of the problem
+	Msg string
+	// Mod_num is the number of the module whose settings have the problem, or NUM_MOD_VISOR if it's in the general
+	// settings (which VISOR can't run without)
+	Mod_num int
+}
+
+func (problem SettingsProblem) String() string {
+	return problem.Path + ": " + problem.Msg
+}
+
+/*
+GetUserSettingsSETTINGS gets the current user settings.
+
+The returned settings are shared and must NOT be modified - use SetUserSettingsSETTINGS() with a copy for that. Get
+them again each time they're needed instead of keeping them, so that changes to the settings file are seen.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the current user settings
+*/
+func GetUserSettingsSETTINGS() *UserSettings {
+	return user_settings_GL.Load()
+}
+
+/*
+SetUserSettingsSETTINGS atomically replaces the current user settings and notifies the subscribers of the sections that
+changed.
+
+-----------------------------------------------------------
+
+– Params:
+  - user_settings – the new user settings
+*/
+func SetUserSettingsSETTINGS(user_settings UserSettings) {
+	var old_settings *UserSettings = user_settings_GL.Swap(&user_settings)
+
+	var old_value reflect.Value = reflect.ValueOf(*old_settings)
+	var new_value reflect.Value = reflect.ValueOf(user_settings)
+
+	settings_subs_mutex_GL.Lock()
+	defer settings_subs_mutex_GL.Unlock()
+
+	for _, settings_sub := range settings_subs_GL {
+		var old_section reflect.Value = old_value.FieldByName(settings_sub.section)
+		var new_section reflect.Value = new_value.FieldByName(settings_sub.section)
+		if !old_section.IsValid() || reflect.DeepEqual(old_section.Interface(), new_section.Interface()) {
+			continue
+		}
+
+		select {
+			case settings_sub.channel <- struct{}{}:
+			default:
+				// Already notified and not received yet
+		}
+	}
+}
+
+/*
+SubscribeSettingsSETTINGS subscribes to the changes of a section of the user settings.
+
+-----------------------------------------------------------
+
+– Params:
+  - section – the name of the section (the name of the UserSettings field, like "PersonalConsts" or "MOD_4")
+
+– Returns:
+  - a channel that receives a value each time the section changes (multiple changes before the value is received are
+    merged into one)
+*/
+func SubscribeSettingsSETTINGS(section string) chan struct{} {
+	var channel chan struct{} = make(chan struct{}, 1)
+
+	settings_subs_mutex_GL.Lock()
+	settings_subs_GL = append(settings_subs_GL, _SettingsSub{
+		section: section,
+		channel: channel,
+	})
+	settings_subs_mutex_GL.Unlock()
+
+	return channel
+}
+
+/*
+UnsubscribeSettingsSETTINGS removes a subscription made with SubscribeSettingsSETTINGS().
+
+-----------------------------------------------------------
+
+– Params:
+  - channel – the channel of the subscription
+*/
+func UnsubscribeSettingsSETTINGS(channel chan struct{}) {
+	settings_subs_mutex_GL.Lock()
+	defer settings_subs_mutex_GL.Unlock()
+
+	for i, settings_sub := range settings_subs_GL {
+		if settings_sub.channel == channel {
+			settings_subs_GL = append(settings_subs_GL[:i], settings_subs_GL[i + 1:]...)
+
+			break
+		}
+	}
+}
+
+/*
+ReadUserSettingsSETTINGS reads and validates the user settings file.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true if the settings are for the server version, false if they're for the client version
+
+– Returns:
+  - the user settings read (only valid if there are no problems)
+  - the problems found in the settings file, or nil if there are none
+*/
+func ReadUserSettingsSETTINGS(server bool) (UserSettings, []SettingsProblem) {
+	var user_settings UserSettings
+
+	bytes, err := os.ReadFile(USER_SETTINGS_FILE)
+	if nil != err {
+		return user_settings, []SettingsProblem{{Path: "$", Msg: "error reading " + USER_SETTINGS_FILE + ": " +
+			err.Error()}}
+	}
+
+	if err = FromJsonGENERAL(bytes, &user_settings); nil != err {
+		var path string = "$"
+		var type_error *json.UnmarshalTypeError
+		if errors.As(err, &type_error) && "" != type_error.Field {
+			path = type_error.Field
+		}
+
+		return user_settings, []SettingsProblem{{Path: path, Msg: "invalid JSON: " + err.Error()}}
+	}
+
+	var problems []SettingsProblem = nil
+	resolveSecretRefsSETTINGS(reflect.ValueOf(&user_settings).Elem(), "", &problems)
+
+	return user_settings, append(problems, ValidateUserSettingsSETTINGS(user_settings, server)...)
+}
+
+/*
+SetSecretsResolverSETTINGS sets the function used to get the values of the secrets referenced in the settings files
+with SECRET_REF_PREFFIX.
+
+-----------------------------------------------------------
+
+– Params:
+  - resolver – the function that gets the value of a secret by its name, returning false if it doesn't exist
+*/
+func SetSecretsResolverSETTINGS(resolver func(name string) (string, bool)) {
+	secrets_resolver_GL.Store(&resolver)
+}
+
+/*
+resolveSecretRefsSETTINGS replaces the references to secrets in all the strings of a value (recursively) by the values
+of the secrets.
+
+-----------------------------------------------------------
+
+– Params:
+  - value – the value (must be settable, like a struct got through a pointer)
+  - path – the JSON path to the value
+  - problems – the list to which the secrets that couldn't be resolved are added
+*/
+func resolveSecretRefsSETTINGS(value reflect.Value, path string, problems *[]SettingsProblem) {
+	switch value.Kind() {
+		case reflect.String:
+			var str string = value.String()
+			if !strings.HasPrefix(str, SECRET_REF_PREFFIX) {
+				return
+			}
+
+			var name string = str[len(SECRET_REF_PREFFIX):]
+			var p_resolver *func(name string) (string, bool) = secrets_resolver_GL.Load()
+			if nil == p_resolver {
+				*problems = append(*problems, newSettingsProblem(path, "references the secret \"" + name +
+					"\" but the secrets store is locked or doesn't exist"))
+
+				return
+			}
+			secret, ok := (*p_resolver)(name)
+			if !ok {
+				*problems = append(*problems, newSettingsProblem(path, "the secret \"" + name +
+					"\" doesn't exist in the secrets store"))
+
+				return
+			}
+			value.SetString(secret)
+		case reflect.Struct:
+			for i := 0; i < value.NumField(); i++ {
+				if value.Field(i).CanSet() {
+					resolveSecretRefsSETTINGS(value.Field(i), joinSettingsPath(path, value.Type().Field(i).Name),
+						problems)
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < value.Len(); i++ {
+				resolveSecretRefsSETTINGS(value.Index(i), path + "[" + strconv.Itoa(i) + "]", problems)
+			}
+		case reflect.Map:
+			// Map values can't be set directly, so copy, resolve and put them back
+			for _, key := range value.MapKeys() {
+				var elem reflect.Value = reflect.New(value.Type().Elem()).Elem()
+				elem.Set(value.MapIndex(key))
+				resolveSecretRefsSETTINGS(elem, joinSettingsPath(path, fmt.Sprint(key.Interface())), problems)
+				value.SetMapIndex(key, elem)
+			}
+		default:
+			// Nothing to resolve
+	}
+}
+
+/*
+joinSettingsPath joins a JSON path with the name of a field.
+
+-----------------------------------------------------------
+
+– Params:
+  - path – the JSON path
+  - name – the name of the field
+
+– Returns:
+  - the joined path
+*/
+func joinSettingsPath(path string, name string) string {
+	if "" == path {
+		return name
+	}
+
+	return path + "." + name
+}
+
+/*
+ValidateUserSettingsSETTINGS checks the user settings for missing required fields, invalid emails and URLs, unknown
+values and unparseable conditions.
+
+Problems in the settings of a module have its number in SettingsProblem.Mod_num and only matter if the module is used
+(see splitSettingsProblems()).
+
+-----------------------------------------------------------
+
+– Params:
+  - user_settings – the user settings to validate
+  - server – true if the settings are for the server version, false if they're for the client version
+
+– Returns:
+  - the problems found in the settings, or nil if there are none
+*/
+func ValidateUserSettingsSETTINGS(user_settings UserSettings, server bool) []SettingsProblem {
+	var validator _SettingsValidator
+
+	var personal_consts _PersonalConsts = user_settings.PersonalConsts
+	validator.required("PersonalConsts.Device_ID", personal_consts.Device_ID)
+	validator.dirExists("PersonalConsts.VISOR_dir", personal_consts.VISOR_dir)
+	validator.email("PersonalConsts.User_email_addr", personal_consts.User_email_addr)
+	validator.url("PersonalConsts.Website_url", personal_consts.Website_url)
+	validator.required("PersonalConsts.Website_pw", personal_consts.Website_pw)
+	if server {
+		validator.email("PersonalConsts.VISOR_email_addr", personal_consts.VISOR_email_addr)
+		validator.required("PersonalConsts.VISOR_email_pw", personal_consts.VISOR_email_pw)
+		validator.dirExists("PersonalConsts.Website_dir", personal_consts.Website_dir)
+		validator.required("PersonalConsts.WolframAlpha_AppID", personal_consts.WolframAlpha_AppID)
+	} else {
+		validator.required("PersonalConsts.Picovoice_API_key", personal_consts.Picovoice_API_key)
+	}
+
+	if "" != user_settings.Scheduler.Quiet_hours {
+		if _, err := parseQuietHoursSCHEDULER(user_settings.Scheduler.Quiet_hours); nil != err {
+			validator.problem("Scheduler.Quiet_hours", err.Error())
+		}
+	}
+	for item_id, schedule := range user_settings.Scheduler.Items {
+		validator.scheduleOverride("Scheduler.Items." + item_id, schedule)
+	}
+
+	for i, mail_to := range user_settings.MOD_4.Mails_to {
+		validator.email("MOD_4.Mails_to[" + strconv.Itoa(i) + "]", mail_to)
+	}
+	var feed_nums map[int]bool = make(map[int]bool)
+	for i, feed_info := range user_settings.MOD_4.Feeds_info {
+		var path string = "MOD_4.Feeds_info[" + strconv.Itoa(i) + "]"
+		if feed_info.Feed_num < 1 {
+			validator.problem(path + ".Feed_num", "must be 1 or more")
+		} else if feed_nums[feed_info.Feed_num] {
+			validator.problem(path + ".Feed_num", "repeated feed number " + strconv.Itoa(feed_info.Feed_num))
+		}
+		feed_nums[feed_info.Feed_num] = true
+		validator.url(path + ".Feed_url", feed_info.Feed_url)
+		validator.feedType(path + ".Feed_type", feed_info.Feed_type)
+	}
+
+	for i, temp_loc := range user_settings.MOD_6.Temp_locs {
+		validator.required("MOD_6.Temp_locs[" + strconv.Itoa(i) + "]", temp_loc)
+	}
+	for i, news_loc := range user_settings.MOD_6.News_locs {
+		validator.required("MOD_6.News_locs[" + strconv.Itoa(i) + "].News_str", news_loc.News_str)
+	}
+
+	if server {
+		validator.fileExists("MOD_7.Model_loc", user_settings.MOD_7.Model_loc)
+	}
+
+	for i, notification := range user_settings.MOD_10.Notifications {
+		var path string = "MOD_10.Notifications[" + strconv.Itoa(i) + "]"
+		validator.condition(path + ".Condition", notification.Condition)
+		validator.required(path + ".Speak", notification.Speak)
+	}
+
+	for i, loc_info := range user_settings.MOD_12.Locs_info {
+		var path string = "MOD_12.Locs_info[" + strconv.Itoa(i) + "]"
+		validator.oneOf(path + ".Type", loc_info.Type, _BEACON_TYPES)
+		if "" == loc_info.Name && "" == loc_info.Address {
+			validator.problem(path, "either Name or Address is required")
+		}
+		if "" != loc_info.Address && !_MAC_ADDRESS_REGEX.MatchString(loc_info.Address) {
+			validator.problem(path + ".Address", "\"" + loc_info.Address + "\" is not in the format XX:XX:XX:XX:XX:XX")
+		}
+		if loc_info.Last_detection < 0 {
+			validator.problem(path + ".Last_detection", "must not be negative")
+		}
+		if loc_info.Max_distance <= 0 {
+			validator.problem(path + ".Max_distance", "must be more than 0")
+		}
+		validator.required(path + ".Location", loc_info.Location)
+	}
+
+	return validator.problems
+}
+
+/*
+CheckUserSettingsSETTINGS reads and validates the user settings file and prints the result, for the --check-config
+option of the main programs.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true if the settings are for the server version, false if they're for the client version
+
+– Returns:
+  - true if the settings are valid, false otherwise
+*/
+func CheckUserSettingsSETTINGS(server bool) bool {
+	_, problems := ReadUserSettingsSETTINGS(server)
+	if len(problems) == 0 {
+		fmt.Println(USER_SETTINGS_FILE + " is valid.")
+
+		return true
+	}
+
+	general_problems, mods_problems := splitSettingsProblems(problems)
+	if len(general_problems) > 0 {
+		fmt.Println(USER_SETTINGS_FILE + " has " + strconv.Itoa(len(general_problems)) + " problem(s):")
+		for _, problem := range general_problems {
+			fmt.Println("- " + problem.String())
+		}
+	}
+	if len(mods_problems) > 0 {
+		fmt.Println(USER_SETTINGS_FILE + " has " + strconv.Itoa(len(mods_problems)) + " problem(s) in the settings " +
+			"of modules (VISOR starts anyway, but the modules may not work properly if they're enabled):")
+		for _, problem := range mods_problems {
+			fmt.Println("- " + problem.String() + " (" + GetModNameMODULES(problem.Mod_num) + ")")
+		}
+	}
+
+	return len(general_problems) == 0
+}
+
+/*
+watchUserSettingsSETTINGS reloads the user settings each time the user settings file changes. If the new settings are
+invalid, the old ones are kept and the problems are logged. Never returns.
+
+If the file can't be watched, it's checked for changes each _SETTINGS_POLL_S seconds instead.
+
+-----------------------------------------------------------
+
+– Params:
+  - server – true if it's the server version running, false if it's the client version
+*/
+func watchUserSettingsSETTINGS(server bool) {
+	var reload func() = func() {
+		if err := getUserSettings(server); nil != err {
+			log.Println(err.Error() + "\nKeeping the previous settings.")
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if nil == err {
+		// Watch the directory and not the file, or the watch is lost when editors replace the file with a new one
+		err = watcher.Add(filepath.Dir(USER_SETTINGS_FILE))
+	}
+	if nil != err {
+		log.Println("Couldn't watch " + USER_SETTINGS_FILE + " for changes (" + err.Error() + "). Checking it each " +
+			strconv.Itoa(_SETTINGS_POLL_S) + " seconds instead.")
+
+		var last_modif time.Time
+		for {
+			if file_stats, err := os.Stat(USER_SETTINGS_FILE); nil == err && !file_stats.ModTime().Equal(last_modif) {
+				if !last_modif.IsZero() {
+					reload()
+				}
+				last_modif = file_stats.ModTime()
+			}
+
+			time.Sleep(time.Duration(_SETTINGS_POLL_S) * time.Second)
+		}
+	}
+
+	var debounce_timer *time.Timer = time.NewTimer(0)
+	<-debounce_timer.C
+	for {
+		select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(event.Name) == USER_SETTINGS_FILE && event.Op & (fsnotify.Write | fsnotify.Create) != 0 {
+					debounce_timer.Reset(time.Duration(_SETTINGS_DEBOUNCE_MS) * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("Error watching " + USER_SETTINGS_FILE + ": " + err.Error())
+			case <-debounce_timer.C:
+				reload()
+		}
+	}
+}
+
+/*
+getModSettingsSectionNameSETTINGS gets the name of the section of the user settings of a module.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the name of the section (the name of the UserSettings field)
+*/
+func getModSettingsSectionNameSETTINGS(mod_num int) string {
+	return "MOD_" + strconv.Itoa(mod_num)
+}
+
+/*
+getModSettingsSectionSETTINGS gets the section of the current user settings of a module in JSON.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_num – the number of the module
+
+– Returns:
+  - the section in JSON or nil if the module has no section or it's empty
+*/
+func getModSettingsSectionSETTINGS(mod_num int) *string {
+	var section reflect.Value = reflect.ValueOf(*GetUserSettingsSETTINGS()).
+		FieldByName(getModSettingsSectionNameSETTINGS(mod_num))
+	if !section.IsValid() || section.IsZero() {
+		return nil
+	}
+
+	return ToJsonGENERAL(section.Interface())
+}
+
+/*
+settingsProblemsToError joins settings problems into an error.
+
+-----------------------------------------------------------
+
+– Params:
+  - file_name – the name of the settings file with the problems
+  - problems – the problems
+
+– Returns:
+  - the error with one problem per line, or nil if there are no problems
+*/
+func settingsProblemsToError(file_name string, problems []SettingsProblem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	var problems_str []string = nil
+	for _, problem := range problems {
+		problems_str = append(problems_str, problem.String())
+	}
+
+	return errors.New("invalid " + file_name + ":\n" + strings.Join(problems_str, "\n"))
+}
+
+/*
+newSettingsProblem creates a settings problem, finding the module whose settings have it from the path.
+
+-----------------------------------------------------------
+
+– Params:
+  - path – the JSON path to the problematic value
+  - msg – the description of the problem
+
+– Returns:
+  - the problem
+*/
+func newSettingsProblem(path string, msg string) SettingsProblem {
+	var mod_num int = NUM_MOD_VISOR
+	if path_mod_num, ok := _SETTINGS_MODS_PATHS[path]; ok {
+		mod_num = path_mod_num
+	} else {
+		// Like "MOD_4.Feeds_info[2].Feed_url" or "Scheduler.Items.MOD_4.feed_3"
+		var section string = strings.TrimPrefix(path, "Scheduler.Items.")
+		section = strings.SplitN(strings.SplitN(section, ".", 2)[0], "[", 2)[0]
+		if strings.HasPrefix(section, _MOD_FOLDER_PREFFIX) {
+			if section_mod_num, err := strconv.Atoi(section[len(_MOD_FOLDER_PREFFIX):]); nil == err &&
+					section_mod_num > NUM_MOD_VISOR {
+				mod_num = section_mod_num
+			}
+		}
+	}
+
+	return SettingsProblem{Path: path, Msg: msg, Mod_num: mod_num}
+}
+
+/*
+splitSettingsProblems separates the problems in the general settings from the ones in the settings of modules.
+
+-----------------------------------------------------------
+
+– Params:
+  - problems – the problems
+
+– Returns:
+  - the problems in the general settings, with which the settings can't be used
+  - the problems in the settings of modules, which only affect those modules
+*/
+func splitSettingsProblems(problems []SettingsProblem) ([]SettingsProblem, []SettingsProblem) {
+	var general_problems []SettingsProblem = nil
+	var mods_problems []SettingsProblem = nil
+	for _, problem := range problems {
+		if NUM_MOD_VISOR == problem.Mod_num {
+			general_problems = append(general_problems, problem)
+		} else {
+			mods_problems = append(mods_problems, problem)
+		}
+	}
+
+	return general_problems, mods_problems
+}
+
+/*
+warnModsSettingsProblemsSETTINGS logs the problems in the settings of the modules that are supported by this version
+and machine and are enabled, to their logs. The ones of the other modules are ignored, as their settings aren't used.
+
+Call it only after the settings are set, so that the modules' enabled state is read from the right VISOR directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - problems – the problems in the settings of modules
+*/
+func warnModsSettingsProblemsSETTINGS(problems []SettingsProblem) {
+	if len(problems) == 0 {
+		return
+	}
+
+	var version_support int = MOD_CLIENT
+	if GetUserSettingsSETTINGS().PersonalConsts.VISOR_server {
+		version_support = MOD_SERVER
+	}
+
+	var mods_enabled map[string]bool = GetSavedModsEnabledMODULES()
+	var mods_used map[int]bool = make(map[int]bool)
+	for _, problem := range problems {
+		mod_used, checked := mods_used[problem.Mod_num]
+		if !checked {
+			// Modules not in the saved states are enabled
+			enabled, saved := mods_enabled[GetModEnabledKeyMODULES(problem.Mod_num)]
+			mod_used = (!saved || enabled) && (MOD_NUMS_SUPPORT[problem.Mod_num] & version_support != 0) &&
+				IsModSupportedMODULES(problem.Mod_num)
+			mods_used[problem.Mod_num] = mod_used
+		}
+		if mod_used {
+			GetModLoggerLOGS(problem.Mod_num).Warning("Invalid user settings - the module may not work properly",
+				"problem", problem.String())
+		}
+	}
+}
+
+// _SettingsValidator accumulates the problems found while validating the user settings.
+type _SettingsValidator struct {
+	problems []SettingsProblem
+}
+
+func (validator *_SettingsValidator) problem(path string, msg string) {
+	validator.problems = append(validator.problems, newSettingsProblem(path, msg))
+}
+
+func (validator *_SettingsValidator) required(path string, value string) bool {
+	if "" == strings.TrimSpace(value) {
+		validator.problem(path, "required but empty")
+
+		return false
+	}
+
+	return true
+}
+
+func (validator *_SettingsValidator) email(path string, value string) {
+	if !validator.required(path, value) {
+		return
+	}
+
+	if _, err := mail.ParseAddress(value); nil != err {
+		validator.problem(path, "\"" + value + "\" is not a valid email address")
+	}
+}
+
+func (validator *_SettingsValidator) url(path string, value string) {
+	if !validator.required(path, value) {
+		return
+	}
+
+	parsed_url, err := url.Parse(value)
+	if nil != err || ("http" != parsed_url.Scheme && "https" != parsed_url.Scheme) || "" == parsed_url.Host {
+		validator.problem(path, "\"" + value + "\" is not a valid HTTP(S) URL")
+	}
+}
+
+func (validator *_SettingsValidator) dirExists(path string, value string) {
+	if !validator.required(path, value) {
+		return
+	}
+
+	if !PathFILESDIRS(true, "", value).Exists() {
+		validator.problem(path, "the directory \"" + value + "\" does not exist")
+	}
+}
+
+func (validator *_SettingsValidator) fileExists(path string, value string) {
+	if !validator.required(path, value) {
+		return
+	}
+
+	if !PathFILESDIRS(false, "", value).Exists() {
+		validator.problem(path, "the file \"" + value + "\" does not exist")
+	}
+}
+
+func (validator *_SettingsValidator) oneOf(path string, value string, allowed []string) bool {
+	if !ContainsSLICES(allowed, value) {
+		validator.problem(path, "unknown value \"" + value + "\" (allowed: " + strings.Join(allowed, ", ") + ")")
+
+		return false
+	}
+
+	return true
+}
+
+func (validator *_SettingsValidator) feedType(path string, value string) {
+	var words []string = strings.Split(value, " ")
+	if !validator.oneOf(path, words[0], _FEED_TYPES_1) {
+		return
+	}
+
+	if "YouTube" == words[0] {
+		if len(words) < 2 {
+			validator.problem(path, "YouTube feeds need the type of feed (allowed: " + strings.Join(_FEED_TYPES_2_YT, ", ") + ")")
+
+			return
+		}
+		validator.oneOf(path, words[1], _FEED_TYPES_2_YT)
+		if len(words) >= 3 {
+			validator.oneOf(path, words[2], _FEED_TYPES_3_YT)
+		}
+		if len(words) > 3 {
+			validator.problem(path, "too many words in \"" + value + "\"")
+		}
+	} else if len(words) > 1 {
+		validator.problem(path, "too many words in \"" + value + "\"")
+	}
+}
+
+func (validator *_SettingsValidator) scheduleOverride(path string, value Schedule) {
+	// Only the fields that are set override the defaults, so fill the others with valid values before checking
+	if "" == value.Cron && 0 == value.Interval_s {
+		value.Cron = "@hourly"
+	}
+	if _, _, err := parseScheduleSCHEDULER(value); nil != err {
+		validator.problem(path, err.Error())
+	}
+}
+
+func (validator *_SettingsValidator) condition(path string, value string) {
+	if !validator.required(path, value) {
+		return
+	}
+
+	expr, err := parser.ParseExpr(value)
+	if nil != err {
+		validator.problem(path, "unparseable condition: " + err.Error())
+
+		return
+	}
+
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch node := node.(type) {
+			case *ast.Ident:
+				if "true" != node.Name && "false" != node.Name && !ContainsSLICES(CONDITION_VARS, node.Name) {
+					validator.problem(path, "unknown variable \"" + node.Name + "\" (allowed: " +
+						strings.Join(CONDITION_VARS, ", ") + ")")
+				}
+			case *ast.BasicLit:
+				if token.INT != node.Kind && token.FLOAT != node.Kind {
+					validator.problem(path, "only numbers and booleans are allowed, got " + node.Value)
+				}
+			case *ast.BinaryExpr, *ast.UnaryExpr, *ast.ParenExpr, nil:
+				// Allowed
+			default:
+				// ParseExpr() positions are the offsets in the string plus 1
+				validator.problem(path, "unsupported expression \"" + value[node.Pos() - 1:node.End() - 1] + "\"")
+
+				return false
+		}
+
+		return true
+	})
+}
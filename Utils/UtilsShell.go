@@ -23,14 +23,20 @@ package Utils
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 )
 
 const GENERIC_ERR int = -230984
 
+// _CMD_WAIT_DELAY is the maximum time to wait for the output of a command to be closed after the command was killed
+// because its context was cancelled.
+const _CMD_WAIT_DELAY time.Duration = 5 * time.Second
+
 // CmdOutput is a struct containing the exit code, stdout and stderr of a command.
 type CmdOutput struct {
 	// Stdout_str is the stdout of the command as a string, with all line breaks replaced by \n.
@@ -58,17 +64,18 @@ PowerShell but the actual program in Linux, for example - but curl.exe calls the
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context to kill the shell with when it's cancelled (use context.Background() to never kill it)
   - commands_list – the commands to execute
 
 – Returns:
   - the CmdOutput struct containing the stdout, stderr and error code of the command. Note that their string versions
     have all line endings replaced with "\n".
   - the error returned by the command execution, if any. Will be nil in case everything related to the command execution
-    went smoothly - CmdOutput.Exit_code can still be non-zero! Will be non-nil if a major error occurred. in which case
-    CmdOutput.Exit_code = GENERIC_ERR.
+    went smoothly - CmdOutput.Exit_code can still be non-zero! Will be non-nil if a major error occurred or if the
+    context was cancelled, in which case CmdOutput.Exit_code = GENERIC_ERR.
 */
-func ExecCmdSHELL(commands_list[] string) (CmdOutput, error) {
-	return ExecCmdMainSHELL(commands_list, "", "")
+func ExecCmdSHELL(ctx context.Context, commands_list[] string) (CmdOutput, error) {
+	return ExecCmdMainSHELL(ctx, commands_list, "", "")
 }
 
 /*
@@ -78,6 +85,7 @@ ExecCmdSHELL for more information.
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context to kill the shell with when it's cancelled
   - commands_list – the commansd to execute
   - windows_shell – the Windows shell, or "" to use the default (powershell.exe)
   - linux_shell – the Linux shell, or "" to use the default (bash)
@@ -85,7 +93,7 @@ ExecCmdSHELL for more information.
 – Returns:
   - the CmdOutput struct containing the stdout, stderr and error code of the command. Note that their string versions
 */
-func ExecCmdMainSHELL(commands_list[] string, windows_shell string, linux_shell string) (CmdOutput, error) {
+func ExecCmdMainSHELL(ctx context.Context, commands_list[] string, windows_shell string, linux_shell string) (CmdOutput, error) {
 	var shell string = GetShell(windows_shell, linux_shell)
 
 	var commands_str string = ""
@@ -104,7 +112,8 @@ func ExecCmdMainSHELL(commands_list[] string, windows_shell string, linux_shell
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 
-	cmd := exec.Command(shell)
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.WaitDelay = _CMD_WAIT_DELAY
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	cmd.Stdin = strings.NewReader(commands_str)
@@ -116,7 +125,11 @@ func ExecCmdMainSHELL(commands_list[] string, windows_shell string, linux_shell
 	stderr_str = strings.ReplaceAll(stderr_str, "\r", "\n")
 
 	var exit_code int = 0
-	if err != nil {
+	if ctx.Err() != nil {
+		// Killed because the context was cancelled - the output is incomplete
+		err = ctx.Err()
+		exit_code = GENERIC_ERR
+	} else if err != nil {
 		var exiterr *exec.ExitError
 		if errors.As(err, &exiterr) {
 			exit_code = exiterr.ExitCode()
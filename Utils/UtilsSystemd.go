@@ -0,0 +1,271 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package Utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// _JOURNALD_SOCKET is the socket of journald for the native protocol.
+const _JOURNALD_SOCKET string = "/run/systemd/journal/socket"
+// _JOURNALD_IDENTIFIER is the SYSLOG_IDENTIFIER of the log entries sent to journald.
+const _JOURNALD_IDENTIFIER string = "visor"
+
+// SYSTEMD_UNIT_FILE is the default path of the unit file installed by InstallUnitSYSTEMD().
+const SYSTEMD_UNIT_FILE string = "/etc/systemd/system/visor.service"
+
+// _JOURNALD_PRIORITIES maps the LOG_LEVEL_ constants to the syslog priorities used by journald.
+var _JOURNALD_PRIORITIES map[int]int = map[int]int{
+	LOG_LEVEL_DEBUG:   7,
+	LOG_LEVEL_INFO:    6,
+	LOG_LEVEL_WARNING: 4,
+	LOG_LEVEL_ERROR:   3,
+}
+
+/*
+SdNotifySYSTEMD sends a notification to systemd (like "READY=1"), if VISOR was started by systemd with a notification
+socket (Type=notify services). Else it does nothing.
+
+-----------------------------------------------------------
+
+– Params:
+  - state – the notification, with one or more "VARIABLE=value" lines
+
+– Returns:
+  - nil if the notification was sent or there's no socket to send it to, an error otherwise
+*/
+func SdNotifySYSTEMD(state string) error {
+	var socket_addr string = os.Getenv("NOTIFY_SOCKET")
+	if "" == socket_addr {
+		return nil
+	}
+	if strings.HasPrefix(socket_addr, "@") {
+		// Abstract socket
+		socket_addr = "\x00" + socket_addr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket_addr, Net: "unixgram"})
+	if nil != err {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+
+	return err
+}
+
+/*
+NotifyModulesStatusSYSTEMD sends to systemd a STATUS line summarising the state of the modules (shown by
+"systemctl status").
+
+-----------------------------------------------------------
+
+– Params:
+  - modules – the list of modules
+*/
+func NotifyModulesStatusSYSTEMD(modules []Module) {
+	var num_running int = 0
+	var problems []string = nil
+	for i := 1; i < len(modules); i++ {
+		var module *Module = &modules[i]
+		switch module.GetState() {
+			case MOD_STATE_RUNNING:
+				num_running++
+			case MOD_STATE_CRASHED, MOD_STATE_HUNG:
+				problems = append(problems, module.Name + " " + strings.ToLower(MOD_STATES_NAMES[module.GetState()]))
+		}
+	}
+
+	var status string = strconv.Itoa(num_running) + " modules running"
+	if nil != problems {
+		status += " (" + strings.Join(problems, ", ") + ")"
+	}
+	_ = SdNotifySYSTEMD("STATUS=" + status)
+}
+
+/*
+StartWatchdogSYSTEMD starts pinging the systemd watchdog, if it's enabled for VISOR (WatchdogSec in the unit file).
+
+The pings are only sent while the Manager is sending its heartbeats, so that systemd restarts VISOR if the Manager (and
+so the supervision of the other modules) gets stuck.
+
+-----------------------------------------------------------
+
+– Params:
+  - modules – the list of modules
+*/
+func StartWatchdogSYSTEMD(modules []Module) {
+	var watchdog_usec int64 = 0
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); nil == err {
+		watchdog_usec = usec
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); "" != pid && strconv.Itoa(os.Getpid()) != pid {
+		// The watchdog is for another process
+		return
+	}
+	if watchdog_usec <= 0 {
+		return
+	}
+
+	go func() {
+		var manager *Module = &modules[NUM_MOD_ModManager]
+		for {
+			// Ping twice per period, as recommended
+			time.Sleep(time.Duration(watchdog_usec / 2) * time.Microsecond)
+
+			last_heartbeat, max_period_s := manager.GetHeartbeat()
+			if manager.IsRunning() && (max_period_s <= 0 ||
+					time.Now().UnixMilli() - last_heartbeat <= int64(max_period_s)*1000) {
+				_ = SdNotifySYSTEMD("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+/*
+sendToJournaldSYSTEMD sends a log entry to journald with the native protocol.
+
+-----------------------------------------------------------
+
+– Params:
+  - log_entry – the log entry
+
+– Returns:
+  - nil if the entry was sent, an error otherwise
+*/
+func sendToJournaldSYSTEMD(log_entry LogEntry) error {
+	var message bytes.Buffer
+	writeJournaldFieldSYSTEMD(&message, "MESSAGE", log_entry.Msg)
+	writeJournaldFieldSYSTEMD(&message, "PRIORITY", strconv.Itoa(_JOURNALD_PRIORITIES[log_entry.Level]))
+	writeJournaldFieldSYSTEMD(&message, "SYSLOG_IDENTIFIER", _JOURNALD_IDENTIFIER)
+	writeJournaldFieldSYSTEMD(&message, "VISOR_MODULE", GetModNameMODULES(log_entry.Mod_num))
+	writeJournaldFieldSYSTEMD(&message, "VISOR_MOD_NUM", strconv.Itoa(log_entry.Mod_num))
+	for key, value := range log_entry.Fields {
+		// Field names can only have upper case letters, digits and underscores
+		var field_name []byte = []byte("VISOR_F_" + strings.ToUpper(key))
+		for i, char := range field_name {
+			if !(char >= 'A' && char <= 'Z') && !(char >= '0' && char <= '9') {
+				field_name[i] = '_'
+			}
+		}
+		writeJournaldFieldSYSTEMD(&message, string(field_name), fmt.Sprint(value))
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: _JOURNALD_SOCKET, Net: "unixgram"})
+	if nil != err {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(message.Bytes())
+
+	return err
+}
+
+/*
+writeJournaldFieldSYSTEMD writes a field of a journald native protocol message.
+
+-----------------------------------------------------------
+
+– Params:
+  - message – the message
+  - name – the name of the field
+  - value – the value of the field
+*/
+func writeJournaldFieldSYSTEMD(message *bytes.Buffer, name string, value string) {
+	message.WriteString(name)
+	if strings.Contains(value, "\n") {
+		// Multi-line values go as binary data, preceded by their size
+		message.WriteByte('\n')
+		_ = binary.Write(message, binary.LittleEndian, uint64(len(value)))
+	} else {
+		message.WriteByte('=')
+	}
+	message.WriteString(value)
+	message.WriteByte('\n')
+}
+
+/*
+InstallUnitSYSTEMD writes a systemd unit file for the server, running the current executable in the current working
+directory (the one with the user settings file), with the notifications and the watchdog enabled and the logs sent to
+journald.
+
+-----------------------------------------------------------
+
+– Params:
+  - unit_file – the path of the unit file to write
+
+– Returns:
+  - nil if the unit file was written, an error otherwise
+*/
+func InstallUnitSYSTEMD(unit_file string) error {
+	working_dir, err := os.Getwd()
+	if nil != err {
+		return err
+	}
+	if _, err = os.Stat(filepath.Join(working_dir, USER_SETTINGS_FILE)); nil != err {
+		return errors.New("no " + USER_SETTINGS_FILE + " in the current directory - run this from the directory " +
+			"with it, which will be the working directory of the service")
+	}
+	executable, err := os.Executable()
+	if nil != err {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); nil != err {
+		return err
+	}
+
+	// The Manager can take a while to stop all the modules
+	var stop_timeout_s int = getModStopTimeoutMODULES(NUM_MOD_ModManager) + _MOD_STOP_TIMEOUT_S
+
+	var unit string = "" +
+		"[Unit]\n" +
+		"Description=V.I.S.O.R.\n" +
+		"Wants=network-online.target\n" +
+		"After=network-online.target\n" +
+		"[Service]\n" +
+		"Type=notify\n" +
+		"NotifyAccess=main\n" +
+		"User=root\n" +
+		"WorkingDirectory=" + working_dir + "\n" +
+		"ExecStart=" + executable + " --nostatus --journald\n" +
+		"# If the secrets store is used, put VISOR_SECRETS_KEY=<master password> in a file only root can read\n" +
+		"#EnvironmentFile=/etc/visor/secrets.env\n" +
+		"Restart=always\n" +
+		"WatchdogSec=60\n" +
+		"TimeoutStopSec=" + strconv.Itoa(stop_timeout_s) + "\n" +
+		"[Install]\n" +
+		"WantedBy=multi-user.target\n"
+
+	return os.WriteFile(unit_file, []byte(unit), 0o644)
+}
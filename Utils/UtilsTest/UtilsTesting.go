@@ -0,0 +1,1232 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package UtilsTest
+
+import (
+	"Utils"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Test directories and URL of the TestHarness (inside the FakeFileSystem)
+const (
+	_TEST_VISOR_DIR   string = "/VISOR"
+	_TEST_WEBSITE_DIR string = "/website"
+	_TEST_WEBSITE_URL string = "https://visor.test"
+)
+
+// Paths of VISOR's directory the TestHarness writes to (the same as the ones Utils reads from)
+const (
+	_TEST_PROGRAM_DATA_REL_DIR string = "data/ProgramData"
+	_TEST_MOD_USER_INFO_JSON   string = "mod_user_info.json"
+)
+
+// _TEST_TIMEOUT is the maximum real time the TestHarness waits for a module to reach its wait or to stop.
+const _TEST_TIMEOUT time.Duration = 10 * time.Second
+
+/*
+FakeClock is a Clock whose time only passes when told to. The waits on it block until the time is advanced past their
+end (or their context is cancelled or one of their channels receives a value).
+
+Create it with NewFakeClockTESTING().
+*/
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*_FakeClockWaiter
+	// changed is closed (and replaced) each time a wait begins
+	changed chan struct{}
+}
+
+// _FakeClockWaiter is a wait on a FakeClock.
+type _FakeClockWaiter struct {
+	time_end time.Time
+	// fired is closed when the wait ends
+	fired chan struct{}
+	// stop_wait is true if it's a wait of WaitStop(), which must check the stop signal when interrupted
+	stop_wait bool
+}
+
+/*
+FakeFileSystem is a FileSystem in memory. The modification times of the files are taken from the Clock in use.
+
+Create it with NewFakeFileSystemTESTING().
+*/
+type FakeFileSystem struct {
+	mutex sync.Mutex
+	// files maps the cleaned paths to the files and directories (the roots always exist)
+	files map[string]*_FakeFile
+}
+
+// _FakeFile is a file or directory of a FakeFileSystem. It's also its fs.FileInfo.
+type _FakeFile struct {
+	name       string
+	data       []byte
+	dir        bool
+	mode       fs.FileMode
+	modif_time time.Time
+}
+
+/*
+FakeWebsiteClient is a WebsiteClient without network. VISOR's website is served from the website files directory, like
+the Website Backend does, and the other pages are the ones set with SetPage(). The forms submitted are recorded and
+answered with the responses set with SetFormResponse().
+
+Create it with NewFakeWebsiteClientTESTING().
+*/
+type FakeWebsiteClient struct {
+	mutex sync.Mutex
+	pages map[string][]byte
+	forms []Utils.WebsiteForm
+	// form_responses maps the types of the forms to their responses
+	form_responses map[string][]byte
+}
+
+/*
+TestHarness runs the loop of a module on the tests, against a FakeClock, a FakeFileSystem and a FakeWebsiteClient.
+
+VISOR's directory and the website directory are inside the FakeFileSystem, so the module starts with no files besides
+the ones the test writes (through GPath, as usual). The event bus starts empty too.
+
+Create it with NewTestHarnessTESTING() and call Close() on it at the end of the test.
+*/
+type TestHarness[T any] struct {
+	// Clock is the clock of the module
+	Clock          *FakeClock
+	// File_system is the file system of the module
+	File_system    *FakeFileSystem
+	// Website_client is the website client of the module
+	Website_client *FakeWebsiteClient
+	// ModuleInfo is the ModuleInfo given to realMain(). Its ModGenInfo is loaded from the generated information file on
+	// each run, so to begin with some, set it and call ModuleInfo.UpdateGenInfo().
+	ModuleInfo     Utils.ModuleInfo[T]
+	// Waiters is the number of goroutines of the module that wait on the clock at the same time at the end of each
+	// iteration of its loop - 1 by default, more if the module starts others (like folder adapters)
+	Waiters        int
+	// On_iteration is called (if not nil) at the end of each iteration but the last, before the clock is advanced - to
+	// change things while the module runs
+	On_iteration   func(iteration int)
+
+	prev_clock          Utils.Clock
+	prev_file_system    Utils.FileSystem
+	prev_website_client Utils.WebsiteClient
+	prev_user_settings  *Utils.UserSettings
+}
+
+/*
+NewFakeClockTESTING creates a FakeClock.
+
+-----------------------------------------------------------
+
+– Params:
+  - now – the initial time
+
+– Returns:
+  - the clock
+*/
+func NewFakeClockTESTING(now time.Time) *FakeClock {
+	return &FakeClock{
+		now:     now,
+		changed: make(chan struct{}),
+	}
+}
+
+/*
+Now gets the current time of the clock.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the current time
+*/
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+/*
+Advance advances the time of the clock, ending the waits that reach their end.
+
+-----------------------------------------------------------
+
+– Params:
+  - duration – the time to advance
+*/
+func (clock *FakeClock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+	clock.fireWaiters()
+}
+
+/*
+AdvanceToNextWaiter advances the time of the clock to the end of the wait that ends first, ending it (and any others
+that end at the same time).
+
+-----------------------------------------------------------
+
+– Returns:
+  - true if there was a wait to end, false otherwise
+*/
+func (clock *FakeClock) AdvanceToNextWaiter() bool {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	if 0 == len(clock.waiters) {
+		return false
+	}
+
+	var time_end time.Time = clock.waiters[0].time_end
+	for _, waiter := range clock.waiters[1:] {
+		if waiter.time_end.Before(time_end) {
+			time_end = waiter.time_end
+		}
+	}
+	if time_end.After(clock.now) {
+		clock.now = time_end
+	}
+	clock.fireWaiters()
+
+	return true
+}
+
+/*
+GetNumWaiters gets the number of waits going on.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the number of waits
+*/
+func (clock *FakeClock) GetNumWaiters() int {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return len(clock.waiters)
+}
+
+/*
+Wait waits until the time is advanced past the duration, the context is cancelled or one of the channels receives a
+value.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context
+  - duration – the time to wait
+  - channels – the channels to wait on too
+
+– Returns:
+  - WAIT_TIMED_OUT, WAIT_CANCELLED or the index of the channel that received the value
+*/
+func (clock *FakeClock) Wait(ctx context.Context, duration time.Duration, channels ...<-chan struct{}) int {
+	var cases []reflect.SelectCase = []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+	for _, channel := range channels {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel)})
+	}
+
+	// What's ready already doesn't need a wait (and the context comes first)
+	if nil != ctx.Err() {
+		return Utils.WAIT_CANCELLED
+	}
+	chosen, _, _ := reflect.Select(append(cases, reflect.SelectCase{Dir: reflect.SelectDefault}))
+	if 0 == chosen {
+		return Utils.WAIT_CANCELLED
+	} else if chosen < len(cases) {
+		return chosen - 1
+	}
+	if duration <= 0 {
+		return Utils.WAIT_TIMED_OUT
+	}
+
+	var waiter *_FakeClockWaiter = clock.addWaiter(duration, false)
+	chosen, _, _ = reflect.Select(append(cases, reflect.SelectCase{Dir: reflect.SelectRecv,
+		Chan: reflect.ValueOf(waiter.fired)}))
+	clock.removeWaiter(waiter)
+
+	switch chosen {
+		case 0:
+			return Utils.WAIT_CANCELLED
+		case len(cases):
+			return Utils.WAIT_TIMED_OUT
+		default:
+			return chosen - 1
+	}
+}
+
+/*
+WaitStop waits until the time is advanced past the duration or the stop signal is given (checked when the
+TestHarness stops the module).
+
+-----------------------------------------------------------
+
+– Params:
+  - stop – the stop signal
+  - duration – the time to wait
+
+– Returns:
+  - whether the stop signal was given (true) or the duration passed (false)
+*/
+func (clock *FakeClock) WaitStop(stop Utils.StopSignal, duration time.Duration) bool {
+	var time_end time.Time = clock.Now().Add(duration)
+	for {
+		if stop() {
+			return true
+		}
+
+		var time_left time.Duration = time_end.Sub(clock.Now())
+		if time_left <= 0 {
+			return false
+		}
+
+		var waiter *_FakeClockWaiter = clock.addWaiter(time_left, true)
+		<-waiter.fired
+		clock.removeWaiter(waiter)
+	}
+}
+
+/*
+addWaiter adds a wait to the clock.
+
+-----------------------------------------------------------
+
+– Params:
+  - duration – the duration of the wait
+  - stop_wait – true if it's a wait of WaitStop()
+
+– Returns:
+  - the wait
+*/
+func (clock *FakeClock) addWaiter(duration time.Duration, stop_wait bool) *_FakeClockWaiter {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	var waiter *_FakeClockWaiter = &_FakeClockWaiter{
+		time_end:  clock.now.Add(duration),
+		fired:     make(chan struct{}),
+		stop_wait: stop_wait,
+	}
+	clock.waiters = append(clock.waiters, waiter)
+
+	close(clock.changed)
+	clock.changed = make(chan struct{})
+
+	return waiter
+}
+
+/*
+removeWaiter removes a wait from the clock, if it's still there.
+
+-----------------------------------------------------------
+
+– Params:
+  - waiter – the wait
+*/
+func (clock *FakeClock) removeWaiter(waiter *_FakeClockWaiter) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	for i, waiter1 := range clock.waiters {
+		if waiter1 == waiter {
+			clock.waiters = append(clock.waiters[:i], clock.waiters[i + 1:]...)
+
+			break
+		}
+	}
+}
+
+/*
+fireWaiters ends the waits that reached their end. Call with the mutex locked.
+*/
+func (clock *FakeClock) fireWaiters() {
+	var waiters []*_FakeClockWaiter = nil
+	for _, waiter := range clock.waiters {
+		if waiter.time_end.After(clock.now) {
+			waiters = append(waiters, waiter)
+		} else {
+			close(waiter.fired)
+		}
+	}
+	clock.waiters = waiters
+}
+
+/*
+interruptStopWaiters ends the waits of WaitStop() so that they check the stop signal.
+*/
+func (clock *FakeClock) interruptStopWaiters() {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	var waiters []*_FakeClockWaiter = nil
+	for _, waiter := range clock.waiters {
+		if waiter.stop_wait {
+			close(waiter.fired)
+		} else {
+			waiters = append(waiters, waiter)
+		}
+	}
+	clock.waiters = waiters
+}
+
+/*
+waitForWaiters waits (in real time) until there are at least the given number of waits going on.
+
+-----------------------------------------------------------
+
+– Params:
+  - num_waiters – the number of waits
+  - finished – a channel closed if there's no point in waiting anymore
+
+– Returns:
+  - nil if there are the waits or finished was closed, an error if they didn't happen in _TEST_TIMEOUT
+*/
+func (clock *FakeClock) waitForWaiters(num_waiters int, finished <-chan struct{}) error {
+	var timer *time.Timer = time.NewTimer(_TEST_TIMEOUT)
+	defer timer.Stop()
+
+	for {
+		clock.mutex.Lock()
+		var curr_num_waiters int = len(clock.waiters)
+		var changed chan struct{} = clock.changed
+		clock.mutex.Unlock()
+
+		if curr_num_waiters >= num_waiters {
+			return nil
+		}
+
+		select {
+			case <-changed:
+				// Check again
+			case <-finished:
+				return nil
+			case <-timer.C:
+				return errors.New("only " + strconv.Itoa(curr_num_waiters) + " of the " + strconv.Itoa(num_waiters) +
+					" waits on the clock happened")
+		}
+	}
+}
+
+/*
+NewFakeFileSystemTESTING creates an empty FakeFileSystem.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the file system
+*/
+func NewFakeFileSystemTESTING() *FakeFileSystem {
+	return &FakeFileSystem{
+		files: make(map[string]*_FakeFile),
+	}
+}
+
+/*
+Stat gets the information about a file or directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+
+– Returns:
+  - the information about the file or directory
+  - nil if the information was got, an error otherwise
+*/
+func (file_system *FakeFileSystem) Stat(name string) (fs.FileInfo, error) {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	file, err := file_system.getFile("stat", name)
+	if nil != err {
+		return nil, err
+	}
+	var file_info _FakeFile = *file
+
+	return &file_info, nil
+}
+
+/*
+ReadFile reads the contents of a file.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file
+
+– Returns:
+  - the contents of the file
+  - nil if the file was read, an error otherwise
+*/
+func (file_system *FakeFileSystem) ReadFile(name string) ([]byte, error) {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	file, err := file_system.getFile("read", name)
+	if nil != err {
+		return nil, err
+	}
+	if file.dir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+
+	return append([]byte(nil), file.data...), nil
+}
+
+/*
+ReadDir reads the entries of a directory, sorted by name.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the directory
+
+– Returns:
+  - the entries of the directory
+  - nil if the directory was read, an error otherwise
+*/
+func (file_system *FakeFileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	dir, err := file_system.getFile("open", name)
+	if nil != err {
+		return nil, err
+	}
+	if !dir.dir {
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errors.New("not a directory")}
+	}
+
+	var dir_path string = filepath.Clean(name)
+	var entries []fs.DirEntry = nil
+	for path, file := range file_system.files {
+		if filepath.Dir(path) == dir_path && path != dir_path {
+			var file_info _FakeFile = *file
+			entries = append(entries, fs.FileInfoToDirEntry(&file_info))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+/*
+WriteFile writes the contents of a file.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file
+  - data – the contents to write
+  - append – true to append to the end of the file (which must exist), false to replace its contents or create it
+  - synced – ignored (the files are always "synced")
+
+– Returns:
+  - nil if the file was written, an error otherwise
+*/
+func (file_system *FakeFileSystem) WriteFile(name string, data []byte, append bool, synced bool) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	file, err := file_system.getFile("open", name)
+	if nil != err {
+		if append {
+			return err
+		}
+		if file, err = file_system.newFile("open", name, false); nil != err {
+			return err
+		}
+	}
+	if file.dir {
+		return &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	if append {
+		file.data = appendBytesTESTING(file.data, data)
+	} else {
+		file.data = appendBytesTESTING(nil, data)
+	}
+	file.modif_time = Utils.GetClockTIMEDATE().Now()
+
+	return nil
+}
+
+/*
+Mkdir creates a directory (not its parents).
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the directory
+
+– Returns:
+  - nil if the directory was created, an error otherwise
+*/
+func (file_system *FakeFileSystem) Mkdir(name string) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	if _, err := file_system.getFile("mkdir", name); nil == err {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	_, err := file_system.newFile("mkdir", name, true)
+
+	return err
+}
+
+/*
+Chmod changes the permissions of a file or directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+  - mode – the new permissions
+
+– Returns:
+  - nil if the permissions were changed, an error otherwise
+*/
+func (file_system *FakeFileSystem) Chmod(name string, mode fs.FileMode) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	file, err := file_system.getFile("chmod", name)
+	if nil != err {
+		return err
+	}
+	file.mode = file.mode & fs.ModeType | mode & fs.ModePerm
+
+	return nil
+}
+
+/*
+Rename renames (moves) a file or directory, replacing the destination if it's a file.
+
+-----------------------------------------------------------
+
+– Params:
+  - old_name – the current path
+  - new_name – the new path
+
+– Returns:
+  - nil if the file or directory was renamed, an error otherwise
+*/
+func (file_system *FakeFileSystem) Rename(old_name string, new_name string) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	file, err := file_system.getFile("rename", old_name)
+	if nil != err {
+		return err
+	}
+	if new_file, err := file_system.getFile("rename", new_name); nil == err && new_file.dir {
+		return &fs.PathError{Op: "rename", Path: new_name, Err: fs.ErrExist}
+	}
+	if parent, err := file_system.getFile("rename", filepath.Dir(new_name)); nil != err || !parent.dir {
+		return &fs.PathError{Op: "rename", Path: new_name, Err: fs.ErrNotExist}
+	}
+
+	var old_path string = filepath.Clean(old_name)
+	var new_path string = filepath.Clean(new_name)
+	for path, file1 := range file_system.files {
+		if strings.HasPrefix(path, old_path + string(os.PathSeparator)) {
+			delete(file_system.files, path)
+			file_system.files[new_path + strings.TrimPrefix(path, old_path)] = file1
+		}
+	}
+	delete(file_system.files, old_path)
+	file.name = filepath.Base(new_path)
+	file_system.files[new_path] = file
+
+	return nil
+}
+
+/*
+Remove removes a file or an empty directory.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the path of the file or directory
+
+– Returns:
+  - nil if the file or directory was removed, an error otherwise
+*/
+func (file_system *FakeFileSystem) Remove(name string) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	if _, err := file_system.getFile("remove", name); nil != err {
+		return err
+	}
+
+	var path string = filepath.Clean(name)
+	for path1 := range file_system.files {
+		if filepath.Dir(path1) == path && path1 != path {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(file_system.files, path)
+
+	return nil
+}
+
+/*
+AddFromDisk copies a file or directory (with all its contents) from the disk into the file system.
+
+-----------------------------------------------------------
+
+– Params:
+  - disk_path – the path of the file or directory on the disk
+  - path – the path where to put it in the file system (its parents are created if needed)
+
+– Returns:
+  - nil if everything was copied, an error otherwise
+*/
+func (file_system *FakeFileSystem) AddFromDisk(disk_path string, path Utils.GPath) error {
+	file_system.mutex.Lock()
+	defer file_system.mutex.Unlock()
+
+	return filepath.WalkDir(disk_path, func(disk_path1 string, entry fs.DirEntry, err error) error {
+		if nil != err {
+			return err
+		}
+
+		rel_path, err := filepath.Rel(disk_path, disk_path1)
+		if nil != err {
+			return err
+		}
+		var file_path string = filepath.Join(path.GPathToStringConversion(), rel_path)
+
+		// Create the parents
+		var parents []string = nil
+		for parent := filepath.Dir(file_path); nil == file_system.files[parent] && filepath.Dir(parent) != parent;
+				parent = filepath.Dir(parent) {
+			parents = append([]string{parent}, parents...)
+		}
+		for _, parent := range parents {
+			if _, err = file_system.newFile("mkdir", parent, true); nil != err {
+				return err
+			}
+		}
+
+		if entry.IsDir() {
+			if nil == file_system.files[filepath.Clean(file_path)] {
+				_, err = file_system.newFile("mkdir", file_path, true)
+			}
+
+			return err
+		}
+
+		data, err := os.ReadFile(disk_path1)
+		if nil != err {
+			return err
+		}
+		file, err := file_system.newFile("open", file_path, false)
+		if nil != err {
+			return err
+		}
+		file.data = data
+
+		return nil
+	})
+}
+
+/*
+getFile gets a file or directory. Call with the mutex locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - op – the operation, for the error
+  - name – the path of the file or directory
+
+– Returns:
+  - the file or directory
+  - nil if it exists, an error otherwise
+*/
+func (file_system *FakeFileSystem) getFile(op string, name string) (*_FakeFile, error) {
+	var path string = filepath.Clean(name)
+	if filepath.Dir(path) == path {
+		// A root (like "/" or ".")
+		return &_FakeFile{
+			name: path,
+			dir:  true,
+			mode: fs.ModeDir | 0o777,
+		}, nil
+	}
+
+	var file *_FakeFile = file_system.files[path]
+	if nil == file {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	return file, nil
+}
+
+/*
+newFile creates a file or directory, whose parent must exist. Call with the mutex locked.
+
+-----------------------------------------------------------
+
+– Params:
+  - op – the operation, for the error
+  - name – the path of the file or directory
+  - dir – true to create a directory, false to create a file
+
+– Returns:
+  - the file or directory
+  - nil if it was created, an error otherwise
+*/
+func (file_system *FakeFileSystem) newFile(op string, name string, dir bool) (*_FakeFile, error) {
+	var path string = filepath.Clean(name)
+	if parent, err := file_system.getFile(op, filepath.Dir(path)); nil != err || !parent.dir {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+
+	var file *_FakeFile = &_FakeFile{
+		name:       filepath.Base(path),
+		dir:        dir,
+		mode:       0o755,
+		modif_time: Utils.GetClockTIMEDATE().Now(),
+	}
+	if dir {
+		file.mode |= fs.ModeDir
+	}
+	file_system.files[path] = file
+
+	return file, nil
+}
+
+/*
+appendBytesTESTING appends bytes to a copy of a slice, so that the original slice is never modified.
+
+-----------------------------------------------------------
+
+– Params:
+  - slice – the slice
+  - bytes – the bytes to append
+
+– Returns:
+  - the new slice
+*/
+func appendBytesTESTING(slice []byte, bytes []byte) []byte {
+	var new_slice []byte = make([]byte, 0, len(slice) + len(bytes))
+	new_slice = append(new_slice, slice...)
+
+	return append(new_slice, bytes...)
+}
+
+// Name, Size, Mode, ModTime, IsDir and Sys implement fs.FileInfo.
+
+func (file *_FakeFile) Name() string {
+	return file.name
+}
+
+func (file *_FakeFile) Size() int64 {
+	return int64(len(file.data))
+}
+
+func (file *_FakeFile) Mode() fs.FileMode {
+	return file.mode
+}
+
+func (file *_FakeFile) ModTime() time.Time {
+	return file.modif_time
+}
+
+func (file *_FakeFile) IsDir() bool {
+	return file.dir
+}
+
+func (file *_FakeFile) Sys() any {
+	return nil
+}
+
+/*
+NewFakeWebsiteClientTESTING creates a FakeWebsiteClient with no pages besides VISOR's website.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the website client
+*/
+func NewFakeWebsiteClientTESTING() *FakeWebsiteClient {
+	return &FakeWebsiteClient{
+		pages:          make(map[string][]byte),
+		form_responses: make(map[string][]byte),
+	}
+}
+
+/*
+SetPage sets the contents of a page outside VISOR's website.
+
+-----------------------------------------------------------
+
+– Params:
+  - url – the URL of the page
+  - page_contents – the contents of the page, or nil to remove it
+*/
+func (website_client *FakeWebsiteClient) SetPage(url string, page_contents []byte) {
+	website_client.mutex.Lock()
+	defer website_client.mutex.Unlock()
+
+	if nil == page_contents {
+		delete(website_client.pages, url)
+	} else {
+		website_client.pages[url] = page_contents
+	}
+}
+
+/*
+SetFormResponse sets the response to the forms of a type (other than "GET", which is answered from the website files
+directory).
+
+-----------------------------------------------------------
+
+– Params:
+  - form_type – the type of the forms
+  - response – the response, or nil for none
+*/
+func (website_client *FakeWebsiteClient) SetFormResponse(form_type string, response []byte) {
+	website_client.mutex.Lock()
+	defer website_client.mutex.Unlock()
+
+	if nil == response {
+		delete(website_client.form_responses, form_type)
+	} else {
+		website_client.form_responses[form_type] = response
+	}
+}
+
+/*
+GetForms gets the forms submitted until now.
+
+-----------------------------------------------------------
+
+– Params:
+  - form_type – the type of the forms to get, or "" for all
+
+– Returns:
+  - the forms in order of submission
+*/
+func (website_client *FakeWebsiteClient) GetForms(form_type string) []Utils.WebsiteForm {
+	website_client.mutex.Lock()
+	defer website_client.mutex.Unlock()
+
+	var forms []Utils.WebsiteForm = nil
+	for _, form := range website_client.forms {
+		if "" == form_type || form.Type == form_type {
+			forms = append(forms, form)
+		}
+	}
+
+	return forms
+}
+
+/*
+Get gets a web page.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to cancel the request with
+  - url – the URL of the page
+  - visor_auth – true if the page is one of VISOR's website
+
+– Returns:
+  - the contents of the page
+  - nil if the page was got, an error otherwise (including if the page doesn't exist)
+*/
+func (website_client *FakeWebsiteClient) Get(ctx context.Context, url string, visor_auth bool) ([]byte, error) {
+	if nil != ctx.Err() {
+		return nil, ctx.Err()
+	}
+
+	if visor_auth {
+		var website_url string = Utils.GetUserSettingsSETTINGS().PersonalConsts.Website_url + "/"
+		if strings.HasPrefix(url, website_url) {
+			var page_contents []byte = Utils.PathFILESDIRS(false, "",
+				Utils.GetUserSettingsSETTINGS().PersonalConsts.Website_dir,
+				strings.TrimPrefix(url, website_url)).ReadFile()
+			if nil != page_contents {
+				return page_contents, nil
+			}
+		}
+	} else {
+		website_client.mutex.Lock()
+		var page_contents []byte = website_client.pages[url]
+		website_client.mutex.Unlock()
+		if nil != page_contents {
+			return page_contents, nil
+		}
+	}
+
+	return nil, errors.New("response status code: 404")
+}
+
+/*
+SubmitForm records a form and gets its response: for the forms of type "GET", the file or its MD5 hash from the website
+files directory (like from the Website Backend), and for the others the one set with SetFormResponse(), if any.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to cancel the request with
+  - form – the form to send
+
+– Returns:
+  - the response
+  - nil if the form was submitted successfully, an error otherwise
+*/
+func (website_client *FakeWebsiteClient) SubmitForm(ctx context.Context, form Utils.WebsiteForm) ([]byte, error) {
+	if nil != ctx.Err() {
+		return nil, ctx.Err()
+	}
+
+	website_client.mutex.Lock()
+	website_client.forms = append(website_client.forms, form)
+	var response []byte = website_client.form_responses[form.Type]
+	website_client.mutex.Unlock()
+
+	if "GET" != form.Type {
+		return response, nil
+	}
+
+	var file_bytes []byte = Utils.GetWebsiteFilesDirFILESDIRS().Add2(false, form.Text2).ReadFile()
+	if "true" == form.Text1 {
+		return file_bytes, nil
+	}
+	var hash [16]byte = md5.Sum(file_bytes)
+
+	return hash[:], nil
+}
+
+/*
+NewTestHarnessTESTING creates a TestHarness for a module, putting its fakes and the given user settings in use until
+Close() is called.
+
+-----------------------------------------------------------
+
+– Generic params:
+  - T – the type of the ModuleInfo.ModGenInfo field of the module
+
+– Params:
+  - mod_num – the number of the module
+  - user_settings – the user settings (VISOR's directory, the website directory and the website URL are set by the
+    harness if empty)
+  - now – the initial time of the clock
+
+– Returns:
+  - the harness
+*/
+func NewTestHarnessTESTING[T any](mod_num int, user_settings Utils.UserSettings, now time.Time) *TestHarness[T] {
+	if "" == user_settings.PersonalConsts.VISOR_dir {
+		user_settings.PersonalConsts.VISOR_dir = _TEST_VISOR_DIR
+	}
+	if "" == user_settings.PersonalConsts.Website_dir {
+		user_settings.PersonalConsts.Website_dir = _TEST_WEBSITE_DIR
+	}
+	if "" == user_settings.PersonalConsts.Website_url {
+		user_settings.PersonalConsts.Website_url = _TEST_WEBSITE_URL
+	}
+
+	var harness *TestHarness[T] = &TestHarness[T]{
+		Clock:          NewFakeClockTESTING(now),
+		File_system:    NewFakeFileSystemTESTING(),
+		Website_client: NewFakeWebsiteClientTESTING(),
+		Waiters:        1,
+	}
+	harness.prev_clock = Utils.SetClockTIMEDATE(harness.Clock)
+	harness.prev_file_system = Utils.SetFileSystemFILESDIRS(harness.File_system)
+	harness.prev_website_client = Utils.SetWebsiteClientWEBSITE(harness.Website_client)
+	harness.prev_user_settings = Utils.GetUserSettingsSETTINGS()
+	Utils.SetUserSettingsSETTINGS(user_settings)
+
+	_ = harness.File_system.Mkdir(user_settings.PersonalConsts.VISOR_dir)
+	_ = Utils.GetWebsiteFilesDirFILESDIRS().Create(false)
+
+	harness.ModuleInfo = Utils.NewModuleInfoMODULES[T](&Utils.Module{
+		Num:  mod_num,
+		Name: Utils.GetModNameMODULES(mod_num),
+	})
+
+	return harness
+}
+
+/*
+Close puts back in use the clock, file system, website client and user settings from before the harness was created.
+*/
+func (harness *TestHarness[T]) Close() {
+	Utils.SetClockTIMEDATE(harness.prev_clock)
+	Utils.SetFileSystemFILESDIRS(harness.prev_file_system)
+	Utils.SetWebsiteClientWEBSITE(harness.prev_website_client)
+	Utils.SetUserSettingsSETTINGS(*harness.prev_user_settings)
+}
+
+/*
+SetModUserInfo writes the module's user info file, which the module reads with ModuleInfo.GetModUserInfo() when it has
+no section in the user settings.
+
+-----------------------------------------------------------
+
+– Params:
+  - mod_user_info – the information, in the module's _ModUserInfo format
+
+– Returns:
+  - nil if the file was written, an error otherwise
+*/
+func (harness *TestHarness[T]) SetModUserInfo(mod_user_info any) error {
+	return harness.ModuleInfo.ModDirsInfo.UserData.Add2(false, _TEST_MOD_USER_INFO_JSON).
+		WriteTextFile(*Utils.ToJsonGENERAL(mod_user_info), false)
+}
+
+/*
+LoadProgramData copies the program data directory of the project (like the email models) into the program data
+directory of the harness.
+
+-----------------------------------------------------------
+
+– Params:
+  - disk_dir – the path to the project's program data directory on the disk (data/ProgramData)
+
+– Returns:
+  - nil if everything was copied, an error otherwise
+*/
+func (harness *TestHarness[T]) LoadProgramData(disk_dir string) error {
+	return harness.File_system.AddFromDisk(disk_dir, Utils.PathFILESDIRS(true, "",
+		Utils.GetUserSettingsSETTINGS().PersonalConsts.VISOR_dir, _TEST_PROGRAM_DATA_REL_DIR))
+}
+
+/*
+Run runs the realMain() of a module for some iterations of its loop and stops it.
+
+An iteration ends when the module waits on the clock (on all its Waiters goroutines). Then the clock is advanced to the
+end of the first wait, for the next iteration to begin. After the last one, the module is signalled to stop.
+
+Each run begins like after a restart of the module: with the generated information loaded from its file.
+
+-----------------------------------------------------------
+
+– Params:
+  - realMain – the realMain() of the module
+  - iterations – the number of iterations
+
+– Returns:
+  - nil if the module ran and stopped normally, an error otherwise (like if it panicked or didn't wait on the clock)
+*/
+func (harness *TestHarness[T]) Run(realMain Utils.RealMain, iterations int) error {
+	return harness.run(realMain, nil, iterations)
+}
+
+/*
+RunCtx is the same as Run() but for modules whose realMain() is a RealMainCtx.
+*/
+func (harness *TestHarness[T]) RunCtx(realMainCtx Utils.RealMainCtx, iterations int) error {
+	return harness.run(nil, realMainCtx, iterations)
+}
+
+/*
+run is the main function for Run() and RunCtx(). Only one of realMain and realMainCtx must be given.
+*/
+func (harness *TestHarness[T]) run(realMain Utils.RealMain, realMainCtx Utils.RealMainCtx, iterations int) error {
+	var mod_gen_info T
+	harness.ModuleInfo.ModGenInfo = mod_gen_info
+	if err := harness.ModuleInfo.LoadGenInfo(); nil != err {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stop atomic.Bool
+	var finished chan struct{} = make(chan struct{})
+	var main_err error = nil
+	go func() {
+		defer close(finished)
+		defer func() {
+			if r := recover(); nil != r {
+				main_err = fmt.Errorf("the module panicked: %v", r)
+			}
+		}()
+
+		if nil != realMainCtx {
+			realMainCtx(ctx, harness.ModuleInfo)
+		} else {
+			realMain(stop.Load, harness.ModuleInfo)
+		}
+	}()
+
+	for iteration := 1; ; iteration++ {
+		if err := harness.Clock.waitForWaiters(harness.Waiters, finished); nil != err {
+			return errors.New("iteration " + strconv.Itoa(iteration) + " didn't end: " + err.Error())
+		}
+		select {
+			case <-finished:
+				if nil == main_err {
+					main_err = errors.New("the module returned by itself on iteration " + strconv.Itoa(iteration))
+				}
+
+				return main_err
+			default:
+				// Still running
+		}
+
+		if iteration >= iterations {
+			break
+		}
+		if nil != harness.On_iteration {
+			harness.On_iteration(iteration)
+		}
+		harness.Clock.AdvanceToNextWaiter()
+	}
+
+	stop.Store(true)
+	cancel()
+	harness.Clock.interruptStopWaiters()
+
+	select {
+		case <-finished:
+			return main_err
+		case <-time.After(_TEST_TIMEOUT):
+			return errors.New("the module didn't stop")
+	}
+}
+
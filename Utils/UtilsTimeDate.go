@@ -22,6 +22,9 @@
 package Utils
 
 import (
+	"context"
+	"reflect"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,6 +32,34 @@ const TIME_FORMAT string = "15:04:05"
 const DATE_FORMAT string = "2006-01-02"
 const DATE_TIME_FORMAT string = DATE_FORMAT + " -- " + TIME_FORMAT + " (MST)"
 
+// Results of Clock.Wait() besides the index of the channel that received a value
+const (
+	// WAIT_TIMED_OUT means the duration passed
+	WAIT_TIMED_OUT int = -1
+	// WAIT_CANCELLED means the context was cancelled
+	WAIT_CANCELLED int = -2
+)
+
+/*
+Clock is where the current time comes from and what the waits are done on. It's the real time everywhere except on the
+tests, which swap it for a FakeClock to run the modules' loops without waiting for real.
+*/
+type Clock interface {
+	// Now gets the current time
+	Now() time.Time
+	// Wait waits until the duration passes, the context is cancelled or one of the channels receives a value - whatever
+	// comes first. It returns WAIT_TIMED_OUT, WAIT_CANCELLED or the index of the channel that received the value.
+	Wait(ctx context.Context, duration time.Duration, channels ...<-chan struct{}) int
+	// WaitStop waits until the duration passes or the stop signal is given, returning true in the latter case
+	WaitStop(stop StopSignal, duration time.Duration) bool
+}
+
+// _RealClock is the Clock of the real time.
+type _RealClock struct{}
+
+// clock_GL is the Clock in use. Only replace it through SetClockTIMEDATE().
+var clock_GL atomic.Pointer[Clock]
+
 /*
 GetDateTimeStrTIMEDATE gets the current time and date in the format DATE_TIME_FORMAT.
 
@@ -90,8 +121,42 @@ func getTimeDateInFormatTIMEDATE(millis int64, format string) string {
 	if millis != -1 {
 		return time.Unix(0, millis*1e6).Format(format)
 	} else {
-		return time.Now().Format(format)
+		return GetClockTIMEDATE().Now().Format(format)
+	}
+}
+
+/*
+GetClockTIMEDATE gets the Clock in use.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the Clock in use
+*/
+func GetClockTIMEDATE() Clock {
+	if p_clock := clock_GL.Load(); nil != p_clock {
+		return *p_clock
 	}
+
+	return _RealClock{}
+}
+
+/*
+SetClockTIMEDATE replaces the Clock in use. Use ONLY on tests.
+
+-----------------------------------------------------------
+
+– Params:
+  - clock – the new Clock
+
+– Returns:
+  - the previous Clock, to be able to restore it
+*/
+func SetClockTIMEDATE(clock Clock) Clock {
+	var prev_clock Clock = GetClockTIMEDATE()
+	clock_GL.Store(&clock)
+
+	return prev_clock
 }
 
 /*
@@ -106,23 +171,136 @@ WaitWithStopTIMEDATE waits for a certain amount of time or until a stop signal i
 – Returns:
   - whether the loop was stopped (true) or it reached the end time (false)
 */
-func WaitWithStopTIMEDATE(stop *bool, time_wait_s int) bool {
-	if *stop {
+func WaitWithStopTIMEDATE(stop StopSignal, time_wait_s int) bool {
+	if stop() {
 		// In case time_wait_s is 0, it returns immediately in case the stop signal has been given
 		return true
 	}
 
-	var time_end int64 = time.Now().Unix() + int64(time_wait_s)
-	var stopped bool = false
-	for time.Now().Unix() < time_end {
-		if *stop {
-			stopped = true
+	return GetClockTIMEDATE().WaitStop(stop, time.Duration(time_wait_s) * time.Second)
+}
+
+/*
+WaitWithCtxTIMEDATE waits for a certain amount of time or until the given context is cancelled.
+
+-----------------------------------------------------------
 
-			break
+– Params:
+  - ctx – the context
+  - time_sleep_s – the time to wait in seconds
+
+– Returns:
+  - whether the context was cancelled (true) or it reached the end time (false)
+*/
+func WaitWithCtxTIMEDATE(ctx context.Context, time_wait_s int) bool {
+	if ctx.Err() != nil {
+		// In case time_wait_s is 0, it returns immediately in case the context has been cancelled
+		return true
+	}
+
+	return WAIT_CANCELLED == GetClockTIMEDATE().Wait(ctx, time.Duration(time_wait_s) * time.Second)
+}
+
+/*
+WaitWithCtxOrChanTIMEDATE waits for a certain amount of time, until the given context is cancelled or until the given
+channel receives a value.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context
+  - channel – the channel
+  - time_sleep_s – the time to wait in seconds
+
+– Returns:
+  - whether the context was cancelled (true) or it reached the end time or the channel received a value (false)
+*/
+func WaitWithCtxOrChanTIMEDATE(ctx context.Context, channel chan struct{}, time_wait_s int) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+
+	return WAIT_CANCELLED == GetClockTIMEDATE().Wait(ctx, time.Duration(time_wait_s) * time.Second, channel)
+}
+
+/*
+Now gets the current time.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the current time
+*/
+func (_RealClock) Now() time.Time {
+	return time.Now()
+}
+
+/*
+Wait waits until the duration passes, the context is cancelled or one of the channels receives a value.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context
+  - duration – the time to wait
+  - channels – the channels to wait on too
+
+– Returns:
+  - WAIT_TIMED_OUT, WAIT_CANCELLED or the index of the channel that received the value
+*/
+func (_RealClock) Wait(ctx context.Context, duration time.Duration, channels ...<-chan struct{}) int {
+	var timer *time.Timer = time.NewTimer(duration)
+	defer timer.Stop()
+
+	if 0 == len(channels) {
+		select {
+			case <-ctx.Done():
+				return WAIT_CANCELLED
+			case <-timer.C:
+				return WAIT_TIMED_OUT
+		}
+	}
+
+	var cases []reflect.SelectCase = []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)},
+	}
+	for _, channel := range channels {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel)})
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	switch chosen {
+		case 0:
+			return WAIT_CANCELLED
+		case 1:
+			return WAIT_TIMED_OUT
+		default:
+			return chosen - 2
+	}
+}
+
+/*
+WaitStop waits until the duration passes or the stop signal is given (checked every second).
+
+-----------------------------------------------------------
+
+– Params:
+  - stop – the stop signal
+  - duration – the time to wait
+
+– Returns:
+  - whether the stop signal was given (true) or the duration passed (false)
+*/
+func (_RealClock) WaitStop(stop StopSignal, duration time.Duration) bool {
+	var time_end time.Time = time.Now().Add(duration)
+	for time.Now().Before(time_end) {
+		if stop() {
+			return true
 		}
 
 		time.Sleep(1 * time.Second)
 	}
 
-	return stopped
+	return false
 }
@@ -22,8 +22,7 @@
 package Utils
 
 import (
-	"io"
-	"net/http"
+	"context"
 )
 
 /*
@@ -32,22 +31,18 @@ GetPageHtmlWEBPAGES gets the HTML of a page.
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context to cancel the request with
   - url – the URL of the page
 
 – Returns:
   - the HTML of the page or nil if an error occurs
 */
-func GetPageHtmlWEBPAGES(url string) *string {
-	resp, err := http.Get(url)
-	if err == nil {
-		body, err := io.ReadAll(resp.Body)
-		_ = resp.Body.Close()
-		if resp.StatusCode <= 299 && err == nil {
-			var ret string = string(body)
-
-			return &ret
-		}
+func GetPageHtmlWEBPAGES(ctx context.Context, url string) *string {
+	page_html, err := GetWebsiteClientWEBSITE().Get(ctx, url, false)
+	if nil != err {
+		return nil
 	}
+	var ret string = string(page_html)
 
-	return nil
+	return &ret
 }
@@ -23,12 +23,14 @@ package Utils
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync/atomic"
 )
 
 // WebsiteForm represents a form to be submitted to VISOR's website
@@ -43,6 +45,57 @@ type WebsiteForm struct {
 	Text3 string
 }
 
+/*
+WebsiteClient is what talks to VISOR's website and gets other web pages. It's the real network everywhere except on the
+tests, which swap it for a FakeWebsiteClient.
+*/
+type WebsiteClient interface {
+	// Get gets a web page. If visor_auth is true, the page is one of VISOR's website and its credentials are sent.
+	Get(ctx context.Context, url string, visor_auth bool) ([]byte, error)
+	// SubmitForm sends a form to VISOR's website and gets its response
+	SubmitForm(ctx context.Context, form WebsiteForm) ([]byte, error)
+}
+
+// _HttpWebsiteClient is the WebsiteClient of the real network.
+type _HttpWebsiteClient struct{}
+
+// website_client_GL is the WebsiteClient in use. Only replace it through SetWebsiteClientWEBSITE().
+var website_client_GL atomic.Pointer[WebsiteClient]
+
+/*
+GetWebsiteClientWEBSITE gets the WebsiteClient in use.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the WebsiteClient in use
+*/
+func GetWebsiteClientWEBSITE() WebsiteClient {
+	if p_website_client := website_client_GL.Load(); nil != p_website_client {
+		return *p_website_client
+	}
+
+	return _HttpWebsiteClient{}
+}
+
+/*
+SetWebsiteClientWEBSITE replaces the WebsiteClient in use. Use ONLY on tests.
+
+-----------------------------------------------------------
+
+– Params:
+  - website_client – the new WebsiteClient
+
+– Returns:
+  - the previous WebsiteClient, to be able to restore it
+*/
+func SetWebsiteClientWEBSITE(website_client WebsiteClient) WebsiteClient {
+	var prev_website_client WebsiteClient = GetWebsiteClientWEBSITE()
+	website_client_GL.Store(&website_client)
+
+	return prev_website_client
+}
+
 /*
 GetPageContentsWEBSITE gets the page contents from the given VISOR's website page.
 
@@ -59,22 +112,13 @@ PersonalConsts_GL).
   - the page contents or nil if an error occurred
 */
 func GetPageContentsWEBSITE(partial_url string) []byte {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{Transport: tr}
-	req, err := http.NewRequest("GET", User_settings_GL.PersonalConsts.Website_url + "/" + partial_url, nil)
-	if err != nil {
+	page_contents, err := GetWebsiteClientWEBSITE().Get(context.Background(),
+		GetUserSettingsSETTINGS().PersonalConsts.Website_url + "/" + partial_url, true)
+	if nil != err {
 		return nil
 	}
-	req.SetBasicAuth("VISOR", User_settings_GL.PersonalConsts.Website_pw)
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil
-	}
-	bodyText, _ := io.ReadAll(resp.Body)
 
-	return bodyText
+	return page_contents
 }
 
 /*
@@ -83,6 +127,7 @@ GetFileContentsWEBSITE gets the file contents from the given VISOR's website URL
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context to cancel the request with
   - partial_path – the partial path of the file to get the contents from. Example: gpt_text.txt to get from
 	https://www.visor.com/files_EOG/gpt_text.txt
   - md5_hash – true if the MD5 hash of the file is to be retrieved, false if the file contents are to be retrieved
@@ -90,9 +135,9 @@ GetFileContentsWEBSITE gets the file contents from the given VISOR's website URL
 – Returns:
   - the file contents or the MD5 hash, or nil if an error occurred
  */
-func GetFileContentsWEBSITE(partial_path string, md5_hash bool) []byte {
+func GetFileContentsWEBSITE(ctx context.Context, partial_path string, md5_hash bool) []byte {
 	// Get the file contents
-	file_contents, err := SubmitFormWEBSITE(WebsiteForm{
+	file_contents, err := SubmitFormWEBSITE(ctx, WebsiteForm{
 		Type:  "GET",
 		Text1: strconv.FormatBool(!md5_hash),
 		Text2: partial_path,
@@ -111,6 +156,7 @@ SubmitFormWEBSITE sends a form to the given VISOR's webserver and receives its r
 -----------------------------------------------------------
 
 – Params:
+  - ctx – the context to cancel the request with
   - form – the form to send
   - website – the website URL
   - passwd – the password to access the website
@@ -118,7 +164,75 @@ SubmitFormWEBSITE sends a form to the given VISOR's webserver and receives its r
 – Returns:
   - true if the form was submitted successfully, false otherwise
 */
-func SubmitFormWEBSITE(form WebsiteForm) ([]byte, error) {
+func SubmitFormWEBSITE(ctx context.Context, form WebsiteForm) ([]byte, error) {
+	return GetWebsiteClientWEBSITE().SubmitForm(ctx, form)
+}
+
+/*
+Get gets a web page.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to cancel the request with
+  - url – the URL of the page
+  - visor_auth – true if the page is one of VISOR's website, to send its credentials
+
+– Returns:
+  - the contents of the page
+  - nil if the page was got, an error otherwise
+*/
+func (_HttpWebsiteClient) Get(ctx context.Context, url string, visor_auth bool) ([]byte, error) {
+	if !visor_auth {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode > 299 {
+			return nil, errors.New("response status code: " + strconv.Itoa(resp.StatusCode))
+		}
+
+		return body, err
+	}
+
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{Transport: tr}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("VISOR", GetUserSettingsSETTINGS().PersonalConsts.Website_pw)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+/*
+SubmitForm sends a form to VISOR's website and receives its response.
+
+-----------------------------------------------------------
+
+– Params:
+  - ctx – the context to cancel the request with
+  - form – the form to send
+
+– Returns:
+  - the response
+  - nil if the form was submitted successfully, an error otherwise
+*/
+func (_HttpWebsiteClient) SubmitForm(ctx context.Context, form WebsiteForm) ([]byte, error) {
 	formData := url.Values{
 		"type": {form.Type},
 		"text1":  {form.Text1},
@@ -130,11 +244,11 @@ func SubmitFormWEBSITE(form WebsiteForm) ([]byte, error) {
 	formDataEncoded := formData.Encode()
 
 	// Create a new POST request with the form data
-	req, err := http.NewRequest("POST", User_settings_GL.PersonalConsts.Website_url + "/submit-form", bytes.NewBufferString(formDataEncoded))
+	req, err := http.NewRequestWithContext(ctx, "POST", GetUserSettingsSETTINGS().PersonalConsts.Website_url + "/submit-form", bytes.NewBufferString(formDataEncoded))
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth("VISOR", User_settings_GL.PersonalConsts.Website_pw)
+	req.SetBasicAuth("VISOR", GetUserSettingsSETTINGS().PersonalConsts.Website_pw)
 
 	// Set the appropriate headers
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
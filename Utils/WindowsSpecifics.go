@@ -24,6 +24,11 @@
 package Utils
 
 import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+
 	"github.com/lxn/win"
 	"golang.org/x/sys/windows"
 )
@@ -79,3 +84,145 @@ new Windows Terminal). So use StartConAppPROCESSES() to start the program with c
 func HideConsoleWindowPROCESSES() {
 	win.ShowWindow(win.GetConsoleWindow(), win.SW_HIDE)
 }
+
+// _PipeControlListener is the control endpoint on a named pipe.
+type _PipeControlListener struct {
+	// path is the path of the pipe
+	path string
+	// next_handle is the instance of the pipe waiting for the next connection, or 0 to create a new one
+	next_handle windows.Handle
+	// closed is true after Close()
+	closed atomic.Bool
+}
+
+func (listener *_PipeControlListener) Accept() (io.ReadWriteCloser, error) {
+	var handle windows.Handle = listener.next_handle
+	listener.next_handle = 0
+	if 0 == handle {
+		var err error
+		if handle, err = createControlPipeCONTROL(listener.path, false); nil != err {
+			return nil, err
+		}
+	}
+
+	err := windows.ConnectNamedPipe(handle, nil)
+	if nil != err && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		_ = windows.CloseHandle(handle)
+
+		return nil, err
+	}
+	if listener.closed.Load() {
+		_ = windows.CloseHandle(handle)
+
+		return nil, errors.New("the control endpoint was closed")
+	}
+
+	return os.NewFile(uintptr(handle), listener.path), nil
+}
+
+func (listener *_PipeControlListener) Close() error {
+	listener.closed.Store(true)
+
+	// Connect to wake up Accept()
+	if file, err := os.OpenFile(listener.path, os.O_RDWR, 0); nil == err {
+		_ = file.Close()
+	}
+
+	return nil
+}
+
+/*
+getUserIdCONTROL gets the name of the user running VISOR, to have one client control endpoint per user.
+
+-----------------------------------------------------------
+
+– Returns:
+  - the name of the user
+*/
+func getUserIdCONTROL() string {
+	return os.Getenv("USERNAME")
+}
+
+/*
+getControlAddrCONTROL gets the address of a control endpoint: a named pipe.
+
+-----------------------------------------------------------
+
+– Params:
+  - name – the name of the endpoint
+
+– Returns:
+  - the address of the endpoint
+*/
+func getControlAddrCONTROL(name string) string {
+	return `\\.\pipe\` + name
+}
+
+/*
+listenControlCONTROL creates a control endpoint on a named pipe (by default only the user running VISOR and the
+administrators can write to it).
+
+-----------------------------------------------------------
+
+– Params:
+  - addr – the path of the pipe
+
+– Returns:
+  - the endpoint
+  - an error if another VISOR is using the pipe or it couldn't be created, nil otherwise
+*/
+func listenControlCONTROL(addr string) (_ControlListener, error) {
+	handle, err := createControlPipeCONTROL(addr, true)
+	if nil != err {
+		return nil, errors.New("couldn't create " + addr + " (is another VISOR listening on it?): " + err.Error())
+	}
+
+	return &_PipeControlListener{
+		path:        addr,
+		next_handle: handle,
+	}, nil
+}
+
+/*
+createControlPipeCONTROL creates an instance of the control named pipe.
+
+-----------------------------------------------------------
+
+– Params:
+  - path – the path of the pipe
+  - first – true if it's the first instance, which fails if the pipe already exists
+
+– Returns:
+  - the handle of the instance
+  - an error if it couldn't be created, nil otherwise
+*/
+func createControlPipeCONTROL(path string, first bool) (windows.Handle, error) {
+	path_utf16, err := windows.UTF16PtrFromString(path)
+	if nil != err {
+		return 0, err
+	}
+
+	var flags uint32 = windows.PIPE_ACCESS_DUPLEX
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+
+	return windows.CreateNamedPipe(path_utf16, flags, windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE |
+		windows.PIPE_WAIT, windows.PIPE_UNLIMITED_INSTANCES, 64*1024, 64*1024, 0, nil)
+}
+
+/*
+dialControlCONTROL connects to a control endpoint.
+
+-----------------------------------------------------------
+
+– Params:
+  - addr – the path of the pipe
+
+– Returns:
+  - the connection
+  - an error if the connection failed, nil otherwise
+*/
+func dialControlCONTROL(addr string) (io.ReadWriteCloser, error) {
+	return os.OpenFile(addr, os.O_RDWR, 0)
+}
@@ -0,0 +1,5 @@
+module VisorCtl
+
+// Keep it on 1.20, so that it can be compiled for Windows 7 too if it's compiled with Go 1.20 (it's the last version
+// supporting it).
+go 1.20
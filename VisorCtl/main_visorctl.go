@@ -0,0 +1,70 @@
+/*******************************************************************************
+ * Copyright 2023-2024 Edw590
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ ******************************************************************************/
+
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"Utils"
+)
+
+const _USAGE string = "" +
+	"Usage: visorctl [--client] <command> [arguments]\n" +
+	"\n" +
+	"Controls a running V.I.S.O.R. (the server version by default, or the client version with --client).\n" +
+	"\n" +
+	"Commands:\n" +
+	"  list                      lists the modules and plugins with their states\n" +
+	"  start <module>            enables a module until VISOR restarts (also if it was crash-looping)\n" +
+	"  stop <module>             disables a module until VISOR restarts\n" +
+	"  restart <module>          stops a running module so that it's started again\n" +
+	"  enable <module>           enables a module persistently\n" +
+	"  disable <module>          disables a module persistently\n" +
+	"  registry                  dumps the Registry\n" +
+	"  logs [-n N] [-l level] [-f] [module]\n" +
+	"                            shows the last N log entries (20 by default) of at least the given level, of a\n" +
+	"                            module or all, and keeps showing the new ones with -f\n" +
+	"  reload                    reloads " + Utils.USER_SETTINGS_FILE + "\n" +
+	"\n" +
+	"A module is its number, \"MOD_<number>\" or its name. Plugins are referred to by their names.\n" +
+	"The endpoint can be changed with the " + Utils.CONTROL_ADDR_ENV + " environment variable."
+
+func main() {
+	var args []string = os.Args[1:]
+	var server bool = true
+	if len(args) > 0 && "--client" == args[0] {
+		server = false
+		args = args[1:]
+	}
+	if len(args) == 0 || "-h" == args[0] || "--help" == args[0] {
+		fmt.Println(_USAGE)
+
+		return
+	}
+
+	if err := Utils.RunControlCmdCONTROL(server, args[0], args[1:], os.Stdout); nil != err {
+		fmt.Fprintln(os.Stderr, "Error: " + err.Error())
+		os.Exit(1)
+	}
+}